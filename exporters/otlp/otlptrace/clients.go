@@ -17,6 +17,7 @@ package otlptrace // import "go.opentelemetry.io/otel/exporters/otlp/otlptrace"
 import (
 	"context"
 
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
 	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
 )
 
@@ -52,3 +53,24 @@ type Client interface {
 	// DO NOT CHANGE: any modification will not be backwards compatible and
 	// must never be done outside of a new major release.
 }
+
+// ExportInvoker sends req to the collector.
+type ExportInvoker func(ctx context.Context, req *coltracepb.ExportTraceServiceRequest) error
+
+// ExportInterceptor intercepts each export request sent by a client. It
+// completes the export by calling invoker, possibly with a derived context or
+// a modified request, and returns the resulting error or its own.
+type ExportInterceptor func(ctx context.Context, req *coltracepb.ExportTraceServiceRequest, invoker ExportInvoker) error
+
+// PayloadSize is the size of an export request sent by a client.
+type PayloadSize struct {
+	// Spans is the number of spans of the request.
+	Spans int
+	// Bytes is the size of the serialized request, in bytes.
+	Bytes int
+	// Compressed tells if Bytes is the size of the request after
+	// compression. It is false if the request is not compressed, or if it
+	// is compressed by the transport after being measured, as the gRPC
+	// client does.
+	Compressed bool
+}
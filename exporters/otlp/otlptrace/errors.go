@@ -0,0 +1,96 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otlptrace // import "go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+
+import (
+	"errors"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	// ErrDisconnected is the kind of the ExportError returned when the
+	// collector cannot be reached.
+	ErrDisconnected = errors.New("disconnected from the collector")
+	// ErrTimeout is the kind of the ExportError returned when an export
+	// does not complete before its deadline.
+	ErrTimeout = errors.New("export timed out")
+	// ErrRejected is the kind of the ExportError returned when the
+	// collector answers an export with an error.
+	ErrRejected = errors.New("export rejected by the collector")
+)
+
+// ErrUnhealthy is wrapped by the errors returned by the health checks of the
+// clients when the receiving endpoint reports it is not serving.
+var ErrUnhealthy = errors.New("the collector is not serving")
+
+// ExportError describes why an export failed. Its kind, one of
+// ErrDisconnected, ErrTimeout and ErrRejected, is matched by errors.Is, as is
+// the error causing it. Its message is the one of the error causing it.
+type ExportError struct {
+	kind      error
+	err       error
+	retryable bool
+}
+
+// NewExportError returns an ExportError of the given kind caused by err.
+// retryable tells whether attempting the export again could succeed.
+func NewExportError(kind, err error, retryable bool) *ExportError {
+	return &ExportError{kind: kind, err: err, retryable: retryable}
+}
+
+func (e *ExportError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the error causing e.
+func (e *ExportError) Unwrap() error {
+	return e.err
+}
+
+// Is returns true if target is the kind of e.
+func (e *ExportError) Is(target error) bool {
+	return target == e.kind
+}
+
+// Retryable returns true if attempting the export again could succeed, for
+// instance once the collector is reachable again. It does not tell whether
+// the client already retried the export.
+func (e *ExportError) Retryable() bool {
+	return e.retryable
+}
+
+// Temporary is the same as Retryable.
+func (e *ExportError) Temporary() bool {
+	return e.retryable
+}
+
+// Timeout returns true if e is of the ErrTimeout kind. Along with Temporary,
+// it makes ExportError satisfy net.Error so os.IsTimeout keeps working.
+func (e *ExportError) Timeout() bool {
+	return e.kind == ErrTimeout
+}
+
+// GRPCStatus returns the gRPC status of the error causing e, so status.Code
+// and status.FromError keep working on the errors of the gRPC client. It
+// returns an Unknown status if the cause has no gRPC status.
+func (e *ExportError) GRPCStatus() *status.Status {
+	var se interface{ GRPCStatus() *status.Status }
+	if errors.As(e.err, &se) {
+		return se.GRPCStatus()
+	}
+	return status.New(codes.Unknown, e.Error())
+}
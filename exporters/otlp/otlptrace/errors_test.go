@@ -0,0 +1,55 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otlptrace_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+)
+
+func TestExportError(t *testing.T) {
+	cause := status.Error(codes.InvalidArgument, "invalid spans")
+	err := fmt.Errorf("wrapped: %w", otlptrace.NewExportError(otlptrace.ErrRejected, cause, false))
+
+	assert.ErrorIs(t, err, otlptrace.ErrRejected)
+	assert.NotErrorIs(t, err, otlptrace.ErrDisconnected)
+	assert.ErrorIs(t, err, cause)
+	assert.Equal(t, "wrapped: rpc error: code = InvalidArgument desc = invalid spans", err.Error())
+
+	var eErr *otlptrace.ExportError
+	if assert.True(t, errors.As(err, &eErr)) {
+		assert.False(t, eErr.Retryable())
+		assert.False(t, eErr.Temporary())
+		assert.False(t, eErr.Timeout())
+		assert.Equal(t, codes.InvalidArgument, status.Code(eErr))
+	}
+}
+
+func TestExportErrorTimeout(t *testing.T) {
+	err := otlptrace.NewExportError(otlptrace.ErrTimeout, errors.New("deadline exceeded"), true)
+
+	assert.ErrorIs(t, err, otlptrace.ErrTimeout)
+	assert.True(t, err.Retryable())
+	assert.True(t, os.IsTimeout(err))
+	assert.Equal(t, codes.Unknown, status.Code(err))
+}
@@ -17,7 +17,9 @@ package otlptrace // import "go.opentelemetry.io/otel/exporters/otlp/otlptrace"
 import (
 	"context"
 	"errors"
+	"fmt"
 	"sync"
+	"time"
 
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/tracetransform"
 	tracesdk "go.opentelemetry.io/otel/sdk/trace"
@@ -25,6 +27,8 @@ import (
 
 var (
 	errAlreadyStarted = errors.New("already started")
+	errNotStarted     = errors.New("not started")
+	errNoHealthCheck  = errors.New("the client does not implement health checks")
 )
 
 // Exporter exports trace data in the OTLP wire format.
@@ -48,6 +52,167 @@ func (e *Exporter) ExportSpans(ctx context.Context, ss []tracesdk.ReadOnlySpan)
 	return e.client.UploadTraces(ctx, protoSpans)
 }
 
+// ForceFlush sends an empty export request to the receiving endpoint and
+// waits for its acknowledgement. The Exporter does not buffer spans, ExportSpans
+// only returns once they are acknowledged, so this confirms the endpoint is
+// reachable and accepting exports. The clients of the otlptracegrpc and
+// otlptracehttp packages configured to coalesce exports first upload the
+// spans they hold. It returns the error of the request, if
+// any, or the last connection error if the client is disconnected.
+//
+// The client sends the request when it implements a ForceFlush(ctx
+// context.Context) error method, as the clients of the otlptracegrpc and
+// otlptracehttp packages do, whose UploadTraces method does not send empty
+// batches. UploadTraces is called with no span otherwise.
+func (e *Exporter) ForceFlush(ctx context.Context) error {
+	e.mu.RLock()
+	started := e.started
+	e.mu.RUnlock()
+
+	if !started {
+		return errNotStarted
+	}
+	if c, ok := e.client.(interface{ ForceFlush(context.Context) error }); ok {
+		return c.ForceFlush(ctx)
+	}
+	return e.client.UploadTraces(ctx, nil)
+}
+
+// Healthy checks the health of the receiving endpoint without exporting
+// spans, for instance for a readiness probe. It returns nil if the endpoint is
+// serving and an error wrapping ErrUnhealthy if it reports it is not, or the
+// error of the check itself. The gRPC client of the otlptracegrpc package
+// uses the gRPC health checking protocol, the HTTP client of the
+// otlptracehttp package sends a GET request to a health path, by
+// implementing a Healthy(ctx context.Context) error method. An error is
+// returned if the client does not.
+func (e *Exporter) Healthy(ctx context.Context) error {
+	e.mu.RLock()
+	started := e.started
+	e.mu.RUnlock()
+
+	if !started {
+		return errNotStarted
+	}
+	if c, ok := e.client.(interface{ Healthy(context.Context) error }); ok {
+		return c.Healthy(ctx)
+	}
+	return errNoHealthCheck
+}
+
+// LastSuccess returns the time of the last export accepted by the receiving
+// endpoint, or the zero time if there is none. Unlike the connection state,
+// it tells whether spans are actually delivered, for instance to implement a
+// health check. The zero time is also returned if the client does not track
+// successful exports: the clients of the otlptracegrpc and otlptracehttp
+// packages do, by implementing a LastSuccess() time.Time method.
+func (e *Exporter) LastSuccess() time.Time {
+	if c, ok := e.client.(interface{ LastSuccess() time.Time }); ok {
+		return c.LastSuccess()
+	}
+	return time.Time{}
+}
+
+// LastConnectError returns the error that made the client fail to reach the
+// receiving endpoint, or nil if it is reachable, for instance to report why
+// exports fail from a readiness check. The gRPC client of the otlptracegrpc
+// package returns the error that disconnected it, if it is disconnected. As
+// HTTP requests do not share a connection, the HTTP client of the
+// otlptracehttp package returns the error of the last attempted export. Nil
+// is also returned if the client does not implement a LastConnectError()
+// error method.
+func (e *Exporter) LastConnectError() error {
+	if c, ok := e.client.(interface{ LastConnectError() error }); ok {
+		return c.LastConnectError()
+	}
+	return nil
+}
+
+// ConnectionStatus describes the connection of a client to the receiving
+// endpoint.
+type ConnectionStatus struct {
+	// Connected tells whether the client is connected.
+	Connected bool
+	// LastError is the error that disconnected the client, nil if it is
+	// connected.
+	LastError error
+	// ReconnectAttempts is the number of attempts to re-establish the
+	// connection made since it was lost. It is reset once the client
+	// reconnects.
+	ReconnectAttempts int
+}
+
+// ConnectionStatus returns the status of the connection of the client to the
+// receiving endpoint, letting tests of reconnections wait for the client to
+// be disconnected or connected again instead of sleeping. The gRPC client of
+// the otlptracegrpc package reports the state of its connection. The HTTP
+// client of the otlptracehttp package, whose requests do not share a
+// connection, reports the error of the last attempted export and never
+// reconnects. The status is derived from LastConnectError if the client does
+// not implement a ConnectionStatus() ConnectionStatus method.
+func (e *Exporter) ConnectionStatus() ConnectionStatus {
+	if c, ok := e.client.(interface{ ConnectionStatus() ConnectionStatus }); ok {
+		return c.ConnectionStatus()
+	}
+	err := e.LastConnectError()
+	return ConnectionStatus{Connected: err == nil, LastError: err}
+}
+
+// StatsSummary summarizes the exports of a client over a reporting period.
+// The clients of the otlptracegrpc and otlptracehttp packages configured with
+// their WithStatsLogInterval option send one to their error handler at the
+// end of each period, which can tell it from the errors with errors.As.
+type StatsSummary struct {
+	// Period is the duration of the reporting period.
+	Period time.Duration
+	// SentSpans is the number of spans accepted by the receiving
+	// endpoint during the period.
+	SentSpans int64
+	// DroppedSpans is the number of spans dropped during the period,
+	// because their export failed or because they exceed the batch
+	// limits.
+	DroppedSpans int64
+	// Errors is the number of exports that returned an error during the
+	// period.
+	Errors int64
+	// Status is the status of the connection at the end of the period.
+	Status ConnectionStatus
+}
+
+// Error returns a summary line, making the summary reportable to an error
+// handler.
+func (s StatsSummary) Error() string {
+	state := "connected"
+	if !s.Status.Connected {
+		state = "disconnected"
+		if s.Status.LastError != nil {
+			state += ": " + s.Status.LastError.Error()
+		}
+	}
+	return fmt.Sprintf("OTLP trace exporter stats for the last %s: %d spans sent, %d spans dropped, %d export errors, %s",
+		s.Period, s.SentSpans, s.DroppedSpans, s.Errors, state)
+}
+
+// RecentError is an error returned by an export of a client, with the time it
+// was returned.
+type RecentError struct {
+	Time time.Time
+	Err  error
+}
+
+// RecentErrors returns the last errors returned by the exports of the client,
+// oldest first, for instance to debug exports failing while the span
+// processor discards their errors. The clients of the otlptracegrpc and
+// otlptracehttp packages keep the number of errors set with their
+// WithErrorHistory option, none by default, by implementing a RecentErrors()
+// []RecentError method. Nil is returned if the client does not.
+func (e *Exporter) RecentErrors() []RecentError {
+	if c, ok := e.client.(interface{ RecentErrors() []RecentError }); ok {
+		return c.RecentErrors()
+	}
+	return nil
+}
+
 // Start establishes a connection to the receiving endpoint.
 func (e *Exporter) Start(ctx context.Context) error {
 	var err = errAlreadyStarted
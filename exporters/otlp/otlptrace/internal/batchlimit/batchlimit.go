@@ -0,0 +1,237 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package batchlimit enforces limits on the size of export requests.
+package batchlimit // import "go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/batchlimit"
+
+import (
+	"errors"
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+// ErrOversize is returned instead of exporting a batch of spans exceeding
+// the limits with the Reject policy.
+var ErrOversize = errors.New("batch of spans exceeds the export limits")
+
+// Policy is the handling of the batches of spans exceeding the limits.
+type Policy int
+
+const (
+	// Split exports the batch in several requests within the limits. Spans
+	// exceeding the maximum size on their own are dropped.
+	Split Policy = iota
+	// Reject fails the export of the batch with ErrOversize.
+	Reject
+	// Drop exports the first spans of the batch within the limits and
+	// drops the others.
+	Drop
+)
+
+// Config defines the limits of the export requests.
+type Config struct {
+	// MaxBytes, if positive, is the maximum encoded size of an export
+	// request.
+	MaxBytes int
+	// MaxSpans, if positive, is the maximum number of spans of an export
+	// request.
+	MaxSpans int
+	// Policy is the handling of the batches exceeding the limits.
+	Policy Policy
+}
+
+// Batches returns the batches of rss to export in separate requests within
+// the limits of c, according to its policy, and the number of spans dropped.
+// The spans keep their order, resource and instrumentation library. An error
+// wrapping ErrOversize is returned if rss exceeds the limits with the Reject
+// policy.
+func (c Config) Batches(rss []*tracepb.ResourceSpans) ([][]*tracepb.ResourceSpans, int, error) {
+	spans, size := c.count(rss)
+	if (c.MaxSpans <= 0 || spans <= c.MaxSpans) && (c.MaxBytes <= 0 || size <= c.MaxBytes) {
+		return [][]*tracepb.ResourceSpans{rss}, 0, nil
+	}
+
+	switch c.Policy {
+	case Reject:
+		return nil, 0, fmt.Errorf("%w: %d spans of %d bytes, limits are %s", ErrOversize, spans, size, c.limits())
+	case Drop:
+		batches, dropped := c.split(rss)
+		if len(batches) == 0 {
+			return nil, dropped, nil
+		}
+		return batches[:1], spans - c.spanCount(batches[0]), nil
+	}
+	batches, dropped := c.split(rss)
+	return batches, dropped, nil
+}
+
+// count returns the number of spans of rss and, if c limits it, the encoded
+// size of their export request.
+func (c Config) count(rss []*tracepb.ResourceSpans) (spans, size int) {
+	spans = c.spanCount(rss)
+	if c.MaxBytes > 0 {
+		for _, rs := range rss {
+			size += fieldSize(proto.Size(rs))
+		}
+	}
+	return spans, size
+}
+
+func (Config) spanCount(rss []*tracepb.ResourceSpans) int {
+	var n int
+	for _, rs := range rss {
+		for _, ils := range rs.InstrumentationLibrarySpans {
+			n += len(ils.Spans)
+		}
+	}
+	return n
+}
+
+// limits describes the limits of c.
+func (c Config) limits() string {
+	switch {
+	case c.MaxBytes > 0 && c.MaxSpans > 0:
+		return fmt.Sprintf("%d spans and %d bytes", c.MaxSpans, c.MaxBytes)
+	case c.MaxSpans > 0:
+		return fmt.Sprintf("%d spans", c.MaxSpans)
+	}
+	return fmt.Sprintf("%d bytes", c.MaxBytes)
+}
+
+// fieldSize returns the encoded size of a length-delimited field numbered
+// below 16 holding n bytes.
+func fieldSize(n int) int {
+	return 1 + protowire.SizeVarint(uint64(n)) + n
+}
+
+// splitter splits ResourceSpans in batches whose export request is not
+// larger than maxBytes bytes nor holds more than maxSpans spans.
+type splitter struct {
+	maxBytes int
+	maxSpans int
+	batches  [][]*tracepb.ResourceSpans
+
+	// batch is the batch being filled, its encoded size is the size of its
+	// closed ResourceSpans, the ones before the last, plus the size of rs.
+	// spans is its number of spans.
+	batch []*tracepb.ResourceSpans
+	size  int
+	spans int
+
+	// rs is the last ResourceSpans of batch, copied from srcRS. rsSize is
+	// the size of its content without its last InstrumentationLibrarySpans.
+	rs     *tracepb.ResourceSpans
+	srcRS  *tracepb.ResourceSpans
+	rsSize int
+
+	// ils is the last InstrumentationLibrarySpans of rs, copied from
+	// srcILS. ilsSize is the size of its content.
+	ils     *tracepb.InstrumentationLibrarySpans
+	srcILS  *tracepb.InstrumentationLibrarySpans
+	ilsSize int
+}
+
+// split splits rss in batches within the limits of c. The spans keep their
+// order, resource and instrumentation library. Spans larger than the maximum
+// size on their own are dropped, their number is returned.
+func (c Config) split(rss []*tracepb.ResourceSpans) ([][]*tracepb.ResourceSpans, int) {
+	s := &splitter{maxBytes: c.MaxBytes, maxSpans: c.MaxSpans}
+	var dropped int
+	for _, rs := range rss {
+		for _, ils := range rs.InstrumentationLibrarySpans {
+			for _, span := range ils.Spans {
+				spanSize := fieldSize(proto.Size(span))
+				if s.add(rs, ils, span, spanSize) {
+					continue
+				}
+				if len(s.batch) > 0 {
+					s.flush()
+					if s.add(rs, ils, span, spanSize) {
+						continue
+					}
+				}
+				dropped++
+			}
+		}
+	}
+	s.flush()
+	return s.batches, dropped
+}
+
+// add adds span, of ils from rs, to the current batch if the batch does not
+// exceed the limits with it, and returns whether it was added.
+func (s *splitter) add(rs *tracepb.ResourceSpans, ils *tracepb.InstrumentationLibrarySpans, span *tracepb.Span, spanSize int) bool {
+	if s.maxSpans > 0 && s.spans >= s.maxSpans {
+		return false
+	}
+	size, rsSize, ilsSize := s.size, s.rsSize, s.ilsSize
+	newRS := s.rs == nil || s.srcRS != rs
+	newILS := newRS || s.srcILS != ils
+	if newRS {
+		if s.rs != nil {
+			size += fieldSize(rsSize + fieldSize(ilsSize))
+		}
+		rsSize = proto.Size(&tracepb.ResourceSpans{
+			Resource:  rs.Resource,
+			SchemaUrl: rs.SchemaUrl,
+		})
+	} else if newILS {
+		rsSize += fieldSize(ilsSize)
+	}
+	if newILS {
+		ilsSize = proto.Size(&tracepb.InstrumentationLibrarySpans{
+			InstrumentationLibrary: ils.InstrumentationLibrary,
+			SchemaUrl:              ils.SchemaUrl,
+		})
+	}
+	ilsSize += spanSize
+	if s.maxBytes > 0 && size+fieldSize(rsSize+fieldSize(ilsSize)) > s.maxBytes {
+		return false
+	}
+
+	if newRS {
+		s.srcRS = rs
+		s.rs = &tracepb.ResourceSpans{
+			Resource:  rs.Resource,
+			SchemaUrl: rs.SchemaUrl,
+		}
+		s.batch = append(s.batch, s.rs)
+	}
+	if newILS {
+		s.srcILS = ils
+		s.ils = &tracepb.InstrumentationLibrarySpans{
+			InstrumentationLibrary: ils.InstrumentationLibrary,
+			SchemaUrl:              ils.SchemaUrl,
+		}
+		s.rs.InstrumentationLibrarySpans = append(s.rs.InstrumentationLibrarySpans, s.ils)
+	}
+	s.ils.Spans = append(s.ils.Spans, span)
+	s.size, s.rsSize, s.ilsSize = size, rsSize, ilsSize
+	s.spans++
+	return true
+}
+
+// flush ends the current batch, if not empty.
+func (s *splitter) flush() {
+	if len(s.batch) > 0 {
+		s.batches = append(s.batches, s.batch)
+	}
+	s.batch, s.size, s.spans = nil, 0, 0
+	s.rs, s.srcRS, s.rsSize = nil, nil, 0
+	s.ils, s.srcILS, s.ilsSize = nil, nil, 0
+}
@@ -0,0 +1,181 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batchlimit
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+type flatSpan struct {
+	rs   *resourcepb.Resource
+	lib  *commonpb.InstrumentationLibrary
+	span *tracepb.Span
+}
+
+func flatten(rss []*tracepb.ResourceSpans) []flatSpan {
+	var spans []flatSpan
+	for _, rs := range rss {
+		for _, ils := range rs.InstrumentationLibrarySpans {
+			for _, span := range ils.Spans {
+				spans = append(spans, flatSpan{rs.Resource, ils.InstrumentationLibrary, span})
+			}
+		}
+	}
+	return spans
+}
+
+func testResourceSpans(nameSize func(i int) int) []*tracepb.ResourceSpans {
+	var rss []*tracepb.ResourceSpans
+	var i int
+	for _, svc := range []string{"a", "b"} {
+		rs := &tracepb.ResourceSpans{
+			Resource: &resourcepb.Resource{Attributes: []*commonpb.KeyValue{{
+				Key:   "service.name",
+				Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: svc}},
+			}}},
+			SchemaUrl: "https://opentelemetry.io/schemas/1.7.0",
+		}
+		for _, lib := range []string{"lib1", "lib2"} {
+			ils := &tracepb.InstrumentationLibrarySpans{
+				InstrumentationLibrary: &commonpb.InstrumentationLibrary{Name: lib},
+			}
+			for j := 0; j < 5; j++ {
+				ils.Spans = append(ils.Spans, &tracepb.Span{Name: strings.Repeat("x", nameSize(i))})
+				i++
+			}
+			rs.InstrumentationLibrarySpans = append(rs.InstrumentationLibrarySpans, ils)
+		}
+		rss = append(rss, rs)
+	}
+	return rss
+}
+
+func TestSplitResourceSpans(t *testing.T) {
+	rss := testResourceSpans(func(i int) int { return 10 + 37*i%200 })
+	total := proto.Size(&coltracepb.ExportTraceServiceRequest{ResourceSpans: rss})
+
+	for _, max := range []int{300, 500, 1000, total - 1, total} {
+		batches, dropped := Config{MaxBytes: max}.split(rss)
+		assert.Zero(t, dropped, "max %d", max)
+
+		var got []*tracepb.ResourceSpans
+		for _, batch := range batches {
+			size := proto.Size(&coltracepb.ExportTraceServiceRequest{ResourceSpans: batch})
+			assert.LessOrEqual(t, size, max)
+			got = append(got, batch...)
+		}
+		assert.Equal(t, flatten(rss), flatten(got), "max %d", max)
+		if max == total {
+			assert.Len(t, batches, 1)
+		} else {
+			assert.Greater(t, len(batches), 1, "max %d", max)
+		}
+	}
+}
+
+func TestSplitResourceSpansDropsLargeSpans(t *testing.T) {
+	rss := testResourceSpans(func(i int) int {
+		if i%7 == 3 {
+			return 1000
+		}
+		return 10
+	})
+
+	batches, dropped := Config{MaxBytes: 500}.split(rss)
+	assert.Equal(t, 3, dropped)
+
+	var got []*tracepb.ResourceSpans
+	for _, batch := range batches {
+		got = append(got, batch...)
+	}
+	var want []flatSpan
+	for _, s := range flatten(rss) {
+		if len(s.span.Name) == 10 {
+			want = append(want, s)
+		}
+	}
+	assert.Equal(t, want, flatten(got))
+}
+
+func TestSplitResourceSpansMaxSpans(t *testing.T) {
+	rss := testResourceSpans(func(int) int { return 10 })
+
+	batches, dropped := Config{MaxSpans: 3}.split(rss)
+	assert.Zero(t, dropped)
+	require.Len(t, batches, 7)
+
+	var got []*tracepb.ResourceSpans
+	for i, batch := range batches {
+		want := 3
+		if i == len(batches)-1 {
+			want = 2
+		}
+		assert.Len(t, flatten(batch), want)
+		got = append(got, batch...)
+	}
+	assert.Equal(t, flatten(rss), flatten(got))
+}
+
+func TestBatches(t *testing.T) {
+	rss := testResourceSpans(func(int) int { return 10 })
+	total := proto.Size(&coltracepb.ExportTraceServiceRequest{ResourceSpans: rss})
+
+	t.Run("within the limits", func(t *testing.T) {
+		for _, policy := range []Policy{Split, Reject, Drop} {
+			for _, c := range []Config{{}, {MaxSpans: 20}, {MaxBytes: total}, {MaxSpans: 20, MaxBytes: total}} {
+				c.Policy = policy
+				batches, dropped, err := c.Batches(rss)
+				assert.NoError(t, err)
+				assert.Zero(t, dropped)
+				assert.Equal(t, [][]*tracepb.ResourceSpans{rss}, batches)
+			}
+		}
+	})
+
+	t.Run("split", func(t *testing.T) {
+		batches, dropped, err := Config{MaxSpans: 8, MaxBytes: total, Policy: Split}.Batches(rss)
+		assert.NoError(t, err)
+		assert.Zero(t, dropped)
+		assert.Len(t, batches, 3)
+	})
+
+	t.Run("reject", func(t *testing.T) {
+		for _, c := range []Config{{MaxSpans: 19}, {MaxBytes: total - 1}} {
+			c.Policy = Reject
+			batches, dropped, err := c.Batches(rss)
+			assert.ErrorIs(t, err, ErrOversize)
+			assert.Zero(t, dropped)
+			assert.Nil(t, batches)
+		}
+	})
+
+	t.Run("drop", func(t *testing.T) {
+		batches, dropped, err := Config{MaxSpans: 8, Policy: Drop}.Batches(rss)
+		assert.NoError(t, err)
+		assert.Equal(t, 12, dropped)
+		require.Len(t, batches, 1)
+		assert.Equal(t, flatten(rss)[:8], flatten(batches[0]))
+	})
+}
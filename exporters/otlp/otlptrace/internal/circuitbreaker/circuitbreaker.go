@@ -0,0 +1,172 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package circuitbreaker stops exports to a collector that keeps failing.
+package circuitbreaker // import "go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/circuitbreaker"
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+)
+
+// ErrOpen is returned instead of attempting an export while the circuit
+// breaker is open.
+var ErrOpen = errors.New("circuit breaker is open: the collector keeps failing")
+
+// Config defines configuration for failing exports fast after the collector
+// failed repeatedly.
+type Config struct {
+	// Enabled indicates whether to use a circuit breaker.
+	Enabled bool
+	// FailureThreshold is the number of consecutive failed exports after
+	// which the circuit breaker opens.
+	FailureThreshold int
+	// Cooldown is the time the circuit breaker stays open before letting a
+	// single export probe the collector.
+	Cooldown time.Duration
+}
+
+type state int
+
+const (
+	closed state = iota
+	open
+	halfOpen
+)
+
+// Breaker tracks the outcome of exports and rejects them while open. A nil
+// Breaker admits all exports.
+type Breaker struct {
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu       sync.Mutex
+	state    state
+	failures int
+	openedAt time.Time
+}
+
+// New returns a Breaker configured with cfg, or nil if cfg is not enabled.
+func New(cfg Config) *Breaker {
+	if !cfg.Enabled {
+		return nil
+	}
+	return &Breaker{
+		threshold: cfg.FailureThreshold,
+		cooldown:  cfg.Cooldown,
+		now:       time.Now,
+	}
+}
+
+// Allow returns ErrOpen if an export with ctx must not be attempted.
+// Otherwise, it returns a function that must be called with the result of the
+// export. Only the retryable export errors, such as an unreachable or
+// unavailable collector, are failures: the other errors, and the results of
+// the exports whose ctx is done, do not tell whether the collector is healthy
+// and are ignored.
+//
+// Once the cooldown of an open Breaker has elapsed, a single export is
+// allowed to probe the collector: the Breaker closes if it succeeds and opens
+// again if it fails. Other exports are rejected in the meantime. The next
+// export probes the collector again if the result of the probe is ignored.
+func (b *Breaker) Allow(ctx context.Context) (func(error), error) {
+	if b == nil {
+		return func(error) {}, nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case open:
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			return nil, ErrOpen
+		}
+		b.state = halfOpen
+		return func(err error) { b.probeDone(ctx, err) }, nil
+	case halfOpen:
+		return nil, ErrOpen
+	}
+	return func(err error) { b.done(ctx, err) }, nil
+}
+
+// failure returns whether the export with ctx failing with err shows that the
+// collector is failing, or ok false if the result is ignored.
+func failure(ctx context.Context, err error) (failed, ok bool) {
+	if err == nil {
+		return false, true
+	}
+	if ctx.Err() != nil {
+		return false, false
+	}
+	var eErr *otlptrace.ExportError
+	if errors.As(err, &eErr) && eErr.Retryable() {
+		return true, true
+	}
+	return false, false
+}
+
+// done records the result of an export allowed while the Breaker was closed.
+func (b *Breaker) done(ctx context.Context, err error) {
+	failed, ok := failure(ctx, err)
+	if !ok {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state != closed {
+		// The Breaker opened while the export was in progress, only the
+		// probe can change its state.
+		return
+	}
+	if !failed {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= b.threshold {
+		b.trip()
+	}
+}
+
+// probeDone records the result of the export probing the collector.
+func (b *Breaker) probeDone(ctx context.Context, err error) {
+	failed, ok := failure(ctx, err)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch {
+	case !ok:
+		// The cooldown has elapsed, the next export probes again.
+		b.state = open
+	case failed:
+		b.trip()
+	default:
+		b.state = closed
+		b.failures = 0
+	}
+}
+
+func (b *Breaker) trip() {
+	b.state = open
+	b.openedAt = b.now()
+}
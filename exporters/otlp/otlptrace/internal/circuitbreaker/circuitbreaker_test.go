@@ -0,0 +1,139 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package circuitbreaker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+)
+
+var (
+	errExport   = otlptrace.NewExportError(otlptrace.ErrDisconnected, errors.New("export failed"), true)
+	errRejected = otlptrace.NewExportError(otlptrace.ErrRejected, errors.New("invalid argument"), false)
+)
+
+func newTestBreaker(threshold int, cooldown time.Duration) (*Breaker, *time.Time) {
+	now := time.Unix(0, 0)
+	b := New(Config{Enabled: true, FailureThreshold: threshold, Cooldown: cooldown})
+	b.now = func() time.Time { return now }
+	return b, &now
+}
+
+func export(t *testing.T, b *Breaker, err error) {
+	t.Helper()
+	done, aErr := b.Allow(context.Background())
+	require.NoError(t, aErr)
+	done(err)
+}
+
+func TestDisabled(t *testing.T) {
+	b := New(Config{FailureThreshold: 1, Cooldown: time.Hour})
+	assert.Nil(t, b)
+	for i := 0; i < 3; i++ {
+		export(t, b, errExport)
+	}
+}
+
+func TestOpensAfterConsecutiveFailures(t *testing.T) {
+	b, _ := newTestBreaker(3, time.Minute)
+
+	export(t, b, errExport)
+	export(t, b, errExport)
+	// A success resets the count of consecutive failures.
+	export(t, b, nil)
+	export(t, b, errExport)
+	export(t, b, errExport)
+	export(t, b, errExport)
+
+	_, err := b.Allow(context.Background())
+	assert.ErrorIs(t, err, ErrOpen)
+}
+
+func TestHalfOpen(t *testing.T) {
+	b, now := newTestBreaker(1, time.Minute)
+	export(t, b, errExport)
+
+	*now = now.Add(30 * time.Second)
+	_, err := b.Allow(context.Background())
+	assert.ErrorIs(t, err, ErrOpen, "cooldown not elapsed")
+
+	*now = now.Add(30 * time.Second)
+	probe, err := b.Allow(context.Background())
+	require.NoError(t, err)
+	_, err = b.Allow(context.Background())
+	assert.ErrorIs(t, err, ErrOpen, "probe in progress")
+
+	probe(errExport)
+	_, err = b.Allow(context.Background())
+	assert.ErrorIs(t, err, ErrOpen, "failed probe reopens")
+
+	*now = now.Add(time.Minute)
+	probe, err = b.Allow(context.Background())
+	require.NoError(t, err)
+	probe(nil)
+	export(t, b, nil)
+	export(t, b, nil)
+}
+
+func TestInFlightResultIgnoredWhileOpen(t *testing.T) {
+	b, now := newTestBreaker(1, time.Minute)
+	inFlight, err := b.Allow(context.Background())
+	require.NoError(t, err)
+	export(t, b, errExport)
+
+	inFlight(nil)
+	_, err = b.Allow(context.Background())
+	assert.ErrorIs(t, err, ErrOpen)
+
+	*now = now.Add(time.Minute)
+	probe, err := b.Allow(context.Background())
+	require.NoError(t, err)
+	inFlight(errExport)
+	probe(nil)
+	export(t, b, nil)
+}
+
+func TestIgnoredErrors(t *testing.T) {
+	b, now := newTestBreaker(1, time.Minute)
+
+	// A rejected export does not tell the collector is failing.
+	export(t, b, errRejected)
+	export(t, b, errors.New("marshal failed"))
+	// Neither does an export whose context is done.
+	ctx, cancel := context.WithCancel(context.Background())
+	done, err := b.Allow(ctx)
+	require.NoError(t, err)
+	cancel()
+	done(otlptrace.NewExportError(otlptrace.ErrTimeout, context.Canceled, true))
+	export(t, b, nil)
+
+	export(t, b, errExport)
+	*now = now.Add(time.Minute)
+	probe, err := b.Allow(context.Background())
+	require.NoError(t, err)
+	// The next export probes again.
+	probe(errRejected)
+	probe, err = b.Allow(context.Background())
+	require.NoError(t, err)
+	probe(nil)
+	export(t, b, nil)
+}
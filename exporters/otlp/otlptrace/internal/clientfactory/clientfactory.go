@@ -0,0 +1,43 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package clientfactory registers the functions creating the clients of each
+// protocol, called by otlptrace.NewFromEnv.
+package clientfactory // import "go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/clientfactory"
+
+import "sync"
+
+var (
+	mu        sync.RWMutex
+	factories = make(map[string]func() interface{})
+)
+
+// Register registers newClient as the function creating the clients of
+// protocol, replacing the one already registered, if any. newClient returns
+// an otlptrace.Client, which this package cannot refer to without an import
+// cycle.
+func Register(protocol string, newClient func() interface{}) {
+	mu.Lock()
+	defer mu.Unlock()
+	factories[protocol] = newClient
+}
+
+// Lookup returns the function registered to create the clients of protocol,
+// if any.
+func Lookup(protocol string) (func() interface{}, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	newClient, ok := factories[protocol]
+	return newClient, ok
+}
@@ -0,0 +1,216 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package coalesce merges the exports arriving within a short window into a
+// single upload.
+package coalesce // import "go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/coalesce"
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+// Config defines configuration for coalescing exports.
+type Config struct {
+	// MaxDelay, if positive, is the maximum time the spans of an export
+	// are held waiting for other exports to be uploaded with.
+	MaxDelay time.Duration
+	// MaxSpans, if positive, is the number of spans the held exports are
+	// uploaded at, without waiting for MaxDelay.
+	MaxSpans int
+}
+
+// UploadFunc uploads spans.
+type UploadFunc func(ctx context.Context, protoSpans []*tracepb.ResourceSpans) error
+
+// Coalescer merges the spans of the exports arriving within the maximum delay
+// into a single upload. The exports wait for the upload of their spans and
+// return its result.
+type Coalescer struct {
+	cfg    Config
+	upload UploadFunc
+	direct func(context.Context) bool
+
+	mu      sync.Mutex
+	pending *batch
+	stopped bool
+	// sending tracks the uploads in progress.
+	sending sync.WaitGroup
+}
+
+// batch is the spans of the exports uploaded together.
+type batch struct {
+	spans []*tracepb.ResourceSpans
+	n     int
+	// deadline is the earliest deadline of the exports, if any.
+	deadline time.Time
+	timer    *time.Timer
+
+	done chan struct{}
+	err  error
+}
+
+// New returns a Coalescer uploading the merged spans with upload, or nil if
+// cfg has no maximum delay. The exports whose context direct, if not nil,
+// returns true for are uploaded on their own, with their context, as the
+// merged spans are uploaded with a context that carries none of the values
+// of the contexts of the exports.
+func New(cfg Config, upload UploadFunc, direct func(context.Context) bool) *Coalescer {
+	if cfg.MaxDelay <= 0 {
+		return nil
+	}
+	return &Coalescer{cfg: cfg, upload: upload, direct: direct}
+}
+
+// Upload adds protoSpans to the spans held, which are uploaded once the
+// maximum delay elapsed since the first of them was added, or once they reach
+// the maximum number of spans. It returns the result of that upload, or the
+// error of ctx if it is done first. The upload is bounded by the earliest
+// deadline of the contexts of the exports it merges, it is not cancelled with
+// them. Empty exports flush the spans held before being uploaded on their
+// own, as are all the exports once c is stopped and the exports uploaded
+// directly.
+func (c *Coalescer) Upload(ctx context.Context, protoSpans []*tracepb.ResourceSpans) error {
+	n := spanCount(protoSpans)
+	if n == 0 {
+		if err := c.Flush(ctx); err != nil {
+			return err
+		}
+		return c.upload(ctx, protoSpans)
+	}
+	if c.direct != nil && c.direct(ctx) {
+		return c.upload(ctx, protoSpans)
+	}
+
+	c.mu.Lock()
+	if c.stopped {
+		c.mu.Unlock()
+		return c.upload(ctx, protoSpans)
+	}
+	b := c.pending
+	if b != nil && c.cfg.MaxSpans > 0 && b.n+n > c.cfg.MaxSpans {
+		// The spans held are uploaded first to keep within the maximum.
+		c.sendLocked(b)
+		b = nil
+	}
+	if b == nil {
+		b = &batch{done: make(chan struct{})}
+		b.timer = time.AfterFunc(c.cfg.MaxDelay, func() { c.expire(b) })
+		c.pending = b
+	}
+	b.spans = append(b.spans, protoSpans...)
+	b.n += n
+	if d, ok := ctx.Deadline(); ok && (b.deadline.IsZero() || d.Before(b.deadline)) {
+		b.deadline = d
+	}
+	if c.cfg.MaxSpans > 0 && b.n >= c.cfg.MaxSpans {
+		c.sendLocked(b)
+	}
+	c.mu.Unlock()
+
+	return b.wait(ctx)
+}
+
+// Flush uploads the spans held, if any, and returns the result of the
+// upload, or the error of ctx if it is done first.
+func (c *Coalescer) Flush(ctx context.Context) error {
+	c.mu.Lock()
+	b := c.pending
+	if b != nil {
+		c.sendLocked(b)
+	}
+	c.mu.Unlock()
+
+	if b == nil {
+		return nil
+	}
+	return b.wait(ctx)
+}
+
+// Stop uploads the spans held, if any, and waits for the uploads in progress
+// to complete, or for ctx to be done. The exports are no longer merged once c
+// is stopped.
+func (c *Coalescer) Stop(ctx context.Context) error {
+	c.mu.Lock()
+	c.stopped = true
+	if c.pending != nil {
+		c.sendLocked(c.pending)
+	}
+	c.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		c.sending.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// expire uploads b once the maximum delay elapsed, unless it is already
+// uploaded.
+func (c *Coalescer) expire(b *batch) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.pending == b {
+		c.sendLocked(b)
+	}
+}
+
+// sendLocked uploads b, the pending batch, in the background. It is called
+// with c.mu held.
+func (c *Coalescer) sendLocked(b *batch) {
+	b.timer.Stop()
+	c.pending = nil
+	c.sending.Add(1)
+	go func() {
+		defer c.sending.Done()
+		ctx := context.Background()
+		if !b.deadline.IsZero() {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithDeadline(ctx, b.deadline)
+			defer cancel()
+		}
+		b.err = c.upload(ctx, b.spans)
+		close(b.done)
+	}()
+}
+
+// wait returns the result of the upload of b, or the error of ctx if it is
+// done first.
+func (b *batch) wait(ctx context.Context) error {
+	select {
+	case <-b.done:
+		return b.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func spanCount(protoSpans []*tracepb.ResourceSpans) int {
+	var n int
+	for _, rs := range protoSpans {
+		for _, ils := range rs.InstrumentationLibrarySpans {
+			n += len(ils.Spans)
+		}
+	}
+	return n
+}
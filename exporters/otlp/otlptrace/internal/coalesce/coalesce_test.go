@@ -0,0 +1,191 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package coalesce
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+// recorder records the spans uploaded, one slice per upload.
+type recorder struct {
+	mu      sync.Mutex
+	uploads [][]*tracepb.ResourceSpans
+	err     error
+}
+
+func (r *recorder) upload(_ context.Context, protoSpans []*tracepb.ResourceSpans) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.uploads = append(r.uploads, protoSpans)
+	return r.err
+}
+
+func (r *recorder) spanCounts() []int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	counts := make([]int, len(r.uploads))
+	for i, u := range r.uploads {
+		counts[i] = spanCount(u)
+	}
+	return counts
+}
+
+func spans(n int) []*tracepb.ResourceSpans {
+	s := make([]*tracepb.Span, n)
+	for i := range s {
+		s[i] = &tracepb.Span{Name: "span"}
+	}
+	return []*tracepb.ResourceSpans{{
+		InstrumentationLibrarySpans: []*tracepb.InstrumentationLibrarySpans{{Spans: s}},
+	}}
+}
+
+func TestNewDisabled(t *testing.T) {
+	assert.Nil(t, New(Config{MaxSpans: 10}, (&recorder{}).upload, nil))
+}
+
+func TestUploadMergesWithinMaxDelay(t *testing.T) {
+	r := &recorder{}
+	c := New(Config{MaxDelay: 50 * time.Millisecond}, r.upload, nil)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, c.Upload(context.Background(), spans(1)))
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, []int{3}, r.spanCounts())
+}
+
+func TestUploadMaxSpans(t *testing.T) {
+	r := &recorder{}
+	c := New(Config{MaxDelay: time.Hour, MaxSpans: 2}, r.upload, nil)
+
+	// The spans are uploaded once they reach the maximum.
+	require.NoError(t, c.Upload(context.Background(), spans(2)))
+	assert.Equal(t, []int{2}, r.spanCounts())
+
+	// The spans held are uploaded first when the maximum would be
+	// exceeded.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, c.Upload(ctx, spans(1)), context.Canceled)
+	require.NoError(t, c.Upload(context.Background(), spans(3)))
+	require.NoError(t, c.Stop(context.Background()))
+	// The uploads of the two batches are concurrent.
+	assert.ElementsMatch(t, []int{2, 1, 3}, r.spanCounts())
+}
+
+func TestUploadError(t *testing.T) {
+	r := &recorder{err: errors.New("rejected")}
+	c := New(Config{MaxDelay: time.Millisecond}, r.upload, nil)
+	assert.EqualError(t, c.Upload(context.Background(), spans(1)), "rejected")
+}
+
+func TestUploadDeadline(t *testing.T) {
+	var deadline time.Time
+	c := New(Config{MaxDelay: 10 * time.Millisecond}, func(ctx context.Context, _ []*tracepb.ResourceSpans) error {
+		deadline, _ = ctx.Deadline()
+		return nil
+	}, nil)
+
+	want := time.Now().Add(time.Minute)
+	ctx, cancel := context.WithDeadline(context.Background(), want)
+	defer cancel()
+	require.NoError(t, c.Upload(ctx, spans(1)))
+	assert.Equal(t, want, deadline)
+}
+
+func TestUploadDirect(t *testing.T) {
+	type key struct{}
+	var values []interface{}
+	c := New(Config{MaxDelay: time.Hour}, func(ctx context.Context, _ []*tracepb.ResourceSpans) error {
+		values = append(values, ctx.Value(key{}))
+		return nil
+	}, func(ctx context.Context) bool {
+		return ctx.Value(key{}) != nil
+	})
+
+	// The export is uploaded with its context, the spans held are not.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, c.Upload(ctx, spans(1)), context.Canceled)
+	require.NoError(t, c.Upload(context.WithValue(context.Background(), key{}, "value"), spans(1)))
+	assert.Equal(t, []interface{}{"value"}, values)
+	require.NoError(t, c.Stop(context.Background()))
+	assert.Equal(t, []interface{}{"value", nil}, values)
+}
+
+func TestUploadEmptyFlushes(t *testing.T) {
+	r := &recorder{}
+	c := New(Config{MaxDelay: time.Hour}, r.upload, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, c.Upload(ctx, spans(2)), context.Canceled)
+	require.NoError(t, c.Upload(context.Background(), nil))
+	assert.Equal(t, []int{2, 0}, r.spanCounts())
+}
+
+func TestStopFlushes(t *testing.T) {
+	r := &recorder{}
+	c := New(Config{MaxDelay: time.Hour}, r.upload, nil)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- c.Upload(context.Background(), spans(2))
+	}()
+	require.Eventually(t, func() bool {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.pending != nil
+	}, time.Second, time.Millisecond)
+
+	require.NoError(t, c.Stop(context.Background()))
+	assert.NoError(t, <-errCh)
+	assert.Equal(t, []int{2}, r.spanCounts())
+
+	// The exports are no longer merged once stopped.
+	require.NoError(t, c.Upload(context.Background(), spans(1)))
+	assert.Equal(t, []int{2, 1}, r.spanCounts())
+}
+
+func TestStopContextDone(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	c := New(Config{MaxDelay: time.Hour}, func(context.Context, []*tracepb.ResourceSpans) error {
+		<-release
+		return nil
+	}, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, c.Upload(ctx, spans(1)), context.Canceled)
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, c.Stop(ctx), context.DeadlineExceeded)
+}
@@ -25,6 +25,10 @@ import (
 // Ensure struct alignment prior to running tests.
 func TestMain(m *testing.M) {
 	fields := []ottest.FieldOffset{
+		{
+			Name:   "Connection.reconnectAttempts",
+			Offset: unsafe.Offsetof(Connection{}.reconnectAttempts),
+		},
 		{
 			Name:   "Connection.lastConnectErrPtr",
 			Offset: unsafe.Offsetof(Connection{}.lastConnectErrPtr),
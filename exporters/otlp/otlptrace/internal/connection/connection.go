@@ -16,7 +16,11 @@ package connection // import "go.opentelemetry.io/otel/exporters/otlp/otlptrace/
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"math/rand"
+	"net"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -24,23 +28,59 @@ import (
 
 	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
 	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/otlpconfig"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/retry"
 )
 
 type Connection struct {
-	// Ensure pointer is 64-bit aligned for atomic operations on both 32 and 64 bit machines.
+	// Ensure the 64-bit fields are aligned for atomic operations on both 32
+	// and 64 bit machines.
+
+	// reconnectAttempts is the number of attempts to re-establish the
+	// Connection since it was lost.
+	reconnectAttempts int64
 	lastConnectErrPtr unsafe.Pointer
 
 	// mu protects the Connection as it is accessed by the
 	// exporter goroutines and background Connection goroutine
 	mu sync.Mutex
 	cc *grpc.ClientConn
+	// endpointIdx is the index in cfg.Endpoints of the endpoint dialed.
+	endpointIdx int
+	// fileEndpoint is the last endpoint read from cfg.EndpointFile, if
+	// any, dialed instead of the configured ones.
+	fileEndpoint string
+	// endpointFileErr is the message of the last error reading the
+	// endpoint file, only accessed by the goroutine reading it.
+	endpointFileErr string
+
+	// stateMu protects state, the last state reported to
+	// stateCallback
+	stateMu       sync.Mutex
+	state         otlpconfig.ConnectionState
+	stateCallback func(old, new otlpconfig.ConnectionState)
+
+	// lossMu protects lostAt and lostAttempts, the time the Connection
+	// was lost and the attempts to re-establish it since an export last
+	// succeeded, and failedErr, the error the Connection gave up
+	// re-establishing it with.
+	lossMu       sync.Mutex
+	lostAt       time.Time
+	lostAttempts int
+	failedErr    error
+
+	// connectOnExport is set by StartConnection if the Connection is
+	// re-established by EnsureConnected instead of in the background.
+	connectOnExport bool
 
 	// these fields are read-only after constructor is finished
 	cfg                  otlpconfig.Config
@@ -48,11 +88,15 @@ type Connection struct {
 	requestFunc          retry.RequestFunc
 	metadata             metadata.MD
 	newConnectionHandler func(cc *grpc.ClientConn)
+	errHandler           otel.ErrorHandler
 
 	// these channels are created once
 	disconnectedCh             chan bool
 	backgroundConnectionDoneCh chan struct{}
 	stopCh                     chan struct{}
+	// endpointFileDoneCh is closed once the endpoint file is no longer
+	// watched, it is nil if the file is not watched.
+	endpointFileDoneCh chan struct{}
 
 	// this is for tests, so they can replace the closing
 	// routine without a worry of modifying some global variable
@@ -60,11 +104,13 @@ type Connection struct {
 	closeBackgroundConnectionDoneCh func(ch chan struct{})
 }
 
-func NewConnection(cfg otlpconfig.Config, sCfg otlpconfig.SignalConfig, handler func(cc *grpc.ClientConn)) *Connection {
+func NewConnection(cfg otlpconfig.Config, sCfg otlpconfig.SignalConfig, handler func(cc *grpc.ClientConn), observer retry.Observer) *Connection {
 	c := new(Connection)
 	c.newConnectionHandler = handler
 	c.cfg = cfg
-	c.requestFunc = cfg.RetryConfig.RequestFunc(evaluate)
+	c.errHandler = cfg.EffectiveErrorHandler()
+	c.stateCallback = cfg.ConnectionStateCallback
+	c.requestFunc = cfg.RetryConfig.StrategyRequestFunc(cfg.BackoffStrategy, retry.Classify(evaluate, cfg.RetryableErrorFunc), observer)
 	c.SCfg = sCfg
 	if len(c.SCfg.Headers) > 0 {
 		c.metadata = metadata.New(c.SCfg.Headers)
@@ -80,18 +126,93 @@ func (c *Connection) StartConnection(ctx context.Context) error {
 	c.disconnectedCh = make(chan bool, 1)
 	c.backgroundConnectionDoneCh = make(chan struct{})
 
-	if err := c.connect(ctx); err == nil {
+	watchFile := c.cfg.EndpointFile != "" && c.cfg.GRPCConn == nil
+	if watchFile {
+		c.readEndpointFile()
+	}
+	if c.cfg.ResolveOnStart && c.cfg.GRPCConn == nil {
+		if err := otlpconfig.ResolveEndpoint(ctx, c.Endpoint()); err != nil {
+			// The Connection is not established.
+			c.closeBackgroundConnectionDoneCh(c.backgroundConnectionDoneCh)
+			return err
+		}
+	}
+
+	startCtx := ctx
+	if c.cfg.StartTimeout > 0 {
+		var cancel context.CancelFunc
+		startCtx, cancel = context.WithTimeout(ctx, c.cfg.StartTimeout)
+		defer cancel()
+	}
+	err := c.connect(startCtx)
+	if err == nil && (c.cfg.StartupProbe || c.cfg.StartTimeout > 0) {
+		err = c.waitForReady(startCtx)
+		if err != nil {
+			c.closeConnection()
+		}
+	}
+	if err == nil {
 		c.setStateConnected()
 	} else {
 		c.SetStateDisconnected(err)
 	}
-	go c.indefiniteBackgroundConnection()
+	if err != nil && c.cfg.StartupProbe {
+		// The Connection failed to start, it is not re-established.
+		c.closeBackgroundConnectionDoneCh(c.backgroundConnectionDoneCh)
+		return err
+	}
+	if err != nil && c.cfg.StartTimeout > 0 {
+		// The endpoint may be misconfigured, it is not dialed
+		// continuously in the background.
+		c.errHandler.Handle(fmt.Errorf("the connection to the collector %s was not ready within the start timeout %s, it is established by the exports instead: %w", c.Endpoint(), c.cfg.StartTimeout, err))
+		c.connectOnExport = true
+	}
+	if c.cfg.DisableReconnect || c.connectOnExport {
+		// Connections are re-established by EnsureConnected instead.
+		c.closeBackgroundConnectionDoneCh(c.backgroundConnectionDoneCh)
+	} else {
+		go c.indefiniteBackgroundConnection()
+	}
+	if watchFile {
+		c.endpointFileDoneCh = make(chan struct{})
+		go c.watchEndpointFile()
+	}
 
 	// TODO: proper error handling when initializing connections.
 	// We can report permanent errors, e.g., invalid settings.
 	return nil
 }
 
+// waitForReady blocks until the client Connection is ready or ctx is done.
+func (c *Connection) waitForReady(ctx context.Context) error {
+	c.mu.Lock()
+	cc := c.cc
+	c.mu.Unlock()
+
+	for {
+		state := cc.GetState()
+		switch state {
+		case connectivity.Ready:
+			return nil
+		case connectivity.Idle:
+			cc.Connect()
+		}
+		if !cc.WaitForStateChange(ctx, state) {
+			return fmt.Errorf("connection to the collector %s is not ready, its state is %s: %w", c.Endpoint(), state, ctx.Err())
+		}
+	}
+}
+
+// closeConnection closes the client Connection, if any.
+func (c *Connection) closeConnection() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cc != nil {
+		_ = c.closeConn(c.cc)
+		c.cc = nil
+	}
+}
+
 func (c *Connection) LastConnectError() error {
 	errPtr := (*error)(atomic.LoadPointer(&c.lastConnectErrPtr))
 	if errPtr == nil {
@@ -100,6 +221,16 @@ func (c *Connection) LastConnectError() error {
 	return *errPtr
 }
 
+// Status returns the status of the Connection.
+func (c *Connection) Status() otlptrace.ConnectionStatus {
+	err := c.LastConnectError()
+	return otlptrace.ConnectionStatus{
+		Connected:         err == nil,
+		LastError:         err,
+		ReconnectAttempts: int(atomic.LoadInt64(&c.reconnectAttempts)),
+	}
+}
+
 func (c *Connection) saveLastConnectError(err error) {
 	var errPtr *error
 	if err != nil {
@@ -109,33 +240,205 @@ func (c *Connection) saveLastConnectError(err error) {
 }
 
 func (c *Connection) SetStateDisconnected(err error) {
+	c.lossMu.Lock()
+	failed := c.failedErr != nil
+	if c.lostAt.IsZero() {
+		c.lostAt = time.Now()
+	}
+	c.lossMu.Unlock()
+	if failed {
+		// The Connection is no longer re-established.
+		return
+	}
+	c.failover(err)
+	if unreachable(err) {
+		c.reconnectNow()
+	}
 	c.saveLastConnectError(err)
 	select {
 	case c.disconnectedCh <- true:
 	default:
 	}
 	c.newConnectionHandler(nil)
+	c.changeState(otlpconfig.ConnectionStateDisconnected)
+}
+
+// Endpoint returns the endpoint of the collector the Connection dials.
+func (c *Connection) Endpoint() string {
+	if len(c.cfg.Endpoints) == 0 && c.cfg.EndpointFile == "" {
+		return c.SCfg.Endpoint
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.endpointLocked()
+}
+
+// endpointLocked returns the endpoint of the collector the Connection dials.
+// It is called with c.mu held.
+func (c *Connection) endpointLocked() string {
+	switch {
+	case c.fileEndpoint != "":
+		return c.fileEndpoint
+	case len(c.cfg.Endpoints) == 0:
+		return c.SCfg.Endpoint
+	}
+	return c.cfg.Endpoints[c.endpointIdx]
+}
+
+// failover makes the Connection dial the next endpoint, if several are
+// configured, when err shows the current one cannot be reached.
+func (c *Connection) failover(err error) {
+	if len(c.cfg.Endpoints) < 2 || !unreachable(err) {
+		return
+	}
+	c.mu.Lock()
+	c.endpointIdx = (c.endpointIdx + 1) % len(c.cfg.Endpoints)
+	c.mu.Unlock()
+}
+
+// reconnectNow resets the connection backoff of the client Connection, if
+// any, so the next export attempts to reconnect to the collector immediately
+// instead of failing until the backoff elapses. The failing attempts make
+// gRPC resolve the endpoint again, so a collector moved to a new address
+// behind the same name is reached without waiting for the Connection to be
+// re-established.
+func (c *Connection) reconnectNow() {
+	c.mu.Lock()
+	cc := c.cc
+	c.mu.Unlock()
+	if cc != nil {
+		cc.ResetConnectBackoff()
+	}
+}
+
+// unreachable returns whether err, or an error it wraps, is a gRPC status
+// error with the Unavailable code, showing the collector could not be
+// reached.
+func unreachable(err error) bool {
+	var se interface{ GRPCStatus() *status.Status }
+	return errors.As(err, &se) && se.GRPCStatus().Code() == codes.Unavailable
 }
 
 func (c *Connection) setStateConnected() {
 	c.saveLastConnectError(nil)
+	atomic.StoreInt64(&c.reconnectAttempts, 0)
+	c.changeState(otlpconfig.ConnectionStateConnected)
+}
+
+// changeState records the new connection state and notifies the state
+// callback, if any, when it differs from the previous one. The callback is
+// invoked without holding any lock so it is free to call back into the
+// client.
+func (c *Connection) changeState(state otlpconfig.ConnectionState) {
+	c.stateMu.Lock()
+	old := c.state
+	if old == otlpconfig.ConnectionStateFailed {
+		// The failed state is terminal.
+		state = old
+	}
+	c.state = state
+	c.stateMu.Unlock()
+
+	if old != state && c.stateCallback != nil {
+		c.stateCallback(old, state)
+	}
 }
 
 func (c *Connection) Connected() bool {
 	return c.LastConnectError() == nil
 }
 
-const defaultConnReattemptPeriod = 10 * time.Second
+// EnsureConnected returns nil if the Connection is connected. Otherwise, if
+// reconnecting in the background is disabled, it synchronously attempts to
+// establish a new connection and returns the error of that attempt. If
+// reconnecting in the background is enabled, it returns the last connection
+// error. Once the Connection gave up reconnecting, it returns an error
+// wrapping ErrReconnectExhausted.
+func (c *Connection) EnsureConnected(ctx context.Context) error {
+	if c.Connected() {
+		return nil
+	}
+	if err := c.failure(); err != nil {
+		return err
+	}
+	if !c.cfg.DisableReconnect && !c.connectOnExport {
+		return c.LastConnectError()
+	}
+
+	if err := c.countReconnectAttempt(); err != nil {
+		c.setStateFailed(err)
+		return err
+	}
+	atomic.AddInt64(&c.reconnectAttempts, 1)
+	if err := c.connect(ctx); err != nil {
+		c.SetStateDisconnected(err)
+		return err
+	}
+	c.setStateConnected()
+	return nil
+}
+
+// ErrReconnectExhausted is wrapped by the errors returned once the
+// Connection gave up re-establishing the connection, after the configured
+// maximum reconnection attempts or time.
+var ErrReconnectExhausted = errors.New("gave up reconnecting to the collector")
+
+// countReconnectAttempt counts a new attempt to re-establish the Connection,
+// unless the configured limits are exhausted: an error wrapping
+// ErrReconnectExhausted is returned then.
+func (c *Connection) countReconnectAttempt() error {
+	c.lossMu.Lock()
+	defer c.lossMu.Unlock()
+	if c.failedErr != nil {
+		return c.failedErr
+	}
+	if c.lostAt.IsZero() {
+		c.lostAt = time.Now()
+	}
+	elapsed := time.Since(c.lostAt)
+	switch {
+	case c.cfg.MaxReconnectAttempts > 0 && c.lostAttempts >= c.cfg.MaxReconnectAttempts:
+		c.failedErr = fmt.Errorf("%w after %d attempts: %v", ErrReconnectExhausted, c.lostAttempts, c.LastConnectError())
+	case c.cfg.MaxReconnectElapsed > 0 && elapsed >= c.cfg.MaxReconnectElapsed:
+		c.failedErr = fmt.Errorf("%w after %s: %v", ErrReconnectExhausted, elapsed.Round(time.Millisecond), c.LastConnectError())
+	default:
+		c.lostAttempts++
+	}
+	return c.failedErr
+}
+
+// resetReconnectAttempts resets the limits of the attempts to re-establish
+// the Connection, which is known to be established.
+func (c *Connection) resetReconnectAttempts() {
+	c.lossMu.Lock()
+	defer c.lossMu.Unlock()
+	c.lostAt = time.Time{}
+	c.lostAttempts = 0
+}
+
+// failure returns the error the Connection gave up reconnecting with, if any.
+func (c *Connection) failure() error {
+	c.lossMu.Lock()
+	defer c.lossMu.Unlock()
+	return c.failedErr
+}
+
+// setStateFailed closes the Connection, which is no longer re-established,
+// and reports err.
+func (c *Connection) setStateFailed(err error) {
+	c.errHandler.Handle(err)
+	c.saveLastConnectError(err)
+	c.newConnectionHandler(nil)
+	c.closeConnection()
+	c.changeState(otlpconfig.ConnectionStateFailed)
+}
 
 func (c *Connection) indefiniteBackgroundConnection() {
 	defer func() {
 		c.closeBackgroundConnectionDoneCh(c.backgroundConnectionDoneCh)
 	}()
 
-	connReattemptPeriod := c.cfg.ReconnectionPeriod
-	if connReattemptPeriod <= 0 {
-		connReattemptPeriod = defaultConnReattemptPeriod
-	}
+	connReattemptPeriod := c.reconnectionPeriod()
 
 	// No strong seeding required, nano time can
 	// already help with pseudo uniqueness.
@@ -167,6 +470,11 @@ func (c *Connection) indefiniteBackgroundConnection() {
 			// Normal scenario that we'll wait for
 		}
 
+		if err := c.countReconnectAttempt(); err != nil {
+			c.setStateFailed(err)
+			return
+		}
+		atomic.AddInt64(&c.reconnectAttempts, 1)
 		if err := c.connect(context.Background()); err == nil {
 			c.setStateConnected()
 		} else {
@@ -178,7 +486,11 @@ func (c *Connection) indefiniteBackgroundConnection() {
 		// Apply some jitter to avoid lockstep retrials of other
 		// collector-exporters. Lockstep retrials could result in an
 		// innocent DDOS, by clogging the machine's resources and network.
-		jitter := time.Duration(rng.Int63n(maxJitterNanos))
+		// A period of a few nanoseconds leaves no room for it.
+		var jitter time.Duration
+		if maxJitterNanos > 0 {
+			jitter = time.Duration(rng.Int63n(maxJitterNanos))
+		}
 		select {
 		case <-c.stopCh:
 			return
@@ -187,35 +499,67 @@ func (c *Connection) indefiniteBackgroundConnection() {
 	}
 }
 
+// reconnectionPeriod returns the delay between the attempts to re-establish
+// the Connection.
+func (c *Connection) reconnectionPeriod() time.Duration {
+	if c.cfg.ReconnectionPeriod <= 0 {
+		return otlpconfig.DefaultReconnectionPeriod
+	}
+	return c.cfg.ReconnectionPeriod
+}
+
 func (c *Connection) connect(ctx context.Context) error {
 	cc, err := c.dialToCollector(ctx)
 	if err != nil {
 		return err
 	}
-	c.setConnection(cc)
+	if err := c.setConnection(cc); err != nil {
+		return err
+	}
 	c.newConnectionHandler(cc)
 	return nil
 }
 
-// setConnection sets cc as the client Connection and returns true if
-// the Connection state changed.
-func (c *Connection) setConnection(cc *grpc.ClientConn) bool {
+// errShutdown is returned by connect when the Connection is shut down while
+// dialing.
+var errShutdown = errors.New("the connection is shut down")
+
+// setConnection sets cc as the client Connection. It closes cc and returns
+// errShutdown if the Connection was shut down while cc was dialed, so no
+// client Connection outlives Shutdown.
+func (c *Connection) setConnection(cc *grpc.ClientConn) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	select {
+	case <-c.stopCh:
+		_ = c.closeConn(cc)
+		return errShutdown
+	default:
+	}
+
 	// If previous clientConn is same as the current then just return.
 	// This doesn't happen right now as this func is only called with new ClientConn.
 	// It is more about future-proofing.
 	if c.cc == cc {
-		return false
+		return nil
 	}
 
 	// If the previous clientConn was non-nil, close it
 	if c.cc != nil {
-		_ = c.cc.Close()
+		_ = c.closeConn(c.cc)
 	}
 	c.cc = cc
-	return true
+	return nil
+}
+
+// closeConn closes cc unless it was supplied by the user with WithGRPCConn,
+// in which case the user owns its lifecycle.
+func (c *Connection) closeConn(cc *grpc.ClientConn) error {
+	if cc == c.cfg.GRPCConn {
+		return nil
+	}
+	return cc.Close()
 }
 
 func (c *Connection) dialToCollector(ctx context.Context) (*grpc.ClientConn, error) {
@@ -223,17 +567,54 @@ func (c *Connection) dialToCollector(ctx context.Context) (*grpc.ClientConn, err
 		return c.cfg.GRPCConn, nil
 	}
 
-	dialOpts := []grpc.DialOption{}
-	if c.cfg.ServiceConfig != "" {
-		dialOpts = append(dialOpts, grpc.WithDefaultServiceConfig(c.cfg.ServiceConfig))
+	// Each attempt to connect to the collector is bounded by the dial
+	// timeout, independently of the export timeout.
+	dialTimeout := c.cfg.EffectiveDialTimeout()
+	dialOpts := []grpc.DialOption{grpc.WithConnectParams(grpc.ConnectParams{
+		Backoff:           backoff.DefaultConfig,
+		MinConnectTimeout: dialTimeout,
+	})}
+	if serviceConfig := c.cfg.EffectiveServiceConfig(); serviceConfig != "" {
+		dialOpts = append(dialOpts, grpc.WithDefaultServiceConfig(serviceConfig))
 	}
 	if c.SCfg.GRPCCredentials != nil {
 		dialOpts = append(dialOpts, grpc.WithTransportCredentials(c.SCfg.GRPCCredentials))
 	} else if c.SCfg.Insecure {
 		dialOpts = append(dialOpts, grpc.WithInsecure())
 	}
-	if c.SCfg.Compression == otlpconfig.GzipCompression {
-		dialOpts = append(dialOpts, grpc.WithDefaultCallOptions(grpc.UseCompressor(gzip.Name)))
+	if c.cfg.KeepaliveParams != nil {
+		dialOpts = append(dialOpts, grpc.WithKeepaliveParams(*c.cfg.KeepaliveParams))
+	}
+	if c.SCfg.UserAgent != "" {
+		dialOpts = append(dialOpts, grpc.WithUserAgent(c.SCfg.UserAgent))
+	}
+	if c.SCfg.Authorization != "" {
+		dialOpts = append(dialOpts, grpc.WithPerRPCCredentials(authCredentials{
+			authorization: c.SCfg.Authorization,
+			requireTLS:    !c.SCfg.Insecure,
+		}))
+	}
+	target := c.Endpoint()
+	if otlpconfig.IsUnixEndpoint(target) {
+		socket := unixSocketPath(target)
+		target = "passthrough:///" + socket
+		dialOpts = append(dialOpts,
+			grpc.WithAuthority("localhost"),
+			grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+			}),
+		)
+	} else if c.cfg.SOCKS5Proxy != nil {
+		if _, ok := otlpconfig.SRVRecordName(target); !ok {
+			// The host of the endpoint is resolved by the proxy, the
+			// network may not allow resolving it locally.
+			target = "passthrough:///" + target
+		}
+		// The TLS handshake is made over the proxied connection, so it
+		// still terminates at the collector.
+		dialOpts = append(dialOpts, grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			return c.cfg.SOCKS5Proxy.DialContext(ctx, "tcp", addr)
+		}))
 	}
 	if len(c.cfg.DialOptions) != 0 {
 		dialOpts = append(dialOpts, c.cfg.DialOptions...)
@@ -241,8 +622,49 @@ func (c *Connection) dialToCollector(ctx context.Context) (*grpc.ClientConn, err
 
 	ctx, cancel := c.ContextWithStop(ctx)
 	defer cancel()
+	ctx, tCancel := context.WithTimeout(ctx, dialTimeout)
+	defer tCancel()
 	ctx = c.ContextWithMetadata(ctx)
-	return grpc.DialContext(ctx, c.SCfg.Endpoint, dialOpts...)
+	if name, ok := otlpconfig.SRVRecordName(target); ok {
+		// The SRV record is resolved again for each connection, so that
+		// the changes of the collectors are picked up when reconnecting.
+		addrs, err := resolveSRV(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		target = srvScheme + ":///" + name
+		dialOpts = append(dialOpts, grpc.WithResolvers(srvBuilder{addrs: addrs}))
+	}
+	return grpc.DialContext(ctx, target, dialOpts...)
+}
+
+// authCredentials sends an authorization header with the metadata of each
+// RPC.
+type authCredentials struct {
+	authorization string
+	// requireTLS prevents sending the header over an insecure connection
+	// unless the client was configured to use one.
+	requireTLS bool
+}
+
+var _ credentials.PerRPCCredentials = authCredentials{}
+
+func (a authCredentials) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	return map[string]string{"authorization": a.authorization}, nil
+}
+
+func (a authCredentials) RequireTransportSecurity() bool {
+	return a.requireTLS
+}
+
+// unixSocketPath returns the path of the Unix domain socket endpoint, given
+// in the unix:path or unix:///absolute/path form.
+func unixSocketPath(endpoint string) string {
+	p := endpoint[len("unix:"):]
+	if strings.HasPrefix(p, "//") {
+		p = p[len("//"):]
+	}
+	return p
 }
 
 func (c *Connection) ContextWithMetadata(ctx context.Context) context.Context {
@@ -252,6 +674,66 @@ func (c *Connection) ContextWithMetadata(ctx context.Context) context.Context {
 	return ctx
 }
 
+type exportMetadataKey struct{}
+
+// WithExportMetadata returns a copy of ctx carrying md, merged with the
+// metadata already carried this way, to be sent with the exports ctx is
+// passed to.
+func WithExportMetadata(ctx context.Context, md metadata.MD) context.Context {
+	merged, _ := ctx.Value(exportMetadataKey{}).(metadata.MD)
+	merged = merged.Copy()
+	for k, v := range md {
+		merged.Set(k, v...)
+	}
+	return context.WithValue(ctx, exportMetadataKey{}, merged)
+}
+
+// HasExportMetadata reports whether ctx carries metadata to be sent with the
+// exports it is passed to, set with WithExportMetadata or as outgoing
+// metadata.
+func HasExportMetadata(ctx context.Context) bool {
+	if _, ok := ctx.Value(exportMetadataKey{}).(metadata.MD); ok {
+		return true
+	}
+	_, ok := metadata.FromOutgoingContext(ctx)
+	return ok
+}
+
+// ContextWithExportMetadata returns a copy of ctx carrying the configured
+// headers as outgoing metadata, merged with the ones returned by the
+// configured headers function when one is set, with the metadata set with
+// WithExportMetadata and with the outgoing metadata already carried by ctx,
+// for instance set by an export interceptor. The latter take precedence.
+func (c *Connection) ContextWithExportMetadata(ctx context.Context) (context.Context, error) {
+	md := c.metadata.Copy()
+	if c.SCfg.HeadersFunc != nil {
+		headers, err := c.SCfg.HeadersFunc(ctx)
+		if err != nil {
+			return ctx, fmt.Errorf("failed to get export headers: %w", err)
+		}
+		for k, v := range headers {
+			md.Set(k, v)
+		}
+	}
+	if exportMD, ok := ctx.Value(exportMetadataKey{}).(metadata.MD); ok {
+		for k, v := range exportMD {
+			md[k] = v
+		}
+	}
+	if ctxMD, ok := metadata.FromOutgoingContext(ctx); ok {
+		for k, v := range ctxMD {
+			md[k] = v
+		}
+	}
+	if md.Len() == 0 {
+		return ctx, nil
+	}
+	return metadata.NewOutgoingContext(ctx, md), nil
+}
+
+// Shutdown stops re-establishing the connection to the collector, canceling
+// any dial in progress, and closes it. If ctx is done first, its error is
+// returned without waiting for the reconnection goroutine.
 func (c *Connection) Shutdown(ctx context.Context) error {
 	close(c.stopCh)
 	// Ensure that the backgroundConnector returns
@@ -260,6 +742,13 @@ func (c *Connection) Shutdown(ctx context.Context) error {
 	case <-ctx.Done():
 		return ctx.Err()
 	}
+	if c.endpointFileDoneCh != nil {
+		select {
+		case <-c.endpointFileDoneCh:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
 
 	c.mu.Lock()
 	cc := c.cc
@@ -267,7 +756,7 @@ func (c *Connection) Shutdown(ctx context.Context) error {
 	c.mu.Unlock()
 
 	if cc != nil {
-		return cc.Close()
+		return c.closeConn(cc)
 	}
 
 	return nil
@@ -292,7 +781,7 @@ func (c *Connection) ContextWithStop(ctx context.Context) (context.Context, cont
 func (c *Connection) DoRequest(ctx context.Context, fn func(context.Context) error) error {
 	ctx, cancel := c.ContextWithStop(ctx)
 	defer cancel()
-	return c.requestFunc(ctx, func(ctx context.Context) error {
+	err := c.requestFunc(ctx, func(ctx context.Context) error {
 		err := fn(ctx)
 		// nil is converted to OK.
 		if status.Code(err) == codes.OK {
@@ -301,6 +790,19 @@ func (c *Connection) DoRequest(ctx context.Context, fn func(context.Context) err
 		}
 		return err
 	})
+	if err == nil {
+		// Connections dialed without blocking are only known to be
+		// established once an export succeeds.
+		c.resetReconnectAttempts()
+	}
+	return err
+}
+
+// Retryable returns true if err is an export error the Connection would
+// retry.
+func Retryable(err error) bool {
+	retryable, _ := evaluate(err)
+	return retryable
 }
 
 // evaluate returns if err is retry-able and a duration to wait for if an
@@ -310,12 +812,16 @@ func evaluate(err error) (bool, time.Duration) {
 	switch s.Code() {
 	case codes.Canceled,
 		codes.DeadlineExceeded,
-		codes.ResourceExhausted,
 		codes.Aborted,
 		codes.OutOfRange,
 		codes.Unavailable,
 		codes.DataLoss:
 		return true, throttleDelay(s)
+	case codes.ResourceExhausted:
+		// Retry only if the server signals that the recovery from resource
+		// exhaustion is possible.
+		throttle := throttleDelay(s)
+		return throttle != 0, throttle
 	}
 
 	// Not a retry-able error.
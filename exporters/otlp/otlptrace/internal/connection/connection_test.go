@@ -16,16 +16,31 @@ package connection
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/resolver/manual"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/durationpb"
 
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/otlpconfig"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/retry"
 )
 
@@ -108,7 +123,7 @@ func TestEvaluate(t *testing.T) {
 		codes.NotFound:           false,
 		codes.AlreadyExists:      false,
 		codes.PermissionDenied:   false,
-		codes.ResourceExhausted:  true,
+		codes.ResourceExhausted:  false,
 		codes.FailedPrecondition: false,
 		codes.Aborted:            true,
 		codes.OutOfRange:         true,
@@ -125,6 +140,19 @@ func TestEvaluate(t *testing.T) {
 	}
 }
 
+func TestEvaluateResourceExhaustedWithRetryInfo(t *testing.T) {
+	s, err := status.New(codes.ResourceExhausted, "throttled").WithDetails(
+		&errdetails.RetryInfo{
+			RetryDelay: durationpb.New(15 * time.Millisecond),
+		},
+	)
+	require.NoError(t, err)
+
+	retryable, throttle := evaluate(s.Err())
+	assert.True(t, retryable)
+	assert.Equal(t, 15*time.Millisecond, throttle)
+}
+
 func TestDoRequest(t *testing.T) {
 	ev := func(error) (bool, time.Duration) { return false, 0 }
 
@@ -143,3 +171,397 @@ func TestDoRequest(t *testing.T) {
 		return assert.AnError
 	}), assert.AnError)
 }
+
+func TestUnixSocketPath(t *testing.T) {
+	for endpoint, want := range map[string]string{
+		"unix:///var/run/otel.sock": "/var/run/otel.sock",
+		"unix:/var/run/otel.sock":   "/var/run/otel.sock",
+		"unix:otel.sock":            "otel.sock",
+	} {
+		assert.Equal(t, want, unixSocketPath(endpoint), endpoint)
+	}
+}
+
+func TestDialTimeout(t *testing.T) {
+	ln, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	endpoint := ln.Addr().String()
+	// Nothing listens on the endpoint anymore, a blocking dial never
+	// succeeds.
+	require.NoError(t, ln.Close())
+
+	cfg := otlpconfig.NewDefaultConfig()
+	cfg.Traces.Endpoint = endpoint
+	cfg.Traces.Insecure = true
+	cfg.Traces.Timeout = time.Minute
+	cfg.DialTimeout = 50 * time.Millisecond
+	cfg.DialOptions = []grpc.DialOption{grpc.WithBlock()}
+	c := NewConnection(cfg, cfg.Traces, func(*grpc.ClientConn) {}, nil)
+	c.stopCh = make(chan struct{})
+
+	start := time.Now()
+	_, err = c.dialToCollector(context.Background())
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, int64(time.Since(start)), int64(10*time.Second))
+}
+
+func TestShutdownDuringReconnect(t *testing.T) {
+	ln, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	endpoint := ln.Addr().String()
+	require.NoError(t, ln.Close())
+
+	cfg := otlpconfig.NewDefaultConfig()
+	cfg.Traces.Endpoint = endpoint
+	cfg.Traces.Insecure = true
+	cfg.Traces.Timeout = time.Minute
+	// The dials block until the unreachable collector accepts the
+	// connection, or their context is canceled.
+	cfg.DialOptions = []grpc.DialOption{grpc.WithBlock()}
+	var connected bool
+	c := NewConnection(cfg, cfg.Traces, func(cc *grpc.ClientConn) {
+		if cc != nil {
+			connected = true
+		}
+	}, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	// The dial of the start fails, the reconnection goroutine dials
+	// again.
+	require.NoError(t, c.StartConnection(ctx))
+	time.Sleep(50 * time.Millisecond)
+
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	start := time.Now()
+	assert.NoError(t, c.Shutdown(ctx))
+	assert.Less(t, int64(time.Since(start)), int64(time.Second))
+	assert.False(t, connected)
+	assert.Nil(t, c.cc)
+}
+
+func TestReconnectionPeriodTooShortForJitter(t *testing.T) {
+	ln, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	endpoint := ln.Addr().String()
+	require.NoError(t, ln.Close())
+
+	cfg := otlpconfig.NewDefaultConfig()
+	cfg.Traces.Endpoint = endpoint
+	cfg.Traces.Insecure = true
+	cfg.Traces.Timeout = 5 * time.Millisecond
+	cfg.DialOptions = []grpc.DialOption{grpc.WithBlock()}
+	// The period has no room for a jitter.
+	cfg.ReconnectionPeriod = time.Nanosecond
+	c := NewConnection(cfg, cfg.Traces, func(*grpc.ClientConn) {}, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	// The failed dials are attempted again without a jitter.
+	require.NoError(t, c.StartConnection(ctx))
+	time.Sleep(50 * time.Millisecond)
+	assert.NoError(t, c.Shutdown(context.Background()))
+}
+
+func TestMaxReconnect(t *testing.T) {
+	for name, limit := range map[string]func(*otlpconfig.Config){
+		"attempts": func(cfg *otlpconfig.Config) { cfg.MaxReconnectAttempts = 2 },
+		"elapsed":  func(cfg *otlpconfig.Config) { cfg.MaxReconnectElapsed = 100 * time.Millisecond },
+	} {
+		t.Run(name, func(t *testing.T) {
+			ln, err := net.Listen("tcp", "localhost:0")
+			require.NoError(t, err)
+			endpoint := ln.Addr().String()
+			require.NoError(t, ln.Close())
+
+			cfg := otlpconfig.NewDefaultConfig()
+			cfg.Traces.Endpoint = endpoint
+			cfg.Traces.Insecure = true
+			cfg.DialTimeout = 10 * time.Millisecond
+			cfg.ReconnectionPeriod = 10 * time.Millisecond
+			cfg.DialOptions = []grpc.DialOption{grpc.WithBlock()}
+			failed := make(chan struct{})
+			cfg.ConnectionStateCallback = func(_, state otlpconfig.ConnectionState) {
+				if state == otlpconfig.ConnectionStateFailed {
+					close(failed)
+				}
+			}
+			limit(&cfg)
+			c := NewConnection(cfg, cfg.Traces, func(*grpc.ClientConn) {}, nil)
+
+			require.NoError(t, c.StartConnection(context.Background()))
+			select {
+			case <-failed:
+			case <-time.After(10 * time.Second):
+				t.Fatal("the connection did not give up reconnecting")
+			}
+			// The reconnection goroutine returned.
+			<-c.backgroundConnectionDoneCh
+
+			err = c.EnsureConnected(context.Background())
+			assert.ErrorIs(t, err, ErrReconnectExhausted)
+			// The failed state is terminal.
+			c.SetStateDisconnected(assert.AnError)
+			assert.ErrorIs(t, c.EnsureConnected(context.Background()), ErrReconnectExhausted)
+			assert.Equal(t, otlpconfig.ConnectionStateFailed, c.state)
+			assert.NoError(t, c.Shutdown(context.Background()))
+		})
+	}
+}
+
+func TestSRVEndpoint(t *testing.T) {
+	listen := func() (*grpc.Server, uint16) {
+		ln, err := net.Listen("tcp", "localhost:0")
+		require.NoError(t, err)
+		srv := grpc.NewServer()
+		go func() { _ = srv.Serve(ln) }()
+		return srv, uint16(ln.Addr().(*net.TCPAddr).Port)
+	}
+	srv, port := listen()
+	defer srv.Stop()
+
+	var mu sync.Mutex
+	var lookups int
+	defer func(orig func(context.Context, string) ([]*net.SRV, error)) {
+		lookupSRV = orig
+	}(lookupSRV)
+	lookupSRV = func(_ context.Context, name string) ([]*net.SRV, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		lookups++
+		if name != "_otlp._grpc.example.com" {
+			return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+		}
+		return []*net.SRV{{Target: "127.0.0.1.", Port: port}}, nil
+	}
+
+	cfg := otlpconfig.NewDefaultConfig()
+	cfg.Traces.Endpoint = "srv://_otlp._grpc.example.com"
+	cfg.Traces.Insecure = true
+	cfg.Traces.Timeout = 10 * time.Second
+	cfg.DialOptions = []grpc.DialOption{grpc.WithBlock()}
+	c := NewConnection(cfg, cfg.Traces, func(*grpc.ClientConn) {}, nil)
+	c.stopCh = make(chan struct{})
+
+	ctx := context.Background()
+	cc, err := c.dialToCollector(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, connectivity.Ready, cc.GetState())
+
+	// The collector moves, the record is resolved again when the
+	// connection is lost.
+	srv.Stop()
+	newSrv, newPort := listen()
+	defer newSrv.Stop()
+	mu.Lock()
+	port = newPort
+	mu.Unlock()
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	for state := cc.GetState(); state != connectivity.Ready; state = cc.GetState() {
+		cc.Connect()
+		require.True(t, cc.WaitForStateChange(ctx, state), "the collector was not reconnected to")
+	}
+
+	require.NoError(t, cc.Close())
+
+	// Each connection resolves the record again.
+	mu.Lock()
+	before := lookups
+	mu.Unlock()
+	newCC, err := c.dialToCollector(context.Background())
+	require.NoError(t, err)
+	assert.NoError(t, newCC.Close())
+	mu.Lock()
+	assert.Equal(t, before+1, lookups)
+	mu.Unlock()
+
+	c.SCfg.Endpoint = "srv://_otlp._grpc.missing.example.com"
+	_, err = c.dialToCollector(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to resolve the SRV record _otlp._grpc.missing.example.com")
+}
+
+func TestReconnectOnUnavailable(t *testing.T) {
+	listen := func() (*grpc.Server, string) {
+		ln, err := net.Listen("tcp", "localhost:0")
+		require.NoError(t, err)
+		srv := grpc.NewServer()
+		go func() { _ = srv.Serve(ln) }()
+		return srv, ln.Addr().String()
+	}
+	srv, addr := listen()
+	defer srv.Stop()
+
+	// The resolver answers with the address of the collector when it is
+	// asked to resolve the name again, as a DNS server would.
+	var mu sync.Mutex
+	var resolutions int
+	r := manual.NewBuilderWithScheme("stale")
+	r.InitialState(resolver.State{Addresses: []resolver.Address{{Addr: addr}}})
+	r.ResolveNowCallback = func(resolver.ResolveNowOptions) {
+		mu.Lock()
+		defer mu.Unlock()
+		resolutions++
+		state := resolver.State{Addresses: []resolver.Address{{Addr: addr}}}
+		go r.UpdateState(state)
+	}
+
+	cfg := otlpconfig.NewDefaultConfig()
+	cfg.Traces.Endpoint = "stale:///collector"
+	cfg.Traces.Insecure = true
+	// The Connection is not re-established, the client connection has to
+	// recover on its own.
+	cfg.DisableReconnect = true
+	cfg.StartupProbe = true
+	cfg.DialOptions = []grpc.DialOption{
+		grpc.WithResolvers(r),
+		// The connection backoff outlasts the test after the first
+		// failed attempt.
+		grpc.WithConnectParams(grpc.ConnectParams{Backoff: backoff.Config{
+			BaseDelay:  10 * time.Millisecond,
+			Multiplier: float64(time.Hour / time.Millisecond),
+			MaxDelay:   time.Hour,
+		}}),
+	}
+	c := NewConnection(cfg, cfg.Traces, func(*grpc.ClientConn) {}, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	require.NoError(t, c.StartConnection(ctx))
+	cc := c.cc
+
+	// The collector moves to a new address behind the same name, the
+	// client connection fails to reconnect to the stale address and waits
+	// for its backoff.
+	srv.Stop()
+	require.Eventually(t, func() bool {
+		cc.Connect()
+		mu.Lock()
+		defer mu.Unlock()
+		// The loss of the connection and the two failed attempts.
+		return resolutions >= 3 && cc.GetState() == connectivity.TransientFailure
+	}, 5*time.Second, time.Millisecond)
+	newSrv, newAddr := listen()
+	defer newSrv.Stop()
+	mu.Lock()
+	addr = newAddr
+	resolutions = 0
+	mu.Unlock()
+
+	// Errors reaching the collector do not reset the backoff.
+	c.SetStateDisconnected(status.Error(codes.InvalidArgument, "rejected"))
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, connectivity.TransientFailure, cc.GetState())
+
+	c.SetStateDisconnected(status.Error(codes.Unavailable, "unavailable"))
+	for state := cc.GetState(); state != connectivity.Ready; state = cc.GetState() {
+		// The exports connect the idle client connection.
+		cc.Connect()
+		require.True(t, cc.WaitForStateChange(ctx, state), "the collector was not reconnected to")
+	}
+	mu.Lock()
+	assert.Greater(t, resolutions, 0)
+	mu.Unlock()
+	assert.NoError(t, c.Shutdown(context.Background()))
+}
+
+func TestUnreachable(t *testing.T) {
+	unavailable := status.Error(codes.Unavailable, "unavailable")
+	for _, tt := range []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "unavailable", err: unavailable, want: true},
+		{name: "wrapped unavailable", err: fmt.Errorf("export failed: %w", unavailable), want: true},
+		{name: "other code", err: status.Error(codes.InvalidArgument, "rejected")},
+		{name: "deadline exceeded", err: context.DeadlineExceeded},
+		{name: "not a status", err: errors.New("no client")},
+		{name: "nil"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, unreachable(tt.err))
+		})
+	}
+}
+
+func TestEndpointFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "endpointfile")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "endpoint")
+	write := func(endpoint string) {
+		require.NoError(t, ioutil.WriteFile(path, []byte(endpoint), 0600))
+	}
+
+	var mu sync.Mutex
+	var warnings []error
+	var targets []string
+	cfg := otlpconfig.NewDefaultConfig()
+	cfg.Traces.Endpoint = "configured:4317"
+	cfg.Traces.Insecure = true
+	cfg.EndpointFile = path
+	cfg.ReconnectionPeriod = 5 * time.Millisecond
+	cfg.ErrorHandler = otel.ErrorHandlerFunc(func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		warnings = append(warnings, err)
+	})
+	c := NewConnection(cfg, cfg.Traces, func(cc *grpc.ClientConn) {
+		if cc != nil {
+			mu.Lock()
+			defer mu.Unlock()
+			targets = append(targets, cc.Target())
+		}
+	}, nil)
+	lastWarning := func() string {
+		mu.Lock()
+		defer mu.Unlock()
+		if len(warnings) == 0 {
+			return ""
+		}
+		return warnings[len(warnings)-1].Error()
+	}
+	dialed := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), targets...)
+	}
+
+	// The configured endpoint is kept until the file is written.
+	require.NoError(t, c.StartConnection(context.Background()))
+	assert.Equal(t, "configured:4317", c.Endpoint())
+	assert.Contains(t, lastWarning(), "failed to read the collector endpoint, keeping configured:4317")
+	assert.Equal(t, []string{"configured:4317"}, dialed())
+
+	// The connection is re-established to each new endpoint.
+	write(" collector \n")
+	require.Eventually(t, func() bool {
+		return len(dialed()) == 2
+	}, 5*time.Second, time.Millisecond)
+	assert.Equal(t, "collector:4317", c.Endpoint())
+	assert.Equal(t, "collector:4317", dialed()[1])
+	assert.True(t, c.Connected())
+
+	// The last endpoint read is kept while the file is invalid, the
+	// warnings are not repeated.
+	write("collector:port")
+	require.Eventually(t, func() bool {
+		return strings.Contains(lastWarning(), "invalid endpoint")
+	}, 5*time.Second, time.Millisecond)
+	assert.Contains(t, lastWarning(), "keeping collector:4317")
+	require.NoError(t, os.Remove(path))
+	require.Eventually(t, func() bool {
+		return strings.Contains(lastWarning(), "no such file")
+	}, 5*time.Second, time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	mu.Lock()
+	assert.Len(t, warnings, 3)
+	mu.Unlock()
+	assert.Equal(t, "collector:4317", c.Endpoint())
+	assert.Len(t, dialed(), 2)
+
+	assert.NoError(t, c.Shutdown(context.Background()))
+	<-c.endpointFileDoneCh
+}
@@ -0,0 +1,74 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connection // import "go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/connection"
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/otlpconfig"
+)
+
+// readEndpointFile reads the endpoint of the collector from the endpoint file
+// and returns it, and true, if it could be read. The endpoint dialed is left
+// unchanged otherwise, and a warning is reported, unless the same one was
+// already reported for the previous read.
+func (c *Connection) readEndpointFile() (string, bool) {
+	endpoint, err := otlpconfig.ReadEndpointFile(c.cfg.EndpointFile, otlpconfig.DefaultCollectorPort)
+	if err != nil {
+		if msg := err.Error(); msg != c.endpointFileErr {
+			c.endpointFileErr = msg
+			c.errHandler.Handle(fmt.Errorf("failed to read the collector endpoint, keeping %s: %w", c.Endpoint(), err))
+		}
+		return "", false
+	}
+	c.endpointFileErr = ""
+	c.mu.Lock()
+	c.fileEndpoint = endpoint
+	c.mu.Unlock()
+	return endpoint, true
+}
+
+// watchEndpointFile reads the endpoint file again every reconnection period
+// and re-establishes the Connection to the endpoint read when it changed,
+// until the Connection is shut down.
+func (c *Connection) watchEndpointFile() {
+	defer close(c.endpointFileDoneCh)
+
+	ticker := time.NewTicker(c.reconnectionPeriod())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stopCh:
+			return
+		case <-ticker.C:
+		}
+
+		old := c.Endpoint()
+		endpoint, ok := c.readEndpointFile()
+		if !ok || endpoint == old || c.failure() != nil {
+			continue
+		}
+		// The new connection replaces the previous one once dialed, the
+		// exports in progress on the previous one are cancelled and
+		// retried on the new one.
+		if err := c.connect(context.Background()); err != nil {
+			c.SetStateDisconnected(err)
+			continue
+		}
+		c.setStateConnected()
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connection // import "go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/connection"
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/resolver"
+)
+
+// srvScheme is the scheme of the gRPC targets resolved from DNS SRV records.
+const srvScheme = "srv"
+
+// srvResolveTimeout bounds the lookups of the SRV records requested by gRPC.
+const srvResolveTimeout = 10 * time.Second
+
+// lookupSRV looks the SRV record name up, it is replaced by tests.
+var lookupSRV = func(ctx context.Context, name string) ([]*net.SRV, error) {
+	_, srvs, err := net.DefaultResolver.LookupSRV(ctx, "", "", name)
+	return srvs, err
+}
+
+// resolveSRV returns the addresses of the collectors listed by the SRV
+// record name, in the order they should be dialed.
+func resolveSRV(ctx context.Context, name string) ([]resolver.Address, error) {
+	srvs, err := lookupSRV(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve the SRV record %s: %w", name, err)
+	}
+	if len(srvs) == 0 {
+		return nil, fmt.Errorf("failed to resolve the SRV record %s: it lists no collector", name)
+	}
+	addrs := make([]resolver.Address, len(srvs))
+	for i, srv := range srvs {
+		host := strings.TrimSuffix(srv.Target, ".")
+		addrs[i] = resolver.Address{
+			Addr: net.JoinHostPort(host, strconv.Itoa(int(srv.Port))),
+			// The certificate of the collector is verified against its
+			// host, not the name of the record.
+			ServerName: host,
+		}
+	}
+	return addrs, nil
+}
+
+// srvBuilder builds the resolver of a srv:///name target, starting from the
+// addresses resolved when it was dialed.
+type srvBuilder struct {
+	addrs []resolver.Address
+}
+
+var _ resolver.Builder = srvBuilder{}
+
+func (b srvBuilder) Scheme() string {
+	return srvScheme
+}
+
+func (b srvBuilder) Build(target resolver.Target, cc resolver.ClientConn, _ resolver.BuildOptions) (resolver.Resolver, error) {
+	if err := cc.UpdateState(resolver.State{Addresses: b.addrs}); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &srvResolver{
+		name:       strings.TrimPrefix(target.URL.Path, "/"),
+		cc:         cc,
+		resolveNow: make(chan struct{}, 1),
+		cancel:     cancel,
+	}
+	r.wg.Add(1)
+	go r.watch(ctx)
+	return r, nil
+}
+
+// srvResolver resolves the SRV record again each time gRPC requests it, for
+// instance when a connection to a collector fails.
+type srvResolver struct {
+	name       string
+	cc         resolver.ClientConn
+	resolveNow chan struct{}
+	cancel     context.CancelFunc
+	wg         sync.WaitGroup
+}
+
+var _ resolver.Resolver = (*srvResolver)(nil)
+
+func (r *srvResolver) watch(ctx context.Context) {
+	defer r.wg.Done()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-r.resolveNow:
+		}
+
+		lookupCtx, cancel := context.WithTimeout(ctx, srvResolveTimeout)
+		addrs, err := resolveSRV(lookupCtx, r.name)
+		cancel()
+		if err != nil {
+			r.cc.ReportError(err)
+			continue
+		}
+		_ = r.cc.UpdateState(resolver.State{Addresses: addrs})
+	}
+}
+
+func (r *srvResolver) ResolveNow(resolver.ResolveNowOptions) {
+	select {
+	case r.resolveNow <- struct{}{}:
+	default:
+	}
+}
+
+func (r *srvResolver) Close() {
+	r.cancel()
+	r.wg.Wait()
+}
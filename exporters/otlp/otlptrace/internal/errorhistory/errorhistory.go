@@ -0,0 +1,75 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package errorhistory keeps the last errors returned by the exports.
+package errorhistory // import "go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/errorhistory"
+
+import (
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+)
+
+// History is a ring of the last errors recorded. A nil History records
+// nothing.
+type History struct {
+	now func() time.Time
+
+	mu   sync.Mutex
+	errs []otlptrace.RecentError
+	// next is the index of errs the next error is recorded at.
+	next int
+	full bool
+}
+
+// New returns a History of the last n errors, or nil if n is not positive.
+func New(n int) *History {
+	if n <= 0 {
+		return nil
+	}
+	return &History{now: time.Now, errs: make([]otlptrace.RecentError, n)}
+}
+
+// Record adds err to h, replacing the oldest error if h is full. A nil err is
+// not recorded.
+func (h *History) Record(err error) {
+	if h == nil || err == nil {
+		return
+	}
+	t := h.now()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.errs[h.next] = otlptrace.RecentError{Time: t, Err: err}
+	h.next++
+	if h.next == len(h.errs) {
+		h.next = 0
+		h.full = true
+	}
+}
+
+// Errors returns the errors recorded in h, oldest first.
+func (h *History) Errors() []otlptrace.RecentError {
+	if h == nil {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.full {
+		return append([]otlptrace.RecentError(nil), h.errs[:h.next]...)
+	}
+	errs := make([]otlptrace.RecentError, 0, len(h.errs))
+	errs = append(errs, h.errs[h.next:]...)
+	return append(errs, h.errs[:h.next]...)
+}
@@ -0,0 +1,68 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package errorhistory
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+)
+
+func TestNilHistory(t *testing.T) {
+	h := New(0)
+	assert.Nil(t, h)
+	h.Record(errors.New("error"))
+	assert.Nil(t, h.Errors())
+}
+
+func TestHistory(t *testing.T) {
+	h := New(3)
+	var now time.Time
+	h.now = func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+	assert.Empty(t, h.Errors())
+
+	errs := make([]error, 5)
+	for i := range errs {
+		errs[i] = errors.New(string(rune('a' + i)))
+	}
+	h.Record(errs[0])
+	h.Record(nil)
+	h.Record(errs[1])
+	assert.Equal(t, []otlptrace.RecentError{
+		{Time: time.Time{}.Add(time.Second), Err: errs[0]},
+		{Time: time.Time{}.Add(2 * time.Second), Err: errs[1]},
+	}, h.Errors())
+
+	// The oldest errors are replaced once full.
+	for _, err := range errs[2:] {
+		h.Record(err)
+	}
+	assert.Equal(t, []otlptrace.RecentError{
+		{Time: time.Time{}.Add(3 * time.Second), Err: errs[2]},
+		{Time: time.Time{}.Add(4 * time.Second), Err: errs[3]},
+		{Time: time.Time{}.Add(5 * time.Second), Err: errs[4]},
+	}, h.Errors())
+
+	// The returned errors are a copy.
+	h.Errors()[0].Err = nil
+	assert.Equal(t, errs[2], h.Errors()[0].Err)
+}
@@ -0,0 +1,46 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package exporterror describes the export errors returned by the drivers.
+package exporterror // import "go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/exporterror"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+)
+
+// Timeout returns err, the error of an export that ran for elapsed,
+// describing which deadline expired if it timed out: the deadline of ctx, set
+// by the caller, or the configured timeout.
+func Timeout(ctx context.Context, err error, timeout, elapsed time.Duration) error {
+	var eErr *otlptrace.ExportError
+	if !errors.As(err, &eErr) || !errors.Is(err, otlptrace.ErrTimeout) {
+		return err
+	}
+	var expired string
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		expired = "the deadline of the caller expired"
+	case timeout > 0 && elapsed >= timeout:
+		expired = fmt.Sprintf("the configured timeout %s expired", timeout)
+	default:
+		expired = "a configured connection timeout expired"
+	}
+	err = fmt.Errorf("export timed out after %s, %s: %w", elapsed.Round(time.Millisecond), expired, err)
+	return otlptrace.NewExportError(otlptrace.ErrTimeout, err, eErr.Retryable())
+}
@@ -0,0 +1,83 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporterror
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+)
+
+func TestTimeout(t *testing.T) {
+	timedOut := otlptrace.NewExportError(otlptrace.ErrTimeout, context.DeadlineExceeded, true)
+	expired, cancel := context.WithDeadline(context.Background(), time.Now())
+	defer cancel()
+	<-expired.Done()
+
+	for _, tt := range []struct {
+		name    string
+		ctx     context.Context
+		err     error
+		elapsed time.Duration
+		want    string
+	}{
+		{
+			name:    "caller deadline",
+			ctx:     expired,
+			err:     timedOut,
+			elapsed: 1500 * time.Millisecond,
+			want:    "export timed out after 1.5s, the deadline of the caller expired: context deadline exceeded",
+		},
+		{
+			name:    "configured timeout",
+			ctx:     context.Background(),
+			err:     timedOut,
+			elapsed: 10*time.Second + 123456*time.Nanosecond,
+			want:    "export timed out after 10s, the configured timeout 10s expired: context deadline exceeded",
+		},
+		{
+			name:    "connection timeout",
+			ctx:     context.Background(),
+			err:     timedOut,
+			elapsed: time.Second,
+			want:    "export timed out after 1s, a configured connection timeout expired: context deadline exceeded",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Timeout(tt.ctx, tt.err, 10*time.Second, tt.elapsed)
+			assert.EqualError(t, err, tt.want)
+			assert.ErrorIs(t, err, otlptrace.ErrTimeout)
+			assert.ErrorIs(t, err, context.DeadlineExceeded)
+			var eErr *otlptrace.ExportError
+			if assert.True(t, errors.As(err, &eErr)) {
+				assert.True(t, eErr.Retryable())
+			}
+		})
+	}
+}
+
+func TestTimeoutOtherErrors(t *testing.T) {
+	// The errors other than timeouts are returned unchanged.
+	rejected := otlptrace.NewExportError(otlptrace.ErrRejected, errors.New("rejected"), false)
+	assert.Same(t, rejected, Timeout(context.Background(), rejected, time.Second, time.Second))
+	err := errors.New("not an export error")
+	assert.Equal(t, err, Timeout(context.Background(), err, time.Second, time.Second))
+	assert.NoError(t, Timeout(context.Background(), nil, time.Second, time.Second))
+}
@@ -0,0 +1,196 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exportqueue // import "go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/exportqueue"
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+)
+
+const (
+	// fileExt is the extension of the files holding export requests.
+	fileExt = ".pb"
+	// tmpExt is the extension of the files being written.
+	tmpExt = ".tmp"
+	// lockFile is the name of the file locking the directory.
+	lockFile = "lock"
+)
+
+// entry is a persisted export request.
+type entry struct {
+	seq  uint64
+	size int64
+}
+
+// diskStore persists export requests in a directory, one file per request
+// named after its sequence number. The directory is locked while the store
+// is open.
+type diskStore struct {
+	dir      string
+	maxBytes int64
+	unlock   func() error
+
+	entries []entry
+	size    int64
+	nextSeq uint64
+}
+
+var _ store = (*diskStore)(nil)
+
+// newDiskStore returns a diskStore holding the export requests already
+// persisted in dir. It fails if dir is used by another store.
+func newDiskStore(dir string, maxBytes int64) (*diskStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	unlock, err := lockDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		_ = unlock()
+		return nil, err
+	}
+
+	s := &diskStore{dir: dir, maxBytes: maxBytes, unlock: unlock}
+	for _, info := range infos {
+		name := info.Name()
+		switch {
+		case strings.HasSuffix(name, tmpExt):
+			// Interrupted while written.
+			_ = os.Remove(filepath.Join(dir, name))
+		case strings.HasSuffix(name, fileExt):
+			seq, err := strconv.ParseUint(strings.TrimSuffix(name, fileExt), 10, 64)
+			if err != nil {
+				continue
+			}
+			s.entries = append(s.entries, entry{seq: seq, size: info.Size()})
+			s.size += info.Size()
+		}
+	}
+	sort.Slice(s.entries, func(i, j int) bool { return s.entries[i].seq < s.entries[j].seq })
+	if n := len(s.entries); n > 0 {
+		s.nextSeq = s.entries[n-1].seq + 1
+	}
+	return s, nil
+}
+
+// path returns the path of the file holding e.
+func (s *diskStore) path(e entry) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%020d%s", e.seq, fileExt))
+}
+
+func (s *diskStore) push(req *coltracepb.ExportTraceServiceRequest) (int, error) {
+	b, err := proto.Marshal(req)
+	if err != nil {
+		return 0, err
+	}
+	if int64(len(b)) > s.maxBytes {
+		return 0, fmt.Errorf("export request of %d bytes exceeds the persistent queue size of %d bytes", len(b), s.maxBytes)
+	}
+
+	e := entry{seq: s.nextSeq, size: int64(len(b))}
+	tmp := s.path(e) + tmpExt
+	if err := writeFileSync(tmp, b); err != nil {
+		_ = os.Remove(tmp)
+		return 0, err
+	}
+	if err := os.Rename(tmp, s.path(e)); err != nil {
+		_ = os.Remove(tmp)
+		return 0, err
+	}
+	if err := syncDir(s.dir); err != nil {
+		return 0, err
+	}
+	s.nextSeq++
+	s.entries = append(s.entries, e)
+	s.size += e.size
+
+	var dropped int
+	for s.size > s.maxBytes {
+		s.removeOldest()
+		dropped++
+	}
+	return dropped, nil
+}
+
+// writeFileSync writes b to the file name and commits it to the disk.
+func writeFileSync(name string, b []byte) error {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(b)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cErr := f.Close(); err == nil {
+		err = cErr
+	}
+	return err
+}
+
+func (s *diskStore) peek() (uint64, *coltracepb.ExportTraceServiceRequest, bool, error) {
+	if len(s.entries) == 0 {
+		return 0, nil, false, nil
+	}
+	e := s.entries[0]
+	b, err := ioutil.ReadFile(s.path(e))
+	if err != nil {
+		return e.seq, nil, true, err
+	}
+	req := &coltracepb.ExportTraceServiceRequest{}
+	return e.seq, req, true, proto.Unmarshal(b, req)
+}
+
+func (s *diskStore) remove(seq uint64) {
+	if len(s.entries) > 0 && s.entries[0].seq == seq {
+		s.removeOldest()
+	}
+}
+
+func (s *diskStore) removeOldest() {
+	e := s.entries[0]
+	_ = os.Remove(s.path(e))
+	s.entries = s.entries[1:]
+	s.size -= e.size
+}
+
+func (s *diskStore) len() int {
+	return len(s.entries)
+}
+
+func (s *diskStore) persistent() bool {
+	return true
+}
+
+func (s *diskStore) close() error {
+	if s.unlock == nil {
+		return nil
+	}
+	unlock := s.unlock
+	s.unlock = nil
+	return unlock()
+}
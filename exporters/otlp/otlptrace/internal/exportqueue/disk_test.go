@@ -0,0 +1,86 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exportqueue
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+func TestDiskPushDropsOldest(t *testing.T) {
+	dir := tempDir(t)
+	b, err := proto.Marshal(request("span0"))
+	require.NoError(t, err)
+	q := newTestQueue(t, Config{Dir: dir, MaxBytes: 2 * int64(len(b))})
+
+	for _, name := range []string{"span0", "span1", "span2"} {
+		require.NoError(t, q.Push(request(name)))
+	}
+	assert.Equal(t, 2, q.Len())
+	assert.Equal(t, int64(1), q.Dropped())
+
+	files, err := filepath.Glob(filepath.Join(dir, "*"+fileExt))
+	require.NoError(t, err)
+	assert.Len(t, files, 2)
+	assert.Equal(t, []string{"span1", "span2"}, names(t, q))
+}
+
+func TestDiskPushTooLarge(t *testing.T) {
+	q := newTestQueue(t, Config{Dir: tempDir(t), MaxBytes: 1})
+	assert.Error(t, q.Push(request("span")))
+	assert.Equal(t, 0, q.Len())
+	assert.False(t, q.Spool([][]*tracepb.ResourceSpans{batch("span")}, errRetryable))
+}
+
+func TestDiskReopen(t *testing.T) {
+	dir := tempDir(t)
+	q := newTestQueue(t, Config{Dir: dir, MaxBytes: 1 << 20})
+	require.True(t, q.Spool([][]*tracepb.ResourceSpans{batch("span0"), batch("span1")}, errRetryable))
+	// A file left by an interrupted write is removed.
+	tmp := filepath.Join(dir, "00000000000000000002"+fileExt+tmpExt)
+	require.NoError(t, ioutil.WriteFile(tmp, []byte("partial"), 0o600))
+	require.NoError(t, q.Stop(context.Background()))
+
+	q = newTestQueue(t, Config{Dir: dir, MaxBytes: 1 << 20})
+	assert.Equal(t, 2, q.Len())
+	_, err := os.Stat(tmp)
+	assert.True(t, os.IsNotExist(err))
+
+	require.NoError(t, q.Push(request("span2")))
+	assert.Equal(t, []string{"span0", "span1", "span2"}, names(t, q))
+}
+
+func TestDiskLocked(t *testing.T) {
+	dir := tempDir(t)
+	q := newTestQueue(t, Config{Dir: dir, MaxBytes: 1 << 20})
+
+	// The directory is used by q until it is stopped.
+	_, err := New(Config{Dir: dir, MaxBytes: 1 << 20})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "is used by another export queue")
+
+	require.NoError(t, q.Stop(context.Background()))
+	q = newTestQueue(t, Config{Dir: dir, MaxBytes: 1 << 20})
+	assert.NoError(t, q.Stop(context.Background()))
+}
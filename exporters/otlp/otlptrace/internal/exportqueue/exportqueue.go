@@ -0,0 +1,359 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package exportqueue holds failed exports, in memory or on disk, and retries
+// them in the background.
+package exportqueue // import "go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/exportqueue"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/circuitbreaker"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+const (
+	// minBackoff and maxBackoff bound the time waited before retrying after
+	// a retryable failure.
+	minBackoff = time.Second
+	maxBackoff = 30 * time.Second
+)
+
+// Config defines configuration for holding failed exports to retry them.
+type Config struct {
+	// Dir, if set, is the directory the export requests are persisted to.
+	Dir string
+	// MaxBytes bounds the total size of the persisted export requests.
+	MaxBytes int64
+	// MaxSpans, if positive and Dir is not set, bounds the number of spans
+	// of the export requests held in memory.
+	MaxSpans int
+	// ErrorHandler, if set, handles the dropped export requests instead
+	// of the global error handler.
+	ErrorHandler otel.ErrorHandler
+}
+
+// store holds export requests, oldest first. Its methods are called with the
+// Queue mutex held.
+type store interface {
+	// push adds req, dropping the oldest requests if the store would
+	// exceed its bound. It returns the number of dropped requests.
+	push(req *coltracepb.ExportTraceServiceRequest) (int, error)
+	// peek returns the oldest request and its identifier, if any.
+	peek() (uint64, *coltracepb.ExportTraceServiceRequest, bool, error)
+	// remove removes the oldest request if it has the identifier id.
+	remove(id uint64)
+	len() int
+	// persistent returns whether the requests outlive the process.
+	persistent() bool
+	// close releases the resources held by the store.
+	close() error
+}
+
+// Queue is a bounded queue of failed export requests retried in the
+// background. The oldest requests are dropped to make room for new ones. A
+// nil Queue holds nothing.
+type Queue struct {
+	mu      sync.Mutex
+	store   store
+	dropped int64
+
+	errHandler otel.ErrorHandler
+
+	minBackoff time.Duration
+	maxBackoff time.Duration
+
+	export   otlptrace.ExportInvoker
+	pushCh   chan struct{}
+	notifyCh chan struct{}
+	stopCh   chan struct{}
+	doneCh   chan struct{}
+	cancel   context.CancelFunc
+}
+
+// New returns a Queue configured with cfg, or nil if cfg neither has a
+// directory nor a maximum number of spans. A Queue with a directory holds the
+// export requests already persisted there.
+func New(cfg Config) (*Queue, error) {
+	var s store
+	switch {
+	case cfg.Dir != "":
+		ds, err := newDiskStore(cfg.Dir, cfg.MaxBytes)
+		if err != nil {
+			return nil, err
+		}
+		s = ds
+	case cfg.MaxSpans > 0:
+		s = newMemoryStore(cfg.MaxSpans)
+	default:
+		return nil, nil
+	}
+	errHandler := cfg.ErrorHandler
+	if errHandler == nil {
+		errHandler = otel.ErrorHandlerFunc(otel.Handle)
+	}
+	return &Queue{
+		store:      s,
+		errHandler: errHandler,
+		minBackoff: minBackoff,
+		maxBackoff: maxBackoff,
+		pushCh:     make(chan struct{}, 1),
+		notifyCh:   make(chan struct{}, 1),
+		stopCh:     make(chan struct{}),
+		doneCh:     make(chan struct{}),
+	}, nil
+}
+
+// Push adds req, dropping the oldest requests if the queue would exceed its
+// bound.
+func (q *Queue) Push(req *coltracepb.ExportTraceServiceRequest) error {
+	q.mu.Lock()
+	dropped, err := q.store.push(req)
+	q.dropped += int64(dropped)
+	total := q.dropped
+	q.mu.Unlock()
+
+	if dropped > 0 {
+		q.errHandler.Handle(fmt.Errorf("export queue is full, dropped the %d oldest export requests (%d in total)", dropped, total))
+	}
+	if err == nil {
+		signal(q.pushCh)
+	}
+	return err
+}
+
+// Spool adds the batches of an export that failed with err, it returns false
+// if err is not retryable, if the batches hold no spans or if a batch could
+// not be added. The empty export of ForceFlush, only checking that the
+// collector accepts exports, is never queued so that its failure is returned.
+func (q *Queue) Spool(batches [][]*tracepb.ResourceSpans, err error) bool {
+	if q == nil || !retryable(err) {
+		return false
+	}
+	reqs := make([]*coltracepb.ExportTraceServiceRequest, 0, len(batches))
+	var spans int
+	for _, batch := range batches {
+		req := &coltracepb.ExportTraceServiceRequest{ResourceSpans: batch}
+		reqs = append(reqs, req)
+		spans += spanCount(req)
+	}
+	if spans == 0 {
+		return false
+	}
+	for _, req := range reqs {
+		if pErr := q.Push(req); pErr != nil {
+			q.errHandler.Handle(fmt.Errorf("failed to queue a failed export: %w", pErr))
+			return false
+		}
+	}
+	return true
+}
+
+// retryable returns whether an export failing with err can be retried.
+func retryable(err error) bool {
+	if errors.Is(err, circuitbreaker.ErrOpen) {
+		return true
+	}
+	var eErr *otlptrace.ExportError
+	return errors.As(err, &eErr) && eErr.Retryable()
+}
+
+// Len returns the number of queued export requests.
+func (q *Queue) Len() int {
+	if q == nil {
+		return 0
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.store.len()
+}
+
+// Dropped returns the number of export requests dropped because the queue
+// was full.
+func (q *Queue) Dropped() int64 {
+	if q == nil {
+		return 0
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.dropped
+}
+
+func (q *Queue) peek() (uint64, *coltracepb.ExportTraceServiceRequest, bool, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.store.peek()
+}
+
+func (q *Queue) remove(id uint64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.store.remove(id)
+}
+
+// Start starts retrying the queued requests in the background with export.
+// The requests already queued are retried immediately. Then they are retried
+// as soon as Notify is called, and after a backoff growing exponentially with
+// each retryable failure once they are queued.
+func (q *Queue) Start(export otlptrace.ExportInvoker) {
+	if q == nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	q.export, q.cancel = export, cancel
+	go q.run(ctx)
+}
+
+// Notify makes the queued requests retried immediately, for instance once an
+// export succeeded.
+func (q *Queue) Notify() {
+	if q == nil {
+		return
+	}
+	signal(q.notifyCh)
+}
+
+func signal(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+// Stop stops retrying the queued requests in the background, interrupting the
+// export in progress. The requests held in memory are then exported once,
+// oldest first, until one fails with a retryable error or ctx is done: the
+// remaining requests are dropped and an error wrapping the failure, or the
+// context error, is returned. The persisted requests are kept and their
+// directory is unlocked.
+func (q *Queue) Stop(ctx context.Context) error {
+	if q == nil {
+		return nil
+	}
+	if q.cancel == nil {
+		return q.store.close()
+	}
+	close(q.stopCh)
+	q.cancel()
+	<-q.doneCh
+
+	if q.store.persistent() {
+		return q.store.close()
+	}
+	if err := q.drain(ctx); err != nil {
+		return fmt.Errorf("dropped %d queued export requests: %w", q.clear(), err)
+	}
+	return nil
+}
+
+// clear drops the queued requests and returns their number.
+func (q *Queue) clear() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var n int
+	for {
+		id, _, ok, _ := q.store.peek()
+		if !ok {
+			break
+		}
+		q.store.remove(id)
+		n++
+	}
+	q.dropped += int64(n)
+	return n
+}
+
+func (q *Queue) run(ctx context.Context) {
+	defer close(q.doneCh)
+
+	var backoff time.Duration
+	retry := q.drain(ctx) != nil
+	for {
+		var (
+			timer  *time.Timer
+			timerC <-chan time.Time
+			pushC  = q.pushCh
+		)
+		if retry {
+			backoff *= 2
+			if backoff < q.minBackoff {
+				backoff = q.minBackoff
+			} else if backoff > q.maxBackoff {
+				backoff = q.maxBackoff
+			}
+			timer = time.NewTimer(backoff)
+			// Wait for the backoff even if new requests are queued.
+			timerC, pushC = timer.C, nil
+		} else {
+			backoff = 0
+		}
+
+		select {
+		case <-q.stopCh:
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		case <-pushC:
+			// The export of the queued request just failed, it is
+			// retried after a backoff.
+			retry = true
+			continue
+		case <-timerC:
+		case <-q.notifyCh:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		retry = q.drain(ctx) != nil
+	}
+}
+
+// drain exports the queued requests, oldest first, until the queue is empty,
+// an export fails with a retryable error or ctx is done. It returns the error
+// leaving requests to be retried, or nil if the queue is empty.
+func (q *Queue) drain(ctx context.Context) error {
+	for {
+		id, req, ok, err := q.peek()
+		if !ok {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			q.errHandler.Handle(fmt.Errorf("dropped an unreadable queued export request: %w", err))
+			q.remove(id)
+			continue
+		}
+
+		if err := q.export(ctx, req); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if retryable(err) {
+				return err
+			}
+			q.errHandler.Handle(fmt.Errorf("dropped a queued export request: %w", err))
+		}
+		q.remove(id)
+	}
+}
@@ -0,0 +1,255 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exportqueue
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/circuitbreaker"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+var (
+	errRetryable = otlptrace.NewExportError(otlptrace.ErrDisconnected, errors.New("unreachable"), true)
+	errRejected  = otlptrace.NewExportError(otlptrace.ErrRejected, errors.New("invalid"), false)
+)
+
+func tempDir(t *testing.T) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "exportqueue")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	return dir
+}
+
+func batch(names ...string) []*tracepb.ResourceSpans {
+	var spans []*tracepb.Span
+	for _, name := range names {
+		spans = append(spans, &tracepb.Span{Name: name})
+	}
+	return []*tracepb.ResourceSpans{{
+		InstrumentationLibrarySpans: []*tracepb.InstrumentationLibrarySpans{{Spans: spans}},
+	}}
+}
+
+func request(names ...string) *coltracepb.ExportTraceServiceRequest {
+	return &coltracepb.ExportTraceServiceRequest{ResourceSpans: batch(names...)}
+}
+
+func spanName(req *coltracepb.ExportTraceServiceRequest) string {
+	return req.ResourceSpans[0].InstrumentationLibrarySpans[0].Spans[0].Name
+}
+
+func newTestQueue(t *testing.T, cfg Config) *Queue {
+	t.Helper()
+	q, err := New(cfg)
+	require.NoError(t, err)
+	require.NotNil(t, q)
+	q.minBackoff, q.maxBackoff = time.Millisecond, time.Millisecond
+	return q
+}
+
+// names returns the span names of the queued requests, oldest first, and
+// removes them.
+func names(t *testing.T, q *Queue) []string {
+	t.Helper()
+	var out []string
+	for {
+		id, req, ok, err := q.peek()
+		if !ok {
+			return out
+		}
+		require.NoError(t, err)
+		out = append(out, spanName(req))
+		q.remove(id)
+	}
+}
+
+func TestNewDisabled(t *testing.T) {
+	q, err := New(Config{})
+	require.NoError(t, err)
+	assert.Nil(t, q)
+
+	assert.False(t, q.Spool([][]*tracepb.ResourceSpans{batch("span")}, errRetryable))
+	assert.Equal(t, 0, q.Len())
+	assert.Equal(t, int64(0), q.Dropped())
+	q.Start(nil)
+	q.Notify()
+	assert.NoError(t, q.Stop(context.Background()))
+}
+
+func TestDirTakesPrecedence(t *testing.T) {
+	q := newTestQueue(t, Config{Dir: tempDir(t), MaxBytes: 1 << 20, MaxSpans: 1})
+	assert.True(t, q.store.persistent())
+}
+
+func TestSpool(t *testing.T) {
+	q := newTestQueue(t, Config{MaxSpans: 10})
+	assert.False(t, q.Spool([][]*tracepb.ResourceSpans{batch("span")}, errRejected))
+	assert.False(t, q.Spool([][]*tracepb.ResourceSpans{batch("span")}, context.Canceled))
+	assert.Equal(t, 0, q.Len())
+
+	// The empty export of ForceFlush is not queued.
+	assert.False(t, q.Spool([][]*tracepb.ResourceSpans{nil}, errRetryable))
+	assert.False(t, q.Spool([][]*tracepb.ResourceSpans{batch()}, errRetryable))
+	assert.Equal(t, 0, q.Len())
+
+	assert.True(t, q.Spool([][]*tracepb.ResourceSpans{batch("span")}, errRetryable))
+	assert.True(t, q.Spool([][]*tracepb.ResourceSpans{batch("span")}, circuitbreaker.ErrOpen))
+	assert.Equal(t, 2, q.Len())
+}
+
+func TestDrain(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		cfg  func(t *testing.T) Config
+	}{
+		{name: "memory", cfg: func(*testing.T) Config { return Config{MaxSpans: 10} }},
+		{name: "disk", cfg: func(t *testing.T) Config { return Config{Dir: tempDir(t), MaxBytes: 1 << 20} }},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			q := newTestQueue(t, tt.cfg(t))
+			for _, name := range []string{"span0", "span1", "span2"} {
+				require.NoError(t, q.Push(request(name)))
+			}
+
+			var (
+				mu       sync.Mutex
+				exported []string
+				results  = []error{errRetryable, nil, errRejected, nil}
+			)
+			q.Start(func(_ context.Context, req *coltracepb.ExportTraceServiceRequest) error {
+				mu.Lock()
+				defer mu.Unlock()
+				exported = append(exported, spanName(req))
+				if len(results) == 0 {
+					return nil
+				}
+				err := results[0]
+				results = results[1:]
+				return err
+			})
+			defer func() {
+				assert.NoError(t, q.Stop(context.Background()))
+			}()
+
+			// The retryable failure is retried after the backoff, the
+			// rejected request is dropped.
+			assert.Eventually(t, func() bool { return q.Len() == 0 }, time.Second, time.Millisecond)
+			mu.Lock()
+			defer mu.Unlock()
+			assert.Equal(t, []string{"span0", "span0", "span1", "span2"}, exported)
+		})
+	}
+}
+
+func TestStopDrainsMemory(t *testing.T) {
+	q := newTestQueue(t, Config{MaxSpans: 10})
+	q.minBackoff, q.maxBackoff = time.Hour, time.Hour
+
+	var (
+		mu        sync.Mutex
+		reachable bool
+		exported  []string
+	)
+	q.Start(func(_ context.Context, req *coltracepb.ExportTraceServiceRequest) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if !reachable {
+			return errRetryable
+		}
+		exported = append(exported, spanName(req))
+		return nil
+	})
+	require.NoError(t, q.Push(request("span0")))
+	require.NoError(t, q.Push(request("span1")))
+
+	mu.Lock()
+	reachable = true
+	mu.Unlock()
+	require.NoError(t, q.Stop(context.Background()))
+	assert.Equal(t, 0, q.Len())
+	assert.Equal(t, []string{"span0", "span1"}, exported)
+}
+
+func TestStopSinglePass(t *testing.T) {
+	q := newTestQueue(t, Config{MaxSpans: 10})
+	q.minBackoff, q.maxBackoff = time.Hour, time.Hour
+	var attempts int32
+	q.Start(func(context.Context, *coltracepb.ExportTraceServiceRequest) error {
+		atomic.AddInt32(&attempts, 1)
+		return errRetryable
+	})
+	require.NoError(t, q.Push(request("span0")))
+	require.NoError(t, q.Push(request("span1")))
+
+	// The unreachable collector does not make Stop wait without a
+	// deadline: the queued requests are dropped once an export fails.
+	err := q.Stop(context.Background())
+	assert.ErrorIs(t, err, otlptrace.ErrDisconnected)
+	assert.EqualError(t, err, "dropped 2 queued export requests: unreachable")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&attempts))
+	assert.Equal(t, 0, q.Len())
+	assert.Equal(t, int64(2), q.Dropped())
+}
+
+func TestStopDeadline(t *testing.T) {
+	q := newTestQueue(t, Config{MaxSpans: 10})
+	q.minBackoff, q.maxBackoff = time.Hour, time.Hour
+	var stopping int32
+	q.Start(func(ctx context.Context, _ *coltracepb.ExportTraceServiceRequest) error {
+		if atomic.LoadInt32(&stopping) == 0 {
+			return errRetryable
+		}
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	require.NoError(t, q.Push(request("span")))
+
+	atomic.StoreInt32(&stopping, 1)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := q.Stop(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Contains(t, err.Error(), "dropped 1 queued export requests")
+	assert.Equal(t, 0, q.Len())
+}
+
+func TestStopKeepsPersisted(t *testing.T) {
+	q := newTestQueue(t, Config{Dir: tempDir(t), MaxBytes: 1 << 20})
+	require.NoError(t, q.Push(request("span")))
+
+	started := make(chan struct{})
+	q.Start(func(ctx context.Context, _ *coltracepb.ExportTraceServiceRequest) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	<-started
+	assert.NoError(t, q.Stop(context.Background()))
+	assert.Equal(t, 1, q.Len())
+}
@@ -0,0 +1,48 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package exportqueue // import "go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/exportqueue"
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// lockDir takes an exclusive lock on dir by creating a lock file, removed by
+// the returned function. A lock file left by a process that did not stop its
+// queue has to be removed by hand.
+func lockDir(dir string) (func() error, error) {
+	path := filepath.Join(dir, lockFile)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_RDWR, 0o600)
+	if err != nil {
+		if os.IsExist(err) {
+			return nil, fmt.Errorf("directory %s is used by another export queue, remove %s if it is not", dir, path)
+		}
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(path)
+		return nil, err
+	}
+	return func() error { return os.Remove(path) }, nil
+}
+
+// syncDir does nothing, the directories cannot be synced on this platform.
+func syncDir(string) error {
+	return nil
+}
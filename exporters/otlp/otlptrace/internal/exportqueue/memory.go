@@ -0,0 +1,104 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exportqueue // import "go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/exportqueue"
+
+import (
+	"fmt"
+
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+)
+
+// memoryRequest is an export request held in memory.
+type memoryRequest struct {
+	seq   uint64
+	spans int
+	req   *coltracepb.ExportTraceServiceRequest
+}
+
+// memoryStore holds export requests in memory, bounded by their number of
+// spans.
+type memoryStore struct {
+	maxSpans int
+
+	requests []memoryRequest
+	spans    int
+	nextSeq  uint64
+}
+
+var _ store = (*memoryStore)(nil)
+
+func newMemoryStore(maxSpans int) *memoryStore {
+	return &memoryStore{maxSpans: maxSpans}
+}
+
+func (s *memoryStore) push(req *coltracepb.ExportTraceServiceRequest) (int, error) {
+	spans := spanCount(req)
+	if spans > s.maxSpans {
+		return 0, fmt.Errorf("export request of %d spans exceeds the memory queue size of %d spans", spans, s.maxSpans)
+	}
+
+	s.requests = append(s.requests, memoryRequest{seq: s.nextSeq, spans: spans, req: req})
+	s.nextSeq++
+	s.spans += spans
+
+	var dropped int
+	for s.spans > s.maxSpans {
+		s.removeOldest()
+		dropped++
+	}
+	return dropped, nil
+}
+
+func (s *memoryStore) peek() (uint64, *coltracepb.ExportTraceServiceRequest, bool, error) {
+	if len(s.requests) == 0 {
+		return 0, nil, false, nil
+	}
+	return s.requests[0].seq, s.requests[0].req, true, nil
+}
+
+func (s *memoryStore) remove(seq uint64) {
+	if len(s.requests) > 0 && s.requests[0].seq == seq {
+		s.removeOldest()
+	}
+}
+
+func (s *memoryStore) removeOldest() {
+	s.spans -= s.requests[0].spans
+	s.requests[0] = memoryRequest{}
+	s.requests = s.requests[1:]
+}
+
+func (s *memoryStore) len() int {
+	return len(s.requests)
+}
+
+func (s *memoryStore) persistent() bool {
+	return false
+}
+
+func (s *memoryStore) close() error {
+	return nil
+}
+
+// spanCount returns the number of spans of req.
+func spanCount(req *coltracepb.ExportTraceServiceRequest) int {
+	var n int
+	for _, rs := range req.GetResourceSpans() {
+		for _, ils := range rs.GetInstrumentationLibrarySpans() {
+			n += len(ils.GetSpans())
+		}
+	}
+	return n
+}
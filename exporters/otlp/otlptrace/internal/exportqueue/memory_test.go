@@ -0,0 +1,39 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exportqueue
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryPushDropsOldest(t *testing.T) {
+	q := newTestQueue(t, Config{MaxSpans: 3})
+
+	require.NoError(t, q.Push(request("span0", "span0")))
+	require.NoError(t, q.Push(request("span1")))
+	require.NoError(t, q.Push(request("span2")))
+	assert.Equal(t, 2, q.Len())
+	assert.Equal(t, int64(1), q.Dropped())
+	assert.Equal(t, []string{"span1", "span2"}, names(t, q))
+}
+
+func TestMemoryPushTooLarge(t *testing.T) {
+	q := newTestQueue(t, Config{MaxSpans: 1})
+	assert.Error(t, q.Push(request("span0", "span1")))
+	assert.Equal(t, 0, q.Len())
+}
@@ -20,74 +20,122 @@ import (
 	"io/ioutil"
 	"net/url"
 	"os"
+	"path"
 	"regexp"
 	"strconv"
 	"strings"
 	"time"
-
-	"go.opentelemetry.io/otel"
 )
 
 var httpSchemeRegexp = regexp.MustCompile(`(?i)^(http://|https://)`)
 
-func ApplyGRPCEnvConfigs(cfg *Config) {
-	e := EnvOptionsReader{
-		GetEnv:   os.Getenv,
-		ReadFile: ioutil.ReadFile,
-	}
+// The signals whose specific environment variables an EnvOptionsReader can
+// read, as they appear in the names of the variables.
+const (
+	TracesSignal  = "TRACES"
+	MetricsSignal = "METRICS"
+)
+
+// DefaultEnvOptionsReader reads the environment of the process and its
+// files. Tests replace its functions.
+var DefaultEnvOptionsReader = EnvOptionsReader{
+	GetEnv:   os.Getenv,
+	ReadFile: ioutil.ReadFile,
+}
 
+func ApplyGRPCEnvConfigs(cfg *Config) {
+	e := DefaultEnvOptionsReader
 	e.ApplyGRPCEnvConfigs(cfg)
 }
 
 func ApplyHTTPEnvConfigs(cfg *Config) {
-	e := EnvOptionsReader{
-		GetEnv:   os.Getenv,
-		ReadFile: ioutil.ReadFile,
-	}
-
+	e := DefaultEnvOptionsReader
 	e.ApplyHTTPEnvConfigs(cfg)
 }
 
+// EnvOptionsReader reads the options set by the OTEL_EXPORTER_OTLP_*
+// environment variables, the generic ones and the ones specific to its signal.
+// The options configure the signal specific configuration of its signal,
+// Config.Traces or Config.Metrics.
 type EnvOptionsReader struct {
 	GetEnv   func(string) string
 	ReadFile func(filename string) ([]byte, error)
+	// Signal is the signal whose specific variables, such as
+	// OTEL_EXPORTER_OTLP_<Signal>_ENDPOINT, are read. TracesSignal is used
+	// if empty.
+	Signal string
+}
+
+// signal returns the signal whose specific variables are read by e.
+func (e *EnvOptionsReader) signal() string {
+	if e.Signal == "" {
+		return TracesSignal
+	}
+	return e.Signal
+}
+
+// signalPath returns the default URL path of the endpoint receiving the
+// signal of e, DefaultTracesPath for traces.
+func (e *EnvOptionsReader) signalPath() string {
+	return "/v1/" + strings.ToLower(e.signal())
+}
+
+// signalKey returns the key of the variable specific to the signal of e
+// named by key.
+func (e *EnvOptionsReader) signalKey(key string) string {
+	return e.signal() + "_" + key
 }
 
 func (e *EnvOptionsReader) ApplyHTTPEnvConfigs(cfg *Config) {
 	opts := e.GetOptionsFromEnv()
-	for _, opt := range opts {
-		opt.ApplyHTTPOption(cfg)
-	}
+	e.applyToSignal(cfg, func() {
+		for _, opt := range opts {
+			opt.ApplyHTTPOption(cfg)
+		}
+	})
 }
 
 func (e *EnvOptionsReader) ApplyGRPCEnvConfigs(cfg *Config) {
 	opts := e.GetOptionsFromEnv()
-	for _, opt := range opts {
-		opt.ApplyGRPCOption(cfg)
+	e.applyToSignal(cfg, func() {
+		for _, opt := range opts {
+			opt.ApplyGRPCOption(cfg)
+		}
+	})
+}
+
+// applyToSignal calls apply, which applies options to cfg, so that the
+// options configure the signal specific configuration of the signal of e. The
+// options set Config.Traces: for MetricsSignal, Config.Metrics is swapped
+// with it while they are applied.
+func (e *EnvOptionsReader) applyToSignal(cfg *Config, apply func()) {
+	if e.signal() == MetricsSignal {
+		cfg.Traces, cfg.Metrics = cfg.Metrics, cfg.Traces
+		defer func() {
+			cfg.Traces, cfg.Metrics = cfg.Metrics, cfg.Traces
+		}()
 	}
+	apply()
 }
 
 func (e *EnvOptionsReader) GetOptionsFromEnv() []GenericOption {
 	var opts []GenericOption
+	defaultPath := e.signalPath()
 
 	// Endpoint
 	if v, ok := e.getEnvValue("ENDPOINT"); ok {
-		if isInsecureEndpoint(v) {
-			opts = append(opts, WithInsecure())
-		} else {
-			opts = append(opts, WithSecure())
-		}
-
-		opts = append(opts, WithEndpoint(trimSchema(v)))
+		opts = append(opts, withEnvEndpoint(v, true, defaultPath))
+	}
+	if v, ok := e.getEnvValue(e.signalKey("ENDPOINT")); ok {
+		opts = append(opts, withEnvEndpoint(v, false, defaultPath))
 	}
-	if v, ok := e.getEnvValue("TRACES_ENDPOINT"); ok {
-		if isInsecureEndpoint(v) {
-			opts = append(opts, WithInsecure())
-		} else {
-			opts = append(opts, WithSecure())
-		}
 
-		opts = append(opts, WithEndpoint(trimSchema(v)))
+	// Insecure, the generic variable is ignored when the signal specific one
+	// is set.
+	if v, ok := e.getEnvValue(e.signalKey("INSECURE")); ok {
+		opts = append(opts, withEnvInsecure(e.signalKey("INSECURE"), v))
+	} else if v, ok := e.getEnvValue("INSECURE"); ok {
+		opts = append(opts, withEnvInsecure("INSECURE", v))
 	}
 
 	// Certificate File
@@ -95,49 +143,142 @@ func (e *EnvOptionsReader) GetOptionsFromEnv() []GenericOption {
 		if tls, err := e.readTLSConfig(path); err == nil {
 			opts = append(opts, WithTLSClientConfig(tls))
 		} else {
-			otel.Handle(fmt.Errorf("failed to configure otlp exporter certificate '%s': %w", path, err))
+			opts = append(opts, withError(fmt.Errorf("failed to configure otlp exporter certificate '%s': %w", path, err)))
 		}
 	}
-	if path, ok := e.getEnvValue("TRACES_CERTIFICATE"); ok {
+	if path, ok := e.getEnvValue(e.signalKey("CERTIFICATE")); ok {
 		if tls, err := e.readTLSConfig(path); err == nil {
 			opts = append(opts, WithTLSClientConfig(tls))
 		} else {
-			otel.Handle(fmt.Errorf("failed to configure otlp traces exporter certificate '%s': %w", path, err))
+			opts = append(opts, withError(fmt.Errorf("failed to configure otlp %s exporter certificate '%s': %w", strings.ToLower(e.signal()), path, err)))
 		}
 	}
 
+	// TLS server name, the generic variable is ignored when the signal
+	// specific one is set. It is applied after the certificates, which
+	// replace the TLS configuration.
+	if name, ok := e.getEnvValue(e.signalKey("TLS_SERVER_NAME")); ok {
+		opts = append(opts, WithTLSServerName(name))
+	} else if name, ok := e.getEnvValue("TLS_SERVER_NAME"); ok {
+		opts = append(opts, WithTLSServerName(name))
+	}
+
+	// Client Certificate
+	if opt, ok := e.clientCertificateOption("CLIENT_CERTIFICATE", "CLIENT_KEY"); ok {
+		opts = append(opts, opt)
+	}
+	if opt, ok := e.clientCertificateOption(e.signalKey("CLIENT_CERTIFICATE"), e.signalKey("CLIENT_KEY")); ok {
+		opts = append(opts, opt)
+	}
+
 	// Headers
 	if h, ok := e.getEnvValue("HEADERS"); ok {
 		opts = append(opts, WithHeaders(stringToHeader(h)))
 	}
-	if h, ok := e.getEnvValue("TRACES_HEADERS"); ok {
+	if h, ok := e.getEnvValue(e.signalKey("HEADERS")); ok {
 		opts = append(opts, WithHeaders(stringToHeader(h)))
 	}
-
-	// Compression
-	if c, ok := e.getEnvValue("COMPRESSION"); ok {
-		opts = append(opts, WithCompression(stringToCompression(c)))
+	// Headers read from files take precedence over the inline ones.
+	for _, key := range []string{"HEADERS_FILE", e.signalKey("HEADERS_FILE")} {
+		if path, ok := e.getEnvValue(key); ok {
+			if h, err := e.readHeadersFile(path); err == nil {
+				opts = append(opts, withMergedHeaders(h))
+			} else {
+				opts = append(opts, withError(fmt.Errorf("failed to read otlp exporter headers file '%s': %w", path, err)))
+			}
+		}
 	}
-	if c, ok := e.getEnvValue("TRACES_COMPRESSION"); ok {
-		opts = append(opts, WithCompression(stringToCompression(c)))
+
+	// Compression, the generic variable is ignored when the signal specific
+	// one is set.
+	if c, ok := e.getEnvValue(e.signalKey("COMPRESSION")); ok {
+		opts = append(opts, withEnvCompression(c))
+	} else if c, ok := e.getEnvValue("COMPRESSION"); ok {
+		opts = append(opts, withEnvCompression(c))
 	}
 	// Timeout
 	if t, ok := e.getEnvValue("TIMEOUT"); ok {
-		if d, err := strconv.Atoi(t); err == nil {
-			opts = append(opts, WithTimeout(time.Duration(d)*time.Millisecond))
-		}
+		opts = append(opts, withEnvTimeout("TIMEOUT", t))
 	}
-	if t, ok := e.getEnvValue("TRACES_TIMEOUT"); ok {
-		if d, err := strconv.Atoi(t); err == nil {
-			opts = append(opts, WithTimeout(time.Duration(d)*time.Millisecond))
-		}
+	if t, ok := e.getEnvValue(e.signalKey("TIMEOUT")); ok {
+		opts = append(opts, withEnvTimeout(e.signalKey("TIMEOUT"), t))
 	}
 
 	return opts
 }
 
+// withEnvTimeout returns an option setting the timeout read from the
+// environment variable key. A bare integer is a number of milliseconds,
+// anything else must be a duration as accepted by time.ParseDuration. Invalid
+// and negative values are recorded as configuration errors and ignored.
+func withEnvTimeout(key, value string) GenericOption {
+	var d time.Duration
+	if ms, err := strconv.ParseInt(value, 10, 64); err == nil {
+		d = time.Duration(ms) * time.Millisecond
+	} else if d, err = time.ParseDuration(value); err != nil {
+		return withError(fmt.Errorf("invalid OTEL_EXPORTER_OTLP_%s value %q, ignoring it: %w", key, value, err))
+	}
+	if d < 0 {
+		return withError(fmt.Errorf("invalid OTEL_EXPORTER_OTLP_%s value %q, ignoring it: must not be negative", key, value))
+	}
+	return WithTimeout(d)
+}
+
+// withEnvInsecure returns an option setting the transport security read from
+// the environment variable key, true, false, 1 or 0 regardless of case. It
+// takes precedence over the scheme of the endpoint read from the environment,
+// but not over the scheme of an endpoint set with WithEndpoint or
+// WithEndpointURL. Invalid values are recorded as configuration errors and
+// ignored.
+func withEnvInsecure(key, value string) GenericOption {
+	var insecure bool
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "true", "1":
+		insecure = true
+	case "false", "0":
+	default:
+		return withError(fmt.Errorf("invalid OTEL_EXPORTER_OTLP_%s value %q, ignoring it: must be true or false", key, value))
+	}
+	return newGenericOption(func(cfg *Config) {
+		cfg.Traces.Insecure = insecure
+		cfg.Traces.insecureFromEnv = true
+	})
+}
+
+// withEnvEndpoint sets the endpoint read from the environment and infers
+// transport security from its scheme. For HTTP, the default path of the
+// signal is appended to the URL path of a base endpoint, while the URL path of
+// a signal specific endpoint is used as is. The default path is used if the
+// endpoint has no URL path.
+func withEnvEndpoint(endpoint string, base bool, defaultPath string) GenericOption {
+	scheme, _ := splitEndpointScheme(endpoint)
+	insecure := isInsecureEndpoint(endpoint)
+	endpoint = trimSchema(endpoint)
+	setScheme := func(cfg *Config) string {
+		cfg.Traces.endpointScheme = scheme
+		if !cfg.Traces.insecureSet {
+			cfg.Traces.Insecure = insecure
+		}
+		return setEndpointCredentials(cfg, endpoint)
+	}
+	return newSplitOption(func(cfg *Config) {
+		host, urlPath := splitEndpointPath(setScheme(cfg))
+		cfg.Traces.Endpoint = host
+		switch {
+		case base:
+			cfg.Traces.URLPath = path.Join("/", urlPath, defaultPath)
+		case urlPath == "" || urlPath == "/":
+			cfg.Traces.URLPath = defaultPath
+		default:
+			cfg.Traces.URLPath = urlPath
+		}
+	}, func(cfg *Config) {
+		cfg.Traces.Endpoint = setScheme(cfg)
+	})
+}
+
 func isInsecureEndpoint(endpoint string) bool {
-	return strings.HasPrefix(strings.ToLower(endpoint), "http://") || strings.HasPrefix(strings.ToLower(endpoint), "unix://")
+	return strings.HasPrefix(strings.ToLower(endpoint), "http://") || IsUnixEndpoint(endpoint)
 }
 
 func trimSchema(endpoint string) string {
@@ -159,13 +300,111 @@ func (e *EnvOptionsReader) readTLSConfig(path string) (*tls.Config, error) {
 	return CreateTLSConfig(b)
 }
 
-func stringToCompression(value string) Compression {
+// withEnvCompression returns an option setting the compression named by
+// value. The gRPC driver accepts any compressor registered with
+// google.golang.org/grpc/encoding, the HTTP driver only the ones defined by
+// Compression.
+func withEnvCompression(value string) GenericOption {
+	return newSplitOption(func(cfg *Config) {
+		c, err := stringToCompression(value)
+		if err != nil {
+			cfg.handleError(err)
+		}
+		WithCompression(c).ApplyHTTPOption(cfg)
+	}, func(cfg *Config) {
+		WithGRPCCompressor(value).ApplyGRPCOption(cfg)
+	})
+}
+
+// clientCertificateOption returns an option setting the client certificate
+// whose certificate and key files are located at the paths held by the
+// certKey and keyKey environment variables, or recording the error if only
+// one of them is set or the certificate cannot be loaded. The returned bool is
+// false if neither variable is set.
+func (e *EnvOptionsReader) clientCertificateOption(certKey, keyKey string) (GenericOption, bool) {
+	certPath, certOk := e.getEnvValue(certKey)
+	keyPath, keyOk := e.getEnvValue(keyKey)
+	switch {
+	case !certOk && !keyOk:
+		return nil, false
+	case !keyOk:
+		return withError(fmt.Errorf("failed to configure otlp exporter client certificate: OTEL_EXPORTER_OTLP_%s is set but OTEL_EXPORTER_OTLP_%s is not", certKey, keyKey)), true
+	case !certOk:
+		return withError(fmt.Errorf("failed to configure otlp exporter client certificate: OTEL_EXPORTER_OTLP_%s is set but OTEL_EXPORTER_OTLP_%s is not", keyKey, certKey)), true
+	}
+
+	cert, err := e.readClientCertificate(certPath, keyPath)
+	if err != nil {
+		return withError(fmt.Errorf("failed to configure otlp exporter client certificate '%s' and key '%s': %w", certPath, keyPath, err)), true
+	}
+	return withClientCertificate(cert), true
+}
+
+func (e *EnvOptionsReader) readClientCertificate(certPath, keyPath string) (tls.Certificate, error) {
+	certPEM, err := e.ReadFile(certPath)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	keyPEM, err := e.ReadFile(keyPath)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.X509KeyPair(certPEM, keyPEM)
+}
+
+// stringToCompression returns the compression named by value, or no
+// compression and an error if value is unknown.
+func stringToCompression(value string) (Compression, error) {
 	switch value {
-	case "gzip":
-		return GzipCompression
+	case GzipCompression.name():
+		return GzipCompression, nil
+	case ZstdCompression.name():
+		return ZstdCompression, nil
+	case "none":
+		return NoCompression, nil
+	}
+
+	return NoCompression, fmt.Errorf("invalid compression type: '%s', using no compression as default", value)
+}
+
+// readHeadersFile reads the headers held by the file at path, one key=value
+// pair per line. Empty lines and lines starting with # are ignored. Keys and
+// values are trimmed of spaces but are not URL decoded.
+func (e *EnvOptionsReader) readHeadersFile(path string) (map[string]string, error) {
+	b, err := e.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	headers := make(map[string]string)
+	for i, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		nameValue := strings.SplitN(line, "=", 2)
+		name := strings.TrimSpace(nameValue[0])
+		if len(nameValue) < 2 || name == "" {
+			return nil, fmt.Errorf("invalid header on line %d: must be key=value", i+1)
+		}
+		headers[name] = strings.TrimSpace(nameValue[1])
 	}
+	return headers, nil
+}
 
-	return NoCompression
+// withMergedHeaders adds headers to the configured ones, replacing the ones
+// with the same keys.
+func withMergedHeaders(headers map[string]string) GenericOption {
+	return newGenericOption(func(cfg *Config) {
+		merged := make(map[string]string, len(cfg.Traces.Headers)+len(headers))
+		for k, v := range cfg.Traces.Headers {
+			merged[k] = v
+		}
+		for k, v := range headers {
+			merged[k] = v
+		}
+		cfg.Traces.Headers = merged
+	})
 }
 
 func stringToHeader(value string) map[string]string {
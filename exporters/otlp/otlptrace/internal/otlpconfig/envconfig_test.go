@@ -44,6 +44,21 @@ func TestStringToHeader(t *testing.T) {
 				"isProduction": "false",
 			},
 		},
+		{
+			name:  "encoded comma in value",
+			value: "authorization=token%2Cwith%2Ccommas,userId=alice",
+			want: map[string]string{
+				"authorization": "token,with,commas",
+				"userId":        "alice",
+			},
+		},
+		{
+			name:  "encoded equals signs in value",
+			value: "authorization=Basic%20dXNlcjpwYXNz%3D%3D",
+			want: map[string]string{
+				"authorization": "Basic dXNlcjpwYXNz==",
+			},
+		},
 		{
 			name:  "invalid headers format",
 			value: "userId:alice",
@@ -15,25 +15,64 @@
 package otlpconfig // import "go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/otlpconfig"
 
 import (
+	"context"
+	"crypto/rand"
 	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
+	"unicode"
 
+	"golang.org/x/net/proxy"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/metadata"
 
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/batchlimit"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/circuitbreaker"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/coalesce"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/exportqueue"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/retry"
+	"go.opentelemetry.io/otel/metric"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
 )
 
 const (
 	// DefaultTracesPath is a default URL path for endpoint that
 	// receives spans.
 	DefaultTracesPath string = "/v1/traces"
+	// DefaultMetricsPath is a default URL path for endpoint that
+	// receives metrics.
+	DefaultMetricsPath string = "/v1/metrics"
 	// DefaultTimeout is a default max waiting time for the backend to process
 	// each span batch.
 	DefaultTimeout time.Duration = 10 * time.Second
+	// DefaultReconnectionPeriod is the default delay between attempts of
+	// the gRPC driver to re-establish a lost connection.
+	DefaultReconnectionPeriod time.Duration = 10 * time.Second
+	// DefaultHealthCheckPath is the default URL path the HTTP driver
+	// checks the health of the collector at.
+	DefaultHealthCheckPath string = "/healthz"
 )
 
+// DefaultUserAgent is the default user agent identifying the exporter to the
+// collector.
+var DefaultUserAgent = "OTel-OTLP-Exporter-Go/" + otlptrace.Version()
+
 type (
 	SignalConfig struct {
 		Endpoint    string
@@ -41,27 +80,247 @@ type (
 		TLSCfg      *tls.Config
 		Headers     map[string]string
 		Compression Compression
+		Marshaler   Marshaler
 		Timeout     time.Duration
 		URLPath     string
 
+		// insecureSet is true if Insecure was set explicitly, in which
+		// case it is not inferred from the scheme of the endpoint.
+		insecureSet bool
+		// insecureFromEnv is true if Insecure was set by the insecure
+		// environment variables, which take precedence over the scheme of
+		// the endpoint read from the environment but not over the one of
+		// an endpoint set with an option.
+		insecureFromEnv bool
+		// endpointScheme is the scheme the endpoint was set with, if any.
+		endpointScheme string
+
+		// UserAgent identifies the exporter to the collector. If empty,
+		// the default of the HTTP or gRPC library is used.
+		UserAgent string
+
+		// Authorization is the value of the authorization header sent
+		// with each export, if not empty.
+		Authorization string
+
+		// HeadersFunc returns headers computed for each export. They
+		// take precedence over Headers.
+		HeadersFunc func(context.Context) (map[string]string, error)
+
+		// IdempotencyKeyHeader, if not empty, is the header carrying
+		// the idempotency key generated for each export request.
+		IdempotencyKeyHeader string
+
+		// PartialSuccessHandler is called when the server accepts a
+		// batch but rejects some of its spans.
+		PartialSuccessHandler func(rejected int64, msg string)
+
+		// PayloadSizeObserver, if set, is called with the size of each
+		// export request once serialized.
+		PayloadSizeObserver func(otlptrace.PayloadSize)
+
+		// ExportInterceptors intercept each export request, the first
+		// one being the outermost.
+		ExportInterceptors []otlptrace.ExportInterceptor
+
+		// ResourceAttributes are added to the resource of the exported
+		// spans. OverrideResourceAttributes tells if they replace the
+		// resource attributes with the same keys.
+		ResourceAttributes         []*commonpb.KeyValue
+		OverrideResourceAttributes bool
+
+		// SpanTransforms modify a copy of each exported span, in order,
+		// before it is sent.
+		SpanTransforms []func(*tracepb.Span)
+
+		// StrictValidation tells if the malformed spans are dropped
+		// before being sent.
+		StrictValidation bool
+
+		// ExportSampler, if set, decides which spans are sent, the
+		// others are dropped.
+		ExportSampler func(*tracepb.Span) bool
+
+		// StableOrdering tells if the spans are sorted in a stable order
+		// before being sent.
+		StableOrdering bool
+
 		// gRPC configurations
 		GRPCCredentials credentials.TransportCredentials
+		// GRPCCompressor is the name of the compressor, registered with
+		// google.golang.org/grpc/encoding, used by the gRPC driver. An
+		// empty string means no compression.
+		GRPCCompressor string
+
+		// Proxy, if set, selects the proxy used by the HTTP driver for
+		// each request instead of the proxy environment variables.
+		Proxy func(*http.Request) (*url.URL, error)
+		// HTTPClient, if set, is used by the HTTP driver to send
+		// requests instead of a client built from the configuration.
+		HTTPClient *http.Client
+		// H2C makes the HTTP driver send the requests with HTTP/2 over
+		// cleartext connections. It requires Insecure.
+		H2C bool
 	}
 
 	Config struct {
 		// Signal specific configurations
 		Traces SignalConfig
+		// Metrics is only set by an EnvOptionsReader reading the
+		// variables of MetricsSignal, for the metrics exporters sharing
+		// this configuration.
+		Metrics SignalConfig
 
 		RetryConfig retry.Config
+		// RetryableErrorFunc, if set, decides which export errors are
+		// retried instead of the default classification.
+		RetryableErrorFunc func(error) bool
+		// BackoffStrategy, if set, decides the delays between the retries
+		// instead of the exponential backoff of RetryConfig.
+		BackoffStrategy retry.Strategy
+
+		// CircuitBreaker configures failing exports fast after repeated
+		// failures.
+		CircuitBreaker circuitbreaker.Config
+
+		// BatchLimit configures the limits of the export requests.
+		BatchLimit batchlimit.Config
+
+		// ExportQueue configures holding the failed exports to retry
+		// them later.
+		ExportQueue exportqueue.Config
+
+		// ErrorHistory is the number of the last errors returned by the
+		// exports kept to be inspected.
+		ErrorHistory int
+
+		// Coalescing configures merging the exports arriving within a
+		// short window into a single upload.
+		Coalescing coalesce.Config
+
+		// MaxConcurrentExports, if positive, is the maximum number of
+		// exports uploaded concurrently.
+		MaxConcurrentExports int
+
+		// StatsLogInterval, if positive, is the interval a summary of
+		// the exports is sent to the error handler at.
+		StatsLogInterval time.Duration
+
+		// DrainTimeout, if positive, is the minimum time the in-flight
+		// exports are given to complete when the drivers are stopped,
+		// even once the context passed to Stop is done.
+		DrainTimeout time.Duration
+
+		// DialTimeout, if positive, bounds the establishment of the
+		// connections to the collector. Traces.Timeout is used
+		// otherwise.
+		DialTimeout time.Duration
+
+		// TLSHandshakeTimeout and ResponseHeaderTimeout, if positive,
+		// bound the TLS handshakes with the collector and the wait for
+		// the headers of its responses by the HTTP driver.
+		// Traces.Timeout is used otherwise.
+		TLSHandshakeTimeout   time.Duration
+		ResponseHeaderTimeout time.Duration
 
 		// gRPC configurations
-		ReconnectionPeriod time.Duration
-		ServiceConfig      string
-		DialOptions        []grpc.DialOption
-		GRPCConn           *grpc.ClientConn
+		ReconnectionPeriod      time.Duration
+		DisableReconnect        bool
+		ServiceConfig           string
+		DialOptions             []grpc.DialOption
+		GRPCConn                *grpc.ClientConn
+		ConnectionStateCallback func(old, new ConnectionState)
+		// ServiceConfigFile is the service config read by
+		// WithServiceConfigFile, used unless ServiceConfig is set.
+		ServiceConfigFile string
+		// KeepaliveParams, if set, enables the keepalive pings of the
+		// gRPC driver connection.
+		KeepaliveParams *keepalive.ClientParameters
+		// SOCKS5Proxy, if set, dials the gRPC driver connections to the
+		// collector through a SOCKS5 proxy.
+		SOCKS5Proxy proxy.ContextDialer
+		// MaxReconnectAttempts and MaxReconnectElapsed, if positive,
+		// limit the attempts and the time spent re-establishing a
+		// lost connection.
+		MaxReconnectAttempts int
+		MaxReconnectElapsed  time.Duration
+		// Endpoints, if set, are the endpoints the gRPC driver fails over
+		// between, the first one being Traces.Endpoint.
+		Endpoints []string
+		// EndpointFile, if set, is the path of the file the gRPC driver
+		// reads the endpoint of the collector from, at start and then
+		// periodically.
+		EndpointFile string
+		// MaxCallSendMsgSize and MaxCallRecvMsgSize, if positive, are
+		// the maximum sizes of the messages sent and received by the
+		// gRPC driver exports.
+		MaxCallSendMsgSize int
+		MaxCallRecvMsgSize int
+		// StartupProbe makes the drivers start only once the collector
+		// is reachable: the gRPC connection is ready or a TCP
+		// connection to the HTTP endpoint succeeded.
+		StartupProbe bool
+		// ResolveOnStart makes the drivers fail to start if the host of
+		// the endpoint cannot be resolved.
+		ResolveOnStart bool
+		// StartTimeout, if positive, bounds the first connection of the
+		// gRPC driver, which connects on export instead of in the
+		// background if it is not ready in time.
+		StartTimeout time.Duration
+		// ResponseMetadataHandler, if set, is called with the header and
+		// trailer metadata of the responses to the gRPC driver exports.
+		ResponseMetadataHandler func(metadata.MD)
+		// HealthCheckService is the name of the service the gRPC driver
+		// checks the health of, the empty name standing for the whole
+		// collector.
+		HealthCheckService string
+		// HealthCheckPath is the URL path the HTTP driver checks the
+		// health of the collector at.
+		HealthCheckPath string
+
+		// MeterProvider, if set, is used to report metrics about the
+		// exports themselves.
+		MeterProvider metric.MeterProvider
+
+		// ErrorHandler, if set, handles the errors of the drivers instead
+		// of the global error handler.
+		ErrorHandler otel.ErrorHandler
+
+		// errs are the failures of the options applied, see Errors.
+		errs []error
 	}
 )
 
+// handleError records err, the failure of an option applied to c. The option
+// is expected to have no effect.
+func (c *Config) handleError(err error) {
+	c.errs = append(c.errs, err)
+}
+
+// Errors returns the failures of the options applied to c, in order. The
+// clients send them to the global error handler, unless they are returned to
+// the caller.
+func (c *Config) Errors() []error {
+	return c.errs
+}
+
+// EffectiveErrorHandler returns the handler of the errors of the drivers: the
+// configured ErrorHandler, or the global error handler if none is set.
+func (c *Config) EffectiveErrorHandler() otel.ErrorHandler {
+	if c.ErrorHandler != nil {
+		return c.ErrorHandler
+	}
+	return otel.ErrorHandlerFunc(otel.Handle)
+}
+
+// withError returns an option recording err, the failure of an option that
+// cannot be applied.
+func withError(err error) GenericOption {
+	return newGenericOption(func(cfg *Config) {
+		cfg.handleError(err)
+	})
+}
+
 func NewDefaultConfig() Config {
 	c := Config{
 		Traces: SignalConfig{
@@ -69,8 +328,17 @@ func NewDefaultConfig() Config {
 			URLPath:     DefaultTracesPath,
 			Compression: NoCompression,
 			Timeout:     DefaultTimeout,
+			UserAgent:   DefaultUserAgent,
 		},
-		RetryConfig: retry.DefaultConfig,
+		Metrics: SignalConfig{
+			Endpoint:    fmt.Sprintf("%s:%d", DefaultCollectorHost, DefaultCollectorPort),
+			URLPath:     DefaultMetricsPath,
+			Compression: NoCompression,
+			Timeout:     DefaultTimeout,
+			UserAgent:   DefaultUserAgent,
+		},
+		RetryConfig:     retry.DefaultConfig,
+		HealthCheckPath: DefaultHealthCheckPath,
 	}
 
 	return c
@@ -182,15 +450,644 @@ func NewGRPCOption(fn func(cfg *Config)) GRPCOption {
 
 // Generic Options
 
+// WithEndpoint sets the endpoint of the collector. For HTTP, a URL path
+// included in endpoint replaces the URL path of the requests. If endpoint
+// starts with an http:// or https:// scheme, the scheme is removed and, unless
+// it was set explicitly with WithInsecure or WithSecure, transport security is
+// inferred from it. User info included in endpoint is removed and sent as
+// basic authorization credentials. For gRPC, an endpoint with the unix scheme
+// is the address of a Unix domain socket and implies an insecure connection.
 func WithEndpoint(endpoint string) GenericOption {
-	return newGenericOption(func(cfg *Config) {
-		cfg.Traces.Endpoint = endpoint
+	setScheme := func(cfg *Config) string {
+		scheme, rest := splitEndpointScheme(endpoint)
+		cfg.Traces.endpointScheme = scheme
+		if scheme != "" && !cfg.Traces.insecureSet {
+			cfg.Traces.Insecure = scheme == "http"
+			cfg.Traces.insecureFromEnv = false
+		}
+		return setEndpointCredentials(cfg, rest)
+	}
+	return newSplitOption(func(cfg *Config) {
+		host, urlPath := splitEndpointPath(setScheme(cfg))
+		cfg.Traces.Endpoint = host
+		if urlPath != "" && urlPath != "/" {
+			cfg.Traces.URLPath = urlPath
+		}
+	}, func(cfg *Config) {
+		cfg.Endpoints = nil
+		cfg.Traces.Endpoint = setScheme(cfg)
+		if IsUnixEndpoint(cfg.Traces.Endpoint) && !cfg.Traces.insecureSet {
+			// Unix domain sockets are local, TLS is not needed.
+			cfg.Traces.Insecure = true
+			cfg.Traces.insecureFromEnv = false
+		}
+	})
+}
+
+// WithEndpointURL sets the endpoint of the collector to the host and port of
+// u, inferring transport security from its http or https scheme unless it was
+// set explicitly with WithInsecure or WithSecure. For HTTP, the URL path of u
+// is the URL path of the requests, the default one being used if it has none.
+// The user info of u, if any, is sent as basic authorization credentials. Its
+// query and fragment are ignored. A nil URL, a URL with another scheme or
+// without a host is invalid: an error is sent to the global error handler and
+// the option has no effect.
+func WithEndpointURL(u *url.URL) GenericOption {
+	setURL := func(cfg *Config) bool {
+		switch {
+		case u == nil:
+			cfg.handleError(errors.New("invalid nil endpoint URL, ignoring it"))
+			return false
+		case u.Scheme != "http" && u.Scheme != "https":
+			cfg.handleError(fmt.Errorf("invalid endpoint URL %q, ignoring it: the scheme must be http or https", u.Redacted()))
+			return false
+		case u.Host == "":
+			cfg.handleError(fmt.Errorf("invalid endpoint URL %q, ignoring it: it has no host", u.Redacted()))
+			return false
+		}
+		cfg.Traces.Endpoint = u.Host
+		cfg.Traces.endpointScheme = u.Scheme
+		if u.User != nil {
+			password, _ := u.User.Password()
+			WithBasicAuth(u.User.Username(), password).ApplyHTTPOption(cfg)
+		}
+		if !cfg.Traces.insecureSet {
+			cfg.Traces.Insecure = u.Scheme == "http"
+			cfg.Traces.insecureFromEnv = false
+		}
+		return true
+	}
+	return newSplitOption(func(cfg *Config) {
+		if !setURL(cfg) {
+			return
+		}
+		cfg.Traces.URLPath = DefaultTracesPath
+		if u.Path != "" && u.Path != "/" {
+			cfg.Traces.URLPath = u.Path
+		}
+	}, func(cfg *Config) {
+		if setURL(cfg) {
+			cfg.Endpoints = nil
+		}
+	})
+}
+
+// WithEndpoints sets the endpoints the gRPC driver fails over between, in
+// order. The first endpoint is set as with WithEndpoint, including the
+// transport security inferred from its scheme. The user info of the other
+// endpoints sets the authorization credentials as it does for the first one,
+// the credentials being sent to all the endpoints: invalid user info, or
+// credentials other than the ones of a previous endpoint, are sent to the
+// global error handler and ignored. An empty list is invalid: an error is
+// sent to the global error handler and the endpoints are left unchanged.
+func WithEndpoints(endpoints []string) GRPCOption {
+	return NewGRPCOption(func(cfg *Config) {
+		if len(endpoints) == 0 {
+			cfg.handleError(errors.New("invalid empty list of endpoints, ignoring it"))
+			return
+		}
+		WithEndpoint(endpoints[0]).ApplyGRPCOption(cfg)
+		if len(endpoints) == 1 {
+			return
+		}
+		cfg.Endpoints = make([]string, len(endpoints))
+		var authorization string
+		for i, e := range endpoints {
+			_, rest := splitEndpointScheme(e)
+			auth, rest, err := splitEndpointCredentials(rest)
+			cfg.Endpoints[i] = rest
+			switch {
+			case i == 0:
+				// Its credentials were set by WithEndpoint.
+				if err == nil {
+					authorization = auth
+				}
+			case err != nil:
+				cfg.handleError(fmt.Errorf("%w, ignoring it", err))
+			case auth == "" || auth == authorization:
+			case authorization == "":
+				authorization = auth
+				cfg.Traces.Authorization = auth
+			default:
+				cfg.handleError(fmt.Errorf("endpoint %q has other credentials than a previous endpoint, ignoring them", rest))
+			}
+		}
+	})
+}
+
+// WithEndpointFile sets the path of the file the gRPC driver reads the
+// endpoint of the collector from, instead of using the configured one. An
+// empty path is invalid: an error is sent to the global error handler and the
+// path is left unchanged.
+func WithEndpointFile(path string) GRPCOption {
+	return NewGRPCOption(func(cfg *Config) {
+		if path == "" {
+			cfg.handleError(errors.New("invalid empty endpoint file path, ignoring it"))
+			return
+		}
+		cfg.EndpointFile = path
 	})
 }
 
+// ReadEndpointFile returns the endpoint written in the file at path, with
+// defaultPort added if its host has no port. Surrounding white space is
+// ignored. An error is returned if the file cannot be read, is empty, or
+// holds more than an endpoint or an endpoint with an invalid port.
+func ReadEndpointFile(path string, defaultPort uint16) (string, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	endpoint := strings.TrimSpace(string(b))
+	switch {
+	case endpoint == "":
+		return "", fmt.Errorf("%s: no endpoint", path)
+	case strings.IndexFunc(endpoint, unicode.IsSpace) >= 0:
+		return "", fmt.Errorf("%s: invalid endpoint %q: it contains white space", path, endpoint)
+	}
+	normalized, err := normalizeEndpoint(endpoint, defaultPort)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", path, err)
+	}
+	return normalized, nil
+}
+
+// IsUnixEndpoint returns true if endpoint is the address of a Unix domain
+// socket, in the unix:path or unix:///absolute/path form.
+func IsUnixEndpoint(endpoint string) bool {
+	return len(endpoint) >= len("unix:") && strings.EqualFold(endpoint[:len("unix:")], "unix:")
+}
+
+// SRVRecordName returns the name of the DNS SRV record listing the
+// collectors, and true, if endpoint is in the srv://name form.
+func SRVRecordName(endpoint string) (string, bool) {
+	const prefix = "srv://"
+	if len(endpoint) < len(prefix) || !strings.EqualFold(endpoint[:len(prefix)], prefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(endpoint[len(prefix):], "/"), true
+}
+
+// ResolveEndpoint looks the host of endpoint, or its SRV record, up with ctx,
+// unless endpoint is the address of a Unix domain socket or its host an IP
+// address, and returns an error wrapping the failure if it cannot be
+// resolved. A gRPC target scheme, as in dns:///collector:4317, is ignored.
+func ResolveEndpoint(ctx context.Context, endpoint string) error {
+	if IsUnixEndpoint(endpoint) {
+		return nil
+	}
+	if name, ok := SRVRecordName(endpoint); ok {
+		if _, _, err := net.DefaultResolver.LookupSRV(ctx, "", "", name); err != nil {
+			return fmt.Errorf("failed to resolve the SRV record %s: %w", name, err)
+		}
+		return nil
+	}
+	host := endpoint
+	if i := strings.LastIndex(host, "/"); i >= 0 {
+		host = host[i+1:]
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if net.ParseIP(host) != nil {
+		return nil
+	}
+	if _, err := net.DefaultResolver.LookupHost(ctx, host); err != nil {
+		return fmt.Errorf("failed to resolve the endpoint %s: %w", endpoint, err)
+	}
+	return nil
+}
+
+// splitEndpointScheme splits endpoint into its lower-cased http or https
+// scheme, if any, and the rest of the endpoint.
+func splitEndpointScheme(endpoint string) (scheme, rest string) {
+	for _, s := range []string{"http", "https"} {
+		prefix := s + "://"
+		if len(endpoint) >= len(prefix) && strings.EqualFold(endpoint[:len(prefix)], prefix) {
+			return s, endpoint[len(prefix):]
+		}
+	}
+	return "", endpoint
+}
+
+// splitEndpointCredentials removes the user info from the authority of the
+// schemeless endpoint and returns it as the value of a basic authorization
+// header, empty if there is none. The user info is removed even if it is
+// invalid, in which case an error is also returned.
+func splitEndpointCredentials(endpoint string) (authorization, rest string, err error) {
+	if IsUnixEndpoint(endpoint) {
+		return "", endpoint, nil
+	}
+	authority, _ := splitEndpointPath(endpoint)
+	i := strings.LastIndex(authority, "@")
+	if i < 0 {
+		return "", endpoint, nil
+	}
+	userInfo, rest := authority[:i], endpoint[i+1:]
+	if i == len(authority)-1 {
+		return "", rest, errors.New("invalid endpoint: it has no host after the user info")
+	}
+
+	user, password := userInfo, ""
+	if j := strings.Index(userInfo, ":"); j >= 0 {
+		user, password = userInfo[:j], userInfo[j+1:]
+	}
+	if user, err = url.PathUnescape(user); err != nil {
+		return "", rest, fmt.Errorf("invalid user info in endpoint %q: %w", rest, err)
+	}
+	if password, err = url.PathUnescape(password); err != nil {
+		return "", rest, fmt.Errorf("invalid user info in endpoint %q: %w", rest, err)
+	}
+	cred := base64.StdEncoding.EncodeToString([]byte(user + ":" + password))
+	return "Basic " + cred, rest, nil
+}
+
+// setEndpointCredentials removes the user info from the schemeless endpoint
+// and sets it as the authorization credentials of cfg. Invalid user info is
+// reported to the global error handler and ignored.
+func setEndpointCredentials(cfg *Config, endpoint string) string {
+	authorization, rest, err := splitEndpointCredentials(endpoint)
+	if err != nil {
+		cfg.handleError(fmt.Errorf("%w, ignoring it", err))
+	} else if authorization != "" {
+		cfg.Traces.Authorization = authorization
+	}
+	return rest
+}
+
+// splitEndpointPath splits a schemeless endpoint into its host and URL path.
+func splitEndpointPath(endpoint string) (host, urlPath string) {
+	if i := strings.Index(endpoint, "/"); i >= 0 {
+		return endpoint[:i], endpoint[i:]
+	}
+	return endpoint, ""
+}
+
+// WithCompression sets the compression of the sent data. For the gRPC driver
+// it is applied with WithGRPCCompressor, so a compression whose compressor is
+// not registered with gRPC is reported and disabled.
 func WithCompression(compression Compression) GenericOption {
-	return newGenericOption(func(cfg *Config) {
+	return newSplitOption(func(cfg *Config) {
 		cfg.Traces.Compression = compression
+	}, func(cfg *Config) {
+		WithGRPCCompressor(compression.name()).ApplyGRPCOption(cfg)
+	})
+}
+
+// WithMarshal sets the format the HTTP driver encodes export requests in.
+func WithMarshal(m Marshaler) GenericOption {
+	return newGenericOption(func(cfg *Config) {
+		cfg.Traces.Marshaler = m
+	})
+}
+
+// WithGRPCCompressor sets the gRPC driver to compress payloads with the
+// compressor registered with google.golang.org/grpc/encoding as name. The
+// names "" and "none" disable compression. If no compressor is registered
+// for name, an error is sent to the global error handler and compression is
+// disabled.
+func WithGRPCCompressor(name string) GRPCOption {
+	return NewGRPCOption(func(cfg *Config) {
+		cfg.Traces.Compression = NoCompression
+		cfg.Traces.GRPCCompressor = ""
+		if name == "" || name == "none" {
+			return
+		}
+		if encoding.GetCompressor(name) == nil {
+			cfg.handleError(fmt.Errorf("invalid compression type: '%s', using no compression as default", name))
+			return
+		}
+		switch name {
+		case GzipCompression.name():
+			cfg.Traces.Compression = GzipCompression
+		case ZstdCompression.name():
+			cfg.Traces.Compression = ZstdCompression
+		}
+		cfg.Traces.GRPCCompressor = name
+	})
+}
+
+// WithServiceConfig sets the default service config of the gRPC driver
+// connection, in its JSON representation. An empty or malformed service
+// config is invalid: an error is sent to the global error handler and the
+// service config is left unchanged.
+func WithServiceConfig(serviceConfig string) GRPCOption {
+	return NewGRPCOption(func(cfg *Config) {
+		if err := validateServiceConfig(serviceConfig); err != nil {
+			cfg.handleError(fmt.Errorf("invalid gRPC service config %q, ignoring it: %w", serviceConfig, err))
+			return
+		}
+		cfg.ServiceConfig = serviceConfig
+	})
+}
+
+// WithServiceConfigFile sets the default service config of the gRPC driver
+// connection to the JSON representation read from the file at path with the
+// ReadFile function of DefaultEnvOptionsReader. The service config set with
+// WithServiceConfig takes precedence, whatever the order of the options. If
+// the file cannot be read or does not hold a valid service config, an error
+// is sent to the global error handler and the service config read from a
+// file is left unchanged.
+func WithServiceConfigFile(path string) GRPCOption {
+	return NewGRPCOption(func(cfg *Config) {
+		b, err := DefaultEnvOptionsReader.ReadFile(path)
+		if err != nil {
+			cfg.handleError(fmt.Errorf("failed to read the gRPC service config file, ignoring it: %w", err))
+			return
+		}
+		if err := validateServiceConfig(string(b)); err != nil {
+			cfg.handleError(fmt.Errorf("invalid gRPC service config in %s, ignoring it: %w", path, err))
+			return
+		}
+		cfg.ServiceConfigFile = string(b)
+	})
+}
+
+// EffectiveServiceConfig returns the service config of the gRPC driver
+// connection: the one set with WithServiceConfig, or else the one read by
+// WithServiceConfigFile.
+func (c *Config) EffectiveServiceConfig() string {
+	if c.ServiceConfig != "" {
+		return c.ServiceConfig
+	}
+	return c.ServiceConfigFile
+}
+
+// validateServiceConfig returns an error if serviceConfig is not a JSON
+// object.
+func validateServiceConfig(serviceConfig string) error {
+	var obj map[string]interface{}
+	if err := json.Unmarshal([]byte(serviceConfig), &obj); err != nil {
+		return fmt.Errorf("it must be a JSON object: %w", err)
+	}
+	return nil
+}
+
+// WithKeepalive enables the keepalive pings of the gRPC driver connection with
+// params. A negative time or timeout is invalid: an error is sent to the
+// global error handler and the keepalive parameters are left unchanged.
+func WithKeepalive(params keepalive.ClientParameters) GRPCOption {
+	return NewGRPCOption(func(cfg *Config) {
+		if params.Time < 0 || params.Timeout < 0 {
+			cfg.handleError(fmt.Errorf("invalid keepalive parameters %+v, ignoring them: time and timeout must not be negative", params))
+			return
+		}
+		cfg.KeepaliveParams = &params
+	})
+}
+
+// WithSOCKS5Proxy dials the gRPC connections to the collector through the
+// SOCKS5 proxy at addr, authenticating with auth if it is not nil. An empty
+// address is invalid: an error is sent to the global error handler and the
+// connections are dialed directly.
+func WithSOCKS5Proxy(addr string, auth *proxy.Auth) GRPCOption {
+	return NewGRPCOption(func(cfg *Config) {
+		if addr == "" {
+			cfg.handleError(errors.New("empty SOCKS5 proxy address, ignoring it"))
+			return
+		}
+		dialer, err := proxy.SOCKS5("tcp", addr, auth, proxy.Direct)
+		if err != nil {
+			cfg.handleError(fmt.Errorf("invalid SOCKS5 proxy %s, ignoring it: %w", addr, err))
+			return
+		}
+		cfg.SOCKS5Proxy = dialer.(proxy.ContextDialer)
+	})
+}
+
+// WithMaxExportBatchBytes sets the maximum size, in bytes, of the export
+// requests. Zero means no limit. A negative size is invalid: an error is sent
+// to the global error handler and the size is left unchanged.
+func WithMaxExportBatchBytes(n int) GenericOption {
+	return newGenericOption(func(cfg *Config) {
+		if n < 0 {
+			cfg.handleError(fmt.Errorf("invalid maximum export batch size %d, ignoring it: must not be negative", n))
+			return
+		}
+		cfg.BatchLimit.MaxBytes = n
+	})
+}
+
+// WithMaxExportBatchSpans sets the maximum number of spans of the export
+// requests. Zero means no limit. A negative number is invalid: an error is
+// sent to the global error handler and the number is left unchanged.
+func WithMaxExportBatchSpans(n int) GenericOption {
+	return newGenericOption(func(cfg *Config) {
+		if n < 0 {
+			cfg.handleError(fmt.Errorf("invalid maximum export batch span count %d, ignoring it: must not be negative", n))
+			return
+		}
+		cfg.BatchLimit.MaxSpans = n
+	})
+}
+
+// WithDrainOnStop sets the minimum time the in-flight exports are given to
+// complete when the drivers are stopped, instead of being cancelled once the
+// context passed to Stop is done. Zero disables it. A negative timeout is invalid: an
+// error is recorded and the timeout is left unchanged.
+func WithDrainOnStop(timeout time.Duration) GenericOption {
+	return newGenericOption(func(cfg *Config) {
+		if timeout < 0 {
+			cfg.handleError(fmt.Errorf("invalid drain timeout %s, ignoring it: must not be negative", timeout))
+			return
+		}
+		cfg.DrainTimeout = timeout
+	})
+}
+
+// WithErrorHistory sets the number of the last errors returned by the exports
+// kept to be inspected. Zero keeps none. A negative number is invalid: an
+// error is recorded and the number is left unchanged.
+func WithErrorHistory(n int) GenericOption {
+	return newGenericOption(func(cfg *Config) {
+		if n < 0 {
+			cfg.handleError(fmt.Errorf("invalid error history size %d, ignoring it: must not be negative", n))
+			return
+		}
+		cfg.ErrorHistory = n
+	})
+}
+
+// WithExportCoalescing sets the maximum delay the spans of an export are held
+// waiting for other exports to be uploaded with, and the number of spans they
+// are uploaded at without waiting. A zero delay disables coalescing, a zero
+// number of spans does not limit it. A negative delay or number of spans is
+// invalid: an error is recorded and the coalescing is left unchanged.
+func WithExportCoalescing(maxDelay time.Duration, maxSpans int) GenericOption {
+	return newGenericOption(func(cfg *Config) {
+		if maxDelay < 0 {
+			cfg.handleError(fmt.Errorf("invalid export coalescing delay %s, ignoring it: must not be negative", maxDelay))
+			return
+		}
+		if maxSpans < 0 {
+			cfg.handleError(fmt.Errorf("invalid export coalescing size %d, ignoring it: must not be negative", maxSpans))
+			return
+		}
+		cfg.Coalescing = coalesce.Config{MaxDelay: maxDelay, MaxSpans: maxSpans}
+	})
+}
+
+// WithMaxConcurrentExports sets the maximum number of exports uploaded
+// concurrently. Zero does not limit them. A negative number is invalid: an
+// error is recorded and the limit is left unchanged.
+func WithMaxConcurrentExports(n int) GenericOption {
+	return newGenericOption(func(cfg *Config) {
+		if n < 0 {
+			cfg.handleError(fmt.Errorf("invalid maximum concurrent exports %d, ignoring it: must not be negative", n))
+			return
+		}
+		cfg.MaxConcurrentExports = n
+	})
+}
+
+// WithStatsLogInterval sets the interval a summary of the exports is sent to
+// the error handler at. Zero sends none. A negative interval is invalid: an
+// error is recorded and the interval is left unchanged.
+func WithStatsLogInterval(d time.Duration) GenericOption {
+	return newGenericOption(func(cfg *Config) {
+		if d < 0 {
+			cfg.handleError(fmt.Errorf("invalid stats log interval %s, ignoring it: must not be negative", d))
+			return
+		}
+		cfg.StatsLogInterval = d
+	})
+}
+
+// WithErrorHandler sets the handler of the errors of the drivers, used
+// instead of the global error handler. A nil handler restores the global
+// error handler.
+func WithErrorHandler(handler otel.ErrorHandler) GenericOption {
+	return newGenericOption(func(cfg *Config) {
+		cfg.ErrorHandler = handler
+	})
+}
+
+// WithOversizeBatchPolicy sets the handling of the batches of spans exceeding
+// the limits of the export requests. An unknown policy is invalid: an error is
+// sent to the global error handler and the policy is left unchanged.
+func WithOversizeBatchPolicy(policy batchlimit.Policy) GenericOption {
+	return newGenericOption(func(cfg *Config) {
+		switch policy {
+		case batchlimit.Split, batchlimit.Reject, batchlimit.Drop:
+			cfg.BatchLimit.Policy = policy
+		default:
+			cfg.handleError(fmt.Errorf("invalid oversize batch policy %d, ignoring it", policy))
+		}
+	})
+}
+
+// WithMaxCallSendMsgSize sets the maximum size, in bytes, of the messages
+// sent by the gRPC driver exports. Zero means the gRPC default. A negative
+// size is invalid: an error is sent to the global error handler and the size
+// is left unchanged.
+func WithMaxCallSendMsgSize(n int) GRPCOption {
+	return NewGRPCOption(func(cfg *Config) {
+		if n < 0 {
+			cfg.handleError(fmt.Errorf("invalid maximum send message size %d, ignoring it: must not be negative", n))
+			return
+		}
+		cfg.MaxCallSendMsgSize = n
+	})
+}
+
+// WithMaxCallRecvMsgSize sets the maximum size, in bytes, of the messages
+// received by the gRPC driver exports. Zero means the gRPC default. A
+// negative size is invalid: an error is sent to the global error handler and
+// the size is left unchanged.
+func WithMaxCallRecvMsgSize(n int) GRPCOption {
+	return NewGRPCOption(func(cfg *Config) {
+		if n < 0 {
+			cfg.handleError(fmt.Errorf("invalid maximum receive message size %d, ignoring it: must not be negative", n))
+			return
+		}
+		cfg.MaxCallRecvMsgSize = n
+	})
+}
+
+// WithReconnectionPeriod sets the delay between attempts of the gRPC driver
+// to re-establish a lost connection. A zero period selects
+// DefaultReconnectionPeriod. A negative period is invalid: an error is sent to
+// the global error handler and the period is left unchanged.
+func WithReconnectionPeriod(rp time.Duration) GRPCOption {
+	return NewGRPCOption(func(cfg *Config) {
+		if rp < 0 {
+			cfg.handleError(fmt.Errorf("invalid reconnection period: %s, must not be negative", rp))
+			return
+		}
+		cfg.ReconnectionPeriod = rp
+	})
+}
+
+// WithStartTimeout sets the time the gRPC driver waits for its first
+// connection to be ready when it starts. A negative duration is invalid: an
+// error is recorded and the option has no effect.
+func WithStartTimeout(d time.Duration) GRPCOption {
+	return NewGRPCOption(func(cfg *Config) {
+		if d < 0 {
+			cfg.handleError(fmt.Errorf("invalid start timeout %s, ignoring it: must not be negative", d))
+			return
+		}
+		cfg.StartTimeout = d
+	})
+}
+
+// WithResponseMetadataHandler sets the function the gRPC driver calls with
+// the header and trailer metadata of the response to each export attempt.
+func WithResponseMetadataHandler(handler func(metadata.MD)) GRPCOption {
+	return NewGRPCOption(func(cfg *Config) {
+		cfg.ResponseMetadataHandler = handler
+	})
+}
+
+// WithMaxReconnectAttempts sets the number of attempts of the gRPC driver to
+// re-establish a lost connection before giving up. Zero means no limit. A
+// negative number is invalid: an error is sent to the global error handler
+// and the limit is left unchanged.
+func WithMaxReconnectAttempts(n int) GRPCOption {
+	return NewGRPCOption(func(cfg *Config) {
+		if n < 0 {
+			cfg.handleError(fmt.Errorf("invalid maximum reconnection attempts: %d, must not be negative", n))
+			return
+		}
+		cfg.MaxReconnectAttempts = n
+	})
+}
+
+// WithMaxReconnectElapsed sets the time the gRPC driver spends trying to
+// re-establish a lost connection before giving up. Zero means no limit. A
+// negative duration is invalid: an error is sent to the global error handler
+// and the limit is left unchanged.
+func WithMaxReconnectElapsed(d time.Duration) GRPCOption {
+	return NewGRPCOption(func(cfg *Config) {
+		if d < 0 {
+			cfg.handleError(fmt.Errorf("invalid maximum reconnection time: %s, must not be negative", d))
+			return
+		}
+		cfg.MaxReconnectElapsed = d
+	})
+}
+
+// WithHealthCheckService sets the name of the service the gRPC driver checks
+// the health of. The empty name, the default, stands for the whole collector.
+func WithHealthCheckService(serviceName string) GRPCOption {
+	return NewGRPCOption(func(cfg *Config) {
+		cfg.HealthCheckService = serviceName
+	})
+}
+
+// WithHealthCheckPath sets the URL path the HTTP driver checks the health of
+// the collector at, made absolute if it is not. A path with a query or a
+// fragment is invalid: an error is recorded and the path is left unchanged.
+func WithHealthCheckPath(urlPath string) HTTPOption {
+	return NewHTTPOption(func(cfg *Config) {
+		if strings.ContainsAny(urlPath, "?#") {
+			cfg.handleError(fmt.Errorf("invalid health check path %q, ignoring it: query and fragment are not allowed", urlPath))
+			return
+		}
+		if !strings.HasPrefix(urlPath, "/") {
+			urlPath = "/" + urlPath
+		}
+		cfg.HealthCheckPath = urlPath
 	})
 }
 
@@ -200,40 +1097,553 @@ func WithURLPath(urlPath string) GenericOption {
 	})
 }
 
+// WithRetry sets the retry policy of the exports, RetryConfig. There is no
+// environment variable for it, so it is never shared with the exporters of
+// other signals, each having its own Config.
 func WithRetry(rc retry.Config) GenericOption {
 	return newGenericOption(func(cfg *Config) {
 		cfg.RetryConfig = rc
 	})
 }
 
+// WithRetryableErrorFunc sets the function deciding which export errors are
+// retried.
+func WithRetryableErrorFunc(fn func(error) bool) GenericOption {
+	return newGenericOption(func(cfg *Config) {
+		cfg.RetryableErrorFunc = fn
+	})
+}
+
+// WithBackoffStrategy sets the function deciding the delays between the
+// retries of the exports, and when to give up retrying them.
+func WithBackoffStrategy(strategy func(attempt int, lastErr error) (delay time.Duration, giveUp bool)) GenericOption {
+	return newGenericOption(func(cfg *Config) {
+		cfg.BackoffStrategy = strategy
+	})
+}
+
+// WithCircuitBreaker enables a circuit breaker failing exports fast for the
+// cooldown duration after failureThreshold consecutive export failures. A
+// threshold lower than one or a non-positive cooldown is invalid: an error is
+// sent to the global error handler and the option has no effect.
+func WithCircuitBreaker(failureThreshold int, cooldown time.Duration) GenericOption {
+	return newGenericOption(func(cfg *Config) {
+		if failureThreshold < 1 || cooldown <= 0 {
+			cfg.handleError(fmt.Errorf("invalid circuit breaker failure threshold %d and cooldown %s, ignoring them: both must be positive", failureThreshold, cooldown))
+			return
+		}
+		cfg.CircuitBreaker = circuitbreaker.Config{
+			Enabled:          true,
+			FailureThreshold: failureThreshold,
+			Cooldown:         cooldown,
+		}
+	})
+}
+
+// WithPersistentQueue sets the drivers to persist the exports failing with
+// a retryable error in dir, bounded to maxBytes. An empty directory or a
+// non-positive size is invalid: an error is sent to the global error handler
+// and the option has no effect.
+func WithPersistentQueue(dir string, maxBytes int64) GenericOption {
+	return newGenericOption(func(cfg *Config) {
+		if dir == "" || maxBytes <= 0 {
+			cfg.handleError(fmt.Errorf("invalid persistent queue directory %q and size %d, ignoring them: the directory must be set and the size positive", dir, maxBytes))
+			return
+		}
+		cfg.ExportQueue = exportqueue.Config{Dir: dir, MaxBytes: maxBytes}
+	})
+}
+
+// WithMemoryQueue sets the drivers to hold the exports failing with a
+// retryable error in memory, bounded to maxSpans spans. A non-positive
+// maxSpans is invalid: an error is sent to the global error handler and the
+// option has no effect.
+func WithMemoryQueue(maxSpans int) GenericOption {
+	return newGenericOption(func(cfg *Config) {
+		if maxSpans <= 0 {
+			cfg.handleError(fmt.Errorf("invalid memory queue size %d, ignoring it: it must be positive", maxSpans))
+			return
+		}
+		cfg.ExportQueue = exportqueue.Config{MaxSpans: maxSpans}
+	})
+}
+
 func WithTLSClientConfig(tlsCfg *tls.Config) GenericOption {
 	return newSplitOption(func(cfg *Config) {
 		cfg.Traces.TLSCfg = tlsCfg.Clone()
 	}, func(cfg *Config) {
+		cfg.Traces.TLSCfg = tlsCfg.Clone()
 		cfg.Traces.GRPCCredentials = credentials.NewTLS(tlsCfg)
 	})
 }
 
+// withTLSConfig returns an option applying update to the TLS configuration
+// of the driver, creating an empty one first if none is set yet. The gRPC
+// transport credentials are rebuilt from the updated configuration.
+func withTLSConfig(update func(*tls.Config)) GenericOption {
+	return newSplitOption(func(cfg *Config) {
+		if cfg.Traces.TLSCfg == nil {
+			cfg.Traces.TLSCfg = &tls.Config{MinVersion: DefaultTLSMinVersion}
+		}
+		update(cfg.Traces.TLSCfg)
+	}, func(cfg *Config) {
+		if cfg.Traces.TLSCfg == nil {
+			cfg.Traces.TLSCfg = &tls.Config{MinVersion: DefaultTLSMinVersion}
+		}
+		update(cfg.Traces.TLSCfg)
+		cfg.Traces.GRPCCredentials = credentials.NewTLS(cfg.Traces.TLSCfg)
+	})
+}
+
+// WithTLSClientCertificate sets the client certificate presented to the
+// collector for mutual TLS authentication. The certificate and its private
+// key are PEM encoded. If the pair cannot be parsed, an error is sent to the
+// global error handler and the option has no effect.
+func WithTLSClientCertificate(certPEM, keyPEM []byte) GenericOption {
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return withError(fmt.Errorf("failed to configure otlp exporter client certificate: %w", err))
+	}
+	return withClientCertificate(cert)
+}
+
+// WithTLSConfigFromFiles sets the TLS configuration built from the PEM
+// encoded files at the given paths: the CA certificates verifying the
+// collector and the client certificate and key presented to it. Empty paths
+// are skipped. If a file cannot be read or parsed, an error is sent to the
+// global error handler and the option has no effect.
+func WithTLSConfigFromFiles(caPath, certPath, keyPath string) GenericOption {
+	tlsCfg, err := loadTLSConfig(caPath, certPath, keyPath)
+	if err != nil {
+		return withError(fmt.Errorf("failed to configure otlp exporter TLS from files: %w", err))
+	}
+	return WithTLSClientConfig(tlsCfg)
+}
+
+// WithTLSRootCAs sets the CA certificates verifying the certificate of the
+// collector to the PEM encoded caPEMs, added to a copy of the system cert
+// pool if appendToSystem is true. If the system pool cannot be loaded or a
+// certificate cannot be parsed, an error is recorded and the option has no
+// effect.
+func WithTLSRootCAs(appendToSystem bool, caPEMs ...[]byte) GenericOption {
+	cp, err := createRootCAs(appendToSystem, caPEMs)
+	if err != nil {
+		return withError(fmt.Errorf("failed to configure otlp exporter root CAs: %w", err))
+	}
+	return withTLSConfig(func(tlsCfg *tls.Config) {
+		tlsCfg.RootCAs = cp
+	})
+}
+
+// WithTLSMinVersion sets the minimum TLS version accepted when connecting to
+// the collector. A value that is not a TLS version is invalid: an error is
+// recorded and the option has no effect.
+func WithTLSMinVersion(version uint16) GenericOption {
+	if !isTLSVersion(version) {
+		return withError(fmt.Errorf("invalid TLS minimum version 0x%04x, ignoring it", version))
+	}
+	return withTLSConfig(func(tlsCfg *tls.Config) {
+		tlsCfg.MinVersion = version
+	})
+}
+
+// WithTLSCipherSuites sets the cipher suites offered when connecting to the
+// collector with TLS 1.2 or earlier, the TLS 1.3 cipher suites are not
+// configurable. Empty suites restore the default ones. An unknown cipher
+// suite is invalid: an error is recorded and the option has no effect.
+func WithTLSCipherSuites(suites []uint16) GenericOption {
+	if err := checkCipherSuites(suites); err != nil {
+		return withError(fmt.Errorf("invalid TLS cipher suites, ignoring them: %w", err))
+	}
+	// The suites are copied, empty suites are nil.
+	suites = append([]uint16(nil), suites...)
+	return withTLSConfig(func(tlsCfg *tls.Config) {
+		tlsCfg.CipherSuites = suites
+	})
+}
+
+// WithInsecureSkipVerify disables the verification of the certificate of the
+// collector. The connections still use TLS.
+func WithInsecureSkipVerify() GenericOption {
+	return withTLSConfig(func(tlsCfg *tls.Config) {
+		tlsCfg.InsecureSkipVerify = true
+	})
+}
+
+// TLSVerificationWarning returns the warning the drivers report each time
+// they start if the certificate of the collector is not verified, or nil.
+func (c *SignalConfig) TLSVerificationWarning() error {
+	if c.Insecure || c.TLSCfg == nil || !c.TLSCfg.InsecureSkipVerify {
+		return nil
+	}
+	return fmt.Errorf("WARNING: the TLS certificate of the collector %s is not verified, the exports are exposed to man-in-the-middle attacks: do not skip the verification in production", c.Endpoint)
+}
+
+// WithTLSServerName sets the name the certificate of the collector is verified
+// against, and sent with SNI, instead of the host of the endpoint. An empty
+// name restores the host of the endpoint.
+func WithTLSServerName(name string) GenericOption {
+	return withTLSConfig(func(tlsCfg *tls.Config) {
+		tlsCfg.ServerName = name
+	})
+}
+
+func withClientCertificate(cert tls.Certificate) GenericOption {
+	return withTLSConfig(func(tlsCfg *tls.Config) {
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	})
+}
+
+// WithInsecure explicitly disables transport security. It takes precedence
+// over the security inferred from the scheme of the endpoint.
 func WithInsecure() GenericOption {
 	return newGenericOption(func(cfg *Config) {
 		cfg.Traces.Insecure = true
+		cfg.Traces.insecureSet = true
 	})
 }
 
+// WithSecure explicitly enables transport security. It takes precedence over
+// the security inferred from the scheme of the endpoint.
 func WithSecure() GenericOption {
 	return newGenericOption(func(cfg *Config) {
 		cfg.Traces.Insecure = false
+		cfg.Traces.insecureSet = true
 	})
 }
 
+// Validate returns an error describing the conflict if transport security was
+// set explicitly, with WithInsecure, WithSecure or the insecure environment
+// variables, and disagrees with the scheme of the endpoint. The explicit
+// setting is the one used.
+func (c *Config) Validate() error {
+	switch {
+	case !c.Traces.insecureSet && !c.Traces.insecureFromEnv:
+	case c.Traces.Insecure && c.Traces.endpointScheme == "https":
+		return fmt.Errorf("endpoint %q has the https scheme but transport security is disabled: using an insecure connection", "https://"+c.Traces.Endpoint)
+	case !c.Traces.Insecure && c.Traces.endpointScheme == "http":
+		return fmt.Errorf("endpoint %q has the http scheme but transport security is enabled: using a secure connection", "http://"+c.Traces.Endpoint)
+	}
+	return nil
+}
+
+// NormalizeEndpoints adds defaultPort to the endpoints of c whose host has no
+// port and returns an error for each endpoint whose port is not a number
+// between 1 and 65535, such an endpoint being left unchanged. The addresses
+// of Unix domain sockets, SRV record names and gRPC targets with a scheme, as
+// in dns:///collector:4317, are not host and port pairs and are left
+// unchanged.
+func (c *Config) NormalizeEndpoints(defaultPort uint16) []error {
+	var errs []error
+	normalize := func(endpoint *string) {
+		normalized, err := normalizeEndpoint(*endpoint, defaultPort)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%w, using it unchanged", err))
+			return
+		}
+		*endpoint = normalized
+	}
+	normalize(&c.Traces.Endpoint)
+	for i := range c.Endpoints {
+		normalize(&c.Endpoints[i])
+	}
+	return errs
+}
+
+// normalizeEndpoint returns endpoint with defaultPort added if its host has no
+// port, or an error if its port is invalid.
+func normalizeEndpoint(endpoint string, defaultPort uint16) (string, error) {
+	if endpoint == "" || IsUnixEndpoint(endpoint) || strings.Contains(endpoint, "/") {
+		return endpoint, nil
+	}
+	withDefaultPort := func(host string) string {
+		return net.JoinHostPort(host, strconv.Itoa(int(defaultPort)))
+	}
+	if net.ParseIP(endpoint) != nil {
+		// An IPv6 address without brackets cannot have a port.
+		return withDefaultPort(endpoint), nil
+	}
+	host, port, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		if strings.HasPrefix(endpoint, "[") && strings.HasSuffix(endpoint, "]") {
+			return withDefaultPort(endpoint[1 : len(endpoint)-1]), nil
+		}
+		if !strings.Contains(endpoint, ":") {
+			return withDefaultPort(endpoint), nil
+		}
+		return "", fmt.Errorf("invalid endpoint %q: %w", endpoint, err)
+	}
+	if port == "" {
+		return withDefaultPort(host), nil
+	}
+	if n, err := strconv.ParseUint(port, 10, 16); err != nil || n == 0 {
+		return "", fmt.Errorf("invalid endpoint %q: the port must be a number between 1 and 65535", endpoint)
+	}
+	return endpoint, nil
+}
+
+// EffectiveDialTimeout returns the timeout bounding the establishment of the
+// connections to the collector: DialTimeout if set, the export timeout
+// otherwise.
+func (c *Config) EffectiveDialTimeout() time.Duration {
+	if c.DialTimeout > 0 {
+		return c.DialTimeout
+	}
+	return c.Traces.Timeout
+}
+
+// EffectiveTLSHandshakeTimeout returns the timeout bounding the TLS
+// handshakes of the HTTP driver: TLSHandshakeTimeout if set, the export
+// timeout otherwise.
+func (c *Config) EffectiveTLSHandshakeTimeout() time.Duration {
+	if c.TLSHandshakeTimeout > 0 {
+		return c.TLSHandshakeTimeout
+	}
+	return c.Traces.Timeout
+}
+
+// EffectiveResponseHeaderTimeout returns the timeout bounding the wait of the
+// HTTP driver for the response headers: ResponseHeaderTimeout if set, the
+// export timeout otherwise.
+func (c *Config) EffectiveResponseHeaderTimeout() time.Duration {
+	if c.ResponseHeaderTimeout > 0 {
+		return c.ResponseHeaderTimeout
+	}
+	return c.Traces.Timeout
+}
+
 func WithHeaders(headers map[string]string) GenericOption {
 	return newGenericOption(func(cfg *Config) {
 		cfg.Traces.Headers = headers
 	})
 }
 
+// WithHeadersFunc sets the function computing the headers of each export,
+// which take precedence over the ones set with WithHeaders.
+func WithHeadersFunc(fn func(context.Context) (map[string]string, error)) GenericOption {
+	return newGenericOption(func(cfg *Config) {
+		cfg.Traces.HeadersFunc = fn
+	})
+}
+
+// WithIdempotencyKeys sets the header carrying the idempotency key generated
+// for each export request, see NewIdempotencyKey. An empty header is invalid:
+// an error is recorded and the option has no effect.
+func WithIdempotencyKeys(header string) GenericOption {
+	return newGenericOption(func(cfg *Config) {
+		if strings.TrimSpace(header) == "" {
+			cfg.handleError(errors.New("invalid empty idempotency key header, ignoring it"))
+			return
+		}
+		cfg.Traces.IdempotencyKeyHeader = header
+	})
+}
+
+// NewIdempotencyKey returns a new random version 4 UUID identifying an export
+// request.
+func NewIdempotencyKey() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("failed to generate an idempotency key: %w", err)
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+// WithUserAgent sets the user agent sent with each export.
+func WithUserAgent(userAgent string) GenericOption {
+	return newGenericOption(func(cfg *Config) {
+		cfg.Traces.UserAgent = userAgent
+	})
+}
+
+// WithBearerToken sets token as the bearer token sent in the authorization
+// header of each export.
+func WithBearerToken(token string) GenericOption {
+	return newGenericOption(func(cfg *Config) {
+		cfg.Traces.Authorization = "Bearer " + token
+	})
+}
+
+// WithBasicAuth sets the user and password sent in the authorization header
+// of each export using the basic authentication scheme.
+func WithBasicAuth(user, password string) GenericOption {
+	return newGenericOption(func(cfg *Config) {
+		cred := base64.StdEncoding.EncodeToString([]byte(user + ":" + password))
+		cfg.Traces.Authorization = "Basic " + cred
+	})
+}
+
+// WithTimeout sets the timeout of the trace exports, Traces.Timeout, and no
+// other field: the configurations of other signals, like
+// OTEL_EXPORTER_OTLP_METRICS_TIMEOUT for the metrics, are not affected. The
+// timeout also bounds the connections when DialTimeout is unset.
 func WithTimeout(duration time.Duration) GenericOption {
 	return newGenericOption(func(cfg *Config) {
 		cfg.Traces.Timeout = duration
 	})
 }
+
+// WithDialTimeout sets the timeout bounding the establishment of the
+// connections to the collector, independently of the export timeout. A zero
+// timeout selects the export timeout. A negative timeout is invalid: an error
+// is sent to the global error handler and the timeout is left unchanged.
+func WithDialTimeout(d time.Duration) GenericOption {
+	return newGenericOption(func(cfg *Config) {
+		if d < 0 {
+			cfg.handleError(fmt.Errorf("invalid dial timeout %s, ignoring it: must not be negative", d))
+			return
+		}
+		cfg.DialTimeout = d
+	})
+}
+
+// WithTLSHandshakeTimeout sets the timeout bounding the TLS handshakes of the
+// HTTP driver with the collector. A zero timeout selects the export timeout.
+// A negative timeout is invalid: an error is recorded and the timeout is left
+// unchanged.
+func WithTLSHandshakeTimeout(d time.Duration) HTTPOption {
+	return NewHTTPOption(func(cfg *Config) {
+		if d < 0 {
+			cfg.handleError(fmt.Errorf("invalid TLS handshake timeout %s, ignoring it: must not be negative", d))
+			return
+		}
+		cfg.TLSHandshakeTimeout = d
+	})
+}
+
+// WithResponseHeaderTimeout sets the timeout bounding the wait of the HTTP
+// driver for the headers of the responses of the collector, once a request is
+// written. A zero timeout selects the export timeout. A negative timeout is
+// invalid: an error is recorded and the timeout is left unchanged.
+func WithResponseHeaderTimeout(d time.Duration) HTTPOption {
+	return NewHTTPOption(func(cfg *Config) {
+		if d < 0 {
+			cfg.handleError(fmt.Errorf("invalid response header timeout %s, ignoring it: must not be negative", d))
+			return
+		}
+		cfg.ResponseHeaderTimeout = d
+	})
+}
+
+// WithExportInterceptor adds interceptor after the already added ones.
+func WithExportInterceptor(interceptor otlptrace.ExportInterceptor) GenericOption {
+	return newGenericOption(func(cfg *Config) {
+		if interceptor == nil {
+			return
+		}
+		cfg.Traces.ExportInterceptors = append(cfg.Traces.ExportInterceptors, interceptor)
+	})
+}
+
+// WithBlockingStart makes the drivers start only once the collector is
+// reachable.
+func WithBlockingStart() GenericOption {
+	return newGenericOption(func(cfg *Config) {
+		cfg.StartupProbe = true
+	})
+}
+
+// WithResolveOnStart makes the drivers resolve the host of the endpoint when
+// they start, and fail to start if it cannot be resolved.
+func WithResolveOnStart() GenericOption {
+	return newGenericOption(func(cfg *Config) {
+		cfg.ResolveOnStart = true
+	})
+}
+
+// WithResourceAttributes sets the attributes added to the resource of the
+// exported spans and whether they replace existing ones.
+func WithResourceAttributes(attrs []*commonpb.KeyValue, override bool) GenericOption {
+	return newGenericOption(func(cfg *Config) {
+		cfg.Traces.ResourceAttributes = attrs
+		cfg.Traces.OverrideResourceAttributes = override
+	})
+}
+
+// WithSpanTransform adds transform after the already added ones. Nil
+// transforms are ignored.
+func WithSpanTransform(transform func(*tracepb.Span)) GenericOption {
+	return newGenericOption(func(cfg *Config) {
+		if transform == nil {
+			return
+		}
+		cfg.Traces.SpanTransforms = append(cfg.Traces.SpanTransforms, transform)
+	})
+}
+
+// WithExportSampler sets the function deciding which spans are sent, the
+// spans it returns false for are dropped. Nil sends all the spans.
+func WithExportSampler(sampler func(*tracepb.Span) bool) GenericOption {
+	return newGenericOption(func(cfg *Config) {
+		cfg.Traces.ExportSampler = sampler
+	})
+}
+
+// ChainExportInterceptors returns an invoker calling interceptors in order
+// around invoker.
+func ChainExportInterceptors(interceptors []otlptrace.ExportInterceptor, invoker otlptrace.ExportInvoker) otlptrace.ExportInvoker {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], invoker
+		invoker = func(ctx context.Context, req *coltracepb.ExportTraceServiceRequest) error {
+			return interceptor(ctx, req, next)
+		}
+	}
+	return invoker
+}
+
+// WithStrictValidation makes the drivers drop the malformed spans before
+// sending them.
+func WithStrictValidation() GenericOption {
+	return newGenericOption(func(cfg *Config) {
+		cfg.Traces.StrictValidation = true
+	})
+}
+
+// WithStableOrdering makes the drivers sort the spans of each export request
+// in a stable order before sending it.
+func WithStableOrdering() GenericOption {
+	return newGenericOption(func(cfg *Config) {
+		cfg.Traces.StableOrdering = true
+	})
+}
+
+// WithPartialSuccessHandler sets the function called when the collector
+// accepts an export request but rejects some of its spans.
+func WithPartialSuccessHandler(handler func(rejected int64, msg string)) GenericOption {
+	return newGenericOption(func(cfg *Config) {
+		cfg.Traces.PartialSuccessHandler = handler
+	})
+}
+
+// WithPayloadSizeObserver sets the function called with the number of spans
+// and the serialized size of each export request.
+func WithPayloadSizeObserver(observer func(otlptrace.PayloadSize)) GenericOption {
+	return newGenericOption(func(cfg *Config) {
+		cfg.Traces.PayloadSizeObserver = observer
+	})
+}
+
+// ObservePayloadSize calls observer, if not nil, with the number of spans of
+// req and its serialized size.
+func ObservePayloadSize(observer func(otlptrace.PayloadSize), req *coltracepb.ExportTraceServiceRequest, bytes int, compressed bool) {
+	if observer == nil {
+		return
+	}
+	var spans int
+	for _, rs := range req.GetResourceSpans() {
+		for _, ils := range rs.GetInstrumentationLibrarySpans() {
+			spans += len(ils.GetSpans())
+		}
+	}
+	observer(otlptrace.PayloadSize{Spans: spans, Bytes: bytes, Compressed: compressed})
+}
+
+// WithSelfObservability sets the MeterProvider the drivers report the metrics
+// about the exports themselves with.
+func WithSelfObservability(mp metric.MeterProvider) GenericOption {
+	return newGenericOption(func(cfg *Config) {
+		cfg.MeterProvider = mp
+	})
+}
@@ -14,10 +14,15 @@
 
 package otlpconfig // import "go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/otlpconfig"
 
+import "google.golang.org/grpc/encoding/gzip"
+
 const (
 	// DefaultCollectorPort is the port the Exporter will attempt connect to
 	// if no collector port is provided.
 	DefaultCollectorPort uint16 = 4317
+	// DefaultHTTPCollectorPort is the port the HTTP Exporter will attempt
+	// connect to if the collector endpoint has no port.
+	DefaultHTTPCollectorPort uint16 = 4318
 	// DefaultCollectorHost is the host address the Exporter will attempt
 	// connect to if no collector address is provided.
 	DefaultCollectorHost string = "localhost"
@@ -34,8 +39,26 @@ const (
 	// GzipCompression tells the driver to send payloads after
 	// compressing them with gzip.
 	GzipCompression
+	// ZstdCompression tells the driver to send payloads after
+	// compressing them with zstd.
+	ZstdCompression
 )
 
+// zstdName is the name zstd compressors are registered under.
+const zstdName = "zstd"
+
+// name returns the name of the compressor implementing c, or an empty
+// string for NoCompression.
+func (c Compression) name() string {
+	switch c {
+	case GzipCompression:
+		return gzip.Name
+	case ZstdCompression:
+		return zstdName
+	}
+	return ""
+}
+
 // Marshaler describes the kind of message format sent to the collector
 type Marshaler int
 
@@ -45,3 +68,22 @@ const (
 	// MarshalJSON tells the driver to send using json format.
 	MarshalJSON
 )
+
+// ConnectionState describes the state of the connection to the collector.
+type ConnectionState int
+
+const (
+	// ConnectionStateIdle is the state of a connection that has not
+	// been established yet.
+	ConnectionStateIdle ConnectionState = iota
+	// ConnectionStateConnected is the state of an established
+	// connection.
+	ConnectionStateConnected
+	// ConnectionStateDisconnected is the state of a connection that
+	// failed to be established or that was lost.
+	ConnectionStateDisconnected
+	// ConnectionStateFailed is the terminal state of a connection that
+	// is no longer re-established, once the reconnection limits are
+	// exhausted.
+	ConnectionStateFailed
+)
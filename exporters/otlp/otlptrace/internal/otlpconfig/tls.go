@@ -18,17 +18,113 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"errors"
+	"fmt"
+	"io/ioutil"
 )
 
+// DefaultTLSMinVersion is the minimum TLS version of the TLS configurations
+// built by the options, unless set otherwise.
+const DefaultTLSMinVersion uint16 = tls.VersionTLS12
+
 // CreateTLSConfig creates a tls.Config from a raw certificate bytes
-// to verify a server certificate.
+// to verify a server certificate. All the PEM encoded certificates of
+// certBytes are added, for instance the intermediate and root certificates of
+// a CA bundle. An error is returned if none is found.
 func CreateTLSConfig(certBytes []byte) (*tls.Config, error) {
 	cp := x509.NewCertPool()
 	if ok := cp.AppendCertsFromPEM(certBytes); !ok {
-		return nil, errors.New("failed to append certificate to the cert pool")
+		return nil, errors.New("failed to append certificate to the cert pool: no PEM encoded certificate found")
 	}
 
 	return &tls.Config{
-		RootCAs: cp,
+		RootCAs:    cp,
+		MinVersion: DefaultTLSMinVersion,
 	}, nil
 }
+
+// loadTLSConfig creates a tls.Config verifying the server certificate with
+// the PEM encoded CA certificates of the file at caPath and presenting the
+// client certificate and key of the files at certPath and keyPath. Empty
+// paths are skipped, but the certificate and key paths must be set together.
+func loadTLSConfig(caPath, certPath, keyPath string) (*tls.Config, error) {
+	tlsCfg := &tls.Config{MinVersion: DefaultTLSMinVersion}
+	if caPath != "" {
+		caPEM, err := ioutil.ReadFile(caPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA certificate file: %w", err)
+		}
+		cp := x509.NewCertPool()
+		if ok := cp.AppendCertsFromPEM(caPEM); !ok {
+			return nil, fmt.Errorf("failed to parse CA certificate file %q: no PEM encoded certificate found", caPath)
+		}
+		tlsCfg.RootCAs = cp
+	}
+
+	switch {
+	case certPath == "" && keyPath == "":
+		return tlsCfg, nil
+	case keyPath == "":
+		return nil, fmt.Errorf("client certificate file %q is set without a key file", certPath)
+	case certPath == "":
+		return nil, fmt.Errorf("client key file %q is set without a certificate file", keyPath)
+	}
+	certPEM, err := ioutil.ReadFile(certPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client certificate file: %w", err)
+	}
+	keyPEM, err := ioutil.ReadFile(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client key file: %w", err)
+	}
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse client certificate file %q and key file %q: %w", certPath, keyPath, err)
+	}
+	tlsCfg.Certificates = []tls.Certificate{cert}
+	return tlsCfg, nil
+}
+
+// createRootCAs creates the cert pool verifying the server certificate with
+// the PEM encoded CA certificates caPEMs, added to a copy of the system cert
+// pool if appendToSystem is true.
+func createRootCAs(appendToSystem bool, caPEMs [][]byte) (*x509.CertPool, error) {
+	cp := x509.NewCertPool()
+	if appendToSystem {
+		var err error
+		if cp, err = x509.SystemCertPool(); err != nil {
+			return nil, fmt.Errorf("failed to load the system cert pool: %w", err)
+		}
+	} else if len(caPEMs) == 0 {
+		return nil, errors.New("no CA certificate to verify the server certificate with")
+	}
+	for i, caPEM := range caPEMs {
+		if ok := cp.AppendCertsFromPEM(caPEM); !ok {
+			return nil, fmt.Errorf("failed to append CA certificate %d to the cert pool: no PEM encoded certificate found", i)
+		}
+	}
+	return cp, nil
+}
+
+// isTLSVersion returns whether v is a TLS version.
+func isTLSVersion(v uint16) bool {
+	switch v {
+	case tls.VersionTLS10, tls.VersionTLS11, tls.VersionTLS12, tls.VersionTLS13:
+		return true
+	}
+	return false
+}
+
+// checkCipherSuites returns an error if one of suites is not a cipher suite
+// implemented by crypto/tls.
+func checkCipherSuites(suites []uint16) error {
+	known := make(map[uint16]bool)
+	for _, cs := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		known[cs.ID] = true
+	}
+	for _, id := range suites {
+		if !known[id] {
+			return fmt.Errorf("unknown cipher suite 0x%04x", id)
+		}
+	}
+	return nil
+}
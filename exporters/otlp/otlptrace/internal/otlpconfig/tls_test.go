@@ -0,0 +1,321 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otlpconfig_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/otlpconfig"
+)
+
+// testCA is a CA signing test certificates.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T, name string) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testCA{
+		cert: cert,
+		key:  key,
+		pem:  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	}
+}
+
+// sign returns a certificate for the collector host signed by ca.
+func (ca *testCA) sign(t *testing.T) *x509.Certificate {
+	cert, _ := ca.signKeyPair(t)
+	return cert
+}
+
+// signKeyPair returns a certificate for the collector host signed by ca and
+// its private key.
+func (ca *testCA) signKeyPair(t *testing.T) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "collector"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:     []string{"collector.example.com"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert, key
+}
+
+// verify verifies cert against the root CAs set by opt.
+func verify(opt otlpconfig.GenericOption, cert *x509.Certificate) error {
+	cfg := otlpconfig.NewDefaultConfig()
+	opt.ApplyHTTPOption(&cfg)
+	if errs := cfg.Errors(); len(errs) > 0 {
+		return errs[0]
+	}
+	_, err := cert.Verify(x509.VerifyOptions{
+		Roots:   cfg.Traces.TLSCfg.RootCAs,
+		DNSName: "collector.example.com",
+	})
+	return err
+}
+
+func TestWithTLSRootCAs(t *testing.T) {
+	internal := newTestCA(t, "internal")
+	other := newTestCA(t, "other")
+
+	assert.NoError(t, verify(otlpconfig.WithTLSRootCAs(false, other.pem, internal.pem), internal.sign(t)))
+	assert.Error(t, verify(otlpconfig.WithTLSRootCAs(false, other.pem), internal.sign(t)))
+
+	assert.Error(t, verify(otlpconfig.WithTLSRootCAs(false), internal.sign(t)))
+	assert.Error(t, verify(otlpconfig.WithTLSRootCAs(false, []byte("invalid")), internal.sign(t)))
+
+	// The gRPC credentials are built from the root CAs.
+	cfg := otlpconfig.NewDefaultConfig()
+	otlpconfig.WithTLSRootCAs(false, internal.pem).ApplyGRPCOption(&cfg)
+	require.NotNil(t, cfg.Traces.GRPCCredentials)
+	assert.Equal(t, "tls", cfg.Traces.GRPCCredentials.Info().SecurityProtocol)
+}
+
+// systemRootEnv is set to the PEM encoded certificate signed by the system
+// root in the process running TestWithTLSRootCAsSystemPool.
+const systemRootEnv = "OTLPCONFIG_TEST_SYSTEM_ROOT_SIGNED"
+
+func TestWithTLSRootCAsSystemPool(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("the system roots are only overridden with SSL_CERT_FILE on Linux")
+	}
+
+	if signedPEM := os.Getenv(systemRootEnv); signedPEM != "" {
+		// The system roots are only loaded from SSL_CERT_FILE in this
+		// process.
+		block, _ := pem.Decode([]byte(signedPEM))
+		require.NotNil(t, block)
+		signed, err := x509.ParseCertificate(block.Bytes)
+		require.NoError(t, err)
+		internal := newTestCA(t, "internal")
+
+		assert.NoError(t, verify(otlpconfig.WithTLSRootCAs(true, internal.pem), signed))
+		assert.NoError(t, verify(otlpconfig.WithTLSRootCAs(true, internal.pem), internal.sign(t)))
+		assert.NoError(t, verify(otlpconfig.WithTLSRootCAs(true), signed))
+		assert.Error(t, verify(otlpconfig.WithTLSRootCAs(false, internal.pem), signed))
+
+		// The system pool is not modified.
+		system, err := x509.SystemCertPool()
+		require.NoError(t, err)
+		_, err = internal.sign(t).Verify(x509.VerifyOptions{Roots: system, DNSName: "collector.example.com"})
+		assert.Error(t, err)
+		return
+	}
+
+	root := newTestCA(t, "system root")
+	rootFile := filepath.Join(t.TempDir(), "roots.pem")
+	require.NoError(t, ioutil.WriteFile(rootFile, root.pem, 0600))
+	signed := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: root.sign(t).Raw})
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestWithTLSRootCAsSystemPool$")
+	cmd.Env = append(os.Environ(),
+		"SSL_CERT_FILE="+rootFile,
+		"SSL_CERT_DIR="+t.TempDir(),
+		systemRootEnv+"="+string(signed))
+	out, err := cmd.CombinedOutput()
+	assert.NoError(t, err, string(out))
+}
+
+func TestCreateTLSConfigBundle(t *testing.T) {
+	intermediate := newTestCA(t, "intermediate")
+	root := newTestCA(t, "root")
+	bundle := append(append([]byte{}, intermediate.pem...), root.pem...)
+
+	tlsCfg, err := otlpconfig.CreateTLSConfig(bundle)
+	require.NoError(t, err)
+	//nolint:staticcheck // The pool is not the system pool.
+	assert.ElementsMatch(t, [][]byte{intermediate.cert.RawSubject, root.cert.RawSubject}, tlsCfg.RootCAs.Subjects())
+	for _, ca := range []*testCA{intermediate, root} {
+		_, err := ca.sign(t).Verify(x509.VerifyOptions{Roots: tlsCfg.RootCAs, DNSName: "collector.example.com"})
+		assert.NoError(t, err)
+	}
+
+	_, err = otlpconfig.CreateTLSConfig([]byte("invalid"))
+	assert.Error(t, err)
+
+	// The certificate file read from the environment is a bundle too.
+	e := otlpconfig.EnvOptionsReader{
+		GetEnv: func(key string) string {
+			if key == "OTEL_EXPORTER_OTLP_CERTIFICATE" {
+				return "bundle.pem"
+			}
+			return ""
+		},
+		ReadFile: func(string) ([]byte, error) { return bundle, nil },
+	}
+	cfg := otlpconfig.NewDefaultConfig()
+	e.ApplyHTTPEnvConfigs(&cfg)
+	require.NotNil(t, cfg.Traces.TLSCfg)
+	//nolint:staticcheck // The pool is not the system pool.
+	assert.Len(t, cfg.Traces.TLSCfg.RootCAs.Subjects(), 2)
+}
+
+// handshake connects to a server accepting the TLS versions up to maxVersion
+// with the TLS configuration built by opts.
+func handshake(t *testing.T, maxVersion uint16, opts ...otlpconfig.GenericOption) error {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	srv.TLS = &tls.Config{MinVersion: tls.VersionTLS10, MaxVersion: maxVersion}
+	srv.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	srv.StartTLS()
+	defer srv.Close()
+
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	return dial(t, srv.Listener.Addr().String(), append([]otlpconfig.GenericOption{otlpconfig.WithTLSRootCAs(false, caPEM)}, opts...)...)
+}
+
+// dial connects to addr with the TLS configuration built by opts.
+func dial(t *testing.T, addr string, opts ...otlpconfig.GenericOption) error {
+	cfg := otlpconfig.NewDefaultConfig()
+	for _, opt := range opts {
+		opt.ApplyHTTPOption(&cfg)
+	}
+	require.Empty(t, cfg.Errors())
+	conn, err := tls.Dial("tcp", addr, cfg.Traces.TLSCfg)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+func TestTLSMinVersion(t *testing.T) {
+	// TLS 1.2 is the default minimum version.
+	assert.Error(t, handshake(t, tls.VersionTLS11))
+	assert.NoError(t, handshake(t, tls.VersionTLS12))
+
+	assert.Error(t, handshake(t, tls.VersionTLS12, otlpconfig.WithTLSMinVersion(tls.VersionTLS13)))
+	assert.NoError(t, handshake(t, tls.VersionTLS13, otlpconfig.WithTLSMinVersion(tls.VersionTLS13)))
+
+	cfg := otlpconfig.NewDefaultConfig()
+	otlpconfig.WithTLSMinVersion(0x0200).ApplyGRPCOption(&cfg)
+	assert.Len(t, cfg.Errors(), 1)
+	assert.Nil(t, cfg.Traces.TLSCfg)
+
+	tlsCfg, err := otlpconfig.CreateTLSConfig(newTestCA(t, "root").pem)
+	require.NoError(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS12), tlsCfg.MinVersion)
+}
+
+func TestTLSCipherSuites(t *testing.T) {
+	suites := []uint16{tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384}
+	cfg := otlpconfig.NewDefaultConfig()
+	otlpconfig.WithTLSCipherSuites(suites).ApplyGRPCOption(&cfg)
+	assert.Empty(t, cfg.Errors())
+	assert.Equal(t, suites, cfg.Traces.TLSCfg.CipherSuites)
+	require.NotNil(t, cfg.Traces.GRPCCredentials)
+
+	otlpconfig.WithTLSCipherSuites(nil).ApplyGRPCOption(&cfg)
+	assert.Nil(t, cfg.Traces.TLSCfg.CipherSuites)
+
+	otlpconfig.WithTLSCipherSuites([]uint16{0xffff}).ApplyGRPCOption(&cfg)
+	assert.Len(t, cfg.Errors(), 1)
+
+	// The offered cipher suites must be accepted by the server.
+	assert.NoError(t, handshake(t, tls.VersionTLS12, otlpconfig.WithTLSCipherSuites(suites)))
+	assert.Error(t, handshake(t, tls.VersionTLS12, otlpconfig.WithTLSCipherSuites([]uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384})))
+}
+
+func TestTLSServerName(t *testing.T) {
+	ca := newTestCA(t, "internal")
+	cert, key := ca.signKeyPair(t)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	srv.TLS = &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{cert.Raw}, PrivateKey: key}}}
+	srv.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	srv.StartTLS()
+	defer srv.Close()
+	addr := srv.Listener.Addr().String()
+
+	// The certificate is issued for the DNS name of the collector, not its
+	// IP address.
+	assert.Error(t, dial(t, addr, otlpconfig.WithTLSRootCAs(false, ca.pem)))
+	assert.NoError(t, dial(t, addr, otlpconfig.WithTLSRootCAs(false, ca.pem), otlpconfig.WithTLSServerName("collector.example.com")))
+	assert.Error(t, dial(t, addr, otlpconfig.WithTLSRootCAs(false, ca.pem), otlpconfig.WithTLSServerName("other.example.com")))
+
+	// The signal specific variable takes precedence, after the certificate.
+	e := otlpconfig.EnvOptionsReader{
+		GetEnv: func(key string) string {
+			return map[string]string{
+				"OTEL_EXPORTER_OTLP_CERTIFICATE":            "ca.pem",
+				"OTEL_EXPORTER_OTLP_TLS_SERVER_NAME":        "other.example.com",
+				"OTEL_EXPORTER_OTLP_TRACES_TLS_SERVER_NAME": "collector.example.com",
+			}[key]
+		},
+		ReadFile: func(string) ([]byte, error) { return ca.pem, nil },
+	}
+	cfg := otlpconfig.NewDefaultConfig()
+	e.ApplyGRPCEnvConfigs(&cfg)
+	require.NotNil(t, cfg.Traces.TLSCfg)
+	assert.Equal(t, "collector.example.com", cfg.Traces.TLSCfg.ServerName)
+	assert.Equal(t, "collector.example.com", cfg.Traces.GRPCCredentials.Info().ServerName)
+}
+
+func TestInsecureSkipVerify(t *testing.T) {
+	cfg := otlpconfig.NewDefaultConfig()
+	assert.NoError(t, cfg.Traces.TLSVerificationWarning())
+
+	otlpconfig.WithInsecureSkipVerify().ApplyGRPCOption(&cfg)
+	require.NotNil(t, cfg.Traces.TLSCfg)
+	assert.True(t, cfg.Traces.TLSCfg.InsecureSkipVerify)
+	assert.Equal(t, "tls", cfg.Traces.GRPCCredentials.Info().SecurityProtocol)
+	assert.Error(t, cfg.Traces.TLSVerificationWarning())
+
+	// No certificate is verified without TLS.
+	otlpconfig.WithInsecure().ApplyGRPCOption(&cfg)
+	assert.NoError(t, cfg.Traces.TLSVerificationWarning())
+}
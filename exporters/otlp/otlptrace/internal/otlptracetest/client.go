@@ -17,11 +17,22 @@ package otlptracetest // import "go.opentelemetry.io/otel/exporters/otlp/otlptra
 import (
 	"context"
 	"errors"
+	"fmt"
+	"sort"
 	"sync"
 	"testing"
 	"time"
 
+	"google.golang.org/protobuf/proto"
+
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/tracetransform"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
 )
 
 func RunExporterShutdownTest(t *testing.T, factory func() otlptrace.Client) {
@@ -42,6 +53,73 @@ func RunExporterShutdownTest(t *testing.T, factory func() otlptrace.Client) {
 	})
 }
 
+// RunExporterDeliveryTest exports a known set of spans with a client created
+// by factory and verifies collector received exactly those spans, in order,
+// with their resource and instrumentation library preserved. The collector
+// must not have received any spans before.
+func RunExporterDeliveryTest(t *testing.T, factory func() otlptrace.Client, collector TracesCollector) {
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+	defer cancel()
+
+	var stubs tracetest.SpanStubs
+	for _, res := range []*resource.Resource{
+		resource.NewSchemaless(attribute.String("service.name", "a"), attribute.Int64("rk", 1)),
+		resource.NewSchemaless(attribute.String("service.name", "b"), attribute.Bool("rk", true)),
+	} {
+		for _, lib := range []instrumentation.Library{
+			{Name: "lib1", Version: "v0.1.0"},
+			{Name: "lib2"},
+		} {
+			for i := 0; i < 3; i++ {
+				stubs = append(stubs, tracetest.SpanStub{
+					Name:                   fmt.Sprintf("%s/%s/%d", res.String(), lib.Name, i),
+					SpanKind:               trace.SpanKindServer,
+					Attributes:             []attribute.KeyValue{attribute.Int("i", i)},
+					Resource:               res,
+					InstrumentationLibrary: lib,
+				})
+			}
+		}
+	}
+	spans := stubs.Snapshots()
+
+	e := initializeExporter(t, factory())
+	defer func() {
+		if err := e.Shutdown(ctx); err != nil {
+			t.Errorf("shutdown errored: expected nil, got %v", err)
+		}
+	}()
+	if err := e.ExportSpans(ctx, spans); err != nil {
+		t.Fatalf("failed to export spans: %v", err)
+	}
+
+	want := sortedResourceSpans(tracetransform.Spans(spans))
+	got := sortedResourceSpans(collector.GetResourceSpans())
+	if len(got) != len(want) {
+		t.Fatalf("resource span count: got %d, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if !proto.Equal(got[i], want[i]) {
+			t.Errorf("resource spans %d:\ngot  %v\nwant %v", i, got[i], want[i])
+		}
+	}
+}
+
+// sortedResourceSpans sorts rss by resource and the instrumentation library
+// spans of each by library name, leaving the order of the spans unchanged.
+func sortedResourceSpans(rss []*tracepb.ResourceSpans) []*tracepb.ResourceSpans {
+	for _, rs := range rss {
+		ils := rs.InstrumentationLibrarySpans
+		sort.SliceStable(ils, func(i, j int) bool {
+			return ils[i].InstrumentationLibrary.GetName() < ils[j].InstrumentationLibrary.GetName()
+		})
+	}
+	sort.SliceStable(rss, func(i, j int) bool {
+		return resourceString(rss[i].Resource) < resourceString(rss[j].Resource)
+	})
+	return rss
+}
+
 func initializeExporter(t *testing.T, client otlptrace.Client) *otlptrace.Exporter {
 	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
 	defer cancel()
@@ -0,0 +1,92 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otlptracetest // import "go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/otlptracetest"
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	// The gzip compressed requests are decompressed by the collector.
+	_ "google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/metadata"
+
+	collectortracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+)
+
+// GRPCCollector is a Collector served by a real gRPC server on a loopback
+// listener, to test the full export path of a client, including its
+// transport security and compression. It also records the metadata of the
+// export requests.
+type GRPCCollector struct {
+	*Collector
+
+	srv *grpc.Server
+
+	mu       sync.Mutex
+	metadata []metadata.MD
+}
+
+// StartGRPCCollector starts a GRPCCollector listening on a random loopback
+// port and returns it with the address to dial. If tlsConfig is nil, the
+// connections are insecure, otherwise they are secured with it. It panics if
+// it cannot listen, like httptest.NewServer does. Stop must be called to stop
+// the collector.
+func StartGRPCCollector(tlsConfig *tls.Config) (*GRPCCollector, string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(fmt.Sprintf("otlptracetest: failed to listen on a loopback port: %v", err))
+	}
+	var opts []grpc.ServerOption
+	if tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+
+	c := &GRPCCollector{
+		Collector: NewCollector(),
+		srv:       grpc.NewServer(opts...),
+	}
+	collectortracepb.RegisterTraceServiceServer(c.srv, c)
+	go func() { _ = c.srv.Serve(ln) }()
+	return c, ln.Addr().String()
+}
+
+// Export records the metadata of the export request and lets the Collector
+// handle it.
+func (c *GRPCCollector) Export(ctx context.Context, req *collectortracepb.ExportTraceServiceRequest) (*collectortracepb.ExportTraceServiceResponse, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	c.mu.Lock()
+	c.metadata = append(c.metadata, md)
+	c.mu.Unlock()
+	return c.Collector.Export(ctx, req)
+}
+
+// Metadata returns the metadata of all the export requests received by the
+// GRPCCollector, including the rejected ones, in the order they were
+// received.
+func (c *GRPCCollector) Metadata() []metadata.MD {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]metadata.MD(nil), c.metadata...)
+}
+
+// Stop stops the GRPCCollector, closing its listener and connections.
+func (c *GRPCCollector) Stop() {
+	c.srv.Stop()
+}
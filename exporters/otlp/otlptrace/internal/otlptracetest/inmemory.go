@@ -0,0 +1,180 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otlptracetest // import "go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/otlptracetest"
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/partialsuccess"
+	collectortracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+// Collector is an in-memory collector recording the export requests it
+// receives. It can be served over gRPC, by registering it with
+// collectortracepb.RegisterTraceServiceServer, or over HTTP, as an
+// http.Handler of the traces path.
+type Collector struct {
+	collectortracepb.UnimplementedTraceServiceServer
+
+	mu       sync.Mutex
+	requests []*collectortracepb.ExportTraceServiceRequest
+	code     codes.Code
+	rejected int64
+	msg      string
+}
+
+var (
+	_ collectortracepb.TraceServiceServer = (*Collector)(nil)
+	_ http.Handler                        = (*Collector)(nil)
+)
+
+// NewCollector returns a Collector accepting all export requests.
+func NewCollector() *Collector {
+	return &Collector{}
+}
+
+// SetResponseError makes the Collector reject the following export requests
+// with code. Use codes.OK to accept them again.
+func (c *Collector) SetResponseError(code codes.Code) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.code = code
+}
+
+// SetPartialSuccess makes the Collector respond to the following accepted
+// export requests with a partial success rejecting the number of spans with
+// msg. Use zero values to respond with full successes again.
+func (c *Collector) SetPartialSuccess(rejected int64, msg string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rejected, c.msg = rejected, msg
+}
+
+// Requests returns the export requests accepted by the Collector, in the order
+// they were received.
+func (c *Collector) Requests() []*collectortracepb.ExportTraceServiceRequest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]*collectortracepb.ExportTraceServiceRequest(nil), c.requests...)
+}
+
+// ResourceSpans returns the resource spans of all the export requests
+// accepted by the Collector, in the order they were received.
+func (c *Collector) ResourceSpans() []*tracepb.ResourceSpans {
+	var rss []*tracepb.ResourceSpans
+	for _, req := range c.Requests() {
+		rss = append(rss, req.ResourceSpans...)
+	}
+	return rss
+}
+
+// Export records req unless the Collector is set to respond with an error.
+func (c *Collector) Export(_ context.Context, req *collectortracepb.ExportTraceServiceRequest) (*collectortracepb.ExportTraceServiceResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.code != codes.OK {
+		return nil, status.Error(c.code, "injected error")
+	}
+	c.requests = append(c.requests, req)
+
+	resp := &collectortracepb.ExportTraceServiceResponse{}
+	partialsuccess.Set(resp, c.rejected, c.msg)
+	return resp, nil
+}
+
+// ServeHTTP handles an OTLP/HTTP export request in the binary protobuf
+// encoding, optionally gzip compressed. Errors set with SetResponseError are
+// translated to their HTTP status equivalent.
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	var body io.Reader = r.Body
+	switch r.Header.Get("Content-Encoding") {
+	case "":
+	case "gzip":
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer gz.Close()
+		body = gz
+	default:
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		return
+	}
+
+	raw, err := ioutil.ReadAll(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req := &collectortracepb.ExportTraceServiceRequest{}
+	if err := proto.Unmarshal(raw, req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resp, err := c.Export(r.Context(), req)
+	if err != nil {
+		http.Error(w, err.Error(), httpStatus(status.Code(err)))
+		return
+	}
+	rawResp, err := proto.Marshal(resp)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	_, _ = w.Write(rawResp)
+}
+
+// httpStatus returns the HTTP status equivalent to code.
+func httpStatus(code codes.Code) int {
+	switch code {
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otlptracetest // import "go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/otlptracetest"
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+var errNoopClientStopped = errors.New("the client is stopped")
+
+// NoopClient is an otlptrace.Client discarding the spans it uploads without
+// any network, only counting them. It is meant to verify an SDK pipeline
+// exports spans.
+type NoopClient struct {
+	mu       sync.Mutex
+	stopped  bool
+	uploaded int
+}
+
+var _ otlptrace.Client = (*NoopClient)(nil)
+
+// NewNoopClient returns a NoopClient that has not uploaded any span.
+func NewNoopClient() *NoopClient {
+	return &NoopClient{}
+}
+
+// Start returns the error of ctx, if any.
+func (c *NoopClient) Start(ctx context.Context) error {
+	return ctx.Err()
+}
+
+// Stop makes the following uploads fail. It returns the error of ctx, if
+// any, like the clients stopping before ctx is done.
+func (c *NoopClient) Stop(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stopped = true
+	return ctx.Err()
+}
+
+// UploadTraces counts and discards the spans of protoSpans. It fails if ctx
+// is done or the NoopClient is stopped.
+func (c *NoopClient) UploadTraces(ctx context.Context, protoSpans []*tracepb.ResourceSpans) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.stopped {
+		return errNoopClientStopped
+	}
+	for _, rs := range protoSpans {
+		for _, ils := range rs.InstrumentationLibrarySpans {
+			c.uploaded += len(ils.Spans)
+		}
+	}
+	return nil
+}
+
+// UploadedSpanCount returns the number of spans uploaded by the NoopClient.
+func (c *NoopClient) UploadedSpanCount() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.uploaded
+}
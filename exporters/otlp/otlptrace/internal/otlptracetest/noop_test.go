@@ -0,0 +1,56 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otlptracetest
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestNoopClientShutdown(t *testing.T) {
+	RunExporterShutdownTest(t, func() otlptrace.Client {
+		return NewNoopClient()
+	})
+}
+
+func TestNoopClientUploadedSpanCount(t *testing.T) {
+	ctx := context.Background()
+	client := NewNoopClient()
+	exp, err := otlptrace.New(ctx, client)
+	if err != nil {
+		t.Fatalf("failed to create exporter: %v", err)
+	}
+
+	spans := tracetest.SpanStubs{{Name: "a"}, {Name: "b"}, {Name: "c"}}.Snapshots()
+	if err := exp.ExportSpans(ctx, spans); err != nil {
+		t.Fatalf("failed to export spans: %v", err)
+	}
+	if got := client.UploadedSpanCount(); got != 3 {
+		t.Errorf("uploaded span count: got %d, want 3", got)
+	}
+
+	if err := exp.Shutdown(ctx); err != nil {
+		t.Fatalf("shutdown errored: %v", err)
+	}
+	if err := client.UploadTraces(ctx, nil); err == nil {
+		t.Error("expected uploading after shutdown to fail")
+	}
+	if got := client.UploadedSpanCount(); got != 3 {
+		t.Errorf("uploaded span count after shutdown: got %d, want 3", got)
+	}
+}
@@ -121,3 +121,23 @@ func RunEndToEndTest(ctx context.Context, t *testing.T, exp *otlptrace.Exporter,
 		}
 	}
 }
+
+// WaitForConnectionStatus polls the connection status of exp until cond
+// holds for it, and returns that status. The test fails if cond does not
+// hold before ctx is done.
+func WaitForConnectionStatus(ctx context.Context, t *testing.T, exp *otlptrace.Exporter, cond func(otlptrace.ConnectionStatus) bool) otlptrace.ConnectionStatus {
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		status := exp.ConnectionStatus()
+		if cond(status) {
+			return status
+		}
+		select {
+		case <-ctx.Done():
+			t.Fatalf("connection status %+v: %v", status, ctx.Err())
+			return status
+		case <-ticker.C:
+		}
+	}
+}
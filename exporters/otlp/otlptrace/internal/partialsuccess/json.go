@@ -0,0 +1,96 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package partialsuccess // import "go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/partialsuccess"
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protojson"
+
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+)
+
+// jsonResponse is the OTLP/JSON partial success of an
+// ExportTraceServiceResponse. Both the lowerCamelCase and the original field
+// names are accepted, as with any JSON mapping of protobuf.
+type jsonResponse struct {
+	PartialSuccess      *jsonPartialSuccess `json:"partialSuccess,omitempty"`
+	PartialSuccessProto *jsonPartialSuccess `json:"partial_success,omitempty"`
+}
+
+type jsonPartialSuccess struct {
+	RejectedSpans      json.RawMessage `json:"rejectedSpans,omitempty"`
+	RejectedSpansProto json.RawMessage `json:"rejected_spans,omitempty"`
+	ErrorMessage       string          `json:"errorMessage,omitempty"`
+	ErrorMessageProto  string          `json:"error_message,omitempty"`
+}
+
+// UnmarshalJSON decodes the OTLP/JSON response b into resp, including its
+// partial success.
+func UnmarshalJSON(b []byte, resp *coltracepb.ExportTraceServiceResponse) error {
+	// The partial success field is unknown to the generated message.
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(b, resp); err != nil {
+		return err
+	}
+
+	var r jsonResponse
+	if err := json.Unmarshal(b, &r); err != nil {
+		return err
+	}
+	ps := r.PartialSuccess
+	if ps == nil {
+		ps = r.PartialSuccessProto
+	}
+	if ps == nil {
+		return nil
+	}
+
+	rawRejected, msg := ps.RejectedSpans, ps.ErrorMessage
+	if rawRejected == nil {
+		rawRejected = ps.RejectedSpansProto
+	}
+	if msg == "" {
+		msg = ps.ErrorMessageProto
+	}
+	var rejected int64
+	if rawRejected != nil {
+		// 64-bit integers are encoded as strings, numbers are accepted.
+		var err error
+		rejected, err = strconv.ParseInt(strings.Trim(string(rawRejected), `"`), 10, 64)
+		if err != nil {
+			return err
+		}
+	}
+	if rejected != 0 || msg != "" {
+		Set(resp, rejected, msg)
+	}
+	return nil
+}
+
+// MarshalJSON encodes resp, including its partial success, as OTLP/JSON.
+func MarshalJSON(resp *coltracepb.ExportTraceServiceResponse) ([]byte, error) {
+	rejected, msg, ok := Get(resp)
+	if !ok {
+		return protojson.Marshal(resp)
+	}
+	var r jsonResponse
+	r.PartialSuccess = &jsonPartialSuccess{ErrorMessage: msg}
+	if rejected != 0 {
+		r.PartialSuccess.RejectedSpans = json.RawMessage(strconv.Quote(strconv.FormatInt(rejected, 10)))
+	}
+	return json.Marshal(r)
+}
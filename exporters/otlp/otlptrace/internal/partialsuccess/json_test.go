@@ -0,0 +1,87 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package partialsuccess
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	resp := &coltracepb.ExportTraceServiceResponse{}
+	Set(resp, 3, "invalid span")
+
+	b, err := MarshalJSON(resp)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"partialSuccess":{"rejectedSpans":"3","errorMessage":"invalid span"}}`, string(b))
+
+	got := &coltracepb.ExportTraceServiceResponse{}
+	require.NoError(t, UnmarshalJSON(b, got))
+	rejected, msg, ok := Get(got)
+	assert.True(t, ok)
+	assert.Equal(t, int64(3), rejected)
+	assert.Equal(t, "invalid span", msg)
+}
+
+func TestUnmarshalJSON(t *testing.T) {
+	for _, tt := range []struct {
+		name         string
+		json         string
+		wantRejected int64
+		wantMsg      string
+		wantOK       bool
+	}{
+		{name: "empty", json: `{}`},
+		{name: "empty partial success", json: `{"partialSuccess":{}}`},
+		{
+			name:         "number",
+			json:         `{"partialSuccess":{"rejectedSpans":2}}`,
+			wantRejected: 2,
+			wantOK:       true,
+		},
+		{
+			name:         "original field names",
+			json:         `{"partial_success":{"rejected_spans":"2","error_message":"dropped"}}`,
+			wantRejected: 2,
+			wantMsg:      "dropped",
+			wantOK:       true,
+		},
+		{
+			name:    "unknown fields",
+			json:    `{"partialSuccess":{"errorMessage":"warning"},"other":true}`,
+			wantMsg: "warning",
+			wantOK:  true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &coltracepb.ExportTraceServiceResponse{}
+			require.NoError(t, UnmarshalJSON([]byte(tt.json), resp))
+			rejected, msg, ok := Get(resp)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.wantRejected, rejected)
+			assert.Equal(t, tt.wantMsg, msg)
+		})
+	}
+}
+
+func TestUnmarshalJSONInvalid(t *testing.T) {
+	resp := &coltracepb.ExportTraceServiceResponse{}
+	assert.Error(t, UnmarshalJSON([]byte(`{`), resp))
+	assert.Error(t, UnmarshalJSON([]byte(`{"partialSuccess":{"rejectedSpans":"many"}}`), resp))
+}
@@ -0,0 +1,169 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package partialsuccess provides support for the partial_success field of
+// OTLP export trace service responses.
+//
+// The version of go.opentelemetry.io/proto/otlp used by this module predates
+// the partial_success field. It is decoded from the unknown fields of the
+// response message instead.
+package partialsuccess // import "go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/partialsuccess"
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"go.opentelemetry.io/otel"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+)
+
+const (
+	// responsePartialSuccessField is the field number of partial_success in
+	// ExportTraceServiceResponse.
+	responsePartialSuccessField protowire.Number = 1
+	// rejectedSpansField is the field number of rejected_spans in
+	// ExportTracePartialSuccess.
+	rejectedSpansField protowire.Number = 1
+	// errorMessageField is the field number of error_message in
+	// ExportTracePartialSuccess.
+	errorMessageField protowire.Number = 2
+)
+
+// Handler is called with the number of spans rejected by the server and
+// the error message the server included in a partial success response.
+type Handler func(rejected int64, msg string)
+
+// Error is returned to the global error handler when a partial success
+// response is received and no Handler has been configured.
+type Error struct {
+	RejectedSpans int64
+	ErrorMessage  string
+}
+
+// Error returns a string representation of the partial success.
+func (e Error) Error() string {
+	return fmt.Sprintf("OTLP partial success: %s (%d spans rejected)", e.ErrorMessage, e.RejectedSpans)
+}
+
+// Get returns the partial success information contained in resp. The
+// returned ok is false if resp does not contain any partial success or the
+// partial success is zero-valued, meaning the server accepted all spans.
+func Get(resp *coltracepb.ExportTraceServiceResponse) (rejected int64, msg string, ok bool) {
+	if resp == nil {
+		return 0, "", false
+	}
+
+	b := resp.ProtoReflect().GetUnknown()
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return 0, "", false
+		}
+		b = b[n:]
+
+		if num != responsePartialSuccessField || typ != protowire.BytesType {
+			n = protowire.ConsumeFieldValue(num, typ, b)
+			if n < 0 {
+				return 0, "", false
+			}
+			b = b[n:]
+			continue
+		}
+
+		v, n := protowire.ConsumeBytes(b)
+		if n < 0 {
+			return 0, "", false
+		}
+		b = b[n:]
+		// Last one wins as with any non-repeated message field.
+		rejected, msg = decode(v)
+	}
+	return rejected, msg, rejected != 0 || msg != ""
+}
+
+// decode decodes an encoded ExportTracePartialSuccess message.
+func decode(b []byte) (rejected int64, msg string) {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return rejected, msg
+		}
+		b = b[n:]
+
+		switch {
+		case num == rejectedSpansField && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			if n < 0 {
+				return rejected, msg
+			}
+			rejected = int64(v)
+			b = b[n:]
+		case num == errorMessageField && typ == protowire.BytesType:
+			v, n := protowire.ConsumeString(b)
+			if n < 0 {
+				return rejected, msg
+			}
+			msg = v
+			b = b[n:]
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+			if n < 0 {
+				return rejected, msg
+			}
+			b = b[n:]
+		}
+	}
+	return rejected, msg
+}
+
+// Set encodes the partial success information into resp, replacing any
+// unknown fields it contains. It is intended to be used by servers and
+// tests.
+func Set(resp *coltracepb.ExportTraceServiceResponse, rejected int64, msg string) {
+	var ps []byte
+	if rejected != 0 {
+		ps = protowire.AppendTag(ps, rejectedSpansField, protowire.VarintType)
+		ps = protowire.AppendVarint(ps, uint64(rejected))
+	}
+	if msg != "" {
+		ps = protowire.AppendTag(ps, errorMessageField, protowire.BytesType)
+		ps = protowire.AppendString(ps, msg)
+	}
+
+	var b []byte
+	b = protowire.AppendTag(b, responsePartialSuccessField, protowire.BytesType)
+	b = protowire.AppendBytes(b, ps)
+	resp.ProtoReflect().SetUnknown(b)
+}
+
+// Handle reports any partial success contained in resp to handler. If
+// handler is nil, the partial success is reported as an Error to errHandler,
+// or to the global error handler if errHandler is nil too.
+func Handle(resp *coltracepb.ExportTraceServiceResponse, handler Handler, errHandler otel.ErrorHandler) {
+	rejected, msg, ok := Get(resp)
+	if !ok {
+		return
+	}
+	if handler != nil {
+		handler(rejected, msg)
+		return
+	}
+	err := Error{RejectedSpans: rejected, ErrorMessage: msg}
+	if errHandler != nil {
+		errHandler.Handle(err)
+		return
+	}
+	otel.Handle(err)
+}
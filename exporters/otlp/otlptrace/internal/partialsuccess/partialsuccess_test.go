@@ -0,0 +1,111 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package partialsuccess
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+
+	"go.opentelemetry.io/otel"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+)
+
+func TestRoundTrip(t *testing.T) {
+	resp := &coltracepb.ExportTraceServiceResponse{}
+	Set(resp, 3, "invalid span")
+
+	b, err := proto.Marshal(resp)
+	assert.NoError(t, err)
+
+	got := &coltracepb.ExportTraceServiceResponse{}
+	assert.NoError(t, proto.Unmarshal(b, got))
+
+	rejected, msg, ok := Get(got)
+	assert.True(t, ok)
+	assert.Equal(t, int64(3), rejected)
+	assert.Equal(t, "invalid span", msg)
+}
+
+func TestGetZeroValue(t *testing.T) {
+	_, _, ok := Get(nil)
+	assert.False(t, ok)
+
+	_, _, ok = Get(&coltracepb.ExportTraceServiceResponse{})
+	assert.False(t, ok)
+
+	resp := &coltracepb.ExportTraceServiceResponse{}
+	Set(resp, 0, "")
+	_, _, ok = Get(resp)
+	assert.False(t, ok)
+}
+
+func TestGetMessageOnly(t *testing.T) {
+	resp := &coltracepb.ExportTraceServiceResponse{}
+	Set(resp, 0, "warning")
+
+	rejected, msg, ok := Get(resp)
+	assert.True(t, ok)
+	assert.Equal(t, int64(0), rejected)
+	assert.Equal(t, "warning", msg)
+}
+
+type errorHandler struct {
+	errs []error
+}
+
+func (h *errorHandler) Handle(err error) {
+	h.errs = append(h.errs, err)
+}
+
+func TestHandle(t *testing.T) {
+	resp := &coltracepb.ExportTraceServiceResponse{}
+	Set(resp, 2, "dropped")
+
+	var gotRejected int64
+	var gotMsg string
+	Handle(resp, func(rejected int64, msg string) {
+		gotRejected, gotMsg = rejected, msg
+	}, nil)
+	assert.Equal(t, int64(2), gotRejected)
+	assert.Equal(t, "dropped", gotMsg)
+
+	h := &errorHandler{}
+	otel.SetErrorHandler(h)
+	Handle(resp, nil, nil)
+	Handle(&coltracepb.ExportTraceServiceResponse{}, nil, nil)
+	if assert.Len(t, h.errs, 1) {
+		var psErr Error
+		assert.True(t, errors.As(h.errs[0], &psErr))
+		assert.Equal(t, Error{RejectedSpans: 2, ErrorMessage: "dropped"}, psErr)
+		assert.Equal(t, "OTLP partial success: dropped (2 spans rejected)", psErr.Error())
+	}
+}
+
+func TestHandleWithErrorHandler(t *testing.T) {
+	resp := &coltracepb.ExportTraceServiceResponse{}
+	Set(resp, 3, "invalid")
+
+	global := &errorHandler{}
+	otel.SetErrorHandler(global)
+	h := &errorHandler{}
+	Handle(resp, nil, h)
+	assert.Empty(t, global.errs)
+	if assert.Len(t, h.errs, 1) {
+		assert.Equal(t, Error{RejectedSpans: 3, ErrorMessage: "invalid"}, h.errs[0])
+	}
+}
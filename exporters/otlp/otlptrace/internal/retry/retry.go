@@ -16,10 +16,13 @@ package retry // import "go.opentelemetry.io/otel/exporters/otlp/otlptrace/inter
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/cenkalti/backoff/v4"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // DefaultConfig are the recommended defaults to use.
@@ -56,13 +59,162 @@ type RequestFunc func(context.Context, func(context.Context) error) error
 // duration should be honored that was included in the error.
 type EvaluateFunc func(error) (bool, time.Duration)
 
+// Classify returns an EvaluateFunc deciding with retryable, if not nil,
+// whether an error is retry-able instead of evaluate. The throttle duration
+// returned by evaluate is still honored. Context cancellation and deadline
+// errors, including the gRPC status errors with the Canceled and
+// DeadlineExceeded codes, are never retry-able.
+func Classify(evaluate EvaluateFunc, retryable func(error) bool) EvaluateFunc {
+	return func(err error) (bool, time.Duration) {
+		if isContextError(err) {
+			return false, 0
+		}
+		ok, throttle := evaluate(err)
+		if retryable == nil {
+			return ok, throttle
+		}
+		if !retryable(err) {
+			return false, 0
+		}
+		return true, throttle
+	}
+}
+
+// isContextError returns whether err, or an error it wraps, is a context
+// cancellation or deadline error, or the gRPC status error it was converted
+// to.
+func isContextError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var se interface{ GRPCStatus() *status.Status }
+	if !errors.As(err, &se) {
+		return false
+	}
+	code := se.GRPCStatus().Code()
+	return code == codes.Canceled || code == codes.DeadlineExceeded
+}
+
+// Outcome is the terminal outcome of a request retried by a RequestFunc.
+type Outcome string
+
+const (
+	// OutcomeSuccess is the outcome of a request that eventually succeeded.
+	OutcomeSuccess Outcome = "success"
+	// OutcomeExhausted is the outcome of a request abandoned because the
+	// maximum retry time elapsed.
+	OutcomeExhausted Outcome = "exhausted"
+	// OutcomeCanceled is the outcome of a request abandoned because its
+	// context was canceled or its deadline was exceeded.
+	OutcomeCanceled Outcome = "context-cancelled"
+	// OutcomeFailed is the outcome of a request that failed with an error
+	// that is not retry-able.
+	OutcomeFailed Outcome = "failed"
+)
+
+// Observer is notified of the completion of each request that was retried at
+// least once, with the number of retries, the total time spent waiting
+// before them and the outcome of the request.
+type Observer func(ctx context.Context, retries int, backoff time.Duration, outcome Outcome)
+
+// RequestFunc returns a RequestFunc retrying the requests failing with an
+// error evaluate reports as retry-able.
 func (c Config) RequestFunc(evaluate EvaluateFunc) RequestFunc {
+	return c.ObservedRequestFunc(evaluate, nil)
+}
+
+// ObservedRequestFunc is like RequestFunc, and notifies observer, if not nil,
+// of the completion of the requests that were retried.
+func (c Config) ObservedRequestFunc(evaluate EvaluateFunc, observer Observer) RequestFunc {
+	return c.StrategyRequestFunc(nil, evaluate, observer)
+}
+
+// Strategy returns the delay to wait before the retry attempt, starting at
+// one, of a request that failed with lastErr, or giveUp to stop retrying it.
+// It is called concurrently for different requests.
+type Strategy func(attempt int, lastErr error) (delay time.Duration, giveUp bool)
+
+// StrategyRequestFunc is like ObservedRequestFunc, and waits before the
+// retries as returned by strategy instead of the exponential backoff of c.
+// The maximum elapsed time of c is not used with a strategy, the requests are
+// retried until strategy gives up or their context is done. The throttle
+// delays are still honored. A nil strategy uses the exponential backoff. The
+// requests whose context is done are not retried, whatever evaluate returns.
+func (c Config) StrategyRequestFunc(strategy Strategy, evaluate EvaluateFunc, observer Observer) RequestFunc {
 	if !c.Enabled {
 		return func(ctx context.Context, fn func(context.Context) error) error {
 			return fn(ctx)
 		}
 	}
 
+	newStrategy := func() Strategy { return strategy }
+	exhausted, maxElapsed := "retry strategy gave up", time.Duration(0)
+	if strategy == nil {
+		newStrategy = c.exponentialStrategy
+		exhausted, maxElapsed = "max retry time elapsed", c.MaxElapsedTime
+	}
+
+	return func(ctx context.Context, fn func(context.Context) error) error {
+		var (
+			next    = newStrategy()
+			start   = time.Now()
+			retries int
+			waited  time.Duration
+		)
+		outcome, err := func() (Outcome, error) {
+			for {
+				err := fn(ctx)
+				if err == nil {
+					return OutcomeSuccess, nil
+				}
+
+				if ctx.Err() != nil {
+					return OutcomeCanceled, err
+				}
+				retryable, throttle := evaluate(err)
+				if !retryable {
+					return OutcomeFailed, err
+				}
+
+				bOff, giveUp := next(retries+1, err)
+				if giveUp {
+					return OutcomeExhausted, fmt.Errorf("%s: %w", exhausted, err)
+				}
+
+				// Wait for the greater of the backoff or throttle delay.
+				var delay time.Duration
+				if bOff > throttle {
+					delay = bOff
+				} else {
+					if maxElapsed != 0 && time.Since(start)+throttle > maxElapsed {
+						return OutcomeExhausted, fmt.Errorf("max retry time would elapse: %w", err)
+					}
+					delay = throttle
+				}
+				// The deadline of ctx caps the time spent retrying, do not wait
+				// for a retry that could not be attempted before it.
+				if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+					return OutcomeCanceled, deadlineError{err: err}
+				}
+
+				if err := waitFunc(ctx, delay); err != nil {
+					return OutcomeCanceled, err
+				}
+				retries++
+				waited += delay
+			}
+		}()
+		if observer != nil && retries > 0 {
+			observer(ctx, retries, waited, outcome)
+		}
+		return err
+	}
+}
+
+// exponentialStrategy returns the default Strategy of a request, an
+// exponential backoff giving up once the maximum elapsed time of c is
+// reached.
+func (c Config) exponentialStrategy() Strategy {
 	// Do not use NewExponentialBackOff since it calls Reset and the code here
 	// must call Reset after changing the InitialInterval (this saves an
 	// unnecessary call to Now).
@@ -76,41 +228,29 @@ func (c Config) RequestFunc(evaluate EvaluateFunc) RequestFunc {
 		Clock:               backoff.SystemClock,
 	}
 	b.Reset()
+	return func(int, error) (time.Duration, bool) {
+		d := b.NextBackOff()
+		return d, d == backoff.Stop
+	}
+}
 
-	return func(ctx context.Context, fn func(context.Context) error) error {
-		for {
-			err := fn(ctx)
-			if err == nil {
-				return nil
-			}
-
-			retryable, throttle := evaluate(err)
-			if !retryable {
-				return err
-			}
+// deadlineError is returned instead of the error of the last attempt when the
+// context deadline would be exceeded before the next retry. It matches both
+// that error and context.DeadlineExceeded.
+type deadlineError struct {
+	err error
+}
 
-			bOff := b.NextBackOff()
-			if bOff == backoff.Stop {
-				return fmt.Errorf("max retry time elapsed: %w", err)
-			}
+func (e deadlineError) Error() string {
+	return "context deadline would be exceeded before the next retry: " + e.err.Error()
+}
 
-			// Wait for the greater of the backoff or throttle delay.
-			var delay time.Duration
-			if bOff > throttle {
-				delay = bOff
-			} else {
-				elapsed := b.GetElapsedTime()
-				if b.MaxElapsedTime != 0 && elapsed+throttle > b.MaxElapsedTime {
-					return fmt.Errorf("max retry time would elapse: %w", err)
-				}
-				delay = throttle
-			}
+func (e deadlineError) Unwrap() error {
+	return e.err
+}
 
-			if err := waitFunc(ctx, delay); err != nil {
-				return err
-			}
-		}
-	}
+func (e deadlineError) Is(target error) bool {
+	return target == context.DeadlineExceeded
 }
 
 // Allow override for testing.
@@ -17,10 +17,15 @@ package retry
 import (
 	"context"
 	"errors"
+	"fmt"
+	"math"
 	"testing"
 	"time"
 
+	"github.com/cenkalti/backoff/v4"
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func TestWait(t *testing.T) {
@@ -147,6 +152,38 @@ func TestBackoffRetry(t *testing.T) {
 	}), assert.AnError)
 }
 
+func TestBackoffRetryJitter(t *testing.T) {
+	ev := func(error) (bool, time.Duration) { return true, 0 }
+
+	delay := time.Millisecond
+	reqFunc := Config{
+		Enabled:         true,
+		InitialInterval: delay,
+		MaxInterval:     delay,
+		// Never stop retrying.
+		MaxElapsedTime: 0,
+	}.RequestFunc(ev)
+
+	origWait := waitFunc
+	var waits int
+	waitFunc = func(_ context.Context, d time.Duration) error {
+		// The backoff is randomized around the interval.
+		delta := math.Ceil(float64(delay) * backoff.DefaultRandomizationFactor)
+		assert.InDelta(t, delay, d, delta, "retry not backoffed")
+		waits++
+		if waits == 10 {
+			return assert.AnError
+		}
+		return nil
+	}
+	defer func() { waitFunc = origWait }()
+
+	ctx := context.Background()
+	assert.ErrorIs(t, reqFunc(ctx, func(context.Context) error {
+		return errors.New("not this error")
+	}), assert.AnError)
+}
+
 func TestThrottledRetryGreaterThanMaxElapsedTime(t *testing.T) {
 	// Ensure the throttle delay is used by making longer than backoff delay.
 	tDelay, bDelay := time.Hour, time.Nanosecond
@@ -180,6 +217,132 @@ func TestMaxElapsedTime(t *testing.T) {
 	}).Error(), "max retry time elapsed: ")
 }
 
+func TestRetryBeyondDeadline(t *testing.T) {
+	ev := func(error) (bool, time.Duration) { return true, 0 }
+	reqFunc := Config{
+		Enabled:         true,
+		InitialInterval: time.Hour,
+		MaxInterval:     time.Hour,
+	}.RequestFunc(ev)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	var attempts int
+	err := reqFunc(ctx, func(context.Context) error {
+		attempts++
+		return assert.AnError
+	})
+	assert.ErrorIs(t, err, assert.AnError)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Contains(t, err.Error(), "context deadline would be exceeded before the next retry: ")
+	assert.Equal(t, 1, attempts)
+}
+
+func TestRetryWithinDeadline(t *testing.T) {
+	ev := func(error) (bool, time.Duration) { return true, 0 }
+	reqFunc := Config{
+		Enabled:         true,
+		InitialInterval: time.Millisecond,
+		MaxInterval:     time.Millisecond,
+	}.RequestFunc(ev)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	var attempts int
+	assert.NoError(t, reqFunc(ctx, func(context.Context) error {
+		attempts++
+		if attempts < 3 {
+			return assert.AnError
+		}
+		return nil
+	}))
+	assert.Equal(t, 3, attempts)
+}
+
+// observation is the notification of an Observer.
+type observation struct {
+	retries int
+	backoff time.Duration
+	outcome Outcome
+}
+
+func TestStrategyRequestFunc(t *testing.T) {
+	ev := func(error) (bool, time.Duration) { return true, 0 }
+	type call struct {
+		attempt int
+		lastErr error
+	}
+	var calls []call
+	strategy := func(attempt int, lastErr error) (time.Duration, bool) {
+		calls = append(calls, call{attempt, lastErr})
+		return time.Duration(attempt) * time.Second, attempt > 2
+	}
+	var got []observation
+	reqFunc := Config{
+		Enabled: true,
+		// Ignored with a strategy.
+		MaxElapsedTime: time.Nanosecond,
+	}.StrategyRequestFunc(strategy, ev, func(_ context.Context, retries int, backoff time.Duration, outcome Outcome) {
+		got = append(got, observation{retries, backoff, outcome})
+	})
+
+	origWait := waitFunc
+	var delays []time.Duration
+	waitFunc = func(_ context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		return nil
+	}
+	defer func() { waitFunc = origWait }()
+
+	var attempts int
+	err := reqFunc(context.Background(), func(context.Context) error {
+		attempts++
+		return assert.AnError
+	})
+	assert.ErrorIs(t, err, assert.AnError)
+	assert.Contains(t, err.Error(), "retry strategy gave up: ")
+	assert.Equal(t, 3, attempts)
+	assert.Equal(t, []call{{1, assert.AnError}, {2, assert.AnError}, {3, assert.AnError}}, calls)
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second}, delays)
+	assert.Equal(t, []observation{{2, 3 * time.Second, OutcomeExhausted}}, got)
+}
+
+func TestStrategyRequestFuncThrottle(t *testing.T) {
+	throttleDelay := time.Minute
+	ev := func(error) (bool, time.Duration) { return true, throttleDelay }
+	reqFunc := Config{Enabled: true}.StrategyRequestFunc(func(int, error) (time.Duration, bool) {
+		return time.Second, false
+	}, ev, nil)
+
+	origWait := waitFunc
+	waitFunc = func(_ context.Context, d time.Duration) error {
+		assert.Equal(t, throttleDelay, d, "retry not throttled")
+		return assert.AnError
+	}
+	defer func() { waitFunc = origWait }()
+
+	assert.ErrorIs(t, reqFunc(context.Background(), func(context.Context) error {
+		return errors.New("not this error")
+	}), assert.AnError)
+}
+
+func TestStrategyRequestFuncContextDone(t *testing.T) {
+	ev := func(error) (bool, time.Duration) { return true, 0 }
+	reqFunc := Config{Enabled: true}.StrategyRequestFunc(func(int, error) (time.Duration, bool) {
+		return time.Hour, false
+	}, ev, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	var attempts int
+	err := reqFunc(ctx, func(context.Context) error {
+		attempts++
+		return assert.AnError
+	})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 1, attempts)
+}
+
 func TestRetryNotEnabled(t *testing.T) {
 	ev := func(error) (bool, time.Duration) {
 		t.Error("evaluated retry when not enabled")
@@ -195,3 +358,151 @@ func TestRetryNotEnabled(t *testing.T) {
 		return assert.AnError
 	}), assert.AnError)
 }
+
+func TestClassify(t *testing.T) {
+	errTransient := errors.New("transient")
+	errPermanent := errors.New("permanent")
+	evaluate := func(err error) (bool, time.Duration) {
+		return err == errTransient, time.Second
+	}
+
+	ev := Classify(evaluate, nil)
+	ok, throttle := ev(errTransient)
+	assert.True(t, ok)
+	assert.Equal(t, time.Second, throttle)
+	ok, _ = ev(errPermanent)
+	assert.False(t, ok)
+
+	ev = Classify(evaluate, func(err error) bool { return err == errPermanent })
+	ok, throttle = ev(errPermanent)
+	assert.True(t, ok)
+	assert.Equal(t, time.Second, throttle)
+	ok, _ = ev(errTransient)
+	assert.False(t, ok)
+
+	ev = Classify(func(error) (bool, time.Duration) { return true, 0 }, func(error) bool { return true })
+	for _, err := range []error{
+		context.Canceled,
+		context.DeadlineExceeded,
+		deadlineError{err: errTransient},
+		status.Error(codes.Canceled, "canceled"),
+		status.Error(codes.DeadlineExceeded, "deadline exceeded"),
+		fmt.Errorf("export failed: %w", status.FromContextError(context.DeadlineExceeded).Err()),
+	} {
+		ok, _ = ev(err)
+		assert.False(t, ok, err)
+	}
+	ok, _ = ev(status.Error(codes.Unavailable, "unavailable"))
+	assert.True(t, ok)
+}
+
+func TestRequestFuncContextDone(t *testing.T) {
+	reqFunc := Config{
+		Enabled:         true,
+		InitialInterval: time.Millisecond,
+		MaxInterval:     time.Millisecond,
+		MaxElapsedTime:  time.Minute,
+	}.RequestFunc(func(error) (bool, time.Duration) { return true, 0 })
+
+	// The request is not retried once its context is done, even though
+	// the error is reported as retry-able.
+	ctx, cancel := context.WithCancel(context.Background())
+	var attempts int
+	err := reqFunc(ctx, func(context.Context) error {
+		attempts++
+		cancel()
+		return assert.AnError
+	})
+	assert.ErrorIs(t, err, assert.AnError)
+	assert.Equal(t, 1, attempts)
+}
+
+func TestObservedRequestFunc(t *testing.T) {
+	origWait := waitFunc
+	waitFunc = func(context.Context, time.Duration) error { return nil }
+	defer func() { waitFunc = origWait }()
+
+	errPermanent := errors.New("permanent")
+	ev := func(err error) (bool, time.Duration) { return err != errPermanent, time.Millisecond }
+	newReqFunc := func(maxElapsed time.Duration) (RequestFunc, *[]observation) {
+		var got []observation
+		return Config{
+			Enabled:         true,
+			InitialInterval: time.Nanosecond,
+			MaxInterval:     time.Nanosecond,
+			MaxElapsedTime:  maxElapsed,
+		}.ObservedRequestFunc(ev, func(_ context.Context, retries int, backoff time.Duration, outcome Outcome) {
+			got = append(got, observation{retries, backoff, outcome})
+		}), &got
+	}
+	// failing returns a request failing n times with err before succeeding.
+	failing := func(n int, err error) func(context.Context) error {
+		return func(context.Context) error {
+			if n == 0 {
+				return nil
+			}
+			n--
+			return err
+		}
+	}
+	ctx := context.Background()
+
+	t.Run("NotRetried", func(t *testing.T) {
+		reqFunc, got := newReqFunc(0)
+		assert.NoError(t, reqFunc(ctx, failing(0, nil)))
+		assert.ErrorIs(t, reqFunc(ctx, failing(1, errPermanent)), errPermanent)
+		assert.Empty(t, *got)
+	})
+
+	t.Run("Success", func(t *testing.T) {
+		reqFunc, got := newReqFunc(0)
+		assert.NoError(t, reqFunc(ctx, failing(2, assert.AnError)))
+		assert.Equal(t, []observation{{2, 2 * time.Millisecond, OutcomeSuccess}}, *got)
+	})
+
+	t.Run("Failed", func(t *testing.T) {
+		reqFunc, got := newReqFunc(0)
+		fails := 0
+		assert.ErrorIs(t, reqFunc(ctx, func(context.Context) error {
+			fails++
+			if fails > 1 {
+				return errPermanent
+			}
+			return assert.AnError
+		}), errPermanent)
+		assert.Equal(t, []observation{{1, time.Millisecond, OutcomeFailed}}, *got)
+	})
+
+	t.Run("Exhausted", func(t *testing.T) {
+		waitFunc = func(context.Context, time.Duration) error {
+			time.Sleep(time.Millisecond)
+			return nil
+		}
+		defer func() { waitFunc = func(context.Context, time.Duration) error { return nil } }()
+		// The throttle delay of the third retry would exceed the maximum
+		// elapsed time.
+		reqFunc, got := newReqFunc(2500 * time.Microsecond)
+		assert.Contains(t, reqFunc(ctx, failing(10, assert.AnError)).Error(), "max retry time")
+		if assert.Len(t, *got, 1) {
+			assert.Equal(t, OutcomeExhausted, (*got)[0].outcome)
+			assert.Equal(t, time.Duration((*got)[0].retries)*time.Millisecond, (*got)[0].backoff)
+		}
+	})
+
+	t.Run("Canceled", func(t *testing.T) {
+		reqFunc, got := newReqFunc(0)
+		ctx, cancel := context.WithCancel(ctx)
+		retried := false
+		waitFunc = func(ctx context.Context, _ time.Duration) error {
+			if retried {
+				cancel()
+				return ctx.Err()
+			}
+			retried = true
+			return nil
+		}
+		defer func() { waitFunc = func(context.Context, time.Duration) error { return nil } }()
+		assert.ErrorIs(t, reqFunc(ctx, failing(10, assert.AnError)), context.Canceled)
+		assert.Equal(t, []observation{{1, time.Millisecond, OutcomeCanceled}}, *got)
+	})
+}
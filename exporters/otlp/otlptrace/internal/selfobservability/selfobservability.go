@@ -0,0 +1,198 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package selfobservability provides the instruments the OTLP trace clients
+// use to report on their own operation.
+package selfobservability // import "go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/selfobservability"
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/retry"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/unit"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+const (
+	instrumentationName = "go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+
+	// ExportedSpansName is the name of the counter of spans successfully
+	// exported.
+	ExportedSpansName = "otlp.exporter.exported_spans"
+	// FailedSpansName is the name of the counter of spans dropped because
+	// their export failed.
+	FailedSpansName = "otlp.exporter.failed_spans"
+	// SampledOutSpansName is the name of the counter of spans dropped by
+	// the export sampler.
+	SampledOutSpansName = "otlp.exporter.sampled_out_spans"
+	// ExportAttemptsName is the name of the counter of requests sent to the
+	// collector, retries included.
+	ExportAttemptsName = "otlp.exporter.export_attempts"
+	// ExportDurationName is the name of the histogram of export latencies,
+	// in milliseconds.
+	ExportDurationName = "otlp.exporter.export_duration"
+	// RetriesName is the name of the counter of retries of failed export
+	// requests.
+	RetriesName = "otlp.exporter.retries"
+	// RetriesExhaustedName is the name of the counter of export requests
+	// abandoned because the maximum retry time elapsed.
+	RetriesExhaustedName = "otlp.exporter.retries_exhausted"
+	// RetryBackoffName is the name of the histogram of the total time spent
+	// waiting before the retries of an export request, in milliseconds.
+	RetryBackoffName = "otlp.exporter.retry_backoff"
+
+	// ProtocolKey is the attribute key identifying the protocol used by the
+	// client recording a measurement.
+	ProtocolKey = attribute.Key("protocol")
+	// OutcomeKey is the attribute key identifying the terminal outcome of a
+	// retried export request.
+	OutcomeKey = attribute.Key("outcome")
+)
+
+// Instruments records measurements about the exports of a client. A nil
+// *Instruments is valid and records nothing.
+type Instruments struct {
+	exported   metric.Int64Counter
+	failed     metric.Int64Counter
+	sampledOut metric.Int64Counter
+	attempts   metric.Int64Counter
+	duration   metric.Float64Histogram
+
+	retries   metric.Int64Counter
+	exhausted metric.Int64Counter
+	backoff   metric.Float64Histogram
+
+	attrs []attribute.KeyValue
+}
+
+// New returns the Instruments created from mp, with every measurement
+// recorded with the protocol attribute set to protocol. It returns nil if mp
+// is nil. Instruments that cannot be created are reported to errHandler, or
+// to the global error handler if errHandler is nil, and left as no-ops.
+func New(mp metric.MeterProvider, protocol string, errHandler otel.ErrorHandler) *Instruments {
+	if mp == nil {
+		return nil
+	}
+	if errHandler == nil {
+		errHandler = otel.ErrorHandlerFunc(otel.Handle)
+	}
+	meter := mp.Meter(instrumentationName)
+	i := &Instruments{attrs: []attribute.KeyValue{ProtocolKey.String(protocol)}}
+
+	var err error
+	if i.exported, err = meter.NewInt64Counter(ExportedSpansName,
+		metric.WithDescription("Number of spans successfully exported"),
+		metric.WithUnit(unit.Dimensionless)); err != nil {
+		errHandler.Handle(err)
+	}
+	if i.failed, err = meter.NewInt64Counter(FailedSpansName,
+		metric.WithDescription("Number of spans dropped because their export failed"),
+		metric.WithUnit(unit.Dimensionless)); err != nil {
+		errHandler.Handle(err)
+	}
+	if i.sampledOut, err = meter.NewInt64Counter(SampledOutSpansName,
+		metric.WithDescription("Number of spans dropped by the export sampler"),
+		metric.WithUnit(unit.Dimensionless)); err != nil {
+		errHandler.Handle(err)
+	}
+	if i.attempts, err = meter.NewInt64Counter(ExportAttemptsName,
+		metric.WithDescription("Number of export requests sent, retries included"),
+		metric.WithUnit(unit.Dimensionless)); err != nil {
+		errHandler.Handle(err)
+	}
+	if i.duration, err = meter.NewFloat64Histogram(ExportDurationName,
+		metric.WithDescription("Duration of exports, retries included"),
+		metric.WithUnit(unit.Milliseconds)); err != nil {
+		errHandler.Handle(err)
+	}
+	if i.retries, err = meter.NewInt64Counter(RetriesName,
+		metric.WithDescription("Number of retries of failed export requests"),
+		metric.WithUnit(unit.Dimensionless)); err != nil {
+		errHandler.Handle(err)
+	}
+	if i.exhausted, err = meter.NewInt64Counter(RetriesExhaustedName,
+		metric.WithDescription("Number of export requests abandoned after the maximum retry time"),
+		metric.WithUnit(unit.Dimensionless)); err != nil {
+		errHandler.Handle(err)
+	}
+	if i.backoff, err = meter.NewFloat64Histogram(RetryBackoffName,
+		metric.WithDescription("Total time waited before the retries of an export request"),
+		metric.WithUnit(unit.Milliseconds)); err != nil {
+		errHandler.Handle(err)
+	}
+	return i
+}
+
+// Attempt records a single request sent to the collector.
+func (i *Instruments) Attempt(ctx context.Context) {
+	if i == nil {
+		return
+	}
+	i.attempts.Add(ctx, 1, i.attrs...)
+}
+
+// Exported records the outcome of an export started at start, the spans of
+// the sent batches being counted as exported and the ones of the failed
+// batches, not sent or rejected, as failed.
+func (i *Instruments) Exported(ctx context.Context, sent, failed [][]*tracepb.ResourceSpans, start time.Time) {
+	if i == nil {
+		return
+	}
+	i.duration.Record(ctx, float64(time.Since(start))/float64(time.Millisecond), i.attrs...)
+
+	if n := spanCount(sent); n > 0 {
+		i.exported.Add(ctx, n, i.attrs...)
+	}
+	if n := spanCount(failed); n > 0 {
+		i.failed.Add(ctx, n, i.attrs...)
+	}
+}
+
+// SampledOut records n spans dropped by the export sampler.
+func (i *Instruments) SampledOut(ctx context.Context, n int) {
+	if i == nil {
+		return
+	}
+	i.sampledOut.Add(ctx, int64(n), i.attrs...)
+}
+
+// Retried records the retries of an export request, which waited backoff in
+// total before them and completed with outcome. It is a retry.Observer.
+func (i *Instruments) Retried(ctx context.Context, retries int, backoff time.Duration, outcome retry.Outcome) {
+	if i == nil {
+		return
+	}
+	attrs := append([]attribute.KeyValue{OutcomeKey.String(string(outcome))}, i.attrs...)
+	i.retries.Add(ctx, int64(retries), attrs...)
+	i.backoff.Record(ctx, float64(backoff)/float64(time.Millisecond), attrs...)
+	if outcome == retry.OutcomeExhausted {
+		i.exhausted.Add(ctx, 1, i.attrs...)
+	}
+}
+
+func spanCount(batches [][]*tracepb.ResourceSpans) int64 {
+	var n int64
+	for _, batch := range batches {
+		for _, rs := range batch {
+			for _, ils := range rs.InstrumentationLibrarySpans {
+				n += int64(len(ils.Spans))
+			}
+		}
+	}
+	return n
+}
@@ -0,0 +1,123 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package selfobservability
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/retry"
+	"go.opentelemetry.io/otel/metric/metrictest"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+var batches = [][]*tracepb.ResourceSpans{
+	{{
+		InstrumentationLibrarySpans: []*tracepb.InstrumentationLibrarySpans{
+			{Spans: []*tracepb.Span{{Name: "a"}, {Name: "b"}}},
+		},
+	}},
+	{{
+		InstrumentationLibrarySpans: []*tracepb.InstrumentationLibrarySpans{
+			{Spans: []*tracepb.Span{{Name: "c"}}},
+		},
+	}},
+}
+
+// sums returns the sum of the measurements recorded by each instrument.
+func sums(mp *metrictest.MeterProvider) map[string]float64 {
+	s := make(map[string]float64)
+	for _, b := range mp.MeasurementBatches {
+		for _, m := range b.Measurements {
+			desc := m.Instrument.Descriptor()
+			s[desc.Name()] += m.Number.CoerceToFloat64(desc.NumberKind())
+		}
+	}
+	return s
+}
+
+func TestNilInstruments(t *testing.T) {
+	i := New(nil, "grpc", nil)
+	assert.Nil(t, i)
+	assert.NotPanics(t, func() {
+		i.Attempt(context.Background())
+		i.Exported(context.Background(), batches, nil, time.Now())
+		i.SampledOut(context.Background(), 1)
+		i.Retried(context.Background(), 1, time.Second, retry.OutcomeSuccess)
+	})
+}
+
+func TestInstruments(t *testing.T) {
+	mp := metrictest.NewMeterProvider()
+	i := New(mp, "grpc", nil)
+	ctx := context.Background()
+
+	i.Attempt(ctx)
+	i.Exported(ctx, batches, nil, time.Now())
+	i.Attempt(ctx)
+	i.Attempt(ctx)
+	// The first batch was sent before the second one failed.
+	i.Exported(ctx, batches[:1], batches[1:], time.Now())
+	i.SampledOut(ctx, 2)
+
+	got := sums(mp)
+	assert.Equal(t, float64(5), got[ExportedSpansName])
+	assert.Equal(t, float64(1), got[FailedSpansName])
+	assert.Equal(t, float64(2), got[SampledOutSpansName])
+	assert.Equal(t, float64(3), got[ExportAttemptsName])
+	assert.Contains(t, got, ExportDurationName)
+
+	for _, b := range mp.MeasurementBatches {
+		assert.Equal(t, instrumentationName, b.Library.InstrumentationName)
+		assert.Equal(t, []attribute.KeyValue{ProtocolKey.String("grpc")}, b.Labels)
+	}
+}
+
+func TestInstrumentsRetried(t *testing.T) {
+	mp := metrictest.NewMeterProvider()
+	i := New(mp, "http/protobuf", nil)
+	ctx := context.Background()
+
+	i.Retried(ctx, 2, 3*time.Second, retry.OutcomeSuccess)
+	i.Retried(ctx, 4, 5*time.Second, retry.OutcomeExhausted)
+	i.Retried(ctx, 1, time.Second, retry.OutcomeCanceled)
+
+	got := sums(mp)
+	assert.Equal(t, float64(7), got[RetriesName])
+	assert.Equal(t, float64(1), got[RetriesExhaustedName])
+	assert.Equal(t, float64(9000), got[RetryBackoffName])
+
+	outcomes := make(map[string]float64)
+	for _, b := range mp.MeasurementBatches {
+		set := attribute.NewSet(b.Labels...)
+		protocol, _ := set.Value(ProtocolKey)
+		assert.Equal(t, "http/protobuf", protocol.AsString())
+		outcome, _ := set.Value(OutcomeKey)
+		for _, m := range b.Measurements {
+			if m.Instrument.Descriptor().Name() == RetriesName {
+				outcomes[outcome.AsString()] += float64(m.Number.AsInt64())
+			}
+		}
+	}
+	assert.Equal(t, map[string]float64{
+		"success":           2,
+		"exhausted":         4,
+		"context-cancelled": 1,
+	}, outcomes)
+}
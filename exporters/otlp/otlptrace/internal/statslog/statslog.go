@@ -0,0 +1,158 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package statslog periodically reports a summary of the exports to an error
+// handler.
+package statslog // import "go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/statslog"
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+// Reporter counts the spans sent and dropped and the export errors, and
+// reports them, with the connection status, at the end of each interval. A
+// nil Reporter counts and reports nothing.
+type Reporter struct {
+	// The counts are first to be 64-bit aligned for atomic operations.
+	sent    int64
+	dropped int64
+	errors  int64
+
+	interval   time.Duration
+	errHandler otel.ErrorHandler
+
+	mu      sync.Mutex
+	stop    chan struct{}
+	done    chan struct{}
+	stopped bool
+}
+
+// New returns a Reporter sending a summary to errHandler every interval, or
+// nil if interval is not positive.
+func New(interval time.Duration, errHandler otel.ErrorHandler) *Reporter {
+	if interval <= 0 {
+		return nil
+	}
+	return &Reporter{interval: interval, errHandler: errHandler}
+}
+
+// Sent counts the spans of batches as sent.
+func (r *Reporter) Sent(batches [][]*tracepb.ResourceSpans) {
+	if r == nil {
+		return
+	}
+	atomic.AddInt64(&r.sent, spanCount(batches))
+}
+
+// Dropped counts n spans as dropped.
+func (r *Reporter) Dropped(n int) {
+	if r == nil {
+		return
+	}
+	atomic.AddInt64(&r.dropped, int64(n))
+}
+
+// DroppedSpans counts the spans of batches as dropped.
+func (r *Reporter) DroppedSpans(batches [][]*tracepb.ResourceSpans) {
+	if r == nil {
+		return
+	}
+	atomic.AddInt64(&r.dropped, spanCount(batches))
+}
+
+// Record counts err as an export error, unless it is nil.
+func (r *Reporter) Record(err error) {
+	if r == nil || err == nil {
+		return
+	}
+	atomic.AddInt64(&r.errors, 1)
+}
+
+// Start starts reporting the summaries, with the connection status returned
+// by status, until r is stopped. It does nothing if r is already started or
+// stopped.
+func (r *Reporter) Start(status func() otlptrace.ConnectionStatus) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stop != nil || r.stopped {
+		return
+	}
+	r.stop = make(chan struct{})
+	r.done = make(chan struct{})
+	go r.run(status)
+}
+
+// Stop stops reporting the summaries and waits for the reporting goroutine to
+// return.
+func (r *Reporter) Stop() {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stopped {
+		return
+	}
+	r.stopped = true
+	if r.stop != nil {
+		close(r.stop)
+		<-r.done
+	}
+}
+
+func (r *Reporter) run(status func() otlptrace.ConnectionStatus) {
+	defer close(r.done)
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			r.errHandler.Handle(r.summary(status()))
+		case <-r.stop:
+			return
+		}
+	}
+}
+
+// summary returns the summary of the period ending, resetting the counts.
+func (r *Reporter) summary(status otlptrace.ConnectionStatus) otlptrace.StatsSummary {
+	return otlptrace.StatsSummary{
+		Period:       r.interval,
+		SentSpans:    atomic.SwapInt64(&r.sent, 0),
+		DroppedSpans: atomic.SwapInt64(&r.dropped, 0),
+		Errors:       atomic.SwapInt64(&r.errors, 0),
+		Status:       status,
+	}
+}
+
+func spanCount(batches [][]*tracepb.ResourceSpans) int64 {
+	var n int64
+	for _, batch := range batches {
+		for _, rs := range batch {
+			for _, ils := range rs.InstrumentationLibrarySpans {
+				n += int64(len(ils.Spans))
+			}
+		}
+	}
+	return n
+}
@@ -0,0 +1,105 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statslog
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+var batches = [][]*tracepb.ResourceSpans{
+	{{
+		InstrumentationLibrarySpans: []*tracepb.InstrumentationLibrarySpans{
+			{Spans: []*tracepb.Span{{Name: "a"}, {Name: "b"}}},
+		},
+	}},
+	{{
+		InstrumentationLibrarySpans: []*tracepb.InstrumentationLibrarySpans{
+			{Spans: []*tracepb.Span{{Name: "c"}}},
+		},
+	}},
+}
+
+func TestNilReporter(t *testing.T) {
+	r := New(0, nil)
+	assert.Nil(t, r)
+	assert.NotPanics(t, func() {
+		r.Sent(batches)
+		r.Dropped(1)
+		r.DroppedSpans(batches)
+		r.Record(errors.New("failed"))
+		r.Start(nil)
+		r.Stop()
+	})
+}
+
+func TestReporter(t *testing.T) {
+	summaries := make(chan error, 16)
+	r := New(10*time.Millisecond, otel.ErrorHandlerFunc(func(err error) {
+		summaries <- err
+	}))
+	status := otlptrace.ConnectionStatus{LastError: errors.New("unreachable")}
+
+	r.Sent(batches)
+	r.Dropped(2)
+	r.DroppedSpans(batches)
+	r.Record(errors.New("failed"))
+	r.Record(nil)
+	r.Start(func() otlptrace.ConnectionStatus { return status })
+
+	var s otlptrace.StatsSummary
+	require.ErrorAs(t, <-summaries, &s)
+	assert.Equal(t, otlptrace.StatsSummary{
+		Period:       10 * time.Millisecond,
+		SentSpans:    3,
+		DroppedSpans: 5,
+		Errors:       1,
+		Status:       status,
+	}, s)
+	assert.EqualError(t, s, "OTLP trace exporter stats for the last 10ms: 3 spans sent, 5 spans dropped, 1 export errors, disconnected: unreachable")
+
+	// The counts are reset at the end of each period.
+	require.ErrorAs(t, <-summaries, &s)
+	assert.Zero(t, s.SentSpans)
+	assert.Zero(t, s.DroppedSpans)
+	assert.Zero(t, s.Errors)
+
+	// No summary is reported once stopped, nor restarted.
+	r.Stop()
+	r.Stop()
+	r.Start(func() otlptrace.ConnectionStatus { return status })
+	for len(summaries) > 0 {
+		<-summaries
+	}
+	time.Sleep(30 * time.Millisecond)
+	assert.Empty(t, summaries)
+}
+
+func TestStopNotStarted(t *testing.T) {
+	r := New(time.Millisecond, otel.ErrorHandlerFunc(func(err error) {
+		t.Errorf("unexpected summary: %v", err)
+	}))
+	r.Stop()
+	r.Start(func() otlptrace.ConnectionStatus { return otlptrace.ConnectionStatus{} })
+	time.Sleep(10 * time.Millisecond)
+}
@@ -16,7 +16,9 @@ package tracetransform // import "go.opentelemetry.io/otel/exporters/otlp/otlptr
 
 import (
 	"go.opentelemetry.io/otel/sdk/resource"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
 	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
 )
 
 // Resource transforms a Resource into an OTLP Resource.
@@ -26,3 +28,52 @@ func Resource(r *resource.Resource) *resourcepb.Resource {
 	}
 	return &resourcepb.Resource{Attributes: ResourceAttributes(r)}
 }
+
+// MergeResourceAttributes returns copies of rss whose resource also has
+// attrs. An attribute of attrs whose key the resource already has replaces
+// the existing attribute if override is true, and is ignored otherwise. rss
+// are not modified, their spans are shared by the copies.
+func MergeResourceAttributes(rss []*tracepb.ResourceSpans, attrs []*commonpb.KeyValue, override bool) []*tracepb.ResourceSpans {
+	if len(attrs) == 0 {
+		return rss
+	}
+
+	out := make([]*tracepb.ResourceSpans, 0, len(rss))
+	for _, rs := range rss {
+		if rs == nil {
+			out = append(out, rs)
+			continue
+		}
+		res := &resourcepb.Resource{
+			Attributes:             mergeAttributes(rs.GetResource().GetAttributes(), attrs, override),
+			DroppedAttributesCount: rs.GetResource().GetDroppedAttributesCount(),
+		}
+		out = append(out, &tracepb.ResourceSpans{
+			Resource:                    res,
+			InstrumentationLibrarySpans: rs.InstrumentationLibrarySpans,
+			SchemaUrl:                   rs.SchemaUrl,
+		})
+	}
+	return out
+}
+
+// mergeAttributes returns a new slice holding existing merged with attrs.
+func mergeAttributes(existing, attrs []*commonpb.KeyValue, override bool) []*commonpb.KeyValue {
+	idx := make(map[string]int, len(existing)+len(attrs))
+	merged := make([]*commonpb.KeyValue, 0, len(existing)+len(attrs))
+	for _, kv := range existing {
+		idx[kv.GetKey()] = len(merged)
+		merged = append(merged, kv)
+	}
+	for _, kv := range attrs {
+		i, ok := idx[kv.GetKey()]
+		switch {
+		case !ok:
+			idx[kv.GetKey()] = len(merged)
+			merged = append(merged, kv)
+		case override:
+			merged[i] = kv
+		}
+	}
+	return merged
+}
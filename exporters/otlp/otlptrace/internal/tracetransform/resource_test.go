@@ -18,9 +18,13 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
 
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/sdk/resource"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
 )
 
 func TestNilResource(t *testing.T) {
@@ -46,3 +50,50 @@ func TestResourceAttributes(t *testing.T) {
 	}
 	assert.ElementsMatch(t, KeyValues(attrs), got)
 }
+
+func TestMergeResourceAttributes(t *testing.T) {
+	kv := func(k, v string) *commonpb.KeyValue {
+		return &commonpb.KeyValue{
+			Key:   k,
+			Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: v}},
+		}
+	}
+	newRSS := func() []*tracepb.ResourceSpans {
+		return []*tracepb.ResourceSpans{
+			{
+				Resource:  &resourcepb.Resource{Attributes: []*commonpb.KeyValue{kv("service.name", "svc"), kv("region", "eu")}},
+				SchemaUrl: "https://opentelemetry.io/schemas/1.7.0",
+				InstrumentationLibrarySpans: []*tracepb.InstrumentationLibrarySpans{
+					{Spans: []*tracepb.Span{{Name: "span"}}},
+				},
+			},
+			{},
+		}
+	}
+	attrs := []*commonpb.KeyValue{kv("region", "us"), kv("k8s.pod.name", "pod")}
+	strs := func(kvs []*commonpb.KeyValue) []string {
+		var out []string
+		for _, kv := range kvs {
+			out = append(out, kv.Key+"="+kv.Value.GetStringValue())
+		}
+		return out
+	}
+
+	rss := newRSS()
+	got := MergeResourceAttributes(rss, attrs, false)
+	assert.True(t, proto.Equal(newRSS()[0], rss[0]), "input modified")
+	if assert.Len(t, got, 2) {
+		assert.Equal(t, []string{"service.name=svc", "region=eu", "k8s.pod.name=pod"}, strs(got[0].Resource.Attributes))
+		assert.Equal(t, rss[0].SchemaUrl, got[0].SchemaUrl)
+		assert.Same(t, rss[0].InstrumentationLibrarySpans[0], got[0].InstrumentationLibrarySpans[0])
+		assert.Equal(t, []string{"region=us", "k8s.pod.name=pod"}, strs(got[1].Resource.Attributes))
+	}
+
+	got = MergeResourceAttributes(rss, attrs, true)
+	assert.True(t, proto.Equal(newRSS()[0], rss[0]), "input modified")
+	if assert.Len(t, got, 2) {
+		assert.Equal(t, []string{"service.name=svc", "region=us", "k8s.pod.name=pod"}, strs(got[0].Resource.Attributes))
+	}
+
+	assert.Len(t, MergeResourceAttributes(rss, nil, true), 2)
+}
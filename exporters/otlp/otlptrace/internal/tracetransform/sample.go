@@ -0,0 +1,69 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracetransform // import "go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/tracetransform"
+
+import tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+
+// SampleSpans returns copies of rss without the spans sample returns false
+// for, and the number of spans dropped. The resources and instrumentation
+// libraries left without spans are removed. rss are not modified, and are
+// returned as is if no span is dropped or sample is nil.
+func SampleSpans(rss []*tracepb.ResourceSpans, sample func(*tracepb.Span) bool) ([]*tracepb.ResourceSpans, int) {
+	if sample == nil {
+		return rss, 0
+	}
+
+	var dropped int
+	out := make([]*tracepb.ResourceSpans, 0, len(rss))
+	for _, rs := range rss {
+		if rs == nil {
+			continue
+		}
+		ilss := make([]*tracepb.InstrumentationLibrarySpans, 0, len(rs.InstrumentationLibrarySpans))
+		for _, ils := range rs.InstrumentationLibrarySpans {
+			if ils == nil {
+				continue
+			}
+			spans := make([]*tracepb.Span, 0, len(ils.Spans))
+			for _, s := range ils.Spans {
+				if s != nil && !sample(s) {
+					dropped++
+					continue
+				}
+				spans = append(spans, s)
+			}
+			if len(spans) == 0 {
+				continue
+			}
+			ilss = append(ilss, &tracepb.InstrumentationLibrarySpans{
+				InstrumentationLibrary: ils.InstrumentationLibrary,
+				Spans:                  spans,
+				SchemaUrl:              ils.SchemaUrl,
+			})
+		}
+		if len(ilss) == 0 {
+			continue
+		}
+		out = append(out, &tracepb.ResourceSpans{
+			Resource:                    rs.Resource,
+			InstrumentationLibrarySpans: ilss,
+			SchemaUrl:                   rs.SchemaUrl,
+		})
+	}
+	if dropped == 0 {
+		return rss, 0
+	}
+	return out, dropped
+}
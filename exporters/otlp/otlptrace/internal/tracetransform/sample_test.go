@@ -0,0 +1,68 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracetransform
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+func TestSampleSpans(t *testing.T) {
+	span := func(name string) *tracepb.Span { return &tracepb.Span{Name: name} }
+	rss := []*tracepb.ResourceSpans{
+		{
+			SchemaUrl: "schema",
+			InstrumentationLibrarySpans: []*tracepb.InstrumentationLibrarySpans{
+				{Spans: []*tracepb.Span{span("a"), span("drop"), span("b")}},
+				{Spans: []*tracepb.Span{span("drop")}},
+			},
+		},
+		{
+			InstrumentationLibrarySpans: []*tracepb.InstrumentationLibrarySpans{
+				{Spans: []*tracepb.Span{span("drop"), span("drop")}},
+			},
+		},
+	}
+	sample := func(s *tracepb.Span) bool { return s.Name != "drop" }
+
+	got, dropped := SampleSpans(rss, sample)
+	assert.Equal(t, 4, dropped)
+	// The resources and instrumentation libraries without spans left are
+	// omitted.
+	require.Len(t, got, 1)
+	assert.Equal(t, "schema", got[0].SchemaUrl)
+	require.Len(t, got[0].InstrumentationLibrarySpans, 1)
+	assert.Equal(t, []*tracepb.Span{span("a"), span("b")}, got[0].InstrumentationLibrarySpans[0].Spans)
+
+	// The spans passed are not modified.
+	assert.Len(t, rss[0].InstrumentationLibrarySpans[0].Spans, 3)
+
+	kept := []*tracepb.ResourceSpans{got[0]}
+	got, dropped = SampleSpans(kept, sample)
+	assert.Zero(t, dropped)
+	assert.Equal(t, kept, got)
+
+	got, dropped = SampleSpans(rss, nil)
+	assert.Zero(t, dropped)
+	assert.Equal(t, rss, got)
+
+	got, dropped = SampleSpans(rss, func(*tracepb.Span) bool { return false })
+	assert.Equal(t, 6, dropped)
+	assert.Empty(t, got)
+}
@@ -0,0 +1,113 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracetransform // import "go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/tracetransform"
+
+import (
+	"bytes"
+	"sort"
+
+	"google.golang.org/protobuf/proto"
+
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+// SortSpans returns copies of rss sorted in a stable order, whatever the
+// order of rss: the resources by their deterministic protobuf encoding and
+// schema URL, the instrumentation libraries of each resource by name,
+// version and schema URL, and the spans of each library by start time, trace
+// ID, span ID and name. rss are not modified, the spans are not copied.
+func SortSpans(rss []*tracepb.ResourceSpans) []*tracepb.ResourceSpans {
+	type resourceKey struct {
+		rs  *tracepb.ResourceSpans
+		key []byte
+	}
+	keys := make([]resourceKey, 0, len(rss))
+	for _, rs := range rss {
+		if rs == nil {
+			keys = append(keys, resourceKey{})
+			continue
+		}
+		ilss := make([]*tracepb.InstrumentationLibrarySpans, 0, len(rs.InstrumentationLibrarySpans))
+		for _, ils := range rs.InstrumentationLibrarySpans {
+			if ils == nil {
+				ilss = append(ilss, ils)
+				continue
+			}
+			spans := append([]*tracepb.Span(nil), ils.Spans...)
+			sort.SliceStable(spans, func(i, j int) bool {
+				return spanLess(spans[i], spans[j])
+			})
+			ilss = append(ilss, &tracepb.InstrumentationLibrarySpans{
+				InstrumentationLibrary: ils.InstrumentationLibrary,
+				Spans:                  spans,
+				SchemaUrl:              ils.SchemaUrl,
+			})
+		}
+		sort.SliceStable(ilss, func(i, j int) bool {
+			return librarySpansLess(ilss[i], ilss[j])
+		})
+		// Deterministic encodings of equal resources are equal, an error
+		// leaves the key empty.
+		key, _ := proto.MarshalOptions{Deterministic: true}.Marshal(rs.Resource)
+		keys = append(keys, resourceKey{
+			rs: &tracepb.ResourceSpans{
+				Resource:                    rs.Resource,
+				InstrumentationLibrarySpans: ilss,
+				SchemaUrl:                   rs.SchemaUrl,
+			},
+			key: key,
+		})
+	}
+	sort.SliceStable(keys, func(i, j int) bool {
+		if c := bytes.Compare(keys[i].key, keys[j].key); c != 0 {
+			return c < 0
+		}
+		return keys[i].rs.GetSchemaUrl() < keys[j].rs.GetSchemaUrl()
+	})
+
+	out := make([]*tracepb.ResourceSpans, len(keys))
+	for i, k := range keys {
+		out[i] = k.rs
+	}
+	return out
+}
+
+// librarySpansLess reports whether a is sorted before b.
+func librarySpansLess(a, b *tracepb.InstrumentationLibrarySpans) bool {
+	switch {
+	case a == nil || b == nil:
+		return a == nil && b != nil
+	case a.InstrumentationLibrary.GetName() != b.InstrumentationLibrary.GetName():
+		return a.InstrumentationLibrary.GetName() < b.InstrumentationLibrary.GetName()
+	case a.InstrumentationLibrary.GetVersion() != b.InstrumentationLibrary.GetVersion():
+		return a.InstrumentationLibrary.GetVersion() < b.InstrumentationLibrary.GetVersion()
+	}
+	return a.SchemaUrl < b.SchemaUrl
+}
+
+// spanLess reports whether a is sorted before b.
+func spanLess(a, b *tracepb.Span) bool {
+	switch {
+	case a == nil || b == nil:
+		return a == nil && b != nil
+	case a.StartTimeUnixNano != b.StartTimeUnixNano:
+		return a.StartTimeUnixNano < b.StartTimeUnixNano
+	case !bytes.Equal(a.TraceId, b.TraceId):
+		return bytes.Compare(a.TraceId, b.TraceId) < 0
+	case !bytes.Equal(a.SpanId, b.SpanId):
+		return bytes.Compare(a.SpanId, b.SpanId) < 0
+	}
+	return a.Name < b.Name
+}
@@ -0,0 +1,104 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracetransform
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+func TestSortSpans(t *testing.T) {
+	start := time.Unix(1000, 0)
+	var stubs tracetest.SpanStubs
+	for i, service := range []string{"b", "a", "c"} {
+		res := resource.NewSchemaless(attribute.String("service.name", service))
+		for j, lib := range []string{"y", "x"} {
+			for k := 0; k < 3; k++ {
+				stubs = append(stubs, tracetest.SpanStub{
+					Name: "span",
+					SpanContext: trace.NewSpanContext(trace.SpanContextConfig{
+						TraceID: trace.TraceID{byte(i + 1)},
+						SpanID:  trace.SpanID{byte(j + 1), byte(k + 1)},
+					}),
+					// The spans of library y start at the same time.
+					StartTime:              start.Add(time.Duration(j*k) * time.Second),
+					Resource:               res,
+					InstrumentationLibrary: instrumentation.Library{Name: lib},
+				})
+			}
+		}
+	}
+
+	// The requests of shuffled spans are identical once sorted.
+	var want []byte
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 10; i++ {
+		rng.Shuffle(len(stubs), func(i, j int) { stubs[i], stubs[j] = stubs[j], stubs[i] })
+		rss := SortSpans(Spans(stubs.Snapshots()))
+		got, err := proto.MarshalOptions{Deterministic: true}.Marshal(&coltracepb.ExportTraceServiceRequest{ResourceSpans: rss})
+		require.NoError(t, err)
+		if want == nil {
+			want = got
+			continue
+		}
+		assert.Equal(t, want, got, "shuffle %d", i)
+	}
+
+	rss := SortSpans(Spans(stubs.Snapshots()))
+	require.Len(t, rss, 3)
+	for i, rs := range rss {
+		assert.Equal(t, []string{"a", "b", "c"}[i], rs.Resource.Attributes[0].Value.GetStringValue())
+		require.Len(t, rs.InstrumentationLibrarySpans, 2)
+		assert.Equal(t, "x", rs.InstrumentationLibrarySpans[0].InstrumentationLibrary.Name)
+		assert.Equal(t, "y", rs.InstrumentationLibrarySpans[1].InstrumentationLibrary.Name)
+		spans := rs.InstrumentationLibrarySpans[0].Spans
+		require.Len(t, spans, 3)
+		for i := 1; i < len(spans); i++ {
+			assert.Less(t, spans[i-1].StartTimeUnixNano, spans[i].StartTimeUnixNano)
+		}
+		// The spans starting at the same time are sorted by ID.
+		spans = rs.InstrumentationLibrarySpans[1].Spans
+		require.Len(t, spans, 3)
+		for i := 1; i < len(spans); i++ {
+			assert.Less(t, string(spans[i-1].SpanId), string(spans[i].SpanId))
+		}
+	}
+}
+
+func TestSortSpansDoesNotModify(t *testing.T) {
+	rss := []*tracepb.ResourceSpans{{
+		InstrumentationLibrarySpans: []*tracepb.InstrumentationLibrarySpans{
+			{Spans: []*tracepb.Span{{Name: "b", StartTimeUnixNano: 2}, {Name: "a", StartTimeUnixNano: 1}}},
+		},
+	}}
+	got := SortSpans(rss)
+	require.Len(t, got, 1)
+	assert.Equal(t, "a", got[0].InstrumentationLibrarySpans[0].Spans[0].Name)
+	// The spans passed are not modified.
+	assert.Equal(t, "b", rss[0].InstrumentationLibrarySpans[0].Spans[0].Name)
+}
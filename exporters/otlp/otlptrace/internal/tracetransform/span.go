@@ -15,6 +15,8 @@
 package tracetransform // import "go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/tracetransform"
 
 import (
+	"google.golang.org/protobuf/proto"
+
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/sdk/instrumentation"
@@ -219,3 +221,49 @@ func spanKind(kind trace.SpanKind) tracepb.Span_SpanKind {
 		return tracepb.Span_SPAN_KIND_UNSPECIFIED
 	}
 }
+
+// TransformSpans returns copies of rss whose spans are copies of those of rss
+// modified by each of transforms, in order. rss are not modified.
+func TransformSpans(rss []*tracepb.ResourceSpans, transforms []func(*tracepb.Span)) []*tracepb.ResourceSpans {
+	if len(transforms) == 0 {
+		return rss
+	}
+
+	out := make([]*tracepb.ResourceSpans, 0, len(rss))
+	for _, rs := range rss {
+		if rs == nil {
+			out = append(out, rs)
+			continue
+		}
+		ilss := make([]*tracepb.InstrumentationLibrarySpans, 0, len(rs.InstrumentationLibrarySpans))
+		for _, ils := range rs.InstrumentationLibrarySpans {
+			if ils == nil {
+				ilss = append(ilss, ils)
+				continue
+			}
+			spans := make([]*tracepb.Span, 0, len(ils.Spans))
+			for _, s := range ils.Spans {
+				if s == nil {
+					spans = append(spans, s)
+					continue
+				}
+				s = proto.Clone(s).(*tracepb.Span)
+				for _, transform := range transforms {
+					transform(s)
+				}
+				spans = append(spans, s)
+			}
+			ilss = append(ilss, &tracepb.InstrumentationLibrarySpans{
+				InstrumentationLibrary: ils.InstrumentationLibrary,
+				Spans:                  spans,
+				SchemaUrl:              ils.SchemaUrl,
+			})
+		}
+		out = append(out, &tracepb.ResourceSpans{
+			Resource:                    rs.Resource,
+			InstrumentationLibrarySpans: ilss,
+			SchemaUrl:                   rs.SchemaUrl,
+		})
+	}
+	return out
+}
@@ -331,3 +331,36 @@ func TestRootSpanData(t *testing.T) {
 func TestSpanDataNilResource(t *testing.T) {
 	assert.NotPanics(t, func() { Spans(tracetest.SpanStubs{{}}.Snapshots()) })
 }
+
+func TestTransformSpans(t *testing.T) {
+	rss := []*tracepb.ResourceSpans{
+		nil,
+		{
+			SchemaUrl: "schema",
+			InstrumentationLibrarySpans: []*tracepb.InstrumentationLibrarySpans{
+				nil,
+				{Spans: []*tracepb.Span{{Name: "a"}, nil, {Name: "b"}}},
+			},
+		},
+	}
+	assert.Equal(t, rss, TransformSpans(rss, nil))
+
+	got := TransformSpans(rss, []func(*tracepb.Span){
+		func(s *tracepb.Span) { s.Name += "1" },
+		func(s *tracepb.Span) { s.Name += "2" },
+	})
+	require.Len(t, got, 2)
+	assert.Nil(t, got[0])
+	assert.Equal(t, "schema", got[1].SchemaUrl)
+	require.Len(t, got[1].InstrumentationLibrarySpans, 2)
+	assert.Nil(t, got[1].InstrumentationLibrarySpans[0])
+	spans := got[1].InstrumentationLibrarySpans[1].Spans
+	require.Len(t, spans, 3)
+	assert.Equal(t, "a12", spans[0].Name)
+	assert.Nil(t, spans[1])
+	assert.Equal(t, "b12", spans[2].Name)
+
+	// The spans passed are not modified.
+	assert.Equal(t, "a", rss[1].InstrumentationLibrarySpans[1].Spans[0].Name)
+	assert.Equal(t, "b", rss[1].InstrumentationLibrarySpans[1].Spans[2].Name)
+}
@@ -0,0 +1,116 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracetransform // import "go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/tracetransform"
+
+import (
+	"errors"
+	"fmt"
+
+	"go.opentelemetry.io/otel/trace"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+var errNilSpan = errors.New("nil span")
+
+// ValidateSpans returns copies of rss without their malformed spans. A span
+// is malformed if its trace or span ID does not have the length defined by
+// OTLP or is all zeros, if its parent span ID is neither empty nor of the
+// length of a span ID, or if its trace state is not a valid W3C tracestate.
+// The resources and instrumentation libraries left without spans are
+// removed. rss are not modified, and are returned as is if no span is
+// malformed. The returned error, if not nil, reports the number of spans
+// dropped and why the first one was.
+func ValidateSpans(rss []*tracepb.ResourceSpans) ([]*tracepb.ResourceSpans, error) {
+	var (
+		dropped int
+		first   error
+	)
+	out := make([]*tracepb.ResourceSpans, 0, len(rss))
+	for _, rs := range rss {
+		if rs == nil {
+			continue
+		}
+		ilss := make([]*tracepb.InstrumentationLibrarySpans, 0, len(rs.InstrumentationLibrarySpans))
+		for _, ils := range rs.InstrumentationLibrarySpans {
+			if ils == nil {
+				continue
+			}
+			spans := make([]*tracepb.Span, 0, len(ils.Spans))
+			for _, s := range ils.Spans {
+				if err := validateSpan(s); err != nil {
+					if first == nil {
+						first = err
+					}
+					dropped++
+					continue
+				}
+				spans = append(spans, s)
+			}
+			if len(spans) == 0 {
+				continue
+			}
+			ilss = append(ilss, &tracepb.InstrumentationLibrarySpans{
+				InstrumentationLibrary: ils.InstrumentationLibrary,
+				Spans:                  spans,
+				SchemaUrl:              ils.SchemaUrl,
+			})
+		}
+		if len(ilss) == 0 {
+			continue
+		}
+		out = append(out, &tracepb.ResourceSpans{
+			Resource:                    rs.Resource,
+			InstrumentationLibrarySpans: ilss,
+			SchemaUrl:                   rs.SchemaUrl,
+		})
+	}
+	if dropped == 0 {
+		return rss, nil
+	}
+	return out, fmt.Errorf("dropped %d malformed spans: %w", dropped, first)
+}
+
+// validateSpan returns why s is malformed, or nil if it is not.
+func validateSpan(s *tracepb.Span) error {
+	if s == nil {
+		return errNilSpan
+	}
+	if !validID(s.TraceId, len(trace.TraceID{})) {
+		return fmt.Errorf("span %q has an invalid trace ID %x", s.Name, s.TraceId)
+	}
+	if !validID(s.SpanId, len(trace.SpanID{})) {
+		return fmt.Errorf("span %q has an invalid span ID %x", s.Name, s.SpanId)
+	}
+	if len(s.ParentSpanId) != 0 && len(s.ParentSpanId) != len(trace.SpanID{}) {
+		return fmt.Errorf("span %q has an invalid parent span ID %x", s.Name, s.ParentSpanId)
+	}
+	if _, err := trace.ParseTraceState(s.TraceState); err != nil {
+		return fmt.Errorf("span %q has an invalid trace state: %w", s.Name, err)
+	}
+	return nil
+}
+
+// validID tells if id has n bytes, not all zeros.
+func validID(id []byte, n int) bool {
+	if len(id) != n {
+		return false
+	}
+	for _, b := range id {
+		if b != 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracetransform
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+var (
+	validTraceID = []byte{0x1, 0x2, 0x3, 0x4, 0x5, 0x6, 0x7, 0x8, 0x9, 0xa, 0xb, 0xc, 0xd, 0xe, 0xf, 0x10}
+	validSpanID  = []byte{0x1, 0x2, 0x3, 0x4, 0x5, 0x6, 0x7, 0x8}
+)
+
+func validSpan(name string) *tracepb.Span {
+	return &tracepb.Span{Name: name, TraceId: validTraceID, SpanId: validSpanID}
+}
+
+func TestValidateSpan(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		modify func(*tracepb.Span)
+		valid  bool
+	}{
+		{name: "valid", modify: func(*tracepb.Span) {}, valid: true},
+		{name: "with parent", modify: func(s *tracepb.Span) { s.ParentSpanId = validSpanID }, valid: true},
+		{name: "with trace state", modify: func(s *tracepb.Span) { s.TraceState = "key1=value1,key2=value2" }, valid: true},
+		{name: "short trace ID", modify: func(s *tracepb.Span) { s.TraceId = validTraceID[:8] }},
+		{name: "zero trace ID", modify: func(s *tracepb.Span) { s.TraceId = make([]byte, 16) }},
+		{name: "missing span ID", modify: func(s *tracepb.Span) { s.SpanId = nil }},
+		{name: "zero span ID", modify: func(s *tracepb.Span) { s.SpanId = make([]byte, 8) }},
+		{name: "long parent span ID", modify: func(s *tracepb.Span) { s.ParentSpanId = validTraceID }},
+		{name: "invalid trace state", modify: func(s *tracepb.Span) { s.TraceState = "Key=value" }},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			s := validSpan("span")
+			tt.modify(s)
+			err := validateSpan(s)
+			if tt.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+	assert.ErrorIs(t, validateSpan(nil), errNilSpan)
+}
+
+func TestValidateSpans(t *testing.T) {
+	malformed := validSpan("malformed")
+	malformed.SpanId = nil
+	rss := []*tracepb.ResourceSpans{
+		{
+			SchemaUrl: "schema",
+			InstrumentationLibrarySpans: []*tracepb.InstrumentationLibrarySpans{
+				{Spans: []*tracepb.Span{validSpan("a"), malformed, validSpan("b")}},
+				{Spans: []*tracepb.Span{malformed}},
+			},
+		},
+		{
+			InstrumentationLibrarySpans: []*tracepb.InstrumentationLibrarySpans{
+				{Spans: []*tracepb.Span{nil}},
+			},
+		},
+	}
+
+	got, err := ValidateSpans(rss)
+	assert.EqualError(t, err, `dropped 3 malformed spans: span "malformed" has an invalid span ID `)
+	require.Len(t, got, 1)
+	assert.Equal(t, "schema", got[0].SchemaUrl)
+	require.Len(t, got[0].InstrumentationLibrarySpans, 1)
+	assert.Equal(t, []*tracepb.Span{validSpan("a"), validSpan("b")}, got[0].InstrumentationLibrarySpans[0].Spans)
+
+	// The spans passed are not modified.
+	assert.Len(t, rss[0].InstrumentationLibrarySpans[0].Spans, 3)
+
+	valid := []*tracepb.ResourceSpans{got[0]}
+	got, err = ValidateSpans(valid)
+	assert.NoError(t, err)
+	assert.Equal(t, valid, got)
+}
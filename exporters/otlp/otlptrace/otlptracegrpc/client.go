@@ -18,42 +18,198 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/encoding"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 
+	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/batchlimit"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/circuitbreaker"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/coalesce"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/connection"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/errorhistory"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/exporterror"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/exportqueue"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/otlpconfig"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/partialsuccess"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/selfobservability"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/statslog"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/tracetransform"
 	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
 	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
 )
 
 type client struct {
 	connection *connection.Connection
+	metrics    *selfobservability.Instruments
+	breaker    *circuitbreaker.Breaker
+	// batchLimit configures the limits of export requests.
+	batchLimit batchlimit.Config
+	// queue persists the failed exports, if configured.
+	queue *exportqueue.Queue
+	// callOptions are used for each export call.
+	callOptions []grpc.CallOption
+	// export sends each export request through the configured
+	// interceptors.
+	export otlptrace.ExportInvoker
+	// lastSuccess holds the time.Time of the last successful export.
+	lastSuccess atomic.Value
+	// drainTimeout, if positive, is the minimum time Stop waits for
+	// the in-flight exports, regardless of its context.
+	drainTimeout time.Duration
+	// responseMetadataHandler, if set, is called with the metadata of
+	// the export responses.
+	responseMetadataHandler func(metadata.MD)
+	// recentErrors keeps the last errors returned by UploadTraces, if
+	// configured.
+	recentErrors *errorhistory.History
+	// errHandler handles the errors that are not returned.
+	errHandler otel.ErrorHandler
+	// coalescer merges the exports into single uploads, if configured.
+	coalescer *coalesce.Coalescer
+	// exportSlots, if not nil, holds a value for each export in
+	// progress, up to the maximum number of concurrent exports.
+	exportSlots chan struct{}
+	// stats reports a summary of the exports periodically, if
+	// configured.
+	stats *statslog.Reporter
 
 	lock         sync.Mutex
 	tracesClient coltracepb.TraceServiceClient
+	healthClient healthpb.HealthClient
+	// healthCheckService is the name of the service whose health is
+	// checked.
+	healthCheckService string
+
+	// stopMu guards stopped, it is held when adding to inFlight so no
+	// export starts once Stop waits for the in-flight ones.
+	stopMu   sync.RWMutex
+	stopped  bool
+	inFlight sync.WaitGroup
 }
 
 var _ otlptrace.Client = (*client)(nil)
 
 var (
 	errNoClient = errors.New("no client")
+	errStopped  = errors.New("the client is stopped")
 )
 
-// NewClient creates a new gRPC trace client.
+// NewClient creates a new gRPC trace client. The invalid options, which have
+// no effect, and the contradictions between them are reported to the error
+// handler set with WithErrorHandler, or to the global error handler, see
+// NewClientWithError to fail instead.
 func NewClient(opts ...Option) otlptrace.Client {
+	c, errs := newClient(opts)
+	for _, err := range errs {
+		c.errHandler.Handle(err)
+	}
+	return c
+}
+
+// NewClientWithError creates a new gRPC trace client like NewClient does, but
+// returns an error instead of a client if any of the options is invalid, for
+// instance sets an unknown compressor or an unparsable endpoint. This
+// includes the options read from the environment. The contradictions between
+// the options, like WithInsecure and the https scheme of the endpoint, are
+// still reported to the error handler, the explicit setting being used.
+func NewClientWithError(opts ...Option) (otlptrace.Client, error) {
+	c, errs := newClient(opts)
+	if len(errs) == 0 {
+		return c, nil
+	}
+	// Release the directory of the persistent queue.
+	if err := c.queue.Stop(context.Background()); err != nil {
+		c.errHandler.Handle(err)
+	}
+	if len(errs) == 1 {
+		return nil, fmt.Errorf("invalid gRPC client configuration: %w", errs[0])
+	}
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+	// The first error is wrapped, the others are only described.
+	return nil, fmt.Errorf("invalid gRPC client configuration: %w (and %d more: %s)", errs[0], len(errs)-1, strings.Join(msgs[1:], "; "))
+}
+
+// newClient creates a new gRPC trace client configured with opts and returns
+// the errors of the invalid options.
+func newClient(opts []Option) (*client, []error) {
 	cfg := otlpconfig.NewDefaultConfig()
 	otlpconfig.ApplyGRPCEnvConfigs(&cfg)
 	for _, opt := range opts {
 		opt.applyGRPCOption(&cfg)
 	}
+	errs := cfg.Errors()
+	errs = append(errs, cfg.NormalizeEndpoints(otlpconfig.DefaultCollectorPort)...)
+	errHandler := cfg.EffectiveErrorHandler()
+	if err := cfg.Validate(); err != nil {
+		errHandler.Handle(err)
+	}
+	if cfg.Traces.Authorization != "" && cfg.Traces.Insecure {
+		// The credentials may be protected by other means, this is not a
+		// configuration error.
+		errHandler.Handle(errors.New("authorization credentials are sent over an insecure connection"))
+	}
 
-	c := &client{}
-	c.connection = connection.NewConnection(cfg, cfg.Traces, c.handleNewConnection)
+	c := &client{
+		metrics:    selfobservability.New(cfg.MeterProvider, "grpc", errHandler),
+		breaker:    circuitbreaker.New(cfg.CircuitBreaker),
+		batchLimit: batchLimit(cfg),
+		errHandler: errHandler,
+	}
+	// The compressor is set for each call so that it can be overridden with
+	// ContextWithCompressor.
+	if cfg.Traces.GRPCCompressor != "" {
+		c.callOptions = append(c.callOptions, grpc.UseCompressor(cfg.Traces.GRPCCompressor))
+	}
+	if cfg.MaxCallSendMsgSize > 0 {
+		c.callOptions = append(c.callOptions, grpc.MaxCallSendMsgSize(cfg.MaxCallSendMsgSize))
+	}
+	if cfg.MaxCallRecvMsgSize > 0 {
+		c.callOptions = append(c.callOptions, grpc.MaxCallRecvMsgSize(cfg.MaxCallRecvMsgSize))
+	}
+	c.connection = connection.NewConnection(cfg, cfg.Traces, c.handleNewConnection, c.metrics.Retried)
+	c.export = otlpconfig.ChainExportInterceptors(cfg.Traces.ExportInterceptors, c.exportRequest)
+	cfg.ExportQueue.ErrorHandler = errHandler
+	queue, err := exportqueue.New(cfg.ExportQueue)
+	if err != nil {
+		errHandler.Handle(fmt.Errorf("failed to open the export queue, failed exports are not retried: %w", err))
+	}
+	c.queue = queue
+	c.recentErrors = errorhistory.New(cfg.ErrorHistory)
+	c.responseMetadataHandler = cfg.ResponseMetadataHandler
+	c.drainTimeout = cfg.DrainTimeout
+	c.healthCheckService = cfg.HealthCheckService
+	c.coalescer = coalesce.New(cfg.Coalescing, c.uploadTraces, uploadsDirectly)
+	c.stats = statslog.New(cfg.StatsLogInterval, errHandler)
+	if cfg.MaxConcurrentExports > 0 {
+		c.exportSlots = make(chan struct{}, cfg.MaxConcurrentExports)
+	}
 
-	return c
+	return c, errs
+}
+
+// batchLimit returns the limits of the export requests configured by cfg. The
+// maximum send message size, if set, is the default maximum size of the
+// requests so that the batches exceeding it are split rather than failing.
+func batchLimit(cfg otlpconfig.Config) batchlimit.Config {
+	limit := cfg.BatchLimit
+	if limit.MaxBytes == 0 && cfg.MaxCallSendMsgSize > 0 {
+		limit.MaxBytes = cfg.MaxCallSendMsgSize
+	}
+	return limit
 }
 
 func (c *client) handleNewConnection(cc *grpc.ClientConn) {
@@ -61,48 +217,415 @@ func (c *client) handleNewConnection(cc *grpc.ClientConn) {
 	defer c.lock.Unlock()
 	if cc != nil {
 		c.tracesClient = coltracepb.NewTraceServiceClient(cc)
+		c.healthClient = healthpb.NewHealthClient(cc)
 	} else {
 		c.tracesClient = nil
+		c.healthClient = nil
 	}
 }
 
-// Start establishes a connection to the collector.
+// Start establishes a connection to the collector and starts reporting the
+// summaries of the exports, if configured. It reports a warning if the
+// certificate of the collector is not verified.
 func (c *client) Start(ctx context.Context) error {
-	return c.connection.StartConnection(ctx)
+	if err := c.connection.SCfg.TLSVerificationWarning(); err != nil {
+		c.errHandler.Handle(err)
+	}
+	if err := c.connection.StartConnection(ctx); err != nil {
+		return err
+	}
+	c.queue.Start(c.export)
+	c.stats.Start(c.ConnectionStatus)
+	return nil
 }
 
-// Stop shuts down the connection to the collector.
+// Stop stops reporting the summaries of the exports, uploads the spans held by
+// the coalescer, waits for the in-flight exports and the exports held in
+// memory to complete and shuts down the connection to the collector. If ctx is
+// done before the exports complete, they are interrupted and an error wrapping
+// the context error is returned.
 func (c *client) Stop(ctx context.Context) error {
-	return c.connection.Shutdown(ctx)
+	c.stats.Stop()
+	var err error
+	if c.coalescer != nil {
+		// The spans held are uploaded before the exports are refused.
+		err = c.coalescer.Stop(ctx)
+	}
+
+	c.stopMu.Lock()
+	c.stopped = true
+	c.stopMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		c.inFlight.Wait()
+		close(done)
+	}()
+	var drained <-chan time.Time
+	if c.drainTimeout > 0 {
+		timer := time.NewTimer(c.drainTimeout)
+		defer timer.Stop()
+		drained = timer.C
+	}
+	select {
+	case <-done:
+	case <-ctx.Done():
+		if err == nil {
+			err = ctx.Err()
+		}
+		if drained != nil {
+			// The in-flight exports are only cancelled, by shutting
+			// down the connection, once the drain timeout elapsed.
+			select {
+			case <-done:
+			case <-drained:
+			}
+		}
+	}
+
+	// The queued exports are drained before the connection is shut down.
+	if qErr := c.queue.Stop(ctx); err == nil {
+		err = qErr
+	}
+	if sErr := c.connection.Shutdown(ctx); err == nil {
+		err = sErr
+	}
+	return err
 }
 
-// UploadTraces sends a batch of spans to the collector.
+// UploadTraces sends a batch of spans to the collector, merged with the
+// batches of the other exports if coalescing is configured. An empty batch is
+// not sent: nil is returned without using the connection.
 func (c *client) UploadTraces(ctx context.Context, protoSpans []*tracepb.ResourceSpans) error {
-	if !c.connection.Connected() {
-		return fmt.Errorf("traces exporter is disconnected from the server %s: %w", c.connection.SCfg.Endpoint, c.connection.LastConnectError())
+	if len(protoSpans) == 0 {
+		return nil
+	}
+	var err error
+	if c.coalescer != nil {
+		err = c.coalescer.Upload(ctx, protoSpans)
+	} else {
+		err = c.uploadTraces(ctx, protoSpans)
+	}
+	c.recentErrors.Record(err)
+	c.stats.Record(err)
+	return err
+}
+
+// ForceFlush uploads the spans held by the coalescer, if any, and sends an
+// empty export request to the collector, confirming it accepts exports.
+func (c *client) ForceFlush(ctx context.Context) error {
+	var err error
+	if c.coalescer != nil {
+		err = c.coalescer.Flush(ctx)
+	}
+	if err == nil {
+		err = c.uploadTraces(ctx, nil)
+	}
+	c.recentErrors.Record(err)
+	c.stats.Record(err)
+	return err
+}
+
+// RecentErrors returns the last errors returned by UploadTraces, oldest
+// first.
+func (c *client) RecentErrors() []otlptrace.RecentError {
+	return c.recentErrors.Errors()
+}
+
+func (c *client) uploadTraces(ctx context.Context, protoSpans []*tracepb.ResourceSpans) error {
+	if c.exportSlots != nil {
+		// The exports wait for a slot before the stop check, so that
+		// Stop does not wait for them.
+		select {
+		case c.exportSlots <- struct{}{}:
+			defer func() { <-c.exportSlots }()
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	c.stopMu.RLock()
+	if c.stopped {
+		c.stopMu.RUnlock()
+		return errStopped
+	}
+	c.inFlight.Add(1)
+	c.stopMu.RUnlock()
+	defer c.inFlight.Done()
+
+	protoSpans = tracetransform.MergeResourceAttributes(protoSpans, c.connection.SCfg.ResourceAttributes, c.connection.SCfg.OverrideResourceAttributes)
+	protoSpans = tracetransform.TransformSpans(protoSpans, c.connection.SCfg.SpanTransforms)
+	// The empty batch of ForceFlush is sent whatever the validation.
+	if c.connection.SCfg.StrictValidation && len(protoSpans) > 0 {
+		var err error
+		if protoSpans, err = tracetransform.ValidateSpans(protoSpans); err != nil {
+			c.errHandler.Handle(err)
+		}
+		if len(protoSpans) == 0 {
+			return nil
+		}
+	}
+	protoSpans, sampledOut := tracetransform.SampleSpans(protoSpans, c.connection.SCfg.ExportSampler)
+	if sampledOut > 0 {
+		c.metrics.SampledOut(ctx, sampledOut)
+		c.stats.Dropped(sampledOut)
+		if len(protoSpans) == 0 {
+			return nil
+		}
+	}
+	if c.connection.SCfg.StableOrdering {
+		protoSpans = tracetransform.SortSpans(protoSpans)
+	}
+	batches, dropped, err := c.batchLimit.Batches(protoSpans)
+	if err != nil {
+		return err
+	}
+	if dropped > 0 {
+		c.errHandler.Handle(fmt.Errorf("dropped %d spans exceeding the export batch limits", dropped))
+		c.stats.Dropped(dropped)
+	}
+
+	done, err := c.breaker.Allow(ctx)
+	if err != nil {
+		if c.queue.Spool(batches, err) {
+			return nil
+		}
+		c.stats.DroppedSpans(batches)
+		return err
+	}
+	start := time.Now()
+	sent, err := c.uploadBatches(ctx, batches)
+	err = exporterror.Timeout(ctx, err, c.connection.SCfg.Timeout, time.Since(start))
+	done(err)
+	c.metrics.Exported(ctx, batches[:sent], batches[sent:], start)
+	if err == nil {
+		c.lastSuccess.Store(time.Now())
+		c.queue.Notify()
+		c.stats.Sent(batches)
+		return nil
+	}
+	c.stats.Sent(batches[:sent])
+	if c.queue.Spool(batches[sent:], err) {
+		return nil
+	}
+	c.stats.DroppedSpans(batches[sent:])
+	return err
+}
+
+// Healthy checks the health of the collector, or of the service set with
+// WithHealthCheckService, with the gRPC health checking protocol. It returns
+// nil if the collector reports the SERVING status, an error wrapping
+// otlptrace.ErrUnhealthy and describing the status reported otherwise, or the
+// error of the check, like the Unimplemented status if the collector does not
+// expose the health checking service. The check is not retried.
+func (c *client) Healthy(ctx context.Context) error {
+	if err := c.connection.EnsureConnected(ctx); err != nil {
+		return fmt.Errorf("traces exporter is disconnected from the server %s: %w", c.connection.Endpoint(), err)
 	}
 
 	ctx, cancel := c.connection.ContextWithStop(ctx)
 	defer cancel()
 	ctx, tCancel := context.WithTimeout(ctx, c.connection.SCfg.Timeout)
 	defer tCancel()
+	ctx, err := c.connection.ContextWithExportMetadata(ctx)
+	if err != nil {
+		return err
+	}
+
+	c.lock.Lock()
+	healthClient := c.healthClient
+	c.lock.Unlock()
+	if healthClient == nil {
+		return errNoClient
+	}
+	resp, err := healthClient.Check(ctx, &healthpb.HealthCheckRequest{Service: c.healthCheckService})
+	if err != nil {
+		return err
+	}
+	if resp.Status != healthpb.HealthCheckResponse_SERVING {
+		return fmt.Errorf("%w: the health status of %q is %s", otlptrace.ErrUnhealthy, c.healthCheckService, resp.Status)
+	}
+	return nil
+}
 
-	ctx = c.connection.ContextWithMetadata(ctx)
-	err := func() error {
-		c.lock.Lock()
-		defer c.lock.Unlock()
-		if c.tracesClient == nil {
-			return errNoClient
+// LastSuccess returns the time of the last export accepted by the collector,
+// or the zero time if there is none.
+func (c *client) LastSuccess() time.Time {
+	t, _ := c.lastSuccess.Load().(time.Time)
+	return t
+}
+
+// LastConnectError returns the error that disconnected the client from the
+// collector, or nil if it is connected.
+func (c *client) LastConnectError() error {
+	return c.connection.LastConnectError()
+}
+
+// ConnectionStatus returns the status of the connection to the collector.
+func (c *client) ConnectionStatus() otlptrace.ConnectionStatus {
+	return c.connection.Status()
+}
+
+// uploadBatches uploads each of batches in its own export request, once the
+// previous one succeeded. It returns the number of batches uploaded.
+func (c *client) uploadBatches(ctx context.Context, batches [][]*tracepb.ResourceSpans) (int, error) {
+	for i, batch := range batches {
+		req := &coltracepb.ExportTraceServiceRequest{ResourceSpans: batch}
+		if err := c.export(ctx, req); err != nil {
+			return i, err
+		}
+	}
+	return len(batches), nil
+}
+
+// exportRequest sends req to the collector.
+func (c *client) exportRequest(ctx context.Context, req *coltracepb.ExportTraceServiceRequest) error {
+	if err := c.connection.EnsureConnected(ctx); err != nil {
+		err = fmt.Errorf("traces exporter is disconnected from the server %s: %w", c.connection.Endpoint(), err)
+		if errors.Is(err, connection.ErrReconnectExhausted) {
+			// The connection is no longer re-established.
+			return otlptrace.NewExportError(otlptrace.ErrDisconnected, err, false)
 		}
-		return c.connection.DoRequest(ctx, func(ctx context.Context) error {
-			_, err := c.tracesClient.Export(ctx, &coltracepb.ExportTraceServiceRequest{
-				ResourceSpans: protoSpans,
-			})
+		if errors.Is(err, context.DeadlineExceeded) {
+			// The deadline was exceeded while reconnecting.
+			return otlptrace.NewExportError(otlptrace.ErrTimeout, err, true)
+		}
+		return otlptrace.NewExportError(otlptrace.ErrDisconnected, err, true)
+	}
+
+	ctx, cancel := c.connection.ContextWithStop(ctx)
+	defer cancel()
+	// A deadline already set on ctx is never extended: the export uses
+	// whichever of it and the configured timeout expires first.
+	ctx, tCancel := context.WithTimeout(ctx, c.connection.SCfg.Timeout)
+	defer tCancel()
+
+	ctx, err := c.connection.ContextWithExportMetadata(ctx)
+	if err != nil {
+		return err
+	}
+	if header := c.connection.SCfg.IdempotencyKeyHeader; header != "" {
+		// The key is the same for all the retries of the request.
+		key, err := otlpconfig.NewIdempotencyKey()
+		if err != nil {
+			return err
+		}
+		ctx = metadata.AppendToOutgoingContext(ctx, header, key)
+	}
+	// gRPC compresses the request while sending it, its size is measured
+	// before.
+	otlpconfig.ObservePayloadSize(c.connection.SCfg.PayloadSizeObserver, req, proto.Size(req), false)
+	callOptions := c.callOptions
+	if cc, ok := ctx.Value(compressorKey{}).(contextCompressor); ok {
+		if cc.err != nil {
+			c.errHandler.Handle(cc.err)
+		}
+		// The compressor set last is used.
+		callOptions = append(callOptions[:len(callOptions):len(callOptions)], grpc.UseCompressor(cc.name))
+	}
+	// The lock is not held during the RPC, so that concurrent exports are
+	// sent concurrently.
+	c.lock.Lock()
+	tracesClient := c.tracesClient
+	c.lock.Unlock()
+	if tracesClient == nil {
+		err = errNoClient
+	} else {
+		err = c.connection.DoRequest(ctx, func(ctx context.Context) error {
+			c.metrics.Attempt(ctx)
+			opts := callOptions
+			var header, trailer metadata.MD
+			if c.responseMetadataHandler != nil {
+				// The metadata is only captured if it is handled.
+				opts = append(opts[:len(opts):len(opts)], grpc.Header(&header), grpc.Trailer(&trailer))
+			}
+			resp, err := tracesClient.Export(ctx, req, opts...)
+			if md := metadata.Join(header, trailer); md.Len() > 0 {
+				c.responseMetadataHandler(md)
+			}
+			if err == nil {
+				partialsuccess.Handle(resp, c.connection.SCfg.PartialSuccessHandler, c.errHandler)
+			}
 			return err
 		})
-	}()
+	}
 	if err != nil {
 		c.connection.SetStateDisconnected(err)
+		return exportError(err)
 	}
-	return err
+	return nil
+}
+
+type compressorKey struct{}
+
+// uploadsDirectly reports whether the exports ctx is passed to are not merged
+// with the others when coalescing, as the metadata and the compressor ctx
+// carries only apply to their own spans.
+func uploadsDirectly(ctx context.Context) bool {
+	if _, ok := ctx.Value(compressorKey{}).(contextCompressor); ok {
+		return true
+	}
+	return connection.HasExportMetadata(ctx)
+}
+
+// contextCompressor is the compressor set with ContextWithCompressor. err is
+// reported by the exports if the requested compressor is not registered.
+type contextCompressor struct {
+	name string
+	err  error
+}
+
+// ContextWithCompressor returns a copy of ctx making the exports it is passed
+// to compress their requests with the compressor named name instead of the
+// one set with WithCompressor, for instance to only compress large batches.
+// An empty name disables the compression. The compressor must be registered
+// with google.golang.org/grpc/encoding: if it is not, each export reports an
+// error to the error handler set with WithErrorHandler, or to the global
+// error handler, and does not compress its requests.
+func ContextWithCompressor(ctx context.Context, name string) context.Context {
+	cc := contextCompressor{name: name}
+	if name == "" {
+		cc.name = encoding.Identity
+	} else if encoding.GetCompressor(name) == nil {
+		cc.name = encoding.Identity
+		cc.err = fmt.Errorf("compressor %q is not registered, exports are not compressed", name)
+	}
+	return context.WithValue(ctx, compressorKey{}, cc)
+}
+
+// ContextWithExportMetadata returns a copy of ctx making the exports it is
+// passed to send md as gRPC metadata, for instance a tenant ID routing the
+// spans at the collector. The metadata is carried by ctx, so it can be
+// computed for each export by the caller of ExportSpans or by an export
+// interceptor from the spans exported. It is merged with the metadata
+// already set this way and with the headers set with WithHeaders and
+// WithHeadersFunc, taking precedence over them. The outgoing gRPC metadata
+// of ctx, see google.golang.org/grpc/metadata.NewOutgoingContext, takes
+// precedence over md.
+func ContextWithExportMetadata(ctx context.Context, md metadata.MD) context.Context {
+	return connection.WithExportMetadata(ctx, md)
+}
+
+// exportError returns an otlptrace.ExportError describing the failed export
+// err, or err itself if it was canceled.
+func exportError(err error) error {
+	if err == errNoClient {
+		return otlptrace.NewExportError(otlptrace.ErrDisconnected, err, true)
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return otlptrace.NewExportError(otlptrace.ErrTimeout, err, true)
+	}
+
+	var se interface{ GRPCStatus() *status.Status }
+	if !errors.As(err, &se) {
+		return err
+	}
+	switch se.GRPCStatus().Code() {
+	case codes.Canceled:
+		return err
+	case codes.DeadlineExceeded:
+		return otlptrace.NewExportError(otlptrace.ErrTimeout, err, true)
+	case codes.Unavailable:
+		return otlptrace.NewExportError(otlptrace.ErrDisconnected, err, true)
+	}
+	return otlptrace.NewExportError(otlptrace.ErrRejected, err, connection.Retryable(se.GRPCStatus().Err()))
 }
@@ -15,26 +15,53 @@ package otlptracegrpc_test
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net"
+	"net/url"
+	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/net/proxy"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/encoding"
 	"google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
 
+	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/otlptracetest"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	ottest "go.opentelemetry.io/otel/internal/internaltest"
+	"go.opentelemetry.io/otel/metric/metrictest"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	collectortracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
 	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
 )
 
 var roSpans = tracetest.SpanStubs{{Name: "Span 0"}}.Snapshots()
@@ -65,6 +92,16 @@ func TestNew_endToEnd(t *testing.T) {
 				otlptracegrpc.WithDialOption(grpc.WithBlock()),
 			},
 		},
+		{
+			name: "WithKeepalive",
+			additionalOpts: []otlptracegrpc.Option{
+				otlptracegrpc.WithKeepalive(keepalive.ClientParameters{
+					Time:                time.Minute,
+					Timeout:             20 * time.Second,
+					PermitWithoutStream: true,
+				}),
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -128,6 +165,19 @@ func TestExporterShutdown(t *testing.T) {
 	})
 }
 
+func TestExporterDelivery(t *testing.T) {
+	mc := runMockCollector(t)
+	defer func() {
+		_ = mc.stop()
+	}()
+
+	otlptracetest.RunExporterDeliveryTest(t, func() otlptrace.Client {
+		return otlptracegrpc.NewClient(
+			otlptracegrpc.WithInsecure(),
+			otlptracegrpc.WithEndpoint(mc.endpoint))
+	}, mc)
+}
+
 func TestNew_invokeStartThenStopManyTimes(t *testing.T) {
 	mc := runMockCollector(t)
 	defer func() {
@@ -296,186 +346,2201 @@ func TestNew_collectorOnBadConnection(t *testing.T) {
 	_ = exp.Shutdown(ctx)
 }
 
-func TestNew_withEndpoint(t *testing.T) {
-	mc := runMockCollector(t)
+func TestNew_withNoReconnect(t *testing.T) {
+	mc := runMockCollectorWithConfig(t, &mockConfig{
+		errors:   []error{status.Error(codes.Unavailable, "backend restarting")},
+		endpoint: "localhost:0",
+	})
 	defer func() {
 		_ = mc.stop()
 	}()
 
 	ctx := context.Background()
-	exp := newGRPCExporter(t, ctx, mc.endpoint)
-	_ = exp.Shutdown(ctx)
+	exp := newGRPCExporter(t, ctx, mc.endpoint,
+		otlptracegrpc.WithNoReconnect(),
+		otlptracegrpc.WithRetry(otlptracegrpc.RetryConfig{Enabled: false}))
+	defer func() {
+		require.NoError(t, exp.Shutdown(ctx))
+	}()
+
+	// The failed export disconnects the client.
+	require.Error(t, exp.ExportSpans(ctx, roSpans))
+
+	// Without a background routine to reconnect, the next export has to
+	// re-establish the connection itself.
+	require.NoError(t, exp.ExportSpans(ctx, roSpans))
+	assert.Len(t, mc.getSpans(), 1)
 }
 
-func TestNew_withHeaders(t *testing.T) {
-	mc := runMockCollector(t)
+func TestNew_withIdempotencyKeys(t *testing.T) {
+	mc := runMockCollectorWithConfig(t, &mockConfig{
+		errors:   []error{status.Error(codes.Unavailable, "backend restarting")},
+		endpoint: "localhost:0",
+	})
 	defer func() {
 		_ = mc.stop()
 	}()
 
+	var mu sync.Mutex
+	var keys []string
+	recordKeys := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		md, _ := metadata.FromOutgoingContext(ctx)
+		mu.Lock()
+		keys = append(keys, md.Get("idempotency-key")...)
+		mu.Unlock()
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
 	ctx := context.Background()
 	exp := newGRPCExporter(t, ctx, mc.endpoint,
-		otlptracegrpc.WithHeaders(map[string]string{"header1": "value1"}))
+		otlptracegrpc.WithIdempotencyKeys("Idempotency-Key"),
+		otlptracegrpc.WithDialOption(grpc.WithUnaryInterceptor(recordKeys)),
+		otlptracegrpc.WithRetry(otlptracegrpc.RetryConfig{
+			Enabled:         true,
+			InitialInterval: time.Millisecond,
+			MaxInterval:     time.Millisecond,
+			MaxElapsedTime:  10 * time.Second,
+		}))
+	defer func() {
+		_ = exp.Shutdown(ctx)
+	}()
+
+	// The retry of the rejected request has the same key.
+	require.NoError(t, exp.ExportSpans(ctx, roSpans))
+	require.Len(t, keys, 2)
+	assert.NotEmpty(t, keys[0])
+	assert.Equal(t, keys[0], keys[1])
+	assert.Equal(t, []string{keys[0]}, mc.getHeaders().Get("idempotency-key"))
+
+	// Another request has another key.
 	require.NoError(t, exp.ExportSpans(ctx, roSpans))
+	require.Len(t, keys, 3)
+	assert.NotEqual(t, keys[0], keys[2])
+}
+
+func TestPayloadSizeObserver(t *testing.T) {
+	mc := runMockCollectorWithConfig(t, &mockConfig{
+		errors:   []error{status.Error(codes.Unavailable, "backend restarting")},
+		endpoint: "localhost:0",
+	})
+	defer func() {
+		_ = mc.stop()
+	}()
 
+	var mu sync.Mutex
+	var sent []int
+	recordSizes := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		mu.Lock()
+		sent = append(sent, proto.Size(req.(proto.Message)))
+		mu.Unlock()
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+	var observed []otlptrace.PayloadSize
+	ctx := context.Background()
+	exp := newGRPCExporter(t, ctx, mc.endpoint,
+		otlptracegrpc.WithPayloadSizeObserver(func(size otlptrace.PayloadSize) {
+			mu.Lock()
+			observed = append(observed, size)
+			mu.Unlock()
+		}),
+		otlptracegrpc.WithCompressor(gzip.Name),
+		otlptracegrpc.WithDialOption(grpc.WithUnaryInterceptor(recordSizes)),
+		otlptracegrpc.WithRetry(otlptracegrpc.RetryConfig{
+			Enabled:         true,
+			InitialInterval: time.Millisecond,
+			MaxInterval:     time.Millisecond,
+			MaxElapsedTime:  10 * time.Second,
+		}))
 	defer func() {
 		_ = exp.Shutdown(ctx)
 	}()
 
-	headers := mc.getHeaders()
-	require.Len(t, headers.Get("header1"), 1)
-	assert.Equal(t, "value1", headers.Get("header1")[0])
+	spans := tracetest.SpanStubs{{Name: "Span 0"}, {Name: "Span 1"}}.Snapshots()
+	require.NoError(t, exp.ExportSpans(ctx, spans))
+
+	// The retry of the request is not observed again, and the size is
+	// measured before gRPC compresses the request.
+	require.Len(t, sent, 2)
+	require.Len(t, observed, 1)
+	assert.Equal(t, otlptrace.PayloadSize{Spans: 2, Bytes: sent[0]}, observed[0])
 }
 
-func TestNew_WithTimeout(t *testing.T) {
-	tts := []struct {
-		name    string
-		fn      func(exp *otlptrace.Exporter) error
-		timeout time.Duration
-		spans   int
-		code    codes.Code
-		delay   bool
-	}{
-		{
-			name: "Timeout Spans",
-			fn: func(exp *otlptrace.Exporter) error {
-				return exp.ExportSpans(context.Background(), roSpans)
-			},
-			timeout: time.Millisecond * 100,
-			code:    codes.DeadlineExceeded,
-			delay:   true,
-		},
+func TestNew_withStartupProbe(t *testing.T) {
+	mc := runMockCollector(t)
+	defer func() {
+		_ = mc.stop()
+	}()
 
-		{
-			name: "No Timeout Spans",
-			fn: func(exp *otlptrace.Exporter) error {
-				return exp.ExportSpans(context.Background(), roSpans)
-			},
-			timeout: time.Minute,
-			spans:   1,
-			code:    codes.OK,
-		},
-	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	exp := newGRPCExporter(t, ctx, mc.endpoint, otlptracegrpc.WithStartupProbe(true))
+	defer func() {
+		require.NoError(t, exp.Shutdown(context.Background()))
+	}()
+	require.NoError(t, exp.ExportSpans(ctx, roSpans))
+	assert.Len(t, mc.getSpans(), 1)
+}
 
-	for _, tt := range tts {
-		t.Run(tt.name, func(t *testing.T) {
+func TestNew_withStartupProbeUnreachable(t *testing.T) {
+	ln, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	// Nothing listens on the endpoint once the listener is closed.
+	endpoint := ln.Addr().String()
+	require.NoError(t, ln.Close())
 
-			mc := runMockCollector(t)
-			if tt.delay {
-				mc.traceSvc.delay = time.Second * 10
-			}
-			defer func() {
-				_ = mc.stop()
-			}()
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	exp := otlptrace.NewUnstarted(otlptracegrpc.NewClient(
+		otlptracegrpc.WithInsecure(),
+		otlptracegrpc.WithEndpoint(endpoint),
+		otlptracegrpc.WithStartupProbe(true),
+	))
+	err = exp.Start(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Contains(t, err.Error(), endpoint)
+
+	assert.Error(t, exp.ExportSpans(context.Background(), roSpans))
+	assert.NoError(t, exp.Shutdown(context.Background()))
+}
 
-			ctx := context.Background()
-			exp := newGRPCExporter(t, ctx, mc.endpoint, otlptracegrpc.WithTimeout(tt.timeout), otlptracegrpc.WithRetry(otlptracegrpc.RetryConfig{Enabled: false}))
-			defer func() {
-				_ = exp.Shutdown(ctx)
-			}()
+func TestNew_withStartTimeout(t *testing.T) {
+	mc := runMockCollector(t)
+	endpoint := mc.endpoint
+	require.NoError(t, mc.stop())
 
-			err := tt.fn(exp)
+	// The context of the start has no deadline.
+	ctx := context.Background()
+	start := time.Now()
+	exp := newGRPCExporter(t, ctx, endpoint,
+		otlptracegrpc.WithStartTimeout(100*time.Millisecond),
+		otlptracegrpc.WithRetry(otlptracegrpc.RetryConfig{Enabled: false}))
+	defer func() {
+		assert.NoError(t, exp.Shutdown(ctx))
+	}()
+	assert.Less(t, int64(time.Since(start)), int64(5*time.Second))
+	status := exp.ConnectionStatus()
+	assert.False(t, status.Connected)
+	assert.Error(t, status.LastError)
+
+	// The connection is not re-established in the background.
+	time.Sleep(200 * time.Millisecond)
+	assert.Zero(t, exp.ConnectionStatus().ReconnectAttempts)
+	assert.Error(t, exp.ExportSpans(ctx, roSpans))
+
+	// The exports connect to the collector once it is reachable.
+	nmc := runMockCollectorAtEndpoint(t, endpoint)
+	defer func() {
+		_ = nmc.stop()
+	}()
+	require.Eventually(t, func() bool {
+		return exp.ExportSpans(ctx, roSpans) == nil
+	}, 10*time.Second, 10*time.Millisecond)
+	assert.Len(t, nmc.getSpans(), 1)
+}
 
-			if tt.code == codes.OK {
-				require.NoError(t, err)
-			} else {
-				require.Error(t, err)
-			}
+func TestNew_withStartTimeoutAndStartupProbe(t *testing.T) {
+	ln, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	endpoint := ln.Addr().String()
+	require.NoError(t, ln.Close())
 
-			s := status.Convert(err)
-			require.Equal(t, tt.code, s.Code())
+	exp := otlptrace.NewUnstarted(otlptracegrpc.NewClient(
+		otlptracegrpc.WithInsecure(),
+		otlptracegrpc.WithEndpoint(endpoint),
+		otlptracegrpc.WithStartTimeout(100*time.Millisecond),
+		otlptracegrpc.WithStartupProbe(true),
+	))
+	assert.ErrorIs(t, exp.Start(context.Background()), context.DeadlineExceeded)
+	assert.NoError(t, exp.Shutdown(context.Background()))
+}
 
-			require.Len(t, mc.getSpans(), tt.spans)
-		})
-	}
+func TestNew_withBlockingStart(t *testing.T) {
+	ln, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	endpoint := ln.Addr().String()
+	require.NoError(t, ln.Close())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	exp := otlptrace.NewUnstarted(otlptracegrpc.NewClient(
+		otlptracegrpc.WithInsecure(),
+		otlptracegrpc.WithEndpoint(endpoint),
+		otlptracegrpc.WithBlockingStart(),
+	))
+	assert.ErrorIs(t, exp.Start(ctx), context.DeadlineExceeded)
+	assert.NoError(t, exp.Shutdown(context.Background()))
 }
 
-func TestNew_withInvalidSecurityConfiguration(t *testing.T) {
+func TestNew_withResolveOnStart(t *testing.T) {
+	ctx := context.Background()
+	exp := otlptrace.NewUnstarted(otlptracegrpc.NewClient(
+		otlptracegrpc.WithInsecure(),
+		otlptracegrpc.WithEndpoint("collector.invalid:4317"),
+		otlptracegrpc.WithResolveOnStart(),
+	))
+	err := exp.Start(ctx)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "collector.invalid:4317")
+	assert.NoError(t, exp.Shutdown(ctx))
+
+	mc := runMockCollector(t)
+	defer func() {
+		_ = mc.stop()
+	}()
+	_, port, err := net.SplitHostPort(mc.endpoint)
+	require.NoError(t, err)
+	exp = newGRPCExporter(t, ctx, "localhost:"+port, otlptracegrpc.WithResolveOnStart())
+	require.NoError(t, exp.ExportSpans(ctx, roSpans))
+	assert.NoError(t, exp.Shutdown(ctx))
+	assert.Len(t, mc.getSpans(), 1)
+}
+
+func TestNewFromEnv(t *testing.T) {
 	mc := runMockCollector(t)
 	defer func() {
 		_ = mc.stop()
 	}()
+	envStore, err := ottest.SetEnvVariables(map[string]string{
+		"OTEL_EXPORTER_OTLP_PROTOCOL":        "grpc",
+		"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT": "http://" + mc.endpoint,
+	})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, envStore.Restore())
+	}()
 
 	ctx := context.Background()
-	driver := otlptracegrpc.NewClient(otlptracegrpc.WithEndpoint(mc.endpoint))
-	exp, err := otlptrace.New(ctx, driver)
-	if err != nil {
-		t.Fatalf("failed to create a new collector exporter: %v", err)
-	}
+	exp, err := otlptrace.NewFromEnv(ctx)
+	require.NoError(t, err)
+	require.NoError(t, exp.ExportSpans(ctx, roSpans))
+	assert.NoError(t, exp.Shutdown(ctx))
+	assert.Len(t, mc.getSpans(), 1)
+}
 
-	err = exp.ExportSpans(ctx, roSpans)
+func TestNew_withEndpoint(t *testing.T) {
+	mc := runMockCollector(t)
+	defer func() {
+		_ = mc.stop()
+	}()
 
-	expectedErr := fmt.Sprintf("traces exporter is disconnected from the server %s: grpc: no transport security set (use grpc.WithInsecure() explicitly or set credentials)", mc.endpoint)
+	ctx := context.Background()
+	exp := newGRPCExporter(t, ctx, mc.endpoint)
+	_ = exp.Shutdown(ctx)
+}
 
-	require.Error(t, err)
-	require.Equal(t, expectedErr, err.Error())
+func TestNew_withEndpointURL(t *testing.T) {
+	mc := runMockCollector(t)
+	defer func() {
+		_ = mc.stop()
+	}()
 
+	ctx := context.Background()
+	exp, err := otlptrace.New(ctx, otlptracegrpc.NewClient(
+		otlptracegrpc.WithEndpointURL(&url.URL{Scheme: "http", Host: mc.endpoint}),
+	))
+	require.NoError(t, err)
 	defer func() {
-		_ = exp.Shutdown(ctx)
+		assert.NoError(t, exp.Shutdown(ctx))
 	}()
+	require.NoError(t, exp.ExportSpans(ctx, roSpans))
+	assert.Len(t, mc.getSpans(), 1)
 }
 
-func TestNew_withMultipleAttributeTypes(t *testing.T) {
+func TestNew_withRoundRobinServiceConfig(t *testing.T) {
 	mc := runMockCollector(t)
+	defer func() {
+		_ = mc.stop()
+	}()
+
+	ctx := context.Background()
+	exp := newGRPCExporter(t, ctx, "dns:///"+mc.endpoint,
+		otlptracegrpc.WithServiceConfig(`{"loadBalancingPolicy":"round_robin"}`))
+	defer func() {
+		assert.NoError(t, exp.Shutdown(ctx))
+	}()
+	require.NoError(t, exp.ExportSpans(ctx, roSpans))
+	assert.Len(t, mc.getSpans(), 1)
+}
 
+func TestNew_withServiceConfigFile(t *testing.T) {
+	mc := runMockCollector(t)
 	defer func() {
 		_ = mc.stop()
 	}()
 
-	<-time.After(5 * time.Millisecond)
+	dir, err := ioutil.TempDir("", "serviceconfig")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "service.json")
+	require.NoError(t, ioutil.WriteFile(path, []byte(`{"loadBalancingPolicy":"round_robin"}`), 0600))
 
 	ctx := context.Background()
-	exp := newGRPCExporter(t, ctx, mc.endpoint)
-
+	exp := newGRPCExporter(t, ctx, "dns:///"+mc.endpoint, otlptracegrpc.WithServiceConfigFile(path))
 	defer func() {
-		_ = exp.Shutdown(ctx)
+		assert.NoError(t, exp.Shutdown(ctx))
 	}()
+	require.NoError(t, exp.ExportSpans(ctx, roSpans))
+	assert.Len(t, mc.getSpans(), 1)
+}
 
-	tp := sdktrace.NewTracerProvider(
-		sdktrace.WithSampler(sdktrace.AlwaysSample()),
-		sdktrace.WithBatcher(
-			exp,
-			// add following two options to ensure flush
-			sdktrace.WithBatchTimeout(5*time.Second),
-			sdktrace.WithMaxExportBatchSize(10),
-		),
-	)
-	defer func() { _ = tp.Shutdown(ctx) }()
+func TestNew_withHeaders(t *testing.T) {
+	mc := runMockCollector(t)
+	defer func() {
+		_ = mc.stop()
+	}()
 
-	tr := tp.Tracer("test-tracer")
-	testKvs := []attribute.KeyValue{
-		attribute.Int("Int", 1),
-		attribute.Int64("Int64", int64(3)),
-		attribute.Float64("Float64", 2.22),
-		attribute.Bool("Bool", true),
-		attribute.String("String", "test"),
-	}
-	_, span := tr.Start(ctx, "AlwaysSample")
-	span.SetAttributes(testKvs...)
-	span.End()
+	ctx := context.Background()
+	exp := newGRPCExporter(t, ctx, mc.endpoint,
+		otlptracegrpc.WithHeaders(map[string]string{"header1": "value1"}))
+	require.NoError(t, exp.ExportSpans(ctx, roSpans))
 
-	// Flush and close.
-	func() {
-		ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
-		defer cancel()
-		if err := tp.Shutdown(ctx); err != nil {
-			t.Fatalf("failed to shut down a tracer provider: %v", err)
-		}
+	defer func() {
+		_ = exp.Shutdown(ctx)
 	}()
 
-	// Wait >2 cycles.
-	<-time.After(40 * time.Millisecond)
+	headers := mc.getHeaders()
+	require.Len(t, headers.Get("header1"), 1)
+	assert.Equal(t, "value1", headers.Get("header1")[0])
+}
 
-	// Now shutdown the exporter
-	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
-	defer cancel()
-	if err := exp.Shutdown(ctx); err != nil {
-		t.Fatalf("failed to stop the exporter: %v", err)
+func TestNew_withTLSCredentialsAndPerRPCCredentials(t *testing.T) {
+	cert, pool, err := generateCertificate()
+	require.NoError(t, err)
+	// The collector requires both a client certificate and a token.
+	mc := runMockCollectorWithConfig(t, &mockConfig{
+		endpoint: "localhost:0",
+		creds: credentials.NewTLS(&tls.Config{
+			Certificates: []tls.Certificate{cert},
+			ClientCAs:    pool,
+			ClientAuth:   tls.RequireAndVerifyClientCert,
+		}),
+	})
+	defer func() {
+		_ = mc.stop()
+	}()
+
+	ctx := context.Background()
+	newExporter := func(tlsCfg *tls.Config) *otlptrace.Exporter {
+		exp, err := otlptrace.New(ctx, otlptracegrpc.NewClient(
+			otlptracegrpc.WithEndpoint(mc.endpoint),
+			otlptracegrpc.WithTLSCredentials(credentials.NewTLS(tlsCfg)),
+			otlptracegrpc.WithBearerToken("token"),
+			otlptracegrpc.WithHeaders(map[string]string{"tenant": "a"}),
+			otlptracegrpc.WithRetry(otlptracegrpc.RetryConfig{Enabled: false}),
+		))
+		require.NoError(t, err)
+		return exp
 	}
 
-	// Shutdown the collector too so that we can begin
-	// verification checks of expected data back.
-	_ = mc.stop()
+	exp := newExporter(&tls.Config{Certificates: []tls.Certificate{cert}, RootCAs: pool})
+	require.NoError(t, exp.ExportSpans(ctx, roSpans))
+	assert.NoError(t, exp.Shutdown(ctx))
+	assert.Len(t, mc.getSpans(), 1)
+	headers := mc.getHeaders()
+	assert.Equal(t, []string{"Bearer token"}, headers.Get("authorization"))
+	assert.Equal(t, []string{"a"}, headers.Get("tenant"))
+
+	// The token alone does not establish the connection.
+	exp = newExporter(&tls.Config{RootCAs: pool})
+	assert.Error(t, exp.ExportSpans(ctx, roSpans))
+	assert.NoError(t, exp.Shutdown(ctx))
+	assert.Len(t, mc.getSpans(), 1)
+}
+
+func TestNew_withSOCKS5Proxy(t *testing.T) {
+	cert, pool, err := generateCertificate()
+	require.NoError(t, err)
+	mc := runMockCollectorWithConfig(t, &mockConfig{
+		endpoint: "localhost:0",
+		creds:    credentials.NewTLS(&tls.Config{Certificates: []tls.Certificate{cert}}),
+	})
+	defer func() {
+		_ = mc.stop()
+	}()
+	p := runSOCKS5Proxy(t, "user", "password")
+	defer p.stop()
+
+	// The host of the endpoint is sent to the proxy unresolved, and the
+	// TLS handshake made with the collector through it.
+	_, port, err := net.SplitHostPort(mc.endpoint)
+	require.NoError(t, err)
+	endpoint := net.JoinHostPort("localhost", port)
+	ctx := context.Background()
+	newExporter := func(auth *proxy.Auth) *otlptrace.Exporter {
+		exp, err := otlptrace.New(ctx, otlptracegrpc.NewClient(
+			otlptracegrpc.WithEndpoint(endpoint),
+			otlptracegrpc.WithTLSCredentials(credentials.NewTLS(&tls.Config{RootCAs: pool})),
+			otlptracegrpc.WithSOCKS5Proxy(p.addr(), auth),
+			otlptracegrpc.WithRetry(otlptracegrpc.RetryConfig{Enabled: false}),
+		))
+		require.NoError(t, err)
+		return exp
+	}
+
+	exp := newExporter(&proxy.Auth{User: "user", Password: "password"})
+	require.NoError(t, exp.ExportSpans(ctx, roSpans))
+	assert.NoError(t, exp.Shutdown(ctx))
+	assert.Len(t, mc.getSpans(), 1)
+	assert.Equal(t, []string{endpoint}, p.getTargets())
+
+	// The connection is not established if the proxy rejects the client.
+	exp = newExporter(&proxy.Auth{User: "user", Password: "wrong"})
+	assert.Error(t, exp.ExportSpans(ctx, roSpans))
+	assert.NoError(t, exp.Shutdown(ctx))
+	assert.Len(t, mc.getSpans(), 1)
+}
+
+func TestNew_withAuthorization(t *testing.T) {
+	tests := []struct {
+		name string
+		opt  otlptracegrpc.Option
+		want string
+	}{
+		{
+			name: "bearer token",
+			opt:  otlptracegrpc.WithBearerToken("token"),
+			want: "Bearer token",
+		},
+		{
+			name: "basic auth",
+			opt:  otlptracegrpc.WithBasicAuth("user", "pass"),
+			want: "Basic dXNlcjpwYXNz",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mc := runMockCollector(t)
+			defer func() {
+				_ = mc.stop()
+			}()
+
+			ctx := context.Background()
+			exp := newGRPCExporter(t, ctx, mc.endpoint, tt.opt)
+			defer func() {
+				_ = exp.Shutdown(ctx)
+			}()
+			require.NoError(t, exp.ExportSpans(ctx, roSpans))
+
+			assert.Equal(t, []string{tt.want}, mc.getHeaders().Get("authorization"))
+		})
+	}
+}
+
+func TestNew_withUserAgent(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []otlptracegrpc.Option
+		want string
+	}{
+		{
+			name: "default",
+			want: "OTel-OTLP-Exporter-Go/" + otlptrace.Version() + " grpc-go/",
+		},
+		{
+			name: "custom",
+			opts: []otlptracegrpc.Option{otlptracegrpc.WithUserAgent("custom-agent/1.0")},
+			want: "custom-agent/1.0 grpc-go/",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mc := runMockCollector(t)
+			defer func() {
+				_ = mc.stop()
+			}()
+
+			ctx := context.Background()
+			exp := newGRPCExporter(t, ctx, mc.endpoint, tt.opts...)
+			defer func() {
+				_ = exp.Shutdown(ctx)
+			}()
+			require.NoError(t, exp.ExportSpans(ctx, roSpans))
+
+			ua := mc.getHeaders().Get("user-agent")
+			require.Len(t, ua, 1)
+			assert.True(t, strings.HasPrefix(ua[0], tt.want), "user agent %q", ua[0])
+		})
+	}
+}
+
+func TestNewClientWithError(t *testing.T) {
+	client, err := otlptracegrpc.NewClientWithError(otlptracegrpc.WithInsecure())
+	require.NoError(t, err)
+	assert.NotNil(t, client)
+
+	_, err = otlptracegrpc.NewClientWithError(otlptracegrpc.WithCompressor("invalid"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid compression type: 'invalid'")
+
+	// The contradictions are reported, the explicit setting is used.
+	var errs []error
+	handler := otel.ErrorHandlerFunc(func(err error) {
+		errs = append(errs, err)
+	})
+	client, err = otlptracegrpc.NewClientWithError(
+		otlptracegrpc.WithEndpoint("https://collector:4317"),
+		otlptracegrpc.WithInsecure(),
+		otlptracegrpc.WithErrorHandler(handler))
+	require.NoError(t, err)
+	assert.NotNil(t, client)
+	require.Len(t, errs, 1)
+	assert.Contains(t, errs[0].Error(), "transport security is disabled")
+
+	// The directory of the persistent queue is released on failure.
+	dir, err := ioutil.TempDir("", "otlptracegrpc")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	_, err = otlptracegrpc.NewClientWithError(
+		otlptracegrpc.WithPersistentQueue(dir, 1<<20),
+		otlptracegrpc.WithCompressor("invalid"))
+	require.Error(t, err)
+	errs = nil
+	client, err = otlptracegrpc.NewClientWithError(
+		otlptracegrpc.WithInsecure(),
+		otlptracegrpc.WithPersistentQueue(dir, 1<<20),
+		otlptracegrpc.WithErrorHandler(handler))
+	require.NoError(t, err)
+	assert.Empty(t, errs)
+	exp, err := otlptrace.New(context.Background(), client)
+	require.NoError(t, err)
+	assert.NoError(t, exp.Shutdown(context.Background()))
+
+	// The options read from the environment are validated too.
+	envStore, err := ottest.SetEnvVariables(map[string]string{
+		"OTEL_EXPORTER_OTLP_TRACES_TIMEOUT": "10s",
+		"OTEL_EXPORTER_OTLP_COMPRESSION":    "invalid",
+	})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, envStore.Restore()) }()
+	_, err = otlptracegrpc.NewClientWithError(otlptracegrpc.WithReconnectionPeriod(-time.Second))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid compression type: 'invalid'")
+	assert.Contains(t, err.Error(), "and 1 more: invalid reconnection period")
+}
+
+func TestNew_withHeadersFunc(t *testing.T) {
+	mc := runMockCollector(t)
+	defer func() {
+		_ = mc.stop()
+	}()
+
+	var calls int
+	ctx := context.Background()
+	exp := newGRPCExporter(t, ctx, mc.endpoint,
+		otlptracegrpc.WithHeaders(map[string]string{"header1": "value1", "authorization": "static"}),
+		otlptracegrpc.WithHeadersFunc(func(context.Context) (map[string]string, error) {
+			calls++
+			return map[string]string{"authorization": fmt.Sprintf("token%d", calls)}, nil
+		}))
+	defer func() {
+		_ = exp.Shutdown(ctx)
+	}()
+
+	for i := 1; i <= 2; i++ {
+		require.NoError(t, exp.ExportSpans(ctx, roSpans))
+		headers := mc.getHeaders()
+		assert.Equal(t, []string{"value1"}, headers.Get("header1"))
+		assert.Equal(t, []string{fmt.Sprintf("token%d", i)}, headers.Get("authorization"))
+	}
+}
+
+func TestNew_withGRPCDialOption(t *testing.T) {
+	mc := runMockCollector(t)
+	defer func() {
+		_ = mc.stop()
+	}()
+
+	var mu sync.Mutex
+	var calls []string
+	interceptor := func(name string) grpc.UnaryClientInterceptor {
+		return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+			mu.Lock()
+			calls = append(calls, name)
+			mu.Unlock()
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+	}
+
+	ctx := context.Background()
+	exp := newGRPCExporter(t, ctx, mc.endpoint,
+		otlptracegrpc.WithGRPCDialOption(grpc.WithChainUnaryInterceptor(interceptor("first"))),
+		otlptracegrpc.WithGRPCDialOption(grpc.WithChainUnaryInterceptor(interceptor("second"))))
+	defer func() {
+		_ = exp.Shutdown(ctx)
+	}()
+
+	require.NoError(t, exp.ExportSpans(ctx, roSpans))
+	assert.Len(t, mc.getSpans(), 1)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"first", "second"}, calls)
+}
+
+func TestNew_withGRPCConn(t *testing.T) {
+	mc := runMockCollector(t)
+	defer func() {
+		_ = mc.stop()
+	}()
+
+	conn, err := grpc.Dial(mc.endpoint, grpc.WithInsecure())
+	require.NoError(t, err)
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		client := otlptracegrpc.NewClient(otlptracegrpc.WithGRPCConn(conn))
+		exp, err := otlptrace.New(ctx, client)
+		require.NoError(t, err)
+		require.NoError(t, exp.ExportSpans(ctx, roSpans))
+		require.NoError(t, exp.Shutdown(ctx))
+
+		// The user supplied connection must outlive the client.
+		assert.NotEqual(t, connectivity.Shutdown, conn.GetState())
+	}
+	assert.Len(t, mc.getSpans(), 2)
+}
+
+func TestNew_withExportInterceptor(t *testing.T) {
+	mc := runMockCollector(t)
+	defer func() {
+		_ = mc.stop()
+	}()
+
+	var calls []string
+	interceptor := func(name string) otlptrace.ExportInterceptor {
+		return func(ctx context.Context, req *collectortracepb.ExportTraceServiceRequest, invoker otlptrace.ExportInvoker) error {
+			calls = append(calls, name)
+			ctx = metadata.AppendToOutgoingContext(ctx, "interceptor", name)
+			return invoker(ctx, req)
+		}
+	}
+	ctx := context.Background()
+	exp := newGRPCExporter(t, ctx, mc.endpoint,
+		otlptracegrpc.WithHeaders(map[string]string{"header1": "value1"}),
+		otlptracegrpc.WithExportInterceptor(interceptor("first")),
+		otlptracegrpc.WithExportInterceptor(interceptor("second")))
+	defer func() {
+		_ = exp.Shutdown(ctx)
+	}()
+
+	require.NoError(t, exp.ExportSpans(ctx, roSpans))
+	assert.Equal(t, []string{"first", "second"}, calls)
+	headers := mc.getHeaders()
+	assert.Equal(t, []string{"value1"}, headers.Get("header1"))
+	assert.Equal(t, []string{"first", "second"}, headers.Get("interceptor"))
+	assert.Len(t, mc.getSpans(), 1)
+}
+
+func TestNew_withMaxConcurrentExports(t *testing.T) {
+	var (
+		mu               sync.Mutex
+		running, maxSeen int
+	)
+	release := make(chan struct{})
+	// The RPCs in flight are counted by the collector.
+	mc := runMockCollectorWithConfig(t, &mockConfig{
+		endpoint: "localhost:0",
+		serverOptions: []grpc.ServerOption{
+			grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+				mu.Lock()
+				running++
+				if running > maxSeen {
+					maxSeen = running
+				}
+				mu.Unlock()
+				<-release
+				mu.Lock()
+				running--
+				mu.Unlock()
+				return handler(ctx, req)
+			}),
+		},
+	})
+	defer func() {
+		_ = mc.stop()
+	}()
+
+	ctx := context.Background()
+	exp := newGRPCExporter(t, ctx, mc.endpoint,
+		otlptracegrpc.WithMaxConcurrentExports(2))
+	defer func() {
+		_ = exp.Shutdown(ctx)
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, exp.ExportSpans(ctx, roSpans))
+		}()
+	}
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return running == 2
+	}, time.Second, time.Millisecond)
+
+	// The exports waiting for a slot return once their context is done.
+	tCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, exp.ExportSpans(tCtx, roSpans), context.DeadlineExceeded)
+
+	close(release)
+	wg.Wait()
+	assert.Equal(t, 2, maxSeen)
+	assert.Len(t, mc.getSpans(), 6)
+}
+
+func TestContextWithExportMetadata(t *testing.T) {
+	mc := runMockCollector(t)
+	defer func() {
+		_ = mc.stop()
+	}()
+
+	// The tenant is routed by the metadata computed from the exported
+	// resource.
+	tenantMetadata := func(ctx context.Context, req *collectortracepb.ExportTraceServiceRequest, invoker otlptrace.ExportInvoker) error {
+		for _, kv := range req.ResourceSpans[0].GetResource().GetAttributes() {
+			if kv.Key == "tenant.id" {
+				ctx = otlptracegrpc.ContextWithExportMetadata(ctx, metadata.Pairs("Tenant-ID", kv.Value.GetStringValue()))
+			}
+		}
+		return invoker(ctx, req)
+	}
+	ctx := context.Background()
+	exp := newGRPCExporter(t, ctx, mc.endpoint,
+		otlptracegrpc.WithHeaders(map[string]string{"header1": "value1", "tenant-id": "default"}),
+		otlptracegrpc.WithExportInterceptor(tenantMetadata))
+	defer func() {
+		_ = exp.Shutdown(ctx)
+	}()
+
+	spans := tracetest.SpanStubs{{
+		Name:     "Span 0",
+		Resource: resource.NewSchemaless(attribute.String("tenant.id", "tenant1")),
+	}}.Snapshots()
+	ctx = otlptracegrpc.ContextWithExportMetadata(ctx, metadata.Pairs("region", "eu", "header1", "value2"))
+	require.NoError(t, exp.ExportSpans(ctx, spans))
+	headers := mc.getHeaders()
+	assert.Equal(t, []string{"tenant1"}, headers.Get("tenant-id"))
+	assert.Equal(t, []string{"eu"}, headers.Get("region"))
+	assert.Equal(t, []string{"value2"}, headers.Get("header1"))
+
+	// The outgoing metadata takes precedence.
+	ctx = metadata.AppendToOutgoingContext(ctx, "region", "us")
+	require.NoError(t, exp.ExportSpans(ctx, roSpans))
+	headers = mc.getHeaders()
+	assert.Equal(t, []string{"default"}, headers.Get("tenant-id"))
+	assert.Equal(t, []string{"us"}, headers.Get("region"))
+}
+
+func TestNew_withExportInterceptorError(t *testing.T) {
+	mc := runMockCollector(t)
+	defer func() {
+		_ = mc.stop()
+	}()
+
+	ctx := context.Background()
+	exp := newGRPCExporter(t, ctx, mc.endpoint,
+		otlptracegrpc.WithExportInterceptor(func(context.Context, *collectortracepb.ExportTraceServiceRequest, otlptrace.ExportInvoker) error {
+			return assert.AnError
+		}))
+	defer func() {
+		_ = exp.Shutdown(ctx)
+	}()
+
+	assert.ErrorIs(t, exp.ExportSpans(ctx, roSpans), assert.AnError)
+	assert.Empty(t, mc.getSpans())
+}
+
+func TestNew_withResourceAttributes(t *testing.T) {
+	mc := runMockCollector(t)
+	defer func() {
+		_ = mc.stop()
+	}()
+
+	ctx := context.Background()
+	exp := newGRPCExporter(t, ctx, mc.endpoint,
+		otlptracegrpc.WithResourceAttributes([]*commonpb.KeyValue{
+			{Key: "a", Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: "c"}}},
+			{Key: "region", Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: "eu"}}},
+		}, false))
+	defer func() {
+		_ = exp.Shutdown(ctx)
+	}()
+
+	require.NoError(t, exp.ExportSpans(ctx, otlptracetest.SingleReadOnlySpan()))
+	rss := mc.getResourceSpans()
+	require.Len(t, rss, 1)
+	got := map[string]string{}
+	for _, kv := range rss[0].Resource.Attributes {
+		got[kv.Key] = kv.Value.GetStringValue()
+	}
+	assert.Equal(t, map[string]string{"a": "b", "region": "eu"}, got)
+}
+
+func TestNew_withSpanTransform(t *testing.T) {
+	mc := runMockCollector(t)
+	defer func() {
+		_ = mc.stop()
+	}()
+
+	ctx := context.Background()
+	exp := newGRPCExporter(t, ctx, mc.endpoint,
+		otlptracegrpc.WithSpanTransform(func(s *tracepb.Span) {
+			for _, kv := range s.Attributes {
+				if kv.Key == "http.url" {
+					url := kv.Value.GetStringValue()
+					if i := strings.IndexByte(url, '?'); i >= 0 {
+						kv.Value = &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: url[:i]}}
+					}
+				}
+			}
+		}))
+	defer func() {
+		_ = exp.Shutdown(ctx)
+	}()
+
+	spans := tracetest.SpanStubs{{
+		Name:       "GET",
+		Attributes: []attribute.KeyValue{attribute.String("http.url", "https://example.com/path?token=secret")},
+	}}.Snapshots()
+	require.NoError(t, exp.ExportSpans(ctx, spans))
+	got := mc.getSpans()
+	require.Len(t, got, 1)
+	require.Len(t, got[0].Attributes, 1)
+	assert.Equal(t, "https://example.com/path", got[0].Attributes[0].Value.GetStringValue())
+}
+
+func TestNew_withStrictValidation(t *testing.T) {
+	mc := runMockCollector(t)
+	defer func() {
+		_ = mc.stop()
+	}()
+
+	ctx := context.Background()
+	exp := newGRPCExporter(t, ctx, mc.endpoint, otlptracegrpc.WithStrictValidation())
+	defer func() {
+		_ = exp.Shutdown(ctx)
+	}()
+
+	valid := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{0x1},
+		SpanID:  trace.SpanID{0x1},
+	})
+	// The span without a span context has all zeros IDs.
+	spans := tracetest.SpanStubs{
+		{Name: "valid", SpanContext: valid},
+		{Name: "malformed"},
+	}.Snapshots()
+	require.NoError(t, exp.ExportSpans(ctx, spans))
+	got := mc.getSpans()
+	require.Len(t, got, 1)
+	assert.Equal(t, "valid", got[0].Name)
+}
+
+func TestNew_withStrictValidationForceFlush(t *testing.T) {
+	mc := runMockCollectorWithConfig(t, &mockConfig{
+		errors:   []error{status.Error(codes.InvalidArgument, "flushed")},
+		endpoint: "localhost:0",
+	})
+	defer func() {
+		_ = mc.stop()
+	}()
+
+	ctx := context.Background()
+	exp := newGRPCExporter(t, ctx, mc.endpoint, otlptracegrpc.WithStrictValidation())
+	defer func() {
+		_ = exp.Shutdown(ctx)
+	}()
+
+	// The empty request of ForceFlush is still sent.
+	assert.Equal(t, codes.InvalidArgument, status.Code(exp.ForceFlush(ctx)))
+}
+
+func TestNew_withExportSampler(t *testing.T) {
+	mc := runMockCollector(t)
+	defer func() {
+		_ = mc.stop()
+	}()
+
+	mp := metrictest.NewMeterProvider()
+	ctx := context.Background()
+	exp := newGRPCExporter(t, ctx, mc.endpoint,
+		otlptracegrpc.WithSelfObservability(mp),
+		otlptracegrpc.WithExportSampler(func(s *tracepb.Span) bool {
+			for _, kv := range s.Attributes {
+				if kv.Key == "shed" {
+					return !kv.Value.GetBoolValue()
+				}
+			}
+			return true
+		}))
+	defer func() {
+		_ = exp.Shutdown(ctx)
+	}()
+
+	shed := []attribute.KeyValue{attribute.Bool("shed", true)}
+	kept := resource.NewSchemaless(attribute.String("service.name", "kept"))
+	dropped := resource.NewSchemaless(attribute.String("service.name", "dropped"))
+	require.NoError(t, exp.ExportSpans(ctx, tracetest.SpanStubs{
+		{Name: "kept", Resource: kept},
+		{Name: "shed", Resource: kept, Attributes: shed},
+		{Name: "shed", Resource: dropped, Attributes: shed},
+	}.Snapshots()))
+	// The resource left without spans is omitted.
+	require.Len(t, mc.getResourceSpans(), 1)
+	got := mc.getSpans()
+	require.Len(t, got, 1)
+	assert.Equal(t, "kept", got[0].Name)
+
+	// An export whose spans are all dropped succeeds without a request.
+	require.NoError(t, exp.ExportSpans(ctx, tracetest.SpanStubs{
+		{Name: "shed", Resource: dropped, Attributes: shed},
+	}.Snapshots()))
+	assert.Len(t, mc.getResourceSpans(), 1)
+
+	var sampledOut int64
+	for _, m := range metrictest.AsStructs(mp.MeasurementBatches) {
+		if m.Name == "otlp.exporter.sampled_out_spans" {
+			sampledOut += m.Number.AsInt64()
+		}
+	}
+	assert.Equal(t, int64(3), sampledOut)
+}
+
+func TestExportTimeoutDeadline(t *testing.T) {
+	tests := []struct {
+		name          string
+		timeout       time.Duration
+		callerTimeout time.Duration
+		want          string
+	}{
+		{
+			name:          "configured timeout",
+			timeout:       50 * time.Millisecond,
+			callerTimeout: time.Minute,
+			want:          "the configured timeout 50ms expired",
+		},
+		{
+			name:          "caller deadline",
+			timeout:       time.Minute,
+			callerTimeout: 50 * time.Millisecond,
+			want:          "the deadline of the caller expired",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mc := runMockCollectorWithConfig(t, &mockConfig{
+				endpoint: "localhost:0",
+				serverOptions: []grpc.ServerOption{
+					grpc.UnaryInterceptor(func(ctx context.Context, _ interface{}, _ *grpc.UnaryServerInfo, _ grpc.UnaryHandler) (interface{}, error) {
+						<-ctx.Done()
+						return nil, status.FromContextError(ctx.Err()).Err()
+					}),
+				},
+			})
+			defer func() {
+				_ = mc.stop()
+			}()
+
+			ctx := context.Background()
+			exp := newGRPCExporter(t, ctx, mc.endpoint,
+				otlptracegrpc.WithTimeout(tt.timeout),
+				otlptracegrpc.WithRetry(otlptracegrpc.RetryConfig{Enabled: false}))
+			defer func() {
+				_ = exp.Shutdown(ctx)
+			}()
+
+			exportCtx, cancel := context.WithTimeout(ctx, tt.callerTimeout)
+			defer cancel()
+			err := exp.ExportSpans(exportCtx, roSpans)
+			require.Error(t, err)
+			assert.ErrorIs(t, err, otlptrace.ErrTimeout)
+			assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
+
+func TestNew_withStableOrdering(t *testing.T) {
+	mc := runMockCollector(t)
+	defer func() {
+		_ = mc.stop()
+	}()
+
+	var requests [][]byte
+	ctx := context.Background()
+	exp := newGRPCExporter(t, ctx, mc.endpoint,
+		otlptracegrpc.WithStableOrdering(),
+		otlptracegrpc.WithExportInterceptor(func(ctx context.Context, req *collectortracepb.ExportTraceServiceRequest, invoker otlptrace.ExportInvoker) error {
+			b, err := proto.MarshalOptions{Deterministic: true}.Marshal(req)
+			require.NoError(t, err)
+			requests = append(requests, b)
+			return invoker(ctx, req)
+		}))
+	defer func() {
+		_ = exp.Shutdown(ctx)
+	}()
+
+	var stubs tracetest.SpanStubs
+	for _, service := range []string{"b", "a"} {
+		res := resource.NewSchemaless(attribute.String("service.name", service))
+		for i, lib := range []string{"y", "x"} {
+			stubs = append(stubs, tracetest.SpanStub{
+				Name:                   service + lib,
+				StartTime:              time.Unix(int64(i), 0),
+				Resource:               res,
+				InstrumentationLibrary: instrumentation.Library{Name: lib},
+			})
+		}
+	}
+	reversed := make(tracetest.SpanStubs, len(stubs))
+	for i, s := range stubs {
+		reversed[len(stubs)-1-i] = s
+	}
+	require.NoError(t, exp.ExportSpans(ctx, stubs.Snapshots()))
+	require.NoError(t, exp.ExportSpans(ctx, reversed.Snapshots()))
+	require.Len(t, requests, 2)
+	assert.Equal(t, requests[0], requests[1])
+
+	var req collectortracepb.ExportTraceServiceRequest
+	require.NoError(t, proto.Unmarshal(requests[0], &req))
+	var names []string
+	for _, rs := range req.ResourceSpans {
+		for _, ils := range rs.InstrumentationLibrarySpans {
+			for _, s := range ils.Spans {
+				names = append(names, s.Name)
+			}
+		}
+	}
+	assert.Equal(t, []string{"ax", "ay", "bx", "by"}, names)
+}
+
+func TestNew_withErrorHistory(t *testing.T) {
+	mc := runMockCollectorWithConfig(t, &mockConfig{
+		errors: []error{
+			status.Error(codes.InvalidArgument, "first"),
+			status.Error(codes.InvalidArgument, "second"),
+			status.Error(codes.InvalidArgument, "third"),
+		},
+		endpoint: "localhost:0",
+	})
+	defer func() {
+		_ = mc.stop()
+	}()
+
+	ctx := context.Background()
+	exp := newGRPCExporter(t, ctx, mc.endpoint, otlptracegrpc.WithErrorHistory(2))
+	defer func() {
+		_ = exp.Shutdown(ctx)
+	}()
+
+	assert.Empty(t, exp.RecentErrors())
+	connected := func(s otlptrace.ConnectionStatus) bool { return s.Connected }
+	for i := 0; i < 3; i++ {
+		// A failed export disconnects the client.
+		otlptracetest.WaitForConnectionStatus(ctx, t, exp, connected)
+		assert.Error(t, exp.ExportSpans(ctx, roSpans))
+	}
+	otlptracetest.WaitForConnectionStatus(ctx, t, exp, connected)
+	require.NoError(t, exp.ExportSpans(ctx, roSpans))
+
+	// The successful export is not recorded, the oldest error is dropped.
+	recent := exp.RecentErrors()
+	require.Len(t, recent, 2)
+	assert.Contains(t, recent[0].Err.Error(), "second")
+	assert.Contains(t, recent[1].Err.Error(), "third")
+	assert.False(t, recent[1].Time.Before(recent[0].Time))
+}
+
+func TestNew_withResponseMetadataHandler(t *testing.T) {
+	var mu sync.Mutex
+	attempt := 0
+	setMetadata := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		mu.Lock()
+		attempt++
+		n := attempt
+		mu.Unlock()
+		_ = grpc.SetHeader(ctx, metadata.Pairs("attempt", fmt.Sprint(n)))
+		_ = grpc.SetTrailer(ctx, metadata.Pairs("otel-rate-limit-remaining", fmt.Sprint(10-n)))
+		return handler(ctx, req)
+	}
+	mc := runMockCollectorWithConfig(t, &mockConfig{
+		errors:        []error{status.Error(codes.Unavailable, "backend restarting")},
+		endpoint:      "localhost:0",
+		serverOptions: []grpc.ServerOption{grpc.UnaryInterceptor(setMetadata)},
+	})
+	defer func() {
+		_ = mc.stop()
+	}()
+
+	var got []metadata.MD
+	ctx := context.Background()
+	exp := newGRPCExporter(t, ctx, mc.endpoint,
+		otlptracegrpc.WithResponseMetadataHandler(func(md metadata.MD) {
+			mu.Lock()
+			got = append(got, md)
+			mu.Unlock()
+		}),
+		otlptracegrpc.WithRetry(otlptracegrpc.RetryConfig{
+			Enabled:         true,
+			InitialInterval: time.Millisecond,
+			MaxInterval:     time.Millisecond,
+			MaxElapsedTime:  10 * time.Second,
+		}))
+	defer func() {
+		_ = exp.Shutdown(ctx)
+	}()
+
+	// The metadata of the failed attempt is handled too.
+	require.NoError(t, exp.ExportSpans(ctx, roSpans))
+	require.Len(t, got, 2)
+	for i, md := range got {
+		assert.Equal(t, []string{fmt.Sprint(i + 1)}, md.Get("attempt"))
+		assert.Equal(t, []string{fmt.Sprint(9 - i)}, md.Get("otel-rate-limit-remaining"))
+	}
+}
+
+func TestNew_withExportCoalescingFlushesOnShutdown(t *testing.T) {
+	mc := runMockCollector(t)
+	defer func() {
+		_ = mc.stop()
+	}()
+
+	ctx := context.Background()
+	exp := newGRPCExporter(t, ctx, mc.endpoint, otlptracegrpc.WithExportCoalescing(time.Hour, 0))
+
+	// The exports return once their context is done, their spans are
+	// still held.
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	assert.ErrorIs(t, exp.ExportSpans(cancelled, roSpans), context.Canceled)
+	assert.ErrorIs(t, exp.ExportSpans(cancelled, roSpans), context.Canceled)
+	assert.Empty(t, mc.getSpans())
+
+	require.NoError(t, exp.Shutdown(ctx))
+	assert.Len(t, mc.getSpans(), 2*len(roSpans))
+}
+
+func TestNew_withExportCoalescingContextValues(t *testing.T) {
+	mc := runMockCollector(t)
+	defer func() {
+		_ = mc.stop()
+	}()
+
+	ctx := context.Background()
+	exp := newGRPCExporter(t, ctx, mc.endpoint, otlptracegrpc.WithExportCoalescing(time.Hour, 0))
+	defer func() {
+		_ = exp.Shutdown(ctx)
+	}()
+
+	// The exports carrying metadata or a compressor are not held, they are
+	// uploaded on their own with their context.
+	tCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	mdCtx := otlptracegrpc.ContextWithExportMetadata(tCtx, metadata.Pairs("tenant-id", "tenant1"))
+	require.NoError(t, exp.ExportSpans(mdCtx, roSpans))
+	assert.Equal(t, []string{"tenant1"}, mc.getHeaders().Get("tenant-id"))
+	require.NoError(t, exp.ExportSpans(metadata.AppendToOutgoingContext(tCtx, "tenant-id", "tenant2"), roSpans))
+	assert.Equal(t, []string{"tenant2"}, mc.getHeaders().Get("tenant-id"))
+	require.NoError(t, exp.ExportSpans(otlptracegrpc.ContextWithCompressor(tCtx, ""), roSpans))
+	assert.Len(t, mc.getSpans(), 3*len(roSpans))
+}
+
+func TestNew_withEmptyBatch(t *testing.T) {
+	mc := runMockCollectorWithConfig(t, &mockConfig{
+		errors:   []error{status.Error(codes.InvalidArgument, "first")},
+		endpoint: "localhost:0",
+	})
+
+	ctx := context.Background()
+	client := otlptracegrpc.NewClient(
+		otlptracegrpc.WithInsecure(),
+		otlptracegrpc.WithEndpoint(mc.endpoint),
+		otlptracegrpc.WithErrorHistory(1),
+		otlptracegrpc.WithRetry(otlptracegrpc.RetryConfig{Enabled: false}),
+	)
+	exp, err := otlptrace.New(ctx, client)
+	require.NoError(t, err)
+	defer func() {
+		_ = exp.Shutdown(ctx)
+	}()
+
+	// No request is sent, the injected error is returned by the next one.
+	assert.NoError(t, client.UploadTraces(ctx, nil))
+	assert.NoError(t, client.UploadTraces(ctx, []*tracepb.ResourceSpans{}))
+	assert.Empty(t, exp.RecentErrors())
+	assert.Equal(t, codes.InvalidArgument, status.Code(exp.ForceFlush(ctx)))
+
+	// Nothing is sent once disconnected either.
+	require.NoError(t, mc.stop())
+	assert.Error(t, exp.ForceFlush(ctx))
+	assert.NoError(t, client.UploadTraces(ctx, nil))
+}
+
+func TestNew_withErrorHandler(t *testing.T) {
+	var errs []error
+	handler := otel.ErrorHandlerFunc(func(err error) {
+		errs = append(errs, err)
+	})
+	client := otlptracegrpc.NewClient(
+		otlptracegrpc.WithInsecure(),
+		otlptracegrpc.WithErrorHistory(-1),
+		otlptracegrpc.WithErrorHandler(handler),
+	)
+	require.NotNil(t, client)
+	require.Len(t, errs, 1)
+	assert.Contains(t, errs[0].Error(), "invalid error history size")
+}
+
+func TestNew_withErrorHandlerEnvironment(t *testing.T) {
+	envStore, err := ottest.SetEnvVariables(map[string]string{
+		"OTEL_EXPORTER_OTLP_TRACES_TIMEOUT": "thirty seconds",
+	})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, envStore.Restore())
+	}()
+
+	// The errors read from the environment are not sent to the global error
+	// handler.
+	var errs []error
+	client := otlptracegrpc.NewClient(
+		otlptracegrpc.WithInsecure(),
+		otlptracegrpc.WithErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+			errs = append(errs, err)
+		})),
+	)
+	require.NotNil(t, client)
+	require.Len(t, errs, 1)
+	assert.Contains(t, errs[0].Error(), "OTEL_EXPORTER_OTLP_TRACES_TIMEOUT")
+
+	_, err = otlptracegrpc.NewClientWithError(otlptracegrpc.WithInsecure())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "OTEL_EXPORTER_OTLP_TRACES_TIMEOUT")
+}
+
+func TestNew_withStatsLogInterval(t *testing.T) {
+	mc := runMockCollectorWithConfig(t, &mockConfig{
+		errors:   []error{status.Error(codes.InvalidArgument, "invalid")},
+		endpoint: "localhost:0",
+	})
+	defer func() {
+		_ = mc.stop()
+	}()
+	summaries := make(chan otlptrace.StatsSummary, 100)
+	handler := otel.ErrorHandlerFunc(func(err error) {
+		var s otlptrace.StatsSummary
+		if errors.As(err, &s) {
+			summaries <- s
+		}
+	})
+	ctx := context.Background()
+	exp := newGRPCExporter(t, ctx, mc.endpoint,
+		otlptracegrpc.WithStatsLogInterval(5*time.Millisecond),
+		otlptracegrpc.WithErrorHandler(handler))
+
+	require.Error(t, exp.ExportSpans(ctx, roSpans))
+	otlptracetest.WaitForConnectionStatus(ctx, t, exp, func(s otlptrace.ConnectionStatus) bool { return s.Connected })
+	require.NoError(t, exp.ExportSpans(ctx, roSpans))
+	var total otlptrace.StatsSummary
+	for total.SentSpans < 1 || total.DroppedSpans < 1 {
+		s := <-summaries
+		total.SentSpans += s.SentSpans
+		total.DroppedSpans += s.DroppedSpans
+		total.Errors += s.Errors
+	}
+	assert.Equal(t, int64(1), total.SentSpans)
+	assert.Equal(t, int64(1), total.DroppedSpans)
+	assert.Equal(t, int64(1), total.Errors)
+
+	// The summaries stop with the exporter.
+	require.NoError(t, exp.Shutdown(ctx))
+	for len(summaries) > 0 {
+		<-summaries
+	}
+	time.Sleep(20 * time.Millisecond)
+	assert.Empty(t, summaries)
+}
+
+func TestNew_withEndpointFile(t *testing.T) {
+	mc := runMockCollector(t)
+	defer func() {
+		_ = mc.stop()
+	}()
+	dir, err := ioutil.TempDir("", "otlptracegrpc")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "endpoint")
+	require.NoError(t, ioutil.WriteFile(path, []byte(mc.endpoint+"\n"), 0600))
+
+	ctx := context.Background()
+	exp := newGRPCExporter(t, ctx, "unused:4317",
+		otlptracegrpc.WithReconnectionPeriod(10*time.Millisecond),
+		otlptracegrpc.WithEndpointFile(path))
+	defer func() {
+		_ = exp.Shutdown(ctx)
+	}()
+	require.NoError(t, exp.ExportSpans(ctx, roSpans))
+	assert.Len(t, mc.getSpans(), 1)
+
+	// The exporter moves to the new collector written to the file.
+	newMC := runMockCollector(t)
+	defer func() {
+		_ = newMC.stop()
+	}()
+	require.NoError(t, ioutil.WriteFile(path, []byte(newMC.endpoint), 0600))
+	require.Eventually(t, func() bool {
+		return exp.ExportSpans(ctx, roSpans) == nil && len(newMC.getSpans()) > 0
+	}, 10*time.Second, 10*time.Millisecond)
+}
+
+func TestNew_withHealthCheck(t *testing.T) {
+	hs := health.NewServer()
+	hs.SetServingStatus("otlp", healthpb.HealthCheckResponse_SERVING)
+	mc := runMockCollectorWithConfig(t, &mockConfig{
+		endpoint: "localhost:0",
+		health:   hs,
+	})
+	defer func() {
+		_ = mc.stop()
+	}()
+
+	ctx := context.Background()
+	exp := newGRPCExporter(t, ctx, mc.endpoint)
+	defer func() {
+		_ = exp.Shutdown(ctx)
+	}()
+	assert.NoError(t, exp.Healthy(ctx))
+	hs.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	err := exp.Healthy(ctx)
+	assert.ErrorIs(t, err, otlptrace.ErrUnhealthy)
+	assert.Contains(t, err.Error(), "NOT_SERVING")
+
+	serviceExp := newGRPCExporter(t, ctx, mc.endpoint, otlptracegrpc.WithHealthCheckService("otlp"))
+	defer func() {
+		_ = serviceExp.Shutdown(ctx)
+	}()
+	assert.NoError(t, serviceExp.Healthy(ctx))
+
+	unknownExp := newGRPCExporter(t, ctx, mc.endpoint, otlptracegrpc.WithHealthCheckService("unknown"))
+	defer func() {
+		_ = unknownExp.Shutdown(ctx)
+	}()
+	assert.Equal(t, codes.NotFound, status.Code(unknownExp.Healthy(ctx)))
+}
+
+func TestNew_withHealthCheckUnimplemented(t *testing.T) {
+	mc := runMockCollector(t)
+	defer func() {
+		_ = mc.stop()
+	}()
+
+	ctx := context.Background()
+	exp := otlptrace.NewUnstarted(otlptracegrpc.NewClient(
+		otlptracegrpc.WithInsecure(),
+		otlptracegrpc.WithEndpoint(mc.endpoint)))
+	assert.Error(t, exp.Healthy(ctx))
+	require.NoError(t, exp.Start(ctx))
+	defer func() {
+		_ = exp.Shutdown(ctx)
+	}()
+	assert.Equal(t, codes.Unimplemented, status.Code(exp.Healthy(ctx)))
+}
+
+func TestNew_withPersistentQueue(t *testing.T) {
+	dir, err := ioutil.TempDir("", "otlptracegrpc")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	mc := runMockCollectorWithConfig(t, &mockConfig{
+		errors:   []error{status.Error(codes.Unavailable, "unavailable")},
+		endpoint: "localhost:0",
+	})
+	defer func() {
+		_ = mc.stop()
+	}()
+
+	ctx := context.Background()
+	exp := newGRPCExporter(t, ctx, mc.endpoint,
+		otlptracegrpc.WithRetry(otlptracegrpc.RetryConfig{Enabled: false}),
+		otlptracegrpc.WithPersistentQueue(dir, 1<<20))
+	defer func() {
+		assert.NoError(t, exp.Shutdown(ctx))
+	}()
+
+	// The failed export is persisted, and sent again once an export
+	// succeeds.
+	assert.NoError(t, exp.ExportSpans(ctx, roSpans))
+	assert.Empty(t, mc.getSpans())
+	require.Eventually(t, func() bool {
+		return exp.ExportSpans(ctx, roSpans) == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Eventually(t, func() bool {
+		return len(mc.getSpans()) == 2*len(roSpans)
+	}, 5*time.Second, time.Millisecond)
+	assert.Eventually(t, func() bool {
+		files, err := filepath.Glob(filepath.Join(dir, "*.pb"))
+		return err == nil && len(files) == 0
+	}, 5*time.Second, time.Millisecond)
+}
+
+func TestNew_withMemoryQueue(t *testing.T) {
+	mc := runMockCollectorWithConfig(t, &mockConfig{
+		errors:   []error{status.Error(codes.Unavailable, "unavailable")},
+		endpoint: "localhost:0",
+	})
+	defer func() {
+		_ = mc.stop()
+	}()
+
+	ctx := context.Background()
+	exp := newGRPCExporter(t, ctx, mc.endpoint,
+		otlptracegrpc.WithRetry(otlptracegrpc.RetryConfig{Enabled: false}),
+		otlptracegrpc.WithMemoryQueue(10))
+
+	// The failed export is queued, and sent when shutting down.
+	assert.NoError(t, exp.ExportSpans(ctx, roSpans))
+	assert.Empty(t, mc.getSpans())
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	require.NoError(t, exp.Shutdown(ctx))
+	assert.Len(t, mc.getSpans(), len(roSpans))
+}
+
+func TestNew_withHeadersFuncError(t *testing.T) {
+	mc := runMockCollector(t)
+	defer func() {
+		_ = mc.stop()
+	}()
+
+	ctx := context.Background()
+	exp := newGRPCExporter(t, ctx, mc.endpoint,
+		otlptracegrpc.WithHeadersFunc(func(context.Context) (map[string]string, error) {
+			return nil, assert.AnError
+		}))
+	defer func() {
+		_ = exp.Shutdown(ctx)
+	}()
+
+	assert.ErrorIs(t, exp.ExportSpans(ctx, roSpans), assert.AnError)
+	assert.Len(t, mc.getSpans(), 0)
+}
+
+func TestNew_WithTimeout(t *testing.T) {
+	tts := []struct {
+		name    string
+		fn      func(exp *otlptrace.Exporter) error
+		timeout time.Duration
+		spans   int
+		code    codes.Code
+		delay   bool
+	}{
+		{
+			name: "Timeout Spans",
+			fn: func(exp *otlptrace.Exporter) error {
+				return exp.ExportSpans(context.Background(), roSpans)
+			},
+			timeout: time.Millisecond * 100,
+			code:    codes.DeadlineExceeded,
+			delay:   true,
+		},
+		{
+			name: "Caller Deadline Shorter Than Timeout",
+			fn: func(exp *otlptrace.Exporter) error {
+				ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+				defer cancel()
+				return exp.ExportSpans(ctx, roSpans)
+			},
+			timeout: time.Minute,
+			code:    codes.DeadlineExceeded,
+			delay:   true,
+		},
+
+		{
+			name: "No Timeout Spans",
+			fn: func(exp *otlptrace.Exporter) error {
+				return exp.ExportSpans(context.Background(), roSpans)
+			},
+			timeout: time.Minute,
+			spans:   1,
+			code:    codes.OK,
+		},
+	}
+
+	for _, tt := range tts {
+		t.Run(tt.name, func(t *testing.T) {
+
+			mc := runMockCollector(t)
+			if tt.delay {
+				mc.traceSvc.delay = time.Second * 10
+			}
+			defer func() {
+				_ = mc.stop()
+			}()
+
+			ctx := context.Background()
+			exp := newGRPCExporter(t, ctx, mc.endpoint, otlptracegrpc.WithTimeout(tt.timeout), otlptracegrpc.WithRetry(otlptracegrpc.RetryConfig{Enabled: false}))
+			defer func() {
+				_ = exp.Shutdown(ctx)
+			}()
+
+			err := tt.fn(exp)
+
+			if tt.code == codes.OK {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+
+			s := status.Convert(err)
+			require.Equal(t, tt.code, s.Code())
+
+			require.Len(t, mc.getSpans(), tt.spans)
+		})
+	}
+}
+
+func TestNew_withRetry(t *testing.T) {
+	mc := runMockCollectorWithConfig(t, &mockConfig{
+		errors: []error{
+			status.Error(codes.Unavailable, "backend restarting"),
+			status.Error(codes.Aborted, "backend restarting"),
+		},
+		endpoint: "localhost:0",
+	})
+	defer func() {
+		_ = mc.stop()
+	}()
+
+	ctx := context.Background()
+	exp := newGRPCExporter(t, ctx, mc.endpoint, otlptracegrpc.WithRetry(otlptracegrpc.RetryConfig{
+		Enabled:         true,
+		InitialInterval: time.Nanosecond,
+		MaxInterval:     time.Nanosecond,
+		// Never stop retrying.
+		MaxElapsedTime: 0,
+	}))
+	defer func() {
+		_ = exp.Shutdown(ctx)
+	}()
+
+	require.NoError(t, exp.ExportSpans(ctx, roSpans))
+	require.Len(t, mc.getSpans(), 1)
+}
+
+func TestNew_withRetryHonorsContext(t *testing.T) {
+	errs := make([]error, 0, 5)
+	for i := 0; i < cap(errs); i++ {
+		errs = append(errs, status.Error(codes.Unavailable, "backend down"))
+	}
+	mc := runMockCollectorWithConfig(t, &mockConfig{
+		errors:   errs,
+		endpoint: "localhost:0",
+	})
+	defer func() {
+		_ = mc.stop()
+	}()
+
+	ctx := context.Background()
+	exp := newGRPCExporter(t, ctx, mc.endpoint, otlptracegrpc.WithRetry(otlptracegrpc.RetryConfig{
+		Enabled:         true,
+		InitialInterval: time.Hour,
+		MaxInterval:     time.Hour,
+		// Never stop retrying.
+		MaxElapsedTime: 0,
+	}))
+	defer func() {
+		_ = exp.Shutdown(ctx)
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	require.Error(t, exp.ExportSpans(ctx, roSpans))
+	assert.Less(t, int64(time.Since(start)), int64(time.Minute))
+	require.Len(t, mc.getSpans(), 0)
+}
+
+func TestNew_withRetryBeyondContextDeadline(t *testing.T) {
+	mc := runMockCollectorWithConfig(t, &mockConfig{
+		errors:   []error{status.Error(codes.Unavailable, "backend down")},
+		endpoint: "localhost:0",
+	})
+	defer func() {
+		_ = mc.stop()
+	}()
+
+	ctx := context.Background()
+	exp := newGRPCExporter(t, ctx, mc.endpoint, otlptracegrpc.WithRetry(otlptracegrpc.RetryConfig{
+		Enabled:         true,
+		InitialInterval: time.Hour,
+		MaxInterval:     time.Hour,
+		MaxElapsedTime:  0,
+	}))
+	defer func() {
+		_ = exp.Shutdown(ctx)
+	}()
+
+	// The retry could not be attempted before the deadline, the export fails
+	// right away instead of waiting for it.
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	start := time.Now()
+	require.Error(t, exp.ExportSpans(ctx, roSpans))
+	assert.Less(t, int64(time.Since(start)), int64(5*time.Second))
+	require.Len(t, mc.getSpans(), 0)
+}
+
+func TestNew_withRetryableErrorFunc(t *testing.T) {
+	mc := runMockCollectorWithConfig(t, &mockConfig{
+		errors:   []error{status.Error(codes.Internal, "transient")},
+		endpoint: "localhost:0",
+	})
+	defer func() {
+		_ = mc.stop()
+	}()
+
+	var calls int
+	ctx := context.Background()
+	exp := newGRPCExporter(t, ctx, mc.endpoint,
+		otlptracegrpc.WithRetry(otlptracegrpc.RetryConfig{
+			Enabled:         true,
+			InitialInterval: time.Nanosecond,
+			MaxInterval:     time.Nanosecond,
+			MaxElapsedTime:  time.Minute,
+		}),
+		otlptracegrpc.WithRetryableErrorFunc(func(err error) bool {
+			calls++
+			return status.Code(err) == codes.Internal
+		}))
+	defer func() {
+		_ = exp.Shutdown(ctx)
+	}()
+
+	// Internal is not retried by default.
+	require.NoError(t, exp.ExportSpans(ctx, roSpans))
+	assert.Equal(t, 1, calls)
+	assert.Len(t, mc.getSpans(), len(roSpans))
+}
+
+func TestNew_withBackoffStrategy(t *testing.T) {
+	errs := make([]error, 0, 5)
+	for i := 0; i < cap(errs); i++ {
+		errs = append(errs, status.Error(codes.Aborted, "backend busy"))
+	}
+	mc := runMockCollectorWithConfig(t, &mockConfig{
+		errors:   errs,
+		endpoint: "localhost:0",
+	})
+	defer func() {
+		_ = mc.stop()
+	}()
+
+	var attempts []int
+	ctx := context.Background()
+	exp := newGRPCExporter(t, ctx, mc.endpoint,
+		otlptracegrpc.WithRetry(otlptracegrpc.RetryConfig{
+			Enabled:         true,
+			InitialInterval: time.Hour,
+			MaxInterval:     time.Hour,
+		}),
+		otlptracegrpc.WithBackoffStrategy(func(attempt int, lastErr error) (time.Duration, bool) {
+			assert.Equal(t, codes.Aborted, status.Code(lastErr))
+			attempts = append(attempts, attempt)
+			return time.Millisecond, attempt > 2
+		}))
+	defer func() {
+		_ = exp.Shutdown(ctx)
+	}()
+
+	err := exp.ExportSpans(ctx, roSpans)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "retry strategy gave up")
+	assert.Equal(t, []int{1, 2, 3}, attempts)
+	assert.Len(t, mc.getSpans(), 0)
+}
+
+func TestNew_withPartialSuccess(t *testing.T) {
+	mc := runMockCollectorWithConfig(t, &mockConfig{
+		endpoint:          "localhost:0",
+		rejectedSpans:     2,
+		partialSuccessMsg: "invalid span",
+	})
+	defer func() {
+		_ = mc.stop()
+	}()
+
+	var (
+		gotRejected int64
+		gotMsg      string
+	)
+	ctx := context.Background()
+	exp := newGRPCExporter(t, ctx, mc.endpoint, otlptracegrpc.WithPartialSuccessHandler(func(rejected int64, msg string) {
+		gotRejected, gotMsg = rejected, msg
+	}))
+	defer func() {
+		_ = exp.Shutdown(ctx)
+	}()
+
+	require.NoError(t, exp.ExportSpans(ctx, roSpans))
+	assert.Equal(t, int64(2), gotRejected)
+	assert.Equal(t, "invalid span", gotMsg)
+}
+
+func TestNew_withZeroPartialSuccess(t *testing.T) {
+	mc := runMockCollector(t)
+	defer func() {
+		_ = mc.stop()
+	}()
+
+	var called bool
+	ctx := context.Background()
+	exp := newGRPCExporter(t, ctx, mc.endpoint, otlptracegrpc.WithPartialSuccessHandler(func(int64, string) {
+		called = true
+	}))
+	defer func() {
+		_ = exp.Shutdown(ctx)
+	}()
+
+	require.NoError(t, exp.ExportSpans(ctx, roSpans))
+	assert.False(t, called, "zero-valued partial success reported")
+}
+
+func TestNew_withSelfObservability(t *testing.T) {
+	mc := runMockCollectorWithConfig(t, &mockConfig{
+		errors: []error{
+			status.Error(codes.Unavailable, "backend restarting"),
+		},
+		endpoint: "localhost:0",
+	})
+	defer func() {
+		_ = mc.stop()
+	}()
+
+	mp := metrictest.NewMeterProvider()
+	ctx := context.Background()
+	exp := newGRPCExporter(t, ctx, mc.endpoint,
+		otlptracegrpc.WithSelfObservability(mp),
+		otlptracegrpc.WithRetry(otlptracegrpc.RetryConfig{
+			Enabled:         true,
+			InitialInterval: time.Nanosecond,
+			MaxInterval:     time.Nanosecond,
+			// Never stop retrying.
+			MaxElapsedTime: 0,
+		}))
+	defer func() {
+		_ = exp.Shutdown(ctx)
+	}()
+
+	require.NoError(t, exp.ExportSpans(ctx, roSpans))
+
+	got := make(map[string]int)
+	for _, m := range metrictest.AsStructs(mp.MeasurementBatches) {
+		got[m.Name] += int(m.Number.AsInt64())
+		assert.Equal(t, "grpc", m.Labels["protocol"].AsString())
+	}
+	assert.Equal(t, 1, got["otlp.exporter.exported_spans"])
+	assert.Equal(t, 2, got["otlp.exporter.export_attempts"])
+	assert.NotContains(t, got, "otlp.exporter.failed_spans")
+	assert.Contains(t, got, "otlp.exporter.export_duration")
+}
+
+type stateRecorder struct {
+	mu          sync.Mutex
+	transitions [][2]otlptracegrpc.ConnectionState
+}
+
+func (r *stateRecorder) record(old, new otlptracegrpc.ConnectionState) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.transitions = append(r.transitions, [2]otlptracegrpc.ConnectionState{old, new})
+}
+
+func (r *stateRecorder) get() [][2]otlptracegrpc.ConnectionState {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([][2]otlptracegrpc.ConnectionState(nil), r.transitions...)
+}
+
+func TestNew_withConnectionStateCallback(t *testing.T) {
+	mc := runMockCollector(t)
+
+	rec := &stateRecorder{}
+	ctx := context.Background()
+	exp := newGRPCExporter(t, ctx, mc.endpoint,
+		otlptracegrpc.WithRetry(otlptracegrpc.RetryConfig{Enabled: false}),
+		otlptracegrpc.WithConnectionStateCallback(rec.record),
+	)
+	defer func() {
+		_ = exp.Shutdown(ctx)
+	}()
+
+	require.Equal(t, [][2]otlptracegrpc.ConnectionState{
+		{otlptracegrpc.ConnectionStateIdle, otlptracegrpc.ConnectionStateConnected},
+	}, rec.get())
+
+	require.NoError(t, mc.stop())
+	require.Error(t, exp.ExportSpans(ctx, roSpans))
+
+	// The background connection routine reconnects after the
+	// reconnection period.
+	require.Eventually(t, func() bool {
+		return len(rec.get()) >= 3
+	}, 10*time.Second, 10*time.Millisecond)
+	assert.Equal(t, [][2]otlptracegrpc.ConnectionState{
+		{otlptracegrpc.ConnectionStateIdle, otlptracegrpc.ConnectionStateConnected},
+		{otlptracegrpc.ConnectionStateConnected, otlptracegrpc.ConnectionStateDisconnected},
+		{otlptracegrpc.ConnectionStateDisconnected, otlptracegrpc.ConnectionStateConnected},
+	}, rec.get()[:3])
+}
+
+func TestNew_withGRPCMaxCallMsgSize(t *testing.T) {
+	tests := []struct {
+		name string
+		opt  otlptracegrpc.Option
+	}{
+		{
+			name: "send",
+			// Not a default call option, the batches are not split.
+			opt: otlptracegrpc.WithDialOption(grpc.WithDefaultCallOptions(grpc.MaxCallSendMsgSize(10))),
+		},
+		{
+			name: "receive",
+			opt:  otlptracegrpc.WithGRPCMaxCallRecvMsgSize(10),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mc := runMockCollectorWithConfig(t, &mockConfig{
+				endpoint:          "localhost:0",
+				rejectedSpans:     1,
+				partialSuccessMsg: "a partial success message longer than the limit",
+			})
+			defer func() {
+				_ = mc.stop()
+			}()
+
+			ctx := context.Background()
+			exp := newGRPCExporter(t, ctx, mc.endpoint, tt.opt,
+				otlptracegrpc.WithPartialSuccessHandler(func(int64, string) {}))
+			defer func() {
+				_ = exp.Shutdown(ctx)
+			}()
+
+			err := exp.ExportSpans(ctx, roSpans)
+			assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+		})
+	}
+}
+
+func TestNew_withMaxExportBatchBytes(t *testing.T) {
+	mc := runMockCollector(t)
+	defer func() {
+		_ = mc.stop()
+	}()
+
+	ctx := context.Background()
+	exp := newGRPCExporter(t, ctx, mc.endpoint, otlptracegrpc.WithMaxExportBatchBytes(1024))
+	defer func() {
+		assert.NoError(t, exp.Shutdown(ctx))
+	}()
+
+	stubs := make(tracetest.SpanStubs, 50)
+	for i := range stubs {
+		stubs[i].Name = fmt.Sprintf("span %d", i)
+	}
+	require.NoError(t, exp.ExportSpans(ctx, stubs.Snapshots()))
+
+	assert.Len(t, mc.getSpans(), len(stubs))
+	mc.traceSvc.mu.RLock()
+	defer mc.traceSvc.mu.RUnlock()
+	assert.Greater(t, mc.traceSvc.requests, 1)
+}
+
+func TestNew_withGRPCMaxCallSendMsgSizeBatches(t *testing.T) {
+	mc := runMockCollector(t)
+	defer func() {
+		_ = mc.stop()
+	}()
+
+	ctx := context.Background()
+	exp := newGRPCExporter(t, ctx, mc.endpoint, otlptracegrpc.WithGRPCMaxCallSendMsgSize(1024))
+	defer func() {
+		assert.NoError(t, exp.Shutdown(ctx))
+	}()
+
+	// The batch exceeding the maximum send message size is split.
+	stubs := make(tracetest.SpanStubs, 50)
+	for i := range stubs {
+		stubs[i].Name = fmt.Sprintf("span %d", i)
+	}
+	require.NoError(t, exp.ExportSpans(ctx, stubs.Snapshots()))
+
+	assert.Len(t, mc.getSpans(), len(stubs))
+	mc.traceSvc.mu.RLock()
+	defer mc.traceSvc.mu.RUnlock()
+	assert.Greater(t, mc.traceSvc.requests, 1)
+}
+
+func TestNew_withOversizeBatchPolicy(t *testing.T) {
+	stubs := make(tracetest.SpanStubs, 25)
+	for i := range stubs {
+		stubs[i].Name = fmt.Sprintf("span %d", i)
+	}
+
+	for _, tt := range []struct {
+		name     string
+		policy   otlptracegrpc.OversizeBatchPolicy
+		wantErr  error
+		spans    int
+		requests int
+	}{
+		{name: "split", policy: otlptracegrpc.SplitOversizeBatch, spans: 25, requests: 3},
+		{name: "reject", policy: otlptracegrpc.RejectOversizeBatch, wantErr: otlptracegrpc.ErrOversizeBatch},
+		{name: "drop", policy: otlptracegrpc.DropOversizeBatch, spans: 10, requests: 1},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			mc := runMockCollector(t)
+			defer func() {
+				_ = mc.stop()
+			}()
+
+			ctx := context.Background()
+			exp := newGRPCExporter(t, ctx, mc.endpoint,
+				otlptracegrpc.WithMaxExportBatchSpans(10),
+				otlptracegrpc.WithOversizeBatchPolicy(tt.policy))
+			defer func() {
+				assert.NoError(t, exp.Shutdown(ctx))
+			}()
+
+			err := exp.ExportSpans(ctx, stubs.Snapshots())
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Len(t, mc.getSpans(), tt.spans)
+			mc.traceSvc.mu.RLock()
+			defer mc.traceSvc.mu.RUnlock()
+			assert.Equal(t, tt.requests, mc.traceSvc.requests)
+		})
+	}
+}
+
+func TestNew_withEndpointsFailover(t *testing.T) {
+	ln, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	unreachable := ln.Addr().String()
+	require.NoError(t, ln.Close())
+
+	mc := runMockCollector(t)
+	defer func() {
+		_ = mc.stop()
+	}()
+
+	rec := &stateRecorder{}
+	ctx := context.Background()
+	exp := newGRPCExporter(t, ctx, "",
+		otlptracegrpc.WithEndpoints([]string{unreachable, mc.endpoint}),
+		otlptracegrpc.WithNoReconnect(),
+		otlptracegrpc.WithRetry(otlptracegrpc.RetryConfig{Enabled: false}),
+		otlptracegrpc.WithConnectionStateCallback(rec.record),
+	)
+	defer func() {
+		_ = exp.Shutdown(ctx)
+	}()
+
+	assert.Equal(t, codes.Unavailable, status.Code(exp.ExportSpans(ctx, roSpans)))
+	require.NoError(t, exp.ExportSpans(ctx, roSpans))
+	assert.Len(t, mc.getSpans(), 1)
+	assert.Equal(t, [][2]otlptracegrpc.ConnectionState{
+		{otlptracegrpc.ConnectionStateIdle, otlptracegrpc.ConnectionStateConnected},
+		{otlptracegrpc.ConnectionStateConnected, otlptracegrpc.ConnectionStateDisconnected},
+		{otlptracegrpc.ConnectionStateDisconnected, otlptracegrpc.ConnectionStateConnected},
+	}, rec.get())
+}
+
+// countingCompressor is an identity compressor that counts its use.
+type countingCompressor struct {
+	mu         sync.Mutex
+	compressed int
+}
+
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
+
+func (c *countingCompressor) Compress(w io.Writer) (io.WriteCloser, error) {
+	c.mu.Lock()
+	c.compressed++
+	c.mu.Unlock()
+	return nopWriteCloser{w}, nil
+}
+
+func (c *countingCompressor) Decompress(r io.Reader) (io.Reader, error) { return r, nil }
+
+func (c *countingCompressor) Name() string { return "otlptracegrpc-counting" }
+
+func (c *countingCompressor) count() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.compressed
+}
+
+func TestNew_withRegisteredCompressor(t *testing.T) {
+	compressor := &countingCompressor{}
+	encoding.RegisterCompressor(compressor)
+
+	mc := runMockCollector(t)
+	defer func() {
+		_ = mc.stop()
+	}()
+
+	ctx := context.Background()
+	exp := newGRPCExporter(t, ctx, mc.endpoint, otlptracegrpc.WithCompressor(compressor.Name()))
+	defer func() {
+		_ = exp.Shutdown(ctx)
+	}()
+
+	require.NoError(t, exp.ExportSpans(ctx, roSpans))
+	assert.Len(t, mc.getSpans(), 1)
+	// The server replies using the same compressor.
+	assert.NotZero(t, compressor.count())
+}
+
+func TestContextWithCompressor(t *testing.T) {
+	compressor := &countingCompressor{}
+	encoding.RegisterCompressor(compressor)
+
+	mc := runMockCollector(t)
+	defer func() {
+		_ = mc.stop()
+	}()
+
+	ctx := context.Background()
+	exp := newGRPCExporter(t, ctx, mc.endpoint, otlptracegrpc.WithCompressor(compressor.Name()))
+	defer func() {
+		_ = exp.Shutdown(ctx)
+	}()
+
+	// The call option overrides the configured compressor.
+	require.NoError(t, exp.ExportSpans(otlptracegrpc.ContextWithCompressor(ctx, ""), roSpans))
+	assert.Zero(t, compressor.count())
+	require.NoError(t, exp.ExportSpans(ctx, roSpans))
+	compressed := compressor.count()
+	assert.NotZero(t, compressed)
+
+	var errs []error
+	uncompressed := newGRPCExporter(t, ctx, mc.endpoint, otlptracegrpc.WithErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		errs = append(errs, err)
+	})))
+	defer func() {
+		_ = uncompressed.Shutdown(ctx)
+	}()
+	require.NoError(t, uncompressed.ExportSpans(ctx, roSpans))
+	assert.Equal(t, compressed, compressor.count())
+	require.NoError(t, uncompressed.ExportSpans(otlptracegrpc.ContextWithCompressor(ctx, compressor.Name()), roSpans))
+	assert.Greater(t, compressor.count(), compressed)
+
+	// Unregistered compressors are ignored and reported by the exports.
+	assert.Empty(t, errs)
+	require.NoError(t, uncompressed.ExportSpans(otlptracegrpc.ContextWithCompressor(ctx, "unregistered"), roSpans))
+	assert.Len(t, mc.getSpans(), 5)
+	require.Len(t, errs, 1)
+	assert.Contains(t, errs[0].Error(), `compressor "unregistered" is not registered`)
+}
+
+func TestNew_withInvalidSecurityConfiguration(t *testing.T) {
+	mc := runMockCollector(t)
+	defer func() {
+		_ = mc.stop()
+	}()
+
+	ctx := context.Background()
+	driver := otlptracegrpc.NewClient(otlptracegrpc.WithEndpoint(mc.endpoint))
+	exp, err := otlptrace.New(ctx, driver)
+	if err != nil {
+		t.Fatalf("failed to create a new collector exporter: %v", err)
+	}
+
+	err = exp.ExportSpans(ctx, roSpans)
+
+	expectedErr := fmt.Sprintf("traces exporter is disconnected from the server %s: grpc: no transport security set (use grpc.WithInsecure() explicitly or set credentials)", mc.endpoint)
+
+	require.Error(t, err)
+	require.Equal(t, expectedErr, err.Error())
+
+	defer func() {
+		_ = exp.Shutdown(ctx)
+	}()
+}
+
+func TestNew_withMultipleAttributeTypes(t *testing.T) {
+	mc := runMockCollector(t)
+
+	defer func() {
+		_ = mc.stop()
+	}()
+
+	<-time.After(5 * time.Millisecond)
+
+	ctx := context.Background()
+	exp := newGRPCExporter(t, ctx, mc.endpoint)
+
+	defer func() {
+		_ = exp.Shutdown(ctx)
+	}()
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithSampler(sdktrace.AlwaysSample()),
+		sdktrace.WithBatcher(
+			exp,
+			// add following two options to ensure flush
+			sdktrace.WithBatchTimeout(5*time.Second),
+			sdktrace.WithMaxExportBatchSize(10),
+		),
+	)
+	defer func() { _ = tp.Shutdown(ctx) }()
+
+	tr := tp.Tracer("test-tracer")
+	testKvs := []attribute.KeyValue{
+		attribute.Int("Int", 1),
+		attribute.Int64("Int64", int64(3)),
+		attribute.Float64("Float64", 2.22),
+		attribute.Bool("Bool", true),
+		attribute.String("String", "test"),
+	}
+	_, span := tr.Start(ctx, "AlwaysSample")
+	span.SetAttributes(testKvs...)
+	span.End()
+
+	// Flush and close.
+	func() {
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+		if err := tp.Shutdown(ctx); err != nil {
+			t.Fatalf("failed to shut down a tracer provider: %v", err)
+		}
+	}()
+
+	// Wait >2 cycles.
+	<-time.After(40 * time.Millisecond)
+
+	// Now shutdown the exporter
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	if err := exp.Shutdown(ctx); err != nil {
+		t.Fatalf("failed to stop the exporter: %v", err)
+	}
+
+	// Shutdown the collector too so that we can begin
+	// verification checks of expected data back.
+	_ = mc.stop()
 
 	// Now verify that we only got one span
 	rss := mc.getSpans()
@@ -563,6 +2628,486 @@ func TestDisconnected(t *testing.T) {
 	}()
 
 	assert.Error(t, exp.ExportSpans(ctx, otlptracetest.SingleReadOnlySpan()))
+	assert.Error(t, exp.ForceFlush(ctx))
+}
+
+func TestForceFlush(t *testing.T) {
+	mc := runMockCollectorWithConfig(t, &mockConfig{
+		errors:   []error{status.Error(codes.InvalidArgument, "rejected")},
+		endpoint: "localhost:0",
+	})
+	defer func() {
+		_ = mc.stop()
+	}()
+
+	ctx := context.Background()
+	exp := newGRPCExporter(t, ctx, mc.endpoint,
+		otlptracegrpc.WithNoReconnect(),
+		otlptracegrpc.WithRetry(otlptracegrpc.RetryConfig{Enabled: false}))
+	defer func() {
+		assert.NoError(t, exp.Shutdown(ctx))
+	}()
+
+	assert.Equal(t, codes.InvalidArgument, status.Code(exp.ForceFlush(ctx)))
+	assert.NoError(t, exp.ForceFlush(ctx))
+	assert.Empty(t, mc.getSpans())
+}
+
+func TestStopWaitsForExport(t *testing.T) {
+	mc := runMockCollector(t)
+	defer func() {
+		_ = mc.stop()
+	}()
+	mc.traceSvc.delay = 500 * time.Millisecond
+
+	ctx := context.Background()
+	exp := newGRPCExporter(t, ctx, mc.endpoint)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- exp.ExportSpans(ctx, otlptracetest.SingleReadOnlySpan())
+	}()
+	<-time.After(100 * time.Millisecond)
+	stopCtx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	assert.NoError(t, exp.Shutdown(stopCtx))
+	assert.NoError(t, <-errCh)
+	assert.Len(t, mc.getSpans(), 1)
+}
+
+func TestStopInterruptsExport(t *testing.T) {
+	mc := runMockCollector(t)
+	defer func() {
+		_ = mc.stop()
+	}()
+	mc.traceSvc.delay = 10 * time.Second
+
+	ctx := context.Background()
+	exp := newGRPCExporter(t, ctx, mc.endpoint)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- exp.ExportSpans(ctx, otlptracetest.SingleReadOnlySpan())
+	}()
+	<-time.After(100 * time.Millisecond)
+	stopCtx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, exp.Shutdown(stopCtx), context.DeadlineExceeded)
+	assert.Error(t, <-errCh)
+}
+
+func TestStopDrainsExport(t *testing.T) {
+	mc := runMockCollector(t)
+	defer func() {
+		_ = mc.stop()
+	}()
+	mc.traceSvc.delay = 500 * time.Millisecond
+
+	ctx := context.Background()
+	exp := newGRPCExporter(t, ctx, mc.endpoint, otlptracegrpc.WithDrainOnStop(10*time.Second))
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- exp.ExportSpans(ctx, otlptracetest.SingleReadOnlySpan())
+	}()
+	<-time.After(100 * time.Millisecond)
+	// The export completes after the shutdown deadline is exceeded.
+	stopCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, exp.Shutdown(stopCtx), context.DeadlineExceeded)
+	assert.NoError(t, <-errCh)
+	assert.Len(t, mc.getSpans(), 1)
+}
+
+func TestExportHonorsRetryInfo(t *testing.T) {
+	throttle := func(delay time.Duration) error {
+		st, err := status.New(codes.ResourceExhausted, "throttled").WithDetails(
+			&errdetails.RetryInfo{RetryDelay: durationpb.New(delay)},
+		)
+		require.NoError(t, err)
+		return st.Err()
+	}
+	retry := otlptracegrpc.RetryConfig{
+		Enabled:         true,
+		InitialInterval: time.Millisecond,
+		MaxInterval:     time.Millisecond,
+		MaxElapsedTime:  time.Minute,
+	}
+
+	t.Run("waits the requested delay", func(t *testing.T) {
+		const delay = 300 * time.Millisecond
+		mc := runMockCollectorWithConfig(t, &mockConfig{
+			errors:   []error{throttle(delay)},
+			endpoint: "localhost:0",
+		})
+		defer func() {
+			_ = mc.stop()
+		}()
+
+		ctx := context.Background()
+		exp := newGRPCExporter(t, ctx, mc.endpoint, otlptracegrpc.WithRetry(retry))
+		defer func() {
+			assert.NoError(t, exp.Shutdown(ctx))
+		}()
+
+		start := time.Now()
+		require.NoError(t, exp.ExportSpans(ctx, otlptracetest.SingleReadOnlySpan()))
+		assert.GreaterOrEqual(t, int64(time.Since(start)), int64(delay))
+		assert.Len(t, mc.getSpans(), 1)
+	})
+
+	t.Run("bounded by the context deadline", func(t *testing.T) {
+		mc := runMockCollectorWithConfig(t, &mockConfig{
+			errors:   []error{throttle(10 * time.Second)},
+			endpoint: "localhost:0",
+		})
+		defer func() {
+			_ = mc.stop()
+		}()
+
+		ctx := context.Background()
+		exp := newGRPCExporter(t, ctx, mc.endpoint, otlptracegrpc.WithRetry(retry))
+		defer func() {
+			assert.NoError(t, exp.Shutdown(ctx))
+		}()
+
+		exportCtx, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		err := exp.ExportSpans(exportCtx, otlptracetest.SingleReadOnlySpan())
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Less(t, int64(time.Since(start)), int64(5*time.Second))
+		assert.Empty(t, mc.getSpans())
+	})
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	mc := runMockCollectorWithConfig(t, &mockConfig{
+		errors: []error{
+			status.Error(codes.InvalidArgument, "rejected"),
+			status.Error(codes.InvalidArgument, "rejected"),
+			status.Error(codes.Unavailable, "unavailable"),
+			status.Error(codes.Unavailable, "unavailable"),
+		},
+		endpoint: "localhost:0",
+	})
+	defer func() {
+		_ = mc.stop()
+	}()
+
+	ctx := context.Background()
+	exp := newGRPCExporter(t, ctx, mc.endpoint,
+		otlptracegrpc.WithNoReconnect(),
+		otlptracegrpc.WithRetry(otlptracegrpc.RetryConfig{Enabled: false}),
+		otlptracegrpc.WithCircuitBreaker(2, time.Hour))
+	defer func() {
+		assert.NoError(t, exp.Shutdown(ctx))
+	}()
+
+	// The rejected exports do not open the circuit breaker, the collector
+	// being healthy.
+	for _, code := range []codes.Code{codes.InvalidArgument, codes.InvalidArgument, codes.Unavailable, codes.Unavailable} {
+		err := exp.ExportSpans(ctx, otlptracetest.SingleReadOnlySpan())
+		assert.Equal(t, code, status.Code(err))
+	}
+	// The collector would accept this export if it was attempted.
+	err := exp.ExportSpans(ctx, otlptracetest.SingleReadOnlySpan())
+	assert.ErrorIs(t, err, otlptracegrpc.ErrCircuitOpen)
+	assert.Empty(t, mc.getSpans())
+}
+
+func TestExportErrors(t *testing.T) {
+	mc := runMockCollectorWithConfig(t, &mockConfig{
+		errors: []error{
+			status.Error(codes.InvalidArgument, "rejected"),
+			status.Error(codes.ResourceExhausted, "exhausted"),
+		},
+		endpoint: "localhost:0",
+	})
+
+	ctx := context.Background()
+	exp := newGRPCExporter(t, ctx, mc.endpoint,
+		otlptracegrpc.WithNoReconnect(),
+		otlptracegrpc.WithRetry(otlptracegrpc.RetryConfig{Enabled: false}))
+	defer func() {
+		assert.NoError(t, exp.Shutdown(ctx))
+	}()
+
+	var eErr *otlptrace.ExportError
+	err := exp.ExportSpans(ctx, roSpans)
+	assert.ErrorIs(t, err, otlptrace.ErrRejected)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	require.True(t, errors.As(err, &eErr))
+	assert.False(t, eErr.Retryable())
+
+	err = exp.ExportSpans(ctx, roSpans)
+	assert.ErrorIs(t, err, otlptrace.ErrRejected)
+
+	deadlineCtx, cancel := context.WithTimeout(ctx, time.Nanosecond)
+	defer cancel()
+	<-deadlineCtx.Done()
+	err = exp.ExportSpans(deadlineCtx, roSpans)
+	assert.ErrorIs(t, err, otlptrace.ErrTimeout)
+
+	require.NoError(t, mc.stop())
+	err = exp.ExportSpans(ctx, roSpans)
+	assert.ErrorIs(t, err, otlptrace.ErrDisconnected)
+	require.True(t, errors.As(err, &eErr))
+	assert.True(t, eErr.Retryable())
+}
+
+func TestLastSuccess(t *testing.T) {
+	mc := runMockCollectorWithConfig(t, &mockConfig{
+		errors:   []error{status.Error(codes.InvalidArgument, "rejected")},
+		endpoint: "localhost:0",
+	})
+	defer func() {
+		_ = mc.stop()
+	}()
+
+	ctx := context.Background()
+	exp := newGRPCExporter(t, ctx, mc.endpoint,
+		otlptracegrpc.WithNoReconnect(),
+		otlptracegrpc.WithRetry(otlptracegrpc.RetryConfig{Enabled: false}))
+	defer func() {
+		assert.NoError(t, exp.Shutdown(ctx))
+	}()
+
+	assert.True(t, exp.LastSuccess().IsZero())
+	assert.Error(t, exp.ExportSpans(ctx, otlptracetest.SingleReadOnlySpan()))
+	assert.True(t, exp.LastSuccess().IsZero())
+
+	before := time.Now()
+	require.NoError(t, exp.ExportSpans(ctx, otlptracetest.SingleReadOnlySpan()))
+	last := exp.LastSuccess()
+	assert.False(t, last.Before(before))
+	assert.False(t, last.After(time.Now()))
+}
+
+func TestLastConnectError(t *testing.T) {
+	mc := runMockCollector(t)
+
+	ctx := context.Background()
+	exp := newGRPCExporter(t, ctx, mc.endpoint,
+		otlptracegrpc.WithNoReconnect(),
+		otlptracegrpc.WithRetry(otlptracegrpc.RetryConfig{Enabled: false}))
+	defer func() {
+		assert.NoError(t, exp.Shutdown(ctx))
+	}()
+
+	require.NoError(t, exp.ExportSpans(ctx, otlptracetest.SingleReadOnlySpan()))
+	assert.NoError(t, exp.LastConnectError())
+
+	require.NoError(t, mc.stop())
+	assert.Error(t, exp.ExportSpans(ctx, otlptracetest.SingleReadOnlySpan()))
+	assert.Error(t, exp.LastConnectError())
+}
+
+func TestConnectionStatus(t *testing.T) {
+	mc := runMockCollector(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	// The reconnection dials fail fast while the collector is down.
+	exp := newGRPCExporter(t, ctx, mc.endpoint,
+		otlptracegrpc.WithDialOption(grpc.WithBlock()),
+		otlptracegrpc.WithDialTimeout(50*time.Millisecond),
+		otlptracegrpc.WithRetry(otlptracegrpc.RetryConfig{Enabled: false}))
+	defer func() {
+		assert.NoError(t, exp.Shutdown(context.Background()))
+	}()
+
+	require.NoError(t, exp.ExportSpans(ctx, roSpans))
+	assert.Equal(t, otlptrace.ConnectionStatus{Connected: true}, exp.ConnectionStatus())
+
+	require.NoError(t, mc.stop())
+	assert.Error(t, exp.ExportSpans(ctx, roSpans))
+	status := otlptracetest.WaitForConnectionStatus(ctx, t, exp, func(s otlptrace.ConnectionStatus) bool {
+		return s.ReconnectAttempts >= 2
+	})
+	assert.False(t, status.Connected)
+	assert.Error(t, status.LastError)
+
+	nmc := runMockCollectorAtEndpoint(t, mc.endpoint)
+	defer func() {
+		_ = nmc.stop()
+	}()
+	otlptracetest.WaitForConnectionStatus(ctx, t, exp, func(s otlptrace.ConnectionStatus) bool {
+		return s.Connected
+	})
+	assert.Equal(t, otlptrace.ConnectionStatus{Connected: true}, exp.ConnectionStatus())
+	require.NoError(t, exp.ExportSpans(ctx, roSpans))
+	assert.Len(t, nmc.getSpans(), 1)
+}
+
+func TestMaxReconnectAttempts(t *testing.T) {
+	mc := runMockCollector(t)
+
+	failed := make(chan struct{})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	exp := newGRPCExporter(t, ctx, mc.endpoint,
+		otlptracegrpc.WithDialOption(grpc.WithBlock()),
+		otlptracegrpc.WithDialTimeout(50*time.Millisecond),
+		otlptracegrpc.WithRetry(otlptracegrpc.RetryConfig{Enabled: false}),
+		otlptracegrpc.WithMaxReconnectAttempts(2),
+		otlptracegrpc.WithConnectionStateCallback(func(_, state otlptracegrpc.ConnectionState) {
+			if state == otlptracegrpc.ConnectionStateFailed {
+				close(failed)
+			}
+		}))
+	defer func() {
+		assert.NoError(t, exp.Shutdown(context.Background()))
+	}()
+	require.NoError(t, exp.ExportSpans(ctx, roSpans))
+
+	require.NoError(t, mc.stop())
+	assert.Error(t, exp.ExportSpans(ctx, roSpans))
+	select {
+	case <-failed:
+	case <-ctx.Done():
+		t.Fatal("the client did not give up reconnecting")
+	}
+
+	// The collector is not reconnected to once the client gave up.
+	nmc := runMockCollectorAtEndpoint(t, mc.endpoint)
+	defer func() {
+		_ = nmc.stop()
+	}()
+	err := exp.ExportSpans(ctx, roSpans)
+	var exportErr *otlptrace.ExportError
+	require.True(t, errors.As(err, &exportErr))
+	assert.ErrorIs(t, err, otlptrace.ErrDisconnected)
+	assert.False(t, exportErr.Retryable())
+	assert.False(t, exp.ConnectionStatus().Connected)
+	assert.Empty(t, nmc.getSpans())
+}
+
+func TestInMemoryCollector(t *testing.T) {
+	ln, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	collector := otlptracetest.NewCollector()
+	srv := grpc.NewServer()
+	collectortracepb.RegisterTraceServiceServer(srv, collector)
+	go func() { _ = srv.Serve(ln) }()
+	defer srv.Stop()
+
+	var rejected int64
+	ctx := context.Background()
+	exp := newGRPCExporter(t, ctx, ln.Addr().String(),
+		otlptracegrpc.WithNoReconnect(),
+		otlptracegrpc.WithRetry(otlptracegrpc.RetryConfig{Enabled: false}),
+		otlptracegrpc.WithPartialSuccessHandler(func(n int64, _ string) {
+			rejected = n
+		}))
+	defer func() {
+		assert.NoError(t, exp.Shutdown(ctx))
+	}()
+
+	collector.SetResponseError(codes.PermissionDenied)
+	assert.Equal(t, codes.PermissionDenied, status.Code(exp.ExportSpans(ctx, roSpans)))
+	assert.Empty(t, collector.ResourceSpans())
+
+	collector.SetResponseError(codes.OK)
+	collector.SetPartialSuccess(1, "span rejected")
+	require.NoError(t, exp.ExportSpans(ctx, roSpans))
+	assert.Len(t, collector.ResourceSpans(), 1)
+	assert.Equal(t, int64(1), rejected)
+}
+
+func TestStartGRPCCollector(t *testing.T) {
+	cert, pool, err := generateCertificate()
+	require.NoError(t, err)
+	collector, addr := otlptracetest.StartGRPCCollector(&tls.Config{Certificates: []tls.Certificate{cert}})
+	defer collector.Stop()
+
+	ctx := context.Background()
+	exp, err := otlptrace.New(ctx, otlptracegrpc.NewClient(
+		otlptracegrpc.WithEndpoint(addr),
+		otlptracegrpc.WithTLSCredentials(credentials.NewTLS(&tls.Config{RootCAs: pool})),
+		otlptracegrpc.WithCompressor(gzip.Name),
+		otlptracegrpc.WithHeaders(map[string]string{"tenant": "a"}),
+	))
+	require.NoError(t, err)
+	require.NoError(t, exp.ExportSpans(ctx, roSpans))
+	require.NoError(t, exp.Shutdown(ctx))
+
+	require.Len(t, collector.ResourceSpans(), 1)
+	assert.Equal(t, "Span 0", collector.ResourceSpans()[0].InstrumentationLibrarySpans[0].Spans[0].Name)
+	md := collector.Metadata()
+	require.Len(t, md, 1)
+	assert.Equal(t, []string{"a"}, md[0].Get("tenant"))
+
+	// An insecure client cannot export to the collector.
+	exp, err = otlptrace.New(ctx, otlptracegrpc.NewClient(
+		otlptracegrpc.WithEndpoint(addr),
+		otlptracegrpc.WithInsecure(),
+		otlptracegrpc.WithRetry(otlptracegrpc.RetryConfig{Enabled: false}),
+	))
+	require.NoError(t, err)
+	assert.Error(t, exp.ExportSpans(ctx, roSpans))
+	assert.NoError(t, exp.Shutdown(ctx))
+	assert.Len(t, collector.Requests(), 1)
+}
+
+func TestNew_withInsecureSkipVerify(t *testing.T) {
+	cert, _, err := generateCertificate()
+	require.NoError(t, err)
+	collector, addr := otlptracetest.StartGRPCCollector(&tls.Config{Certificates: []tls.Certificate{cert}})
+	defer collector.Stop()
+
+	var errs []error
+	ctx := context.Background()
+	exp, err := otlptrace.New(ctx, otlptracegrpc.NewClient(
+		otlptracegrpc.WithEndpoint(addr),
+		otlptracegrpc.WithInsecureSkipVerify(),
+		otlptracegrpc.WithErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+			errs = append(errs, err)
+		})),
+	))
+	require.NoError(t, err)
+	// The collector only accepts TLS connections, its self-signed
+	// certificate is not verified.
+	require.NoError(t, exp.ExportSpans(ctx, roSpans))
+	require.NoError(t, exp.Shutdown(ctx))
+	assert.Len(t, collector.ResourceSpans(), 1)
+	require.Len(t, errs, 1)
+	assert.Contains(t, errs[0].Error(), "is not verified")
+
+	// The certificate is verified otherwise.
+	exp, err = otlptrace.New(ctx, otlptracegrpc.NewClient(
+		otlptracegrpc.WithEndpoint(addr),
+		otlptracegrpc.WithTLSServerName("localhost"),
+		otlptracegrpc.WithRetry(otlptracegrpc.RetryConfig{Enabled: false}),
+	))
+	require.NoError(t, err)
+	assert.Error(t, exp.ExportSpans(ctx, roSpans))
+	assert.NoError(t, exp.Shutdown(ctx))
+	assert.Len(t, collector.ResourceSpans(), 1)
+}
+
+func TestNew_withUnixSocketEndpoint(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "otel.sock")
+	ln, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	collector := otlptracetest.NewCollector()
+	srv := grpc.NewServer()
+	collectortracepb.RegisterTraceServiceServer(srv, collector)
+	go func() { _ = srv.Serve(ln) }()
+	defer srv.Stop()
+
+	ctx := context.Background()
+	// No WithInsecure: it is implied by the unix scheme.
+	exp, err := otlptrace.New(ctx, otlptracegrpc.NewClient(
+		otlptracegrpc.WithEndpoint("unix://"+socket),
+	))
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, exp.Shutdown(ctx))
+	}()
+
+	require.NoError(t, exp.ExportSpans(ctx, roSpans))
+	assert.Len(t, collector.ResourceSpans(), 1)
 }
 
 func TestEmptyData(t *testing.T) {
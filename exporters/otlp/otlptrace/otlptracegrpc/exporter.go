@@ -18,8 +18,15 @@ import (
 	"context"
 
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/clientfactory"
 )
 
+func init() {
+	clientfactory.Register(otlptrace.ProtocolGRPC, func() interface{} {
+		return NewClient()
+	})
+}
+
 // New constructs a new Exporter and starts it.
 func New(ctx context.Context, opts ...Option) (*otlptrace.Exporter, error) {
 	return otlptrace.New(ctx, NewClient(opts...))
@@ -25,9 +25,12 @@ import (
 	"time"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/metadata"
 
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/otlptracetest"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/partialsuccess"
 	collectortracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
 	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
 )
@@ -36,8 +39,10 @@ func makeMockCollector(t *testing.T, mockConfig *mockConfig) *mockCollector {
 	return &mockCollector{
 		t: t,
 		traceSvc: &mockTraceService{
-			storage: otlptracetest.NewSpansStorage(),
-			errors:  mockConfig.errors,
+			storage:           otlptracetest.NewSpansStorage(),
+			errors:            mockConfig.errors,
+			rejectedSpans:     mockConfig.rejectedSpans,
+			partialSuccessMsg: mockConfig.partialSuccessMsg,
 		},
 	}
 }
@@ -51,6 +56,9 @@ type mockTraceService struct {
 	storage  otlptracetest.SpansStorage
 	headers  metadata.MD
 	delay    time.Duration
+
+	rejectedSpans     int64
+	partialSuccessMsg string
 }
 
 func (mts *mockTraceService) getHeaders() metadata.MD {
@@ -90,6 +98,7 @@ func (mts *mockTraceService) Export(ctx context.Context, exp *collectortracepb.E
 
 	mts.headers, _ = metadata.FromIncomingContext(ctx)
 	mts.storage.AddSpans(exp)
+	partialsuccess.Set(reply, mts.rejectedSpans, mts.partialSuccessMsg)
 	return reply, nil
 }
 
@@ -107,6 +116,17 @@ type mockCollector struct {
 type mockConfig struct {
 	errors   []error
 	endpoint string
+
+	rejectedSpans     int64
+	partialSuccessMsg string
+
+	// creds, if set, secure the connections to the collector.
+	creds credentials.TransportCredentials
+	// serverOptions are added to the options of the collector server.
+	serverOptions []grpc.ServerOption
+	// health, if set, is registered as the health checking service of
+	// the collector.
+	health healthpb.HealthServer
 }
 
 var _ collectortracepb.TraceServiceServer = (*mockTraceService)(nil)
@@ -166,9 +186,17 @@ func runMockCollectorWithConfig(t *testing.T, mockConfig *mockConfig) *mockColle
 		t.Fatalf("Failed to get an endpoint: %v", err)
 	}
 
-	srv := grpc.NewServer()
+	var opts []grpc.ServerOption
+	if mockConfig.creds != nil {
+		opts = append(opts, grpc.Creds(mockConfig.creds))
+	}
+	opts = append(opts, mockConfig.serverOptions...)
+	srv := grpc.NewServer(opts...)
 	mc := makeMockCollector(t, mockConfig)
 	collectortracepb.RegisterTraceServiceServer(srv, mc.traceSvc)
+	if mockConfig.health != nil {
+		healthpb.RegisterHealthServer(srv, mockConfig.health)
+	}
 	mc.ln = newListener(ln)
 	go func() {
 		_ = srv.Serve((net.Listener)(mc.ln))
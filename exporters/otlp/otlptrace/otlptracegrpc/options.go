@@ -15,15 +15,43 @@
 package otlptracegrpc // import "go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
 
 import (
-	"fmt"
+	"context"
+	"net/url"
 	"time"
 
+	"golang.org/x/net/proxy"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/metadata"
 
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/batchlimit"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/circuitbreaker"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/otlpconfig"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/retry"
+	"go.opentelemetry.io/otel/metric"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+// ConnectionState describes the state of the connection to the collector.
+type ConnectionState otlpconfig.ConnectionState
+
+const (
+	// ConnectionStateIdle is the state of the connection before it is
+	// first established.
+	ConnectionStateIdle = ConnectionState(otlpconfig.ConnectionStateIdle)
+	// ConnectionStateConnected is the state of an established connection.
+	ConnectionStateConnected = ConnectionState(otlpconfig.ConnectionStateConnected)
+	// ConnectionStateDisconnected is the state of a connection that failed
+	// to be established or that was lost.
+	ConnectionStateDisconnected = ConnectionState(otlpconfig.ConnectionStateDisconnected)
+	// ConnectionStateFailed is the terminal state of a connection the client
+	// gave up re-establishing, see WithMaxReconnectAttempts and
+	// WithMaxReconnectElapsed.
+	ConnectionStateFailed = ConnectionState(otlpconfig.ConnectionStateFailed)
 )
 
 // Option applies an option to the gRPC driver.
@@ -46,42 +74,257 @@ func (w wrappedOption) applyGRPCOption(cfg *otlpconfig.Config) {
 // WithInsecure disables client transport security for the exporter's gRPC connection
 // just like grpc.WithInsecure() https://pkg.go.dev/google.golang.org/grpc#WithInsecure
 // does. Note, by default, client security is required unless WithInsecure is used.
+// WithInsecure takes precedence over the security inferred from the scheme of
+// the endpoint.
 func WithInsecure() Option {
 	return wrappedOption{otlpconfig.WithInsecure()}
 }
 
 // WithEndpoint allows one to set the endpoint that the exporter will
 // connect to the collector on. If unset, it will instead try to use
-// connect to DefaultCollectorHost:DefaultCollectorPort.
+// connect to DefaultCollectorHost:DefaultCollectorPort. If the endpoint has an
+// http:// or https:// scheme, the scheme is removed and client transport
+// security is disabled or required accordingly, unless WithInsecure is used. A
+// conflict between the scheme and WithInsecure is reported as an invalid
+// option, see NewClient. User info in the endpoint, e.g.
+// "user:password@collector:4317", is removed and sent as basic authorization
+// credentials. An endpoint whose host has no port, e.g. "collector", gets the
+// default OTLP/gRPC port 4317. A port that is not a number between 1 and 65535
+// is reported as an invalid option, see NewClient.
+//
+// An endpoint in the srv://name form, e.g. "srv://_otlp._grpc.example.com",
+// makes the exporter look the DNS SRV record name up to find the host and
+// port of the collectors, in the order of their priority and weight. The
+// record is looked up again each time the connection is re-established, so
+// that the collectors added or removed are picked up. If the record cannot be
+// resolved, the connection fails with an error naming it.
 func WithEndpoint(endpoint string) Option {
 	return wrappedOption{otlpconfig.WithEndpoint(endpoint)}
 }
 
+// WithEndpointURL sets the endpoint that the exporter will connect to the
+// collector on to the host and port of u. Client transport security is disabled
+// or required according to its http or https scheme, unless WithInsecure is
+// used. The user info of u, if any, is sent as basic authorization credentials.
+// Its URL path, query and fragment are ignored. A nil URL, a URL with another
+// scheme or without a host is reported as an invalid option, see NewClient, and
+// the option has no effect.
+func WithEndpointURL(u *url.URL) Option {
+	return wrappedOption{otlpconfig.WithEndpointURL(u)}
+}
+
+// WithEndpoints sets the endpoints of the collectors the exporter connects
+// to, in failover order. The exporter connects to the first endpoint, as if
+// set with WithEndpoint, and moves to the next one, wrapping around after the
+// last, each time the collector it is connected to cannot be reached, that is
+// each time an export fails with the Unavailable code. It does not move back
+// to a previous endpoint when it becomes reachable again. A
+// failover is observable as a disconnection followed by a connection with the
+// callback set with WithConnectionStateCallback. All the endpoints use the
+// same transport security, inferred from the scheme of the first endpoint
+// unless WithInsecure is used, and the same basic authorization credentials,
+// taken from the user info of the endpoints. Invalid user info, or credentials
+// other than the ones of a previous endpoint, are reported as invalid
+// options, see NewClient, and ignored.
+func WithEndpoints(endpoints []string) Option {
+	return wrappedOption{otlpconfig.WithEndpoints(endpoints)}
+}
+
+// WithEndpointFile makes the exporter read the endpoint of the collector from
+// the file at path, for instance written by a sidecar, instead of using the
+// endpoint set with WithEndpoint or WithEndpoints. The file holds a single
+// endpoint, in the form accepted by WithEndpoint but without a scheme,
+// surrounded by optional white space. It is read when the exporter starts and
+// then again every reconnection period, set with WithReconnectionPeriod, and
+// the exporter connects to the new endpoint when it changes: the exports in
+// progress are cancelled, and retried on the new connection if retries are
+// enabled. While the file is missing or does not hold a valid endpoint, the
+// last endpoint read, or the configured one, is kept and a warning is sent to
+// the error handler. The file is not read if the connection is set with
+// WithGRPCConn.
+func WithEndpointFile(path string) Option {
+	return wrappedOption{otlpconfig.WithEndpointFile(path)}
+}
+
+// OversizeBatchPolicy is the handling of the batches of spans exceeding the
+// limits set with WithMaxExportBatchBytes or WithMaxExportBatchSpans.
+type OversizeBatchPolicy batchlimit.Policy
+
+const (
+	// SplitOversizeBatch exports the batch in several requests within the
+	// limits, each sent once the previous one succeeded. Spans larger than
+	// the maximum size on their own are dropped and reported to the error
+	// handler set with WithErrorHandler.
+	SplitOversizeBatch = OversizeBatchPolicy(batchlimit.Split)
+	// RejectOversizeBatch fails the export of the batch with
+	// ErrOversizeBatch, without sending any of its spans.
+	RejectOversizeBatch = OversizeBatchPolicy(batchlimit.Reject)
+	// DropOversizeBatch exports the first spans of the batch within the
+	// limits in a single request. The other spans are dropped and reported
+	// to the error handler set with WithErrorHandler.
+	DropOversizeBatch = OversizeBatchPolicy(batchlimit.Drop)
+)
+
+// ErrOversizeBatch is returned by the exports of batches of spans exceeding
+// the export limits with the RejectOversizeBatch policy.
+var ErrOversizeBatch = batchlimit.ErrOversize
+
+// WithMaxExportBatchBytes sets the maximum size, in bytes, of the export
+// requests sent to the collector, for instance to stay below the maximum
+// message size the collector receives, 4 MiB by default. The size is the one of
+// the requests before compression. The batches of spans exceeding it are
+// handled according to the policy set with WithOversizeBatchPolicy, split into
+// several requests by default. If unset or zero, the size set with
+// WithGRPCMaxCallSendMsgSize is used, and the size of the requests is not
+// limited if it is not set either. The size set with grpc.MaxCallSendMsgSize
+// as a default call option of WithDialOption cannot be read from the dial
+// options: it is not used, the batches exceeding it fail with a
+// ResourceExhausted error. A negative size is invalid: it is reported as an
+// invalid option, see NewClient, and ignored.
+func WithMaxExportBatchBytes(n int) Option {
+	return wrappedOption{otlpconfig.WithMaxExportBatchBytes(n)}
+}
+
+// WithMaxExportBatchSpans sets the maximum number of spans of the export
+// requests sent to the collector. The batches of spans exceeding it are
+// handled according to the policy set with WithOversizeBatchPolicy, split
+// into several requests by default. If unset or zero, the number of spans of
+// the requests is not limited. A negative number is invalid: it is reported
+// as an invalid option, see NewClient, and ignored.
+func WithMaxExportBatchSpans(n int) Option {
+	return wrappedOption{otlpconfig.WithMaxExportBatchSpans(n)}
+}
+
+// WithOversizeBatchPolicy sets the handling of the batches of spans exceeding
+// the limits set with WithMaxExportBatchBytes or WithMaxExportBatchSpans. If
+// unset, SplitOversizeBatch is used. An unknown policy is invalid: it is
+// reported as an invalid option, see NewClient, and ignored.
+func WithOversizeBatchPolicy(policy OversizeBatchPolicy) Option {
+	return wrappedOption{otlpconfig.WithOversizeBatchPolicy(batchlimit.Policy(policy))}
+}
+
+// WithGRPCMaxCallSendMsgSize sets the maximum size, in bytes, of the export
+// requests the exporter sends. Larger requests fail with a ResourceExhausted
+// error without being sent. If unset or zero, the gRPC default is used. A
+// negative size is invalid: it is reported as an invalid option, see
+// NewClient, and ignored. Unless set with WithMaxExportBatchBytes, it is also
+// the maximum size of the export requests: the batches of spans exceeding it
+// are handled according to the policy set with WithOversizeBatchPolicy.
+func WithGRPCMaxCallSendMsgSize(n int) Option {
+	return wrappedOption{otlpconfig.WithMaxCallSendMsgSize(n)}
+}
+
+// WithGRPCMaxCallRecvMsgSize sets the maximum size, in bytes, of the export
+// responses the exporter accepts from the collector, such as the ones
+// reporting a partial success. Exports with a larger response fail with a
+// ResourceExhausted error. If unset or zero, the gRPC default of 4 MiB is
+// used. A negative size is invalid: it is reported as an invalid option, see
+// NewClient, and ignored.
+func WithGRPCMaxCallRecvMsgSize(n int) Option {
+	return wrappedOption{otlpconfig.WithMaxCallRecvMsgSize(n)}
+}
+
 // WithReconnectionPeriod allows one to set the delay between next connection attempt
-// after failing to connect with the collector.
+// after failing to connect with the collector. If unset or zero, the default will
+// be 10 seconds. A random jitter of up to 70% of the period is added to each delay.
+// A negative period is invalid: it is reported as an invalid option, see
+// NewClient, and ignored.
 func WithReconnectionPeriod(rp time.Duration) Option {
+	return wrappedOption{otlpconfig.WithReconnectionPeriod(rp)}
+}
+
+// WithMaxReconnectAttempts sets the number of attempts to re-establish a
+// lost connection to the collector before giving up, for instance to let an
+// orchestrator restart the process rather than retrying forever. The attempts
+// are counted until an export succeeds, as connections dialed without
+// blocking are only known to be established then. Once the attempts are
+// exhausted, the connection enters the terminal ConnectionStateFailed state:
+// the connection state callback is called and the exports fail with a
+// non-retryable error. If unset or zero, the connection is re-established
+// forever. A negative number is invalid: it is reported as an invalid option,
+// see NewClient, and ignored.
+func WithMaxReconnectAttempts(n int) Option {
+	return wrappedOption{otlpconfig.WithMaxReconnectAttempts(n)}
+}
+
+// WithMaxReconnectElapsed sets the time spent re-establishing a lost
+// connection to the collector before giving up, like
+// WithMaxReconnectAttempts does for the number of attempts. The time is
+// measured from the moment the connection is lost until an export succeeds.
+// If unset or zero, the connection is re-established forever. A negative
+// duration is invalid: it is reported as an invalid option, see NewClient,
+// and ignored.
+func WithMaxReconnectElapsed(d time.Duration) Option {
+	return wrappedOption{otlpconfig.WithMaxReconnectElapsed(d)}
+}
+
+// WithNoReconnect disables the background routine re-establishing the
+// connection to the collector after it is lost. Instead, the connection is
+// re-established synchronously by the next export. This avoids keeping a
+// goroutine running in short-lived processes like command line tools or
+// serverless functions.
+func WithNoReconnect() Option {
+	return wrappedOption{otlpconfig.NewGRPCOption(func(cfg *otlpconfig.Config) {
+		cfg.DisableReconnect = true
+	})}
+}
+
+// WithStartupProbe sets whether starting the client waits for its connection
+// to the collector to be ready. If enabled, Start returns an error if the
+// connection is not ready before its context is done, instead of the first
+// export failing, and the connection is not re-established in the background.
+// The wait is only bounded by the context passed to Start, which should carry
+// a deadline. If unset, Start returns once the connection is dialed, without
+// waiting for it to be ready.
+func WithStartupProbe(enabled bool) Option {
 	return wrappedOption{otlpconfig.NewGRPCOption(func(cfg *otlpconfig.Config) {
-		cfg.ReconnectionPeriod = rp
+		cfg.StartupProbe = enabled
 	})}
 }
 
-func compressorToCompression(compressor string) otlpconfig.Compression {
-	switch compressor {
-	case "gzip":
-		return otlpconfig.GzipCompression
-	}
+// WithBlockingStart makes starting the client wait for its connection to the
+// collector to be ready, as WithStartupProbe(true) does. Start returns an
+// error if the connection is not ready before its context is done, letting
+// short-lived jobs fail fast when the collector is unreachable.
+func WithBlockingStart() Option {
+	return wrappedOption{otlpconfig.WithBlockingStart()}
+}
+
+// WithStartTimeout bounds the time starting the client waits for its first
+// connection to the collector to be ready, even if the context passed to
+// Start has no deadline. If the connection is not ready in time, a warning is
+// sent to the error handler set with WithErrorHandler and Start returns
+// without error, but the connection is no longer re-established in the
+// background: each export dials the collector instead, as with
+// WithNoReconnect, which avoids retrying a misconfigured endpoint forever.
+// Combined with WithStartupProbe(true), Start returns an error instead. If
+// unset or zero, Start does not wait for the connection unless
+// WithStartupProbe(true) is used. A negative duration is invalid: it is
+// reported as an invalid option, see NewClient, and ignored.
+func WithStartTimeout(d time.Duration) Option {
+	return wrappedOption{otlpconfig.WithStartTimeout(d)}
+}
 
-	otel.Handle(fmt.Errorf("invalid compression type: '%s', using no compression as default", compressor))
-	return otlpconfig.NoCompression
+// WithResolveOnStart makes starting the client resolve the host of the
+// endpoint, with the context passed to Start, before dialing the collector.
+// Start returns an error wrapping the resolution failure if it cannot be
+// resolved, instead of deferring to the background reconnection while the
+// exported spans are dropped. Unix domain socket endpoints, IP addresses and
+// the connections set with WithGRPCConn are not resolved.
+func WithResolveOnStart() Option {
+	return wrappedOption{otlpconfig.WithResolveOnStart()}
 }
 
 // WithCompressor will set the compressor for the gRPC client to use when sending requests.
 // It is the responsibility of the caller to ensure that the compressor set has been registered
 // with google.golang.org/grpc/encoding. This can be done by encoding.RegisterCompressor. Some
 // compressors auto-register on import, such as gzip, which can be registered by calling
-// `import _ "google.golang.org/grpc/encoding/gzip"`.
+// `import _ "google.golang.org/grpc/encoding/gzip"`. If the compressor is not registered,
+// it is reported as an invalid option, see NewClient, and no compression is
+// used. The compressor is set on each export call, and can be overridden for
+// an export with ContextWithCompressor.
 func WithCompressor(compressor string) Option {
-	return wrappedOption{otlpconfig.WithCompression(compressorToCompression(compressor))}
+	return wrappedOption{otlpconfig.WithGRPCCompressor(compressor)}
 }
 
 // WithHeaders will send the provided headers with gRPC requests.
@@ -89,22 +332,89 @@ func WithHeaders(headers map[string]string) Option {
 	return wrappedOption{otlpconfig.WithHeaders(headers)}
 }
 
+// WithHeadersFunc sets a function called before each export to compute
+// headers sent with the gRPC request, for instance to provide a frequently
+// rotated authentication token. The returned headers are merged with the
+// ones set by WithHeaders, taking precedence over them. If the function
+// returns an error the export fails with that error.
+func WithHeadersFunc(fn func(context.Context) (map[string]string, error)) Option {
+	return wrappedOption{otlpconfig.WithHeadersFunc(fn)}
+}
+
 // WithTLSCredentials allows the connection to use TLS credentials
 // when talking to the server. It takes in grpc.TransportCredentials instead
 // of say a Certificate file or a tls.Certificate, because the retrieving of
 // these credentials can be done in many ways e.g. plain file, in code tls.Config
 // or by certificate rotation, so it is up to the caller to decide what to use.
+// The transport credentials, e.g. a client certificate for a collector
+// requiring mutual TLS, authenticate the connection and combine with the
+// per-RPC credentials set with WithBearerToken or WithBasicAuth and the
+// metadata set with WithHeaders, sent with each request over the secure
+// connection.
 func WithTLSCredentials(creds credentials.TransportCredentials) Option {
 	return wrappedOption{otlpconfig.NewGRPCOption(func(cfg *otlpconfig.Config) {
 		cfg.Traces.GRPCCredentials = creds
 	})}
 }
 
-// WithServiceConfig defines the default gRPC service config used.
+// WithServiceConfig defines the default gRPC service config used, in its
+// JSON representation. It can for instance enable client-side load balancing
+// across the replicas of a collector with
+// `{"loadBalancingPolicy":"round_robin"}`, along with an endpoint using the
+// dns:/// scheme, e.g. "dns:///collector:4317", so that all the addresses the
+// name resolves to are connected to. An empty or malformed service config is
+// invalid: it is reported as an invalid option, see NewClient, and the option
+// has no effect. It takes precedence over a service config read with
+// WithServiceConfigFile.
 func WithServiceConfig(serviceConfig string) Option {
-	return wrappedOption{otlpconfig.NewGRPCOption(func(cfg *otlpconfig.Config) {
-		cfg.ServiceConfig = serviceConfig
-	})}
+	return wrappedOption{otlpconfig.WithServiceConfig(serviceConfig)}
+}
+
+// WithServiceConfigFile defines the default gRPC service config used as the
+// JSON representation read from the file at path, so that the load balancing
+// or the retry policy of the connection can be changed without rebuilding the
+// application. The file is read when the client is created. A service config
+// set with WithServiceConfig takes precedence, whatever the order of the
+// options. If the file cannot be read or does not hold a JSON object, it is
+// reported as an invalid option, see NewClient, and the option has no effect.
+func WithServiceConfigFile(path string) Option {
+	return wrappedOption{otlpconfig.WithServiceConfigFile(path)}
+}
+
+// WithKeepalive enables the gRPC client keepalive pings with params, so
+// that idle connections to the collector are not dropped by the intermediary
+// load balancers and proxies closing the silent ones, and broken connections
+// are detected before the next export. Pinging idle connections every
+// minute, with a timeout of 20 seconds, is a sensible choice:
+//
+//	WithKeepalive(keepalive.ClientParameters{
+//		Time:                time.Minute,
+//		Timeout:             20 * time.Second,
+//		PermitWithoutStream: true,
+//	})
+//
+// The keepalive enforcement policy of the collector must allow these pings:
+// a client pinging more often than permitted, or without an export in flight
+// if that is not permitted, has its connection closed by the collector with
+// the ENHANCE_YOUR_CALM error code, and then doubles its ping interval. The
+// client never pings more often than every 10 seconds. Keepalive pings are
+// disabled if unset. A negative time or timeout is invalid: it is reported
+// as an invalid option, see NewClient, and the option has no effect.
+func WithKeepalive(params keepalive.ClientParameters) Option {
+	return wrappedOption{otlpconfig.WithKeepalive(params)}
+}
+
+// WithSOCKS5Proxy makes the client connect to the collector through the
+// SOCKS5 proxy at addr, for networks only allowing egress through such a
+// proxy. The client authenticates to the proxy with the username and password
+// of auth, if it is not nil. The host of the endpoint is resolved by the
+// proxy. The TLS credentials, if any, secure the connection with the
+// collector, the proxy only relaying it. The option has no effect on a Unix
+// domain socket endpoint or on a connection set with WithGRPCConn. An empty
+// address is invalid: it is reported as an invalid option, see NewClient, and
+// the client connects directly.
+func WithSOCKS5Proxy(addr string, auth *proxy.Auth) Option {
+	return wrappedOption{otlpconfig.WithSOCKS5Proxy(addr, auth)}
 }
 
 // WithDialOption opens support to any grpc.DialOption to be used. If it conflicts
@@ -116,25 +426,505 @@ func WithDialOption(opts ...grpc.DialOption) Option {
 	})}
 }
 
+// WithGRPCDialOption appends opts to the grpc.DialOptions used when the client
+// establishes its connection to the collector. Unlike WithDialOption, which
+// replaces any previously passed dial options, repeated uses of this option
+// accumulate. These dial options are applied after the ones the client
+// derives from its own configuration, so they can override defaults like the
+// transport credentials. They are ignored if WithGRPCConn is used.
+func WithGRPCDialOption(opts ...grpc.DialOption) Option {
+	return wrappedOption{otlpconfig.NewGRPCOption(func(cfg *otlpconfig.Config) {
+		cfg.DialOptions = append(cfg.DialOptions, opts...)
+	})}
+}
+
 // WithGRPCConn allows reusing existing gRPC connection when it has already been
 // established for other services. When set, other dial options will be ignored.
+// The connection is owned by the caller: it is not closed when the client is
+// stopped.
 func WithGRPCConn(conn *grpc.ClientConn) Option {
 	return wrappedOption{otlpconfig.NewGRPCOption(func(cfg *otlpconfig.Config) {
 		cfg.GRPCConn = conn
 	})}
 }
 
+// WithIdempotencyKeys attaches a random idempotency key, a UUID, to each
+// export request in the gRPC metadata named header, so that the collectors
+// supporting it can drop the duplicate batches sent when retrying after a
+// timeout. The key of a request is the same for all its retries. A batch
+// exported again later, after being queued, gets a new key. An empty header is
+// invalid: it is reported as an invalid option, see NewClient, and ignored.
+func WithIdempotencyKeys(header string) Option {
+	return wrappedOption{otlpconfig.WithIdempotencyKeys(header)}
+}
+
+// WithUserAgent sets the user agent identifying the exporter to the
+// collector, sent with each gRPC request followed by the version of gRPC. If
+// unset, "OTel-OTLP-Exporter-Go/" followed by the version of the exporter is
+// used. An empty user agent only sends the version of gRPC.
+func WithUserAgent(userAgent string) Option {
+	return wrappedOption{otlpconfig.WithUserAgent(userAgent)}
+}
+
+// WithBearerToken sets the token sent in the authorization metadata, using
+// the bearer scheme, with each gRPC request. It must not be combined with an
+// authorization header set with WithHeaders. The token is only sent over a
+// secure connection unless WithInsecure is used, in which case a warning is
+// sent to the error handler set with WithErrorHandler.
+func WithBearerToken(token string) Option {
+	return wrappedOption{otlpconfig.WithBearerToken(token)}
+}
+
+// WithBasicAuth sets the user and password sent in the authorization
+// metadata, using the basic scheme, with each gRPC request. It must not be
+// combined with an authorization header set with WithHeaders. The credentials
+// are only sent over a secure connection unless WithInsecure is used, in which
+// case a warning is sent to the error handler set with WithErrorHandler.
+func WithBasicAuth(user, password string) Option {
+	return wrappedOption{otlpconfig.WithBasicAuth(user, password)}
+}
+
 // WithTimeout tells the driver the max waiting time for the backend to process
-// each spans batch. If unset, the default will be 10 seconds.
+// each spans batch. If unset, the default will be 10 seconds. A shorter
+// deadline set on the context passed to the export is honored. The timeout
+// only applies to the trace exports; like OTEL_EXPORTER_OTLP_TRACES_TIMEOUT,
+// over which it takes precedence, and unlike OTEL_EXPORTER_OTLP_TIMEOUT, it
+// does not affect the exporters of other signals.
 func WithTimeout(duration time.Duration) Option {
 	return wrappedOption{otlpconfig.WithTimeout(duration)}
 }
 
+// WithDialTimeout sets the maximum time the client waits for each attempt to
+// connect to the collector, when it starts or reconnects, so a slow DNS
+// resolution or TCP handshake does not consume the export timeout. If unset,
+// the timeout set with WithTimeout is used. A negative timeout is invalid: it
+// is reported as an invalid option, see NewClient, and ignored.
+func WithDialTimeout(d time.Duration) Option {
+	return wrappedOption{otlpconfig.WithDialTimeout(d)}
+}
+
 // WithRetry configures the retry policy for transient errors that may occurs
 // when exporting traces. An exponential back-off algorithm is used to ensure
 // endpoints are not overwhelmed with retries. If unset, the default retry
 // policy will retry after 5 seconds and increase exponentially after each
-// error for a total of 1 minute.
+// error for a total of 1 minute. The deadline of the context passed to the
+// export also bounds the retries: the export fails as soon as the next retry
+// could not be attempted before it.
+//
+// The following gRPC status codes are considered transient: Canceled,
+// DeadlineExceeded, Aborted, OutOfRange, Unavailable and DataLoss.
+// ResourceExhausted is only retried if the server includes a RetryInfo
+// detail in the status. If a RetryInfo delay is present it is honored instead
+// of the back-off delay when it is the greater of the two.
 func WithRetry(settings RetryConfig) Option {
 	return wrappedOption{otlpconfig.WithRetry(retry.Config(settings))}
 }
+
+// WithBackoffStrategy sets the function deciding how long to wait before each
+// retry of a failed export, and when to give up retrying it, instead of the
+// exponential back-off configured by WithRetry. The function is called after
+// each retryable failure with the number of the retry about to be attempted,
+// starting at one, and the error of the last attempt. The export fails with
+// that error once the function returns giveUp. Retries must still be enabled
+// with WithRetry, whose intervals and maximum elapsed time are then unused.
+// A delay requested by the collector is honored when it is the greater, and
+// the deadline of the context passed to the export still bounds the retries.
+// The function is called concurrently by the exports. If unset or nil, the
+// exponential back-off is used.
+func WithBackoffStrategy(strategy func(attempt int, lastErr error) (delay time.Duration, giveUp bool)) Option {
+	return wrappedOption{otlpconfig.WithBackoffStrategy(strategy)}
+}
+
+// ErrCircuitOpen is returned by exports rejected without being attempted
+// because the circuit breaker set with WithCircuitBreaker is open.
+var ErrCircuitOpen = circuitbreaker.ErrOpen
+
+// WithRetryableErrorFunc sets the function deciding whether a failed export
+// is retried, as configured by WithRetry, instead of the default
+// classification. A delay requested by the collector is still honored.
+// The function receives the error returned by the gRPC export call, whose
+// status code it can inspect with the google.golang.org/grpc/status package,
+// for instance to retry the Internal code that some collectors return for
+// transient conditions.
+// Errors caused by the cancellation or the deadline of the export context are
+// never retried, whatever the function returns.
+func WithRetryableErrorFunc(fn func(error) bool) Option {
+	return wrappedOption{otlpconfig.WithRetryableErrorFunc(fn)}
+}
+
+// WithCircuitBreaker stops attempting exports after failureThreshold
+// consecutive exports failed with a retryable error, such as an unreachable or
+// unavailable collector, once retries are exhausted. The exports rejected by
+// the collector and the ones whose context is done are not counted. Exports
+// then fail fast with ErrCircuitOpen for the cooldown duration, after which a
+// single export probes the collector: the exports resume if it succeeds,
+// otherwise they keep failing fast for another cooldown. If unset, all
+// exports are attempted. A threshold lower than one or a non-positive cooldown
+// is invalid: it is reported as an invalid option, see NewClient, and the
+// option has no effect.
+func WithCircuitBreaker(failureThreshold int, cooldown time.Duration) Option {
+	return wrappedOption{otlpconfig.WithCircuitBreaker(failureThreshold, cooldown)}
+}
+
+// WithExportInterceptor adds interceptor around each export request sent to
+// the collector, for instance to log, measure or trace exports. Interceptors
+// are called in the order they are added, the first one being the
+// outermost. The last one calls an invoker sending the request, retrying it
+// as configured by WithRetry. Each part of a batch split by the export batch
+// limits is sent in its own request.
+// Outgoing gRPC metadata added to the context passed to invoker is sent
+// with the request, taking precedence over the configured headers.
+func WithExportInterceptor(interceptor otlptrace.ExportInterceptor) Option {
+	return wrappedOption{otlpconfig.WithExportInterceptor(interceptor)}
+}
+
+// WithPersistentQueue sets the client to write the export requests failing
+// with a retryable error, or rejected by the circuit breaker, to files in dir
+// instead of dropping them, once the retries configured by WithRetry are
+// exhausted. Such exports do not return an error. The persisted requests are
+// sent again in the background, oldest first, as soon as an export succeeds
+// and with an exponential backoff after each retryable failure. The ones
+// persisted before the process restarted are sent too. They are kept until
+// accepted or rejected by the collector, including when the exporter is shut
+// down.
+//
+// The files in dir total at most maxBytes, the oldest requests are dropped to
+// make room for new ones and the drops are reported to the error handler set
+// with WithErrorHandler. dir is locked until the exporter is shut down: a
+// client whose dir is used by another client, of this process or another one,
+// fails to open the queue and does not hold the failed exports. An empty dir
+// or a non-positive maxBytes is invalid: it is reported as an invalid option,
+// see NewClient, and the option has no effect. It replaces the queue set by
+// WithMemoryQueue.
+func WithPersistentQueue(dir string, maxBytes int64) Option {
+	return wrappedOption{otlpconfig.WithPersistentQueue(dir, maxBytes)}
+}
+
+// WithMemoryQueue sets the client to hold the export requests failing with a
+// retryable error, or rejected by the circuit breaker, in memory instead of
+// dropping them, once the retries configured by WithRetry are exhausted. Such
+// exports do not return an error, non-retryable errors are still returned. The
+// held requests are sent again in the background, oldest first, with an
+// exponential backoff after each retryable failure and as soon as an export
+// succeeds. Shutting down the exporter sends the held requests once, oldest
+// first: the ones left when a send fails with a retryable error, or when its
+// context is done, are dropped and reported by the error returned. The empty
+// export of ForceFlush is never held, its failure is returned.
+//
+// The held requests total at most maxSpans spans, the oldest requests are
+// dropped to make room for new ones and the drops are reported to the error
+// handler set with WithErrorHandler. A non-positive maxSpans is invalid: it is
+// reported as an invalid option, see NewClient, and the option has no effect.
+// It replaces the queue set by WithPersistentQueue.
+func WithMemoryQueue(maxSpans int) Option {
+	return wrappedOption{otlpconfig.WithMemoryQueue(maxSpans)}
+}
+
+// WithResourceAttributes adds attrs to the resource of each exported span,
+// for instance to identify the deployment exporting them. An attribute of
+// attrs whose key the resource already has replaces the existing attribute if
+// override is true, and is ignored otherwise. The spans passed to the client
+// are not modified, the resources are copied.
+func WithResourceAttributes(attrs []*commonpb.KeyValue, override bool) Option {
+	return wrappedOption{otlpconfig.WithResourceAttributes(attrs, override)}
+}
+
+// WithSpanTransform adds transform, called with each exported span right
+// before it is sent, for instance to redact sensitive attribute values
+// centrally. Unlike the span processors of the SDK, it operates on the OTLP
+// form of the spans at export time. It is passed a copy of the span, that it
+// may modify in place: the spans passed to the client are not modified. The
+// transforms are called in the order they are added, nil ones are ignored.
+func WithSpanTransform(transform func(*tracepb.Span)) Option {
+	return wrappedOption{otlpconfig.WithSpanTransform(transform)}
+}
+
+// WithStrictValidation drops the malformed spans before they are sent, so
+// that a collector strict about their fields does not reject the whole batch
+// containing them. A span is malformed if its trace or span ID does not have
+// the length defined by OTLP or is all zeros, if its parent span ID is
+// neither empty nor of the length of a span ID, or if its trace state is not
+// a valid W3C tracestate. The version of OTLP sent by the client has no span
+// flags to validate. The dropped spans are reported to the error handler set
+// with WithErrorHandler. The validation is done after the transforms added
+// with WithSpanTransform.
+func WithStrictValidation() Option {
+	return wrappedOption{otlpconfig.WithStrictValidation()}
+}
+
+// WithExportSampler sets sampler, called with each span right before it is
+// sent, to shed load as a last resort when the collector is overwhelmed. The
+// spans it returns false for are dropped, whatever the decision of the
+// sampler of the SDK, and the resources and instrumentation libraries left
+// without spans are omitted. An export whose spans are all dropped succeeds
+// without sending a request. The sampler is called after the transforms added
+// with WithSpanTransform and the validation enabled with
+// WithStrictValidation, concurrently by the exports. The dropped spans are
+// counted by the metrics set with WithSelfObservability and the summaries
+// enabled with WithStatsLogInterval. Nil sends all the spans.
+func WithExportSampler(sampler func(*tracepb.Span) bool) Option {
+	return wrappedOption{otlpconfig.WithExportSampler(sampler)}
+}
+
+// WithStableOrdering sorts the spans of each export in a stable order before
+// they are sent, whatever the order they are passed in, so that the requests
+// of the same spans are identical, for instance to compare them byte for byte
+// with golden files in tests. The resources are sorted by their protobuf
+// encoding, the instrumentation libraries of each resource by name and
+// version, and the spans of each library by start time, then by trace and
+// span IDs. The sorting is done after the sampler set with WithExportSampler
+// and before the batch limits split the requests. It has a cost: it is not
+// recommended in production.
+func WithStableOrdering() Option {
+	return wrappedOption{otlpconfig.WithStableOrdering()}
+}
+
+// WithDrainOnStop gives the in-flight exports at least timeout to complete
+// when the exporter is shut down, even if the context passed to Shutdown is
+// done before. By default, Shutdown waits for the in-flight exports until its
+// context is done and then cancels them. With a drain timeout, they are
+// cancelled once both the context is done and the timeout, counted from the
+// call to Shutdown, elapsed, before the connection to the collector is shut
+// down. Shutdown still returns the error of its context if it is done. If
+// unset, or zero, no drain timeout is used. A negative timeout is invalid: it
+// is reported as an invalid option, see NewClient, and ignored.
+func WithDrainOnStop(timeout time.Duration) Option {
+	return wrappedOption{otlpconfig.WithDrainOnStop(timeout)}
+}
+
+// WithErrorHistory keeps the last n errors returned by the exports, with the
+// time they were returned, to be inspected with the RecentErrors method of the
+// exporter, for instance to debug failing exports while the span processor
+// discards their errors. The exports whose spans are held by the queue set
+// with WithPersistentQueue or WithMemoryQueue do not fail. If unset, or zero,
+// no error is kept. A negative n is invalid: it is reported as an invalid
+// option, see NewClient, and ignored.
+func WithErrorHistory(n int) Option {
+	return wrappedOption{otlpconfig.WithErrorHistory(n)}
+}
+
+// WithExportCoalescing makes the client merge the exports arriving within
+// maxDelay into a single upload, reducing the overhead of the small batches
+// exported at high rates. The spans of an export are held until maxDelay
+// elapsed since the first spans held were exported, or until the spans held
+// reach maxSpans, and each export returns the result of the upload of its
+// spans. The upload is bounded by the earliest deadline of the contexts of the
+// exports it merges: an export whose context is done before returns the
+// context error, its spans are still uploaded. The exports whose context
+// carries gRPC metadata, set with ContextWithExportMetadata or as outgoing
+// metadata, or a compressor set with ContextWithCompressor are not merged:
+// they are uploaded on their own. The spans held are uploaded when the
+// exporter is flushed or shut down. If unset, or if maxDelay is zero, the
+// exports are not merged. A zero maxSpans does not limit the spans held. A
+// negative maxDelay or maxSpans is invalid: an error is sent to the error
+// handler and the option is ignored.
+func WithExportCoalescing(maxDelay time.Duration, maxSpans int) Option {
+	return wrappedOption{otlpconfig.WithExportCoalescing(maxDelay, maxSpans)}
+}
+
+// WithMaxConcurrentExports limits the number of exports uploaded
+// concurrently to n, so that the bursts of exports of the span processors do
+// not overwhelm the collector. Once n exports are in progress, the others
+// block until one of them completes, or return the error of their context if
+// it is done first. The exports merged with WithExportCoalescing are uploaded
+// as one, and the retries of the queue set with WithPersistentQueue or
+// WithMemoryQueue are not limited. If unset, or zero, the exports are not
+// limited. A negative n is invalid: an error is sent to the error handler and
+// it is ignored.
+func WithMaxConcurrentExports(n int) Option {
+	return wrappedOption{otlpconfig.WithMaxConcurrentExports(n)}
+}
+
+// WithStatsLogInterval makes the client send a summary of its exports to
+// the error handler every d, for visibility into the exporter without
+// setting up a meter provider with WithSelfObservability. The summary is an
+// otlptrace.StatsSummary reporting the spans sent and dropped and the export
+// errors since the previous one, and the connection status. The summaries
+// are sent from the start of the exporter until it is shut down. If unset,
+// or zero, no summary is sent. A negative d is invalid: an error is sent to
+// the error handler and it is ignored.
+func WithStatsLogInterval(d time.Duration) Option {
+	return wrappedOption{otlpconfig.WithStatsLogInterval(d)}
+}
+
+// WithHealthCheckService sets the name of the service whose health is checked
+// by the Healthy method of the exporter with the gRPC health checking
+// protocol, for instance the name of the OTLP trace service,
+// "opentelemetry.proto.collector.trace.v1.TraceService", if the collector
+// reports its health. If unset, the health of the whole collector is checked
+// with the empty service name.
+func WithHealthCheckService(serviceName string) Option {
+	return wrappedOption{otlpconfig.WithHealthCheckService(serviceName)}
+}
+
+// WithErrorHandler sets the handler of the errors of the client that are not
+// returned by its methods, such as the invalid options, the dropped spans and
+// batches or the partial successes not handled otherwise, instead of the
+// global error handler, for instance to route the diagnostics of one
+// exporter separately from the others. Reconnection failures, including giving up
+// reconnecting, and the invalid values read from the environment variables
+// are reported to it too. If unset, or nil, the global error handler is used.
+func WithErrorHandler(handler otel.ErrorHandler) Option {
+	return wrappedOption{otlpconfig.WithErrorHandler(handler)}
+}
+
+// WithResponseMetadataHandler sets the function called with the header and
+// trailer metadata returned by the collector, joined, for instance to adapt
+// the batch sizes to backpressure hints sent by the collector. It is called
+// after each attempt of an export, including the failed ones and the retries,
+// for which the collector returned metadata. The metadata is only captured if
+// a handler is set. The handler must not modify the metadata.
+func WithResponseMetadataHandler(handler func(md metadata.MD)) Option {
+	return wrappedOption{otlpconfig.WithResponseMetadataHandler(handler)}
+}
+
+// WithPartialSuccessHandler sets the function called when the collector
+// accepts an export but rejects some of the spans it contains. The handler
+// receives the number of rejected spans and the error message returned by the
+// collector. If unset, partial successes are reported to the global error
+// handler. A partial success that rejects no spans and has no error message
+// is not reported.
+func WithPartialSuccessHandler(handler func(rejected int64, msg string)) Option {
+	return wrappedOption{otlpconfig.WithPartialSuccessHandler(handler)}
+}
+
+// WithPayloadSizeObserver sets the function called with the number of spans
+// and the serialized size of each export request, for instance to tune the
+// batch sizes and the compression. It is called once per request, before it
+// is sent, and not for its retries. The size is the protobuf encoding of the
+// request before compression: the compression configured with
+// WithCompressor is done by gRPC while sending the request, so
+// PayloadSize.Compressed is always false.
+func WithPayloadSizeObserver(observer func(otlptrace.PayloadSize)) Option {
+	return wrappedOption{otlpconfig.WithPayloadSizeObserver(observer)}
+}
+
+// WithSelfObservability sets the MeterProvider used to record metrics about
+// the exports performed by the client: the number of spans exported, the
+// number of spans dropped because their export failed or by the sampler set
+// with WithExportSampler, the number of export requests sent, retries
+// included, and the duration of each export. The retries of failed requests,
+// the requests abandoned because the maximum retry time elapsed and the time
+// spent waiting before the retries are also recorded, by terminal outcome of
+// the request. No metrics are recorded if unset.
+func WithSelfObservability(mp metric.MeterProvider) Option {
+	return wrappedOption{otlpconfig.WithSelfObservability(mp)}
+}
+
+// WithConnectionStateCallback sets a function that is called every time the
+// state of the connection to the collector changes: when the connection is
+// first established, when it is lost and each time it is re-established.
+// The callback is called synchronously, without holding any internal lock,
+// so it should return quickly.
+func WithConnectionStateCallback(callback func(old, new ConnectionState)) Option {
+	return wrappedOption{otlpconfig.NewGRPCOption(func(cfg *otlpconfig.Config) {
+		if callback == nil {
+			cfg.ConnectionStateCallback = nil
+			return
+		}
+		cfg.ConnectionStateCallback = func(old, new otlpconfig.ConnectionState) {
+			callback(ConnectionState(old), ConnectionState(new))
+		}
+	})}
+}
+
+// WithTLSClientCertificate sets the PEM encoded certificate and private key
+// presented to the collector for mutual TLS authentication. The transport
+// credentials are built from a TLS configuration holding the certificate and
+// the ones from the OTEL_EXPORTER_OTLP_CERTIFICATE environment variable, if
+// set. They replace any credentials passed before with WithTLSCredentials. If
+// the certificate cannot be parsed, it is reported as an invalid option, see
+// NewClient, and the option has no effect.
+func WithTLSClientCertificate(certPEM, keyPEM []byte) Option {
+	return wrappedOption{otlpconfig.WithTLSClientCertificate(certPEM, keyPEM)}
+}
+
+// WithTLSMinVersion sets the minimum TLS version, such as tls.VersionTLS13,
+// accepted when connecting to the collector, for instance to comply with a
+// security policy. If unset, TLS 1.2 is the minimum version of the TLS
+// configurations built by the TLS options, while the one passed with
+// WithTLSClientConfig is used as is.
+//
+// The transport credentials are built from a TLS configuration holding the
+// minimum version and the other TLS options. They replace any credentials
+// passed before with WithTLSCredentials. A value that is not a TLS version is
+// invalid: an error is sent to the error handler and the option has no effect.
+func WithTLSMinVersion(version uint16) Option {
+	return wrappedOption{otlpconfig.WithTLSMinVersion(version)}
+}
+
+// WithTLSCipherSuites sets the IDs of the cipher suites, such as
+// tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256, offered when connecting to the
+// collector with TLS 1.2 or earlier. The cipher suites of TLS 1.3 are not
+// configurable. If unset, or empty, the default cipher suites of crypto/tls
+// are offered.
+//
+// The transport credentials are built from a TLS configuration holding the
+// cipher suites and the other TLS options. They replace any credentials passed
+// before with WithTLSCredentials. An unknown cipher suite is invalid: an error
+// is sent to the error handler and the option has no effect.
+func WithTLSCipherSuites(suites []uint16) Option {
+	return wrappedOption{otlpconfig.WithTLSCipherSuites(suites)}
+}
+
+// WithInsecureSkipVerify disables the verification of the certificate of the
+// collector, for instance to develop against a collector with a self-signed
+// certificate. Unlike WithInsecure, the connections still use TLS, but
+// anyone able to intercept them can impersonate the collector: it must not
+// be used in production. A warning is sent to the error handler each time
+// the client starts, as it is for a TLS configuration skipping the
+// verification passed with WithTLSClientConfig.
+//
+// The transport credentials are built from a TLS configuration skipping the
+// verification and holding the other TLS options. They replace any
+// credentials passed before with WithTLSCredentials.
+func WithInsecureSkipVerify() Option {
+	return wrappedOption{otlpconfig.WithInsecureSkipVerify()}
+}
+
+// WithTLSServerName sets the name the certificate of the collector is verified
+// against, and sent to it with SNI, instead of the host of the endpoint, for
+// instance when the endpoint is an IP address or a proxy while the certificate
+// is issued for the DNS name of the collector. If unset, or empty, the host of
+// the endpoint is used. This can also be set with the
+// OTEL_EXPORTER_OTLP_TLS_SERVER_NAME and
+// OTEL_EXPORTER_OTLP_TRACES_TLS_SERVER_NAME environment variables.
+//
+// The transport credentials are built from a TLS configuration holding the
+// server name and the other TLS options. They replace any credentials passed
+// before with WithTLSCredentials.
+func WithTLSServerName(name string) Option {
+	return wrappedOption{otlpconfig.WithTLSServerName(name)}
+}
+
+// WithTLSRootCAs sets the CA certificates verifying the certificate of the
+// collector: the PEM encoded caPEMs, added to a copy of the system cert pool
+// if appendToSystem is true, for instance to reach both collectors signed by
+// an internal CA and public ones. Otherwise only caPEMs are trusted.
+//
+// Appending to the system pool makes the exporter trust every public CA of
+// the host in addition to the internal ones: any of them can then issue a
+// certificate the exporter accepts for the collector host. Prefer a fresh
+// pool when all the collectors are signed by internal CAs.
+//
+// The transport credentials are built from a TLS configuration holding the
+// CAs and the other TLS options. They replace any credentials passed before
+// with WithTLSCredentials. If the system pool cannot be loaded, a certificate
+// cannot be parsed or no certificate is passed without appendToSystem, it is
+// reported as an invalid option, see NewClient, and the option has no effect.
+func WithTLSRootCAs(appendToSystem bool, caPEMs ...[]byte) Option {
+	return wrappedOption{otlpconfig.WithTLSRootCAs(appendToSystem, caPEMs...)}
+}
+
+// WithTLSConfigFromFiles sets the transport credentials built from the PEM
+// encoded files at the given paths: the CA certificates verifying the
+// collector, caPath, and the client certificate and key presented to it for
+// mutual TLS authentication, certPath and keyPath. Empty paths are skipped:
+// the system CA certificates are used if caPath is empty, and no client
+// certificate is presented if certPath and keyPath are empty. The credentials
+// replace any passed before with WithTLSCredentials. If a file cannot be read
+// or parsed, it is reported as an invalid option, see NewClient, and the
+// option has no effect.
+func WithTLSConfigFromFiles(caPath, certPath, keyPath string) Option {
+	return wrappedOption{otlpconfig.WithTLSConfigFromFiles(caPath, certPath, keyPath)}
+}
@@ -0,0 +1,217 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otlptracegrpc_test
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// socks5Proxy is a minimal SOCKS5 proxy, supporting the CONNECT command and
+// the username/password authentication.
+type socks5Proxy struct {
+	ln       net.Listener
+	user     string
+	password string
+
+	mu sync.Mutex
+	// targets are the addresses the clients asked to connect to.
+	targets []string
+	wg      sync.WaitGroup
+}
+
+// runSOCKS5Proxy starts a SOCKS5 proxy requiring the user and password, or
+// no authentication if user is empty.
+func runSOCKS5Proxy(t *testing.T, user, password string) *socks5Proxy {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	p := &socks5Proxy{ln: ln, user: user, password: password}
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			p.wg.Add(1)
+			go func() {
+				defer p.wg.Done()
+				p.serve(conn)
+			}()
+		}
+	}()
+	return p
+}
+
+func (p *socks5Proxy) addr() string {
+	return p.ln.Addr().String()
+}
+
+func (p *socks5Proxy) getTargets() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.targets...)
+}
+
+// stop closes the listener and waits for the relayed connections, which must
+// be closed by then.
+func (p *socks5Proxy) stop() {
+	_ = p.ln.Close()
+	p.wg.Wait()
+}
+
+func (p *socks5Proxy) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	target, err := p.handshake(r, conn)
+	if err != nil {
+		return
+	}
+	upstream, err := net.Dial("tcp", target)
+	if err != nil {
+		// General failure.
+		_, _ = conn.Write([]byte{5, 1, 0, 1, 0, 0, 0, 0, 0, 0})
+		return
+	}
+	defer upstream.Close()
+	if _, err := conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0}); err != nil {
+		return
+	}
+	p.mu.Lock()
+	p.targets = append(p.targets, target)
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		_, _ = io.Copy(upstream, r)
+		_ = upstream.(*net.TCPConn).CloseWrite()
+		close(done)
+	}()
+	_, _ = io.Copy(conn, upstream)
+	_ = conn.Close()
+	<-done
+}
+
+// handshake negotiates the authentication and reads the CONNECT request,
+// returning the address to connect to.
+func (p *socks5Proxy) handshake(r *bufio.Reader, w io.Writer) (string, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return "", err
+	}
+	methods := make([]byte, header[1])
+	if _, err := io.ReadFull(r, methods); err != nil {
+		return "", err
+	}
+	method := byte(0)
+	if p.user != "" {
+		method = 2
+	}
+	if !containsByte(methods, method) {
+		_, _ = w.Write([]byte{5, 0xff})
+		return "", errors.New("no acceptable authentication method")
+	}
+	if _, err := w.Write([]byte{5, method}); err != nil {
+		return "", err
+	}
+	if method == 2 {
+		user, password, err := readCredentials(r)
+		if err != nil {
+			return "", err
+		}
+		if user != p.user || password != p.password {
+			_, _ = w.Write([]byte{1, 1})
+			return "", errors.New("invalid credentials")
+		}
+		if _, err := w.Write([]byte{1, 0}); err != nil {
+			return "", err
+		}
+	}
+
+	request := make([]byte, 4)
+	if _, err := io.ReadFull(r, request); err != nil {
+		return "", err
+	}
+	if request[1] != 1 {
+		return "", errors.New("unsupported command")
+	}
+	var host string
+	switch request[3] {
+	case 1, 4:
+		ip := make(net.IP, 4)
+		if request[3] == 4 {
+			ip = make(net.IP, 16)
+		}
+		if _, err := io.ReadFull(r, ip); err != nil {
+			return "", err
+		}
+		host = ip.String()
+	case 3:
+		n, err := r.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		name := make([]byte, n)
+		if _, err := io.ReadFull(r, name); err != nil {
+			return "", err
+		}
+		host = string(name)
+	default:
+		return "", errors.New("unsupported address type")
+	}
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(r, port); err != nil {
+		return "", err
+	}
+	return net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port)))), nil
+}
+
+// readCredentials reads the username/password authentication request.
+func readCredentials(r *bufio.Reader) (string, string, error) {
+	var fields [2]string
+	if _, err := r.ReadByte(); err != nil {
+		return "", "", err
+	}
+	for i := range fields {
+		n, err := r.ReadByte()
+		if err != nil {
+			return "", "", err
+		}
+		b := make([]byte, n)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return "", "", err
+		}
+		fields[i] = string(b)
+	}
+	return fields[0], fields[1], nil
+}
+
+func containsByte(b []byte, c byte) bool {
+	for _, x := range b {
+		if x == c {
+			return true
+		}
+	}
+	return false
+}
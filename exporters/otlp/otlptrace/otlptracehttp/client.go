@@ -18,27 +18,50 @@ import (
 	"bytes"
 	"compress/gzip"
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"mime"
 	"net"
 	"net/http"
+	"net/url"
 	"path"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/klauspost/compress/zstd"
+	"golang.org/x/net/http2"
+	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/proto"
 
+	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/batchlimit"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/circuitbreaker"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/coalesce"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/errorhistory"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/exporterror"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/exportqueue"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/otlpconfig"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/partialsuccess"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/retry"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/selfobservability"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/statslog"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/tracetransform"
 	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
 	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
 )
 
-const contentTypeProto = "application/x-protobuf"
+const (
+	contentTypeProto = "application/x-protobuf"
+	contentTypeJSON  = "application/json"
+)
 
 var gzPool = sync.Pool{
 	New: func() interface{} {
@@ -47,6 +70,14 @@ var gzPool = sync.Pool{
 	},
 }
 
+var zstdPool = sync.Pool{
+	New: func() interface{} {
+		// NewWriter only fails for invalid options.
+		w, _ := zstd.NewWriter(ioutil.Discard)
+		return w
+	},
+}
+
 // Keep it in sync with golang's DefaultTransport from net/http! We
 // have our own copy to avoid handling a situation where the
 // DefaultTransport is overwritten with some different implementation
@@ -71,68 +102,276 @@ type client struct {
 	requestFunc retry.RequestFunc
 	client      *http.Client
 	stopCh      chan struct{}
+	metrics     *selfobservability.Instruments
+	breaker     *circuitbreaker.Breaker
+	batchLimit  batchlimit.Config
+	// queue persists the failed exports, if configured.
+	queue *exportqueue.Queue
+	// export sends each export request through the configured
+	// interceptors.
+	export otlptrace.ExportInvoker
+	// lastSuccess holds the time.Time of the last successful export.
+	lastSuccess atomic.Value
+	// lastResult holds the exportResult of the last attempted export.
+	lastResult atomic.Value
+	// recentErrors keeps the last errors returned by UploadTraces, if
+	// configured.
+	recentErrors *errorhistory.History
+	// errHandler handles the errors that are not returned.
+	errHandler otel.ErrorHandler
+	// coalescer merges the exports into single uploads, if configured.
+	coalescer *coalesce.Coalescer
+	// exportSlots, if not nil, holds a value for each export in
+	// progress, up to the maximum number of concurrent exports.
+	exportSlots chan struct{}
+	// stats reports a summary of the exports periodically, if
+	// configured.
+	stats *statslog.Reporter
+
+	// stopMu guards stopped, it is held when adding to inFlight so no
+	// request starts once Stop waits for the in-flight ones.
+	stopMu   sync.RWMutex
+	stopped  bool
+	inFlight sync.WaitGroup
 }
 
+var errStopped = errors.New("the client is stopped")
+
 var _ otlptrace.Client = (*client)(nil)
 
-// NewClient creates a new HTTP trace client.
+// NewClient creates a new HTTP trace client. The invalid options, which have
+// no effect, are reported to the error handler set with WithErrorHandler, or
+// to the global error handler.
 func NewClient(opts ...Option) otlptrace.Client {
 	cfg := otlpconfig.NewDefaultConfig()
 	otlpconfig.ApplyHTTPEnvConfigs(&cfg)
 	for _, opt := range opts {
 		opt.applyHTTPOption(&cfg)
 	}
+	errHandler := cfg.EffectiveErrorHandler()
+	for _, err := range cfg.Errors() {
+		errHandler.Handle(err)
+	}
+	for _, err := range cfg.NormalizeEndpoints(otlpconfig.DefaultHTTPCollectorPort) {
+		errHandler.Handle(err)
+	}
+	if err := cfg.Validate(); err != nil {
+		errHandler.Handle(err)
+	}
 
 	for pathPtr, defaultPath := range map[*string]string{
 		&cfg.Traces.URLPath: otlpconfig.DefaultTracesPath,
 	} {
 		tmp := strings.TrimSpace(*pathPtr)
+		if strings.ContainsAny(tmp, "?#") {
+			errHandler.Handle(fmt.Errorf("invalid URL path %q, using default %q: query and fragment are not allowed", tmp, defaultPath))
+			tmp = ""
+		}
 		if tmp == "" {
 			tmp = defaultPath
 		} else {
 			tmp = path.Clean(tmp)
 			if !path.IsAbs(tmp) {
-				tmp = fmt.Sprintf("/%s", tmp)
+				abs := fmt.Sprintf("/%s", tmp)
+				errHandler.Handle(fmt.Errorf("invalid URL path %q, using %q: the path must be absolute", tmp, abs))
+				tmp = abs
 			}
 		}
 		*pathPtr = tmp
 	}
 
+	transport := ourTransport.Clone()
+	// The connections are established within the dial timeout,
+	// independently of the export timeout.
+	transport.DialContext = (&net.Dialer{
+		Timeout:   cfg.EffectiveDialTimeout(),
+		KeepAlive: 30 * time.Second,
+	}).DialContext
+	transport.TLSHandshakeTimeout = cfg.EffectiveTLSHandshakeTimeout()
+	transport.ResponseHeaderTimeout = cfg.EffectiveResponseHeaderTimeout()
+	if cfg.Traces.TLSCfg != nil {
+		transport.TLSClientConfig = cfg.Traces.TLSCfg
+	}
+	if cfg.Traces.Proxy != nil {
+		transport.Proxy = cfg.Traces.Proxy
+	}
 	httpClient := &http.Client{
-		Transport: ourTransport,
+		Transport: transport,
 		Timeout:   cfg.Traces.Timeout,
 	}
-	if cfg.Traces.TLSCfg != nil {
-		transport := ourTransport.Clone()
-		transport.TLSClientConfig = cfg.Traces.TLSCfg
-		httpClient.Transport = transport
+	if cfg.Traces.H2C {
+		if cfg.Traces.Insecure {
+			httpClient.Transport = newH2CTransport(cfg.EffectiveDialTimeout())
+		} else {
+			errHandler.Handle(errors.New("h2c requires an insecure connection, ignoring it"))
+		}
+	}
+	if cfg.Traces.HTTPClient != nil {
+		httpClient = cfg.Traces.HTTPClient
 	}
 
+	protocol := otlptrace.ProtocolHTTPProtobuf
+	if cfg.Traces.Marshaler == otlpconfig.MarshalJSON {
+		protocol = otlptrace.ProtocolHTTPJSON
+	}
+	metrics := selfobservability.New(cfg.MeterProvider, protocol, errHandler)
 	stopCh := make(chan struct{})
-	return &client{
+	d := &client{
 		name:        "traces",
 		cfg:         cfg.Traces,
 		generalCfg:  cfg,
-		requestFunc: cfg.RetryConfig.RequestFunc(evaluate),
+		requestFunc: cfg.RetryConfig.StrategyRequestFunc(cfg.BackoffStrategy, retry.Classify(evaluate, cfg.RetryableErrorFunc), metrics.Retried),
 		stopCh:      stopCh,
 		client:      httpClient,
+		metrics:     metrics,
+		breaker:     circuitbreaker.New(cfg.CircuitBreaker),
+		batchLimit:  cfg.BatchLimit,
+		errHandler:  errHandler,
+	}
+	d.export = otlpconfig.ChainExportInterceptors(cfg.Traces.ExportInterceptors, d.exportRequest)
+	cfg.ExportQueue.ErrorHandler = errHandler
+	queue, err := exportqueue.New(cfg.ExportQueue)
+	if err != nil {
+		errHandler.Handle(fmt.Errorf("failed to open the export queue, failed exports are not retried: %w", err))
+	}
+	d.queue = queue
+	d.recentErrors = errorhistory.New(cfg.ErrorHistory)
+	d.coalescer = coalesce.New(cfg.Coalescing, d.uploadTraces, nil)
+	d.stats = statslog.New(cfg.StatsLogInterval, errHandler)
+	if cfg.MaxConcurrentExports > 0 {
+		d.exportSlots = make(chan struct{}, cfg.MaxConcurrentExports)
+	}
+	return d
+}
+
+// newH2CTransport returns a transport sending the requests with HTTP/2 over
+// cleartext connections, established within dialTimeout, without first
+// negotiating the protocol with the server.
+func newH2CTransport(dialTimeout time.Duration) *http2.Transport {
+	dialer := &net.Dialer{
+		Timeout:   dialTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+	return &http2.Transport{
+		AllowHTTP: true,
+		// The transport dials its connections with DialTLS, they are not
+		// secured.
+		DialTLS: func(network, addr string, _ *tls.Config) (net.Conn, error) {
+			return dialer.Dial(network, addr)
+		},
 	}
 }
 
-// Start does nothing in a HTTP client
+// Start waits for the collector to be reachable if the startup probe is
+// enabled, and starts retrying the persisted exports, if any, and reporting
+// the summaries of the exports, if configured. It reports a warning if the
+// certificate of the collector is not verified.
 func (d *client) Start(ctx context.Context) error {
-	// nothing to do
 	select {
 	case <-ctx.Done():
 		return ctx.Err()
 	default:
 	}
+	if err := d.cfg.TLSVerificationWarning(); err != nil {
+		d.errHandler.Handle(err)
+	}
+	if d.generalCfg.ResolveOnStart {
+		if err := otlpconfig.ResolveEndpoint(ctx, d.cfg.Endpoint); err != nil {
+			return err
+		}
+	}
+	if d.generalCfg.StartupProbe {
+		if err := d.probe(ctx); err != nil {
+			return err
+		}
+	}
+	d.queue.Start(func(ctx context.Context, req *coltracepb.ExportTraceServiceRequest) error {
+		return exportError(d.export(ctx, req))
+	})
+	d.stats.Start(d.connectionStatus)
 	return nil
 }
 
-// Stop shuts down the client and interrupt any in-flight request.
+// startupProbeInterval is the time waited between the connections attempted
+// by probe.
+const startupProbeInterval = 100 * time.Millisecond
+
+// probe opens TCP connections to the endpoint until one succeeds or ctx is
+// done.
+func (d *client) probe(ctx context.Context) error {
+	address := d.cfg.Endpoint
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		port := "443"
+		if d.cfg.Insecure {
+			port = "80"
+		}
+		address = net.JoinHostPort(address, port)
+	}
+
+	dialer := net.Dialer{Timeout: d.generalCfg.EffectiveDialTimeout()}
+	for {
+		conn, err := dialer.DialContext(ctx, "tcp", address)
+		if err == nil {
+			return conn.Close()
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("collector %s is not reachable: %v: %w", address, err, ctx.Err())
+		case <-time.After(startupProbeInterval):
+		}
+	}
+}
+
+// Stop stops reporting the summaries of the exports, uploads the spans held by
+// the coalescer, waits for the in-flight requests and the exports held in
+// memory to complete and shuts down the client. If ctx is done before the
+// requests complete, they are interrupted and an error wrapping the context
+// error is returned.
 func (d *client) Stop(ctx context.Context) error {
+	d.stats.Stop()
+	var cErr error
+	if d.coalescer != nil {
+		// The spans held are uploaded before the exports are refused.
+		cErr = d.coalescer.Stop(ctx)
+	}
+
+	d.stopMu.Lock()
+	d.stopped = true
+	d.stopMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.inFlight.Wait()
+		close(done)
+	}()
+	var drained <-chan time.Time
+	if d.generalCfg.DrainTimeout > 0 {
+		timer := time.NewTimer(d.generalCfg.DrainTimeout)
+		defer timer.Stop()
+		drained = timer.C
+	}
+	select {
+	case <-done:
+	case <-ctx.Done():
+		if drained != nil {
+			// The in-flight exports are only cancelled, by closing
+			// stopCh, once the drain timeout elapsed.
+			select {
+			case <-done:
+			case <-drained:
+			}
+		}
+	}
+
+	// The queued exports are drained before the requests are interrupted.
+	err := d.queue.Stop(ctx)
 	close(d.stopCh)
+	if cErr != nil {
+		return cErr
+	}
+	if err != nil {
+		return err
+	}
 	select {
 	case <-ctx.Done():
 		return ctx.Err()
@@ -141,12 +380,208 @@ func (d *client) Stop(ctx context.Context) error {
 	return nil
 }
 
-// UploadTraces sends a batch of spans to the collector.
+// UploadTraces sends a batch of spans to the collector, merged with the
+// batches of the other exports if coalescing is configured. An empty batch is
+// not sent: nil is returned without using the connection.
 func (d *client) UploadTraces(ctx context.Context, protoSpans []*tracepb.ResourceSpans) error {
-	pbRequest := &coltracepb.ExportTraceServiceRequest{
-		ResourceSpans: protoSpans,
+	if len(protoSpans) == 0 {
+		return nil
+	}
+	var err error
+	if d.coalescer != nil {
+		err = d.coalescer.Upload(ctx, protoSpans)
+	} else {
+		err = d.uploadTraces(ctx, protoSpans)
+	}
+	d.recentErrors.Record(err)
+	d.stats.Record(err)
+	return err
+}
+
+// ForceFlush uploads the spans held by the coalescer, if any, and sends an
+// empty export request to the collector, confirming it accepts exports.
+func (d *client) ForceFlush(ctx context.Context) error {
+	var err error
+	if d.coalescer != nil {
+		err = d.coalescer.Flush(ctx)
+	}
+	if err == nil {
+		err = d.uploadTraces(ctx, nil)
+	}
+	d.recentErrors.Record(err)
+	d.stats.Record(err)
+	return err
+}
+
+// RecentErrors returns the last errors returned by UploadTraces, oldest
+// first.
+func (d *client) RecentErrors() []otlptrace.RecentError {
+	return d.recentErrors.Errors()
+}
+
+func (d *client) uploadTraces(ctx context.Context, protoSpans []*tracepb.ResourceSpans) error {
+	if d.exportSlots != nil {
+		// The exports wait for a slot before the stop check, so that
+		// Stop does not wait for them.
+		select {
+		case d.exportSlots <- struct{}{}:
+			defer func() { <-d.exportSlots }()
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	d.stopMu.RLock()
+	if d.stopped {
+		d.stopMu.RUnlock()
+		return errStopped
+	}
+	d.inFlight.Add(1)
+	d.stopMu.RUnlock()
+	defer d.inFlight.Done()
+
+	protoSpans = tracetransform.MergeResourceAttributes(protoSpans, d.cfg.ResourceAttributes, d.cfg.OverrideResourceAttributes)
+	protoSpans = tracetransform.TransformSpans(protoSpans, d.cfg.SpanTransforms)
+	// The empty batch of ForceFlush is sent whatever the validation.
+	if d.cfg.StrictValidation && len(protoSpans) > 0 {
+		var err error
+		if protoSpans, err = tracetransform.ValidateSpans(protoSpans); err != nil {
+			d.errHandler.Handle(err)
+		}
+		if len(protoSpans) == 0 {
+			return nil
+		}
+	}
+	protoSpans, sampledOut := tracetransform.SampleSpans(protoSpans, d.cfg.ExportSampler)
+	if sampledOut > 0 {
+		d.metrics.SampledOut(ctx, sampledOut)
+		d.stats.Dropped(sampledOut)
+		if len(protoSpans) == 0 {
+			return nil
+		}
+	}
+	if d.cfg.StableOrdering {
+		protoSpans = tracetransform.SortSpans(protoSpans)
+	}
+	batches, dropped, err := d.batchLimit.Batches(protoSpans)
+	if err != nil {
+		return err
+	}
+	if dropped > 0 {
+		d.errHandler.Handle(fmt.Errorf("dropped %d spans exceeding the export batch limits", dropped))
+		d.stats.Dropped(dropped)
+	}
+
+	done, err := d.breaker.Allow(ctx)
+	if err != nil {
+		if d.queue.Spool(batches, err) {
+			return nil
+		}
+		d.stats.DroppedSpans(batches)
+		return err
+	}
+	start := time.Now()
+	sent, err := d.uploadBatches(ctx, batches)
+	err = exportError(err)
+	err = exporterror.Timeout(ctx, err, d.cfg.Timeout, time.Since(start))
+	done(err)
+	d.metrics.Exported(ctx, batches[:sent], batches[sent:], start)
+	d.lastResult.Store(exportResult{err: err})
+	if err == nil {
+		d.lastSuccess.Store(time.Now())
+		d.queue.Notify()
+		d.stats.Sent(batches)
+		return nil
+	}
+	d.stats.Sent(batches[:sent])
+	if d.queue.Spool(batches[sent:], err) {
+		return nil
+	}
+	d.stats.DroppedSpans(batches[sent:])
+	return err
+}
+
+// exportResult is the outcome of an export.
+type exportResult struct {
+	err error
+}
+
+// LastSuccess returns the time of the last export accepted by the collector,
+// or the zero time if there is none.
+func (d *client) LastSuccess() time.Time {
+	t, _ := d.lastSuccess.Load().(time.Time)
+	return t
+}
+
+// LastConnectError returns the error of the last attempted export, or nil if
+// it succeeded or no export was attempted. HTTP requests do not share a
+// connection whose state could be reported instead.
+func (d *client) LastConnectError() error {
+	r, _ := d.lastResult.Load().(exportResult)
+	return r.err
+}
+
+// Healthy checks the health of the collector by sending a GET request to the
+// health check path, set with WithHealthCheckPath, with the configured
+// headers. It returns nil if the collector answers with a 2xx status, an
+// error wrapping otlptrace.ErrUnhealthy and describing the status otherwise,
+// or the error of the request. The check is not retried.
+func (d *client) Healthy(ctx context.Context) error {
+	ctx, cancel := d.contextWithStop(ctx)
+	defer cancel()
+
+	address := fmt.Sprintf("%s://%s%s", d.getScheme(), d.cfg.Endpoint, d.generalCfg.HealthCheckPath)
+	r, err := http.NewRequestWithContext(ctx, http.MethodGet, address, nil)
+	if err != nil {
+		return err
+	}
+	if d.cfg.UserAgent != "" {
+		r.Header.Set("User-Agent", d.cfg.UserAgent)
+	}
+	setHeaders(r.Header, d.cfg.Headers)
+	if d.cfg.Authorization != "" {
+		r.Header.Set("Authorization", d.cfg.Authorization)
+	}
+	if err := d.setExportHeaders(ctx, r); err != nil {
+		return err
+	}
+
+	resp, err := d.client.Do(r)
+	if err != nil {
+		return err
+	}
+	// The body is drained so that the connection can be reused.
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	if err := resp.Body.Close(); err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%w: %s answered the health check with %s", otlptrace.ErrUnhealthy, address, resp.Status)
 	}
-	rawRequest, err := proto.Marshal(pbRequest)
+	return nil
+}
+
+// connectionStatus returns the status derived from the last attempted
+// export, reported by the summaries of the exports.
+func (d *client) connectionStatus() otlptrace.ConnectionStatus {
+	err := d.LastConnectError()
+	return otlptrace.ConnectionStatus{Connected: err == nil, LastError: err}
+}
+
+// uploadBatches uploads each of batches in its own export request, once the
+// previous one succeeded. It returns the number of batches uploaded.
+func (d *client) uploadBatches(ctx context.Context, batches [][]*tracepb.ResourceSpans) (int, error) {
+	for i, batch := range batches {
+		req := &coltracepb.ExportTraceServiceRequest{ResourceSpans: batch}
+		if err := d.export(ctx, req); err != nil {
+			return i, err
+		}
+	}
+	return len(batches), nil
+}
+
+// exportRequest sends req to the collector.
+func (d *client) exportRequest(ctx context.Context, req *coltracepb.ExportTraceServiceRequest) error {
+	rawRequest, err := d.marshal(req)
 	if err != nil {
 		return err
 	}
@@ -158,6 +593,18 @@ func (d *client) UploadTraces(ctx context.Context, protoSpans []*tracepb.Resourc
 	if err != nil {
 		return err
 	}
+	if err := d.setExportHeaders(ctx, request.Request); err != nil {
+		return err
+	}
+	if d.cfg.IdempotencyKeyHeader != "" {
+		// The request, and so the key, is reused by the retries.
+		key, err := otlpconfig.NewIdempotencyKey()
+		if err != nil {
+			return err
+		}
+		request.Header.Set(d.cfg.IdempotencyKeyHeader, key)
+	}
+	otlpconfig.ObservePayloadSize(d.cfg.PayloadSizeObserver, req, request.size, Compression(d.cfg.Compression) != NoCompression)
 
 	return d.requestFunc(ctx, func(ctx context.Context) error {
 		select {
@@ -166,6 +613,7 @@ func (d *client) UploadTraces(ctx context.Context, protoSpans []*tracepb.Resourc
 		default:
 		}
 
+		d.metrics.Attempt(ctx)
 		request.reset(ctx)
 		resp, err := d.client.Do(request.Request)
 		if err != nil {
@@ -175,7 +623,8 @@ func (d *client) UploadTraces(ctx context.Context, protoSpans []*tracepb.Resourc
 		var rErr error
 		switch resp.StatusCode {
 		case http.StatusOK:
-			// Success, do not retry.
+			// Success, do not retry. Report any partial success.
+			d.handleResponse(resp.Header.Get("Content-Type"), resp.Body)
 		case http.StatusTooManyRequests,
 			http.StatusServiceUnavailable:
 			// Retry-able failure.
@@ -187,7 +636,8 @@ func (d *client) UploadTraces(ctx context.Context, protoSpans []*tracepb.Resourc
 				return err
 			}
 		default:
-			rErr = fmt.Errorf("failed to send %s to %s: %s", d.name, request.URL, resp.Status)
+			err := fmt.Errorf("failed to send %s to %s: %s", d.name, request.URL, resp.Status)
+			rErr = otlptrace.NewExportError(otlptrace.ErrRejected, err, false)
 		}
 
 		if err := resp.Body.Close(); err != nil {
@@ -197,6 +647,83 @@ func (d *client) UploadTraces(ctx context.Context, protoSpans []*tracepb.Resourc
 	})
 }
 
+// exportError returns an otlptrace.ExportError describing the failed export
+// err, or err itself if it was canceled or did not fail while sending the
+// request.
+func exportError(err error) error {
+	var (
+		eErr   *otlptrace.ExportError
+		rErr   retryableError
+		urlErr *url.Error
+	)
+	switch {
+	case err == nil, errors.As(err, &eErr), errors.Is(err, context.Canceled):
+		return err
+	case errors.Is(err, context.DeadlineExceeded):
+		return otlptrace.NewExportError(otlptrace.ErrTimeout, err, true)
+	case errors.As(err, &rErr):
+		return otlptrace.NewExportError(otlptrace.ErrRejected, err, true)
+	case errors.As(err, &urlErr):
+		if urlErr.Timeout() {
+			return otlptrace.NewExportError(otlptrace.ErrTimeout, err, true)
+		}
+		return otlptrace.NewExportError(otlptrace.ErrDisconnected, err, true)
+	}
+	return err
+}
+
+// marshal encodes req in the configured format.
+func (d *client) marshal(req *coltracepb.ExportTraceServiceRequest) ([]byte, error) {
+	if d.cfg.Marshaler == otlpconfig.MarshalJSON {
+		// OTLP/JSON requires integer enum values.
+		return protojson.MarshalOptions{UseEnumNumbers: true}.Marshal(req)
+	}
+	return proto.Marshal(req)
+}
+
+// contentType returns the content type of the configured format.
+func (d *client) contentType() string {
+	if d.cfg.Marshaler == otlpconfig.MarshalJSON {
+		return contentTypeJSON
+	}
+	return contentTypeProto
+}
+
+// handleResponse reads a successful export response with contentType from
+// body and reports any partial success it contains. A response with neither
+// the protobuf nor the JSON content type is decoded in the format of the
+// request. The spans were accepted, so a response that cannot be read is
+// reported to the error handler rather than failing the export.
+func (d *client) handleResponse(contentType string, body io.Reader) {
+	rawResponse, err := ioutil.ReadAll(body)
+	if err != nil {
+		d.errHandler.Handle(fmt.Errorf("failed to read the export response: %w", err))
+		return
+	}
+	if len(rawResponse) == 0 {
+		return
+	}
+
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil &&
+		(mediaType == contentTypeProto || mediaType == contentTypeJSON) {
+		contentType = mediaType
+	} else {
+		contentType = d.contentType()
+	}
+
+	var pbResponse coltracepb.ExportTraceServiceResponse
+	if contentType == contentTypeJSON {
+		err = partialsuccess.UnmarshalJSON(rawResponse, &pbResponse)
+	} else {
+		err = proto.Unmarshal(rawResponse, &pbResponse)
+	}
+	if err != nil {
+		d.errHandler.Handle(fmt.Errorf("failed to decode the export response: %w", err))
+		return
+	}
+	partialsuccess.Handle(&pbResponse, d.cfg.PartialSuccessHandler, d.errHandler)
+}
+
 func (d *client) newRequest(body []byte) (request, error) {
 	address := fmt.Sprintf("%s://%s%s", d.getScheme(), d.cfg.Endpoint, d.cfg.URLPath)
 	r, err := http.NewRequest(http.MethodPost, address, nil)
@@ -204,16 +731,21 @@ func (d *client) newRequest(body []byte) (request, error) {
 		return request{Request: r}, err
 	}
 
-	for k, v := range d.cfg.Headers {
-		r.Header.Set(k, v)
+	if d.cfg.UserAgent != "" {
+		r.Header.Set("User-Agent", d.cfg.UserAgent)
+	}
+	setHeaders(r.Header, d.cfg.Headers)
+	if d.cfg.Authorization != "" {
+		r.Header.Set("Authorization", d.cfg.Authorization)
 	}
-	r.Header.Set("Content-Type", contentTypeProto)
+	r.Header.Set("Content-Type", d.contentType())
 
 	req := request{Request: r}
 	switch Compression(d.cfg.Compression) {
 	case NoCompression:
 		r.ContentLength = (int64)(len(body))
 		req.bodyReader = bodyReader(body)
+		req.size = len(body)
 	case GzipCompression:
 		// Ensure the content length is not used.
 		r.ContentLength = -1
@@ -234,11 +766,65 @@ func (d *client) newRequest(body []byte) (request, error) {
 		}
 
 		req.bodyReader = bodyReader(b.Bytes())
+		req.size = b.Len()
+	case ZstdCompression:
+		// Ensure the content length is not used.
+		r.ContentLength = -1
+		r.Header.Set("Content-Encoding", "zstd")
+
+		zw := zstdPool.Get().(*zstd.Encoder)
+		defer zstdPool.Put(zw)
+
+		var b bytes.Buffer
+		zw.Reset(&b)
+
+		if _, err := zw.Write(body); err != nil {
+			return req, err
+		}
+		// Close needs to be called to ensure body if fully written.
+		if err := zw.Close(); err != nil {
+			return req, err
+		}
+
+		req.bodyReader = bodyReader(b.Bytes())
+		req.size = b.Len()
 	}
 
 	return req, nil
 }
 
+// setExportHeaders sets the headers returned by the configured headers
+// function, if any, on r.
+func (d *client) setExportHeaders(ctx context.Context, r *http.Request) error {
+	if d.cfg.HeadersFunc == nil {
+		return nil
+	}
+
+	headers, err := d.cfg.HeadersFunc(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get export headers: %w", err)
+	}
+	// Do not let the function change the payload content type.
+	contentType := r.Header.Get("Content-Type")
+	setHeaders(r.Header, headers)
+	r.Header.Set("Content-Type", contentType)
+	return nil
+}
+
+// setHeaders sets headers in h in the order of their keys, so that the value
+// set for keys differing only by case, that h canonicalizes to the same key,
+// does not depend on the iteration order of the map.
+func setHeaders(h http.Header, headers map[string]string) {
+	keys := make([]string, 0, len(headers))
+	for k := range headers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		h.Set(k, headers[k])
+	}
+}
+
 // bodyReader returns a closure returning a new reader for buf.
 func bodyReader(buf []byte) func() io.ReadCloser {
 	return func() io.ReadCloser {
@@ -252,6 +838,8 @@ type request struct {
 
 	// bodyReader allows the same body to be used for multiple requests.
 	bodyReader func() io.ReadCloser
+	// size is the size of the body, once compressed if it is.
+	size int
 }
 
 // reset reinitializes the request Body and uses ctx for the request.
@@ -16,18 +16,39 @@ package otlptracehttp_test
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/proto"
 
+	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/otlpconfig"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/otlptracetest"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	ottest "go.opentelemetry.io/otel/internal/internaltest"
+	"go.opentelemetry.io/otel/metric/metrictest"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	collectortracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
 )
 
 const (
@@ -59,6 +80,31 @@ func TestEndToEnd(t *testing.T) {
 				otlptracehttp.WithCompression(otlptracehttp.GzipCompression),
 			},
 		},
+		{
+			name: "with zstd compression",
+			opts: []otlptracehttp.Option{
+				otlptracehttp.WithCompression(otlptracehttp.ZstdCompression),
+			},
+			mcCfg: mockCollectorConfig{
+				ExpectedHeaders: map[string]string{"Content-Encoding": "zstd"},
+			},
+		},
+		{
+			name: "with json marshaling",
+			opts: []otlptracehttp.Option{
+				otlptracehttp.WithMarshal(otlptracehttp.MarshalJSON),
+			},
+			mcCfg: mockCollectorConfig{
+				ExpectedHeaders: map[string]string{"Content-Type": "application/json"},
+			},
+		},
+		{
+			name: "with json marshaling and gzip compression",
+			opts: []otlptracehttp.Option{
+				otlptracehttp.WithMarshal(otlptracehttp.MarshalJSON),
+				otlptracehttp.WithCompression(otlptracehttp.GzipCompression),
+			},
+		},
 		{
 			name: "retry",
 			opts: []otlptracehttp.Option{
@@ -114,6 +160,12 @@ func TestEndToEnd(t *testing.T) {
 				otlptracehttp.WithURLPath(""),
 			},
 		},
+		{
+			name: "with invalid paths (forced to defaults)",
+			opts: []otlptracehttp.Option{
+				otlptracehttp.WithURLPath("/v1/traces?format=json"),
+			},
+		},
 		{
 			name: "with relative paths",
 			opts: []otlptracehttp.Option{
@@ -131,6 +183,69 @@ func TestEndToEnd(t *testing.T) {
 			},
 			tls: true,
 		},
+		{
+			name: "with client certificate and bearer token",
+			opts: []otlptracehttp.Option{
+				otlptracehttp.WithBearerToken("token"),
+				otlptracehttp.WithHeaders(map[string]string{"Tenant": "a"}),
+			},
+			mcCfg: mockCollectorConfig{
+				WithTLS:           true,
+				RequireClientCert: true,
+				ExpectedHeaders: map[string]string{
+					"Authorization": "Bearer token",
+					"Tenant":        "a",
+				},
+			},
+			tls: true,
+		},
+		{
+			name: "with headers func",
+			opts: []otlptracehttp.Option{
+				otlptracehttp.WithHeaders(map[string]string{"Authorization": "static"}),
+				otlptracehttp.WithHeadersFunc(func(context.Context) (map[string]string, error) {
+					return map[string]string{"Authorization": "Bearer fresh"}, nil
+				}),
+			},
+			mcCfg: mockCollectorConfig{
+				ExpectedHeaders: map[string]string{"Authorization": "Bearer fresh"},
+			},
+		},
+		{
+			name: "with default user agent",
+			opts: nil,
+			mcCfg: mockCollectorConfig{
+				ExpectedHeaders: map[string]string{"User-Agent": "OTel-OTLP-Exporter-Go/" + otlptrace.Version()},
+			},
+		},
+		{
+			name: "with user agent",
+			opts: []otlptracehttp.Option{
+				otlptracehttp.WithUserAgent("custom-agent/1.0"),
+			},
+			mcCfg: mockCollectorConfig{
+				ExpectedHeaders: map[string]string{"User-Agent": "custom-agent/1.0"},
+			},
+		},
+		{
+			name: "with bearer token",
+			opts: []otlptracehttp.Option{
+				otlptracehttp.WithHeaders(map[string]string{"Authorization": "static"}),
+				otlptracehttp.WithBearerToken("token"),
+			},
+			mcCfg: mockCollectorConfig{
+				ExpectedHeaders: map[string]string{"Authorization": "Bearer token"},
+			},
+		},
+		{
+			name: "with basic auth",
+			opts: []otlptracehttp.Option{
+				otlptracehttp.WithBasicAuth("user", "pass"),
+			},
+			mcCfg: mockCollectorConfig{
+				ExpectedHeaders: map[string]string{"Authorization": "Basic dXNlcjpwYXNz"},
+			},
+		},
 		{
 			name: "with extra headers",
 			opts: []otlptracehttp.Option{
@@ -170,6 +285,163 @@ func TestEndToEnd(t *testing.T) {
 	}
 }
 
+func TestEnvironmentCompression(t *testing.T) {
+	const envVar = "OTEL_EXPORTER_OTLP_COMPRESSION"
+	orig, hadOrig := os.LookupEnv(envVar)
+	require.NoError(t, os.Setenv(envVar, "gzip"))
+	defer func() {
+		if hadOrig {
+			_ = os.Setenv(envVar, orig)
+		} else {
+			_ = os.Unsetenv(envVar)
+		}
+	}()
+
+	mc := runMockCollector(t, mockCollectorConfig{
+		ExpectedHeaders: map[string]string{"Content-Encoding": "gzip"},
+	})
+	defer mc.MustStop(t)
+	driver := otlptracehttp.NewClient(
+		otlptracehttp.WithEndpoint(mc.Endpoint()),
+		otlptracehttp.WithInsecure(),
+	)
+	ctx := context.Background()
+	exporter, err := otlptrace.New(ctx, driver)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, exporter.Shutdown(ctx))
+	}()
+	assert.NoError(t, exporter.ExportSpans(ctx, otlptracetest.SingleReadOnlySpan()))
+	assert.Len(t, mc.GetSpans(), 1)
+}
+
+func TestNewFromEnv(t *testing.T) {
+	mc := runMockCollector(t, mockCollectorConfig{
+		ExpectedHeaders: map[string]string{"Content-Type": "application/json"},
+	})
+	defer mc.MustStop(t)
+	envStore, err := ottest.SetEnvVariables(map[string]string{
+		"OTEL_EXPORTER_OTLP_TRACES_PROTOCOL": "http/json",
+		"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT": "http://" + mc.Endpoint(),
+	})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, envStore.Restore())
+	}()
+
+	ctx := context.Background()
+	exporter, err := otlptrace.NewFromEnv(ctx)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, exporter.Shutdown(ctx))
+	}()
+	assert.NoError(t, exporter.ExportSpans(ctx, otlptracetest.SingleReadOnlySpan()))
+	assert.Len(t, mc.GetSpans(), 1)
+}
+
+func TestURLPath(t *testing.T) {
+	tests := []struct {
+		name    string
+		urlPath string
+		want    string
+		wantErr string
+	}{
+		{
+			name:    "absolute",
+			urlPath: "/custom/traces",
+			want:    "/custom/traces",
+		},
+		{
+			name:    "relative",
+			urlPath: "custom/traces",
+			want:    "/custom/traces",
+			wantErr: `invalid URL path "custom/traces", using "/custom/traces": the path must be absolute`,
+		},
+		{
+			name:    "query",
+			urlPath: "/custom/traces?format=json",
+			want:    "/v1/traces",
+			wantErr: "query and fragment are not allowed",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			paths := make(chan string, 1)
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				paths <- r.URL.Path
+			}))
+			defer srv.Close()
+
+			var errs []error
+			client := otlptracehttp.NewClient(
+				otlptracehttp.WithEndpoint(strings.TrimPrefix(srv.URL, "http://")),
+				otlptracehttp.WithInsecure(),
+				otlptracehttp.WithURLPath(tt.urlPath),
+				otlptracehttp.WithErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+					errs = append(errs, err)
+				})),
+			)
+			if tt.wantErr == "" {
+				assert.Empty(t, errs)
+			} else if assert.Len(t, errs, 1) {
+				assert.Contains(t, errs[0].Error(), tt.wantErr)
+			}
+
+			ctx := context.Background()
+			exporter, err := otlptrace.New(ctx, client)
+			require.NoError(t, err)
+			defer func() {
+				assert.NoError(t, exporter.Shutdown(ctx))
+			}()
+			require.NoError(t, exporter.ExportSpans(ctx, otlptracetest.SingleReadOnlySpan()))
+			assert.Equal(t, tt.want, <-paths)
+		})
+	}
+}
+
+func TestEndpointWithPath(t *testing.T) {
+	const tracesPath = "/otlp/v1/traces"
+	mc := runMockCollector(t, mockCollectorConfig{
+		TracesURLPath: tracesPath,
+	})
+	defer mc.MustStop(t)
+	driver := otlptracehttp.NewClient(
+		otlptracehttp.WithEndpoint(mc.Endpoint()+tracesPath),
+		otlptracehttp.WithInsecure(),
+	)
+	ctx := context.Background()
+	exporter, err := otlptrace.New(ctx, driver)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, exporter.Shutdown(ctx))
+	}()
+	assert.NoError(t, exporter.ExportSpans(ctx, otlptracetest.SingleReadOnlySpan()))
+	assert.Len(t, mc.GetSpans(), 1)
+}
+
+func TestEndpointURL(t *testing.T) {
+	const tracesPath = "/otlp/v1/traces"
+	mc := runMockCollector(t, mockCollectorConfig{
+		TracesURLPath: tracesPath,
+	})
+	defer mc.MustStop(t)
+	driver := otlptracehttp.NewClient(
+		otlptracehttp.WithEndpointURL(&url.URL{
+			Scheme: "http",
+			Host:   mc.Endpoint(),
+			Path:   tracesPath,
+		}),
+	)
+	ctx := context.Background()
+	exporter, err := otlptrace.New(ctx, driver)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, exporter.Shutdown(ctx))
+	}()
+	assert.NoError(t, exporter.ExportSpans(ctx, otlptracetest.SingleReadOnlySpan()))
+	assert.Len(t, mc.GetSpans(), 1)
+}
+
 func TestExporterShutdown(t *testing.T) {
 	mc := runMockCollector(t, mockCollectorConfig{})
 	defer func() {
@@ -186,6 +458,18 @@ func TestExporterShutdown(t *testing.T) {
 	})
 }
 
+func TestExporterDelivery(t *testing.T) {
+	mc := runMockCollector(t, mockCollectorConfig{})
+	defer mc.MustStop(t)
+
+	otlptracetest.RunExporterDeliveryTest(t, func() otlptrace.Client {
+		return otlptracehttp.NewClient(
+			otlptracehttp.WithInsecure(),
+			otlptracehttp.WithEndpoint(mc.Endpoint()),
+		)
+	}, mc)
+}
+
 func TestTimeout(t *testing.T) {
 	mcCfg := mockCollectorConfig{
 		InjectDelay: 100 * time.Millisecond,
@@ -205,127 +489,266 @@ func TestTimeout(t *testing.T) {
 	}()
 	err = exporter.ExportSpans(ctx, otlptracetest.SingleReadOnlySpan())
 	assert.Equal(t, true, os.IsTimeout(err))
+	assert.ErrorIs(t, err, otlptrace.ErrTimeout)
 }
 
-func TestNoRetry(t *testing.T) {
-	mc := runMockCollector(t, mockCollectorConfig{
-		InjectHTTPStatus: []int{http.StatusBadRequest},
-	})
+func TestTimeoutDeadline(t *testing.T) {
+	tests := []struct {
+		name          string
+		timeout       time.Duration
+		callerTimeout time.Duration
+		want          string
+	}{
+		{
+			name:          "configured timeout",
+			timeout:       50 * time.Millisecond,
+			callerTimeout: time.Minute,
+			want:          "the configured timeout 50ms expired",
+		},
+		{
+			name:          "caller deadline",
+			timeout:       time.Minute,
+			callerTimeout: 50 * time.Millisecond,
+			want:          "the deadline of the caller expired",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mc := runMockCollector(t, mockCollectorConfig{
+				InjectDelay: 200 * time.Millisecond,
+			})
+			defer mc.MustStop(t)
+			driver := otlptracehttp.NewClient(
+				otlptracehttp.WithEndpoint(mc.Endpoint()),
+				otlptracehttp.WithInsecure(),
+				otlptracehttp.WithTimeout(tt.timeout),
+				otlptracehttp.WithRetry(otlptracehttp.RetryConfig{Enabled: false}),
+			)
+			ctx := context.Background()
+			require.NoError(t, driver.Start(ctx))
+			defer func() {
+				assert.NoError(t, driver.Stop(ctx))
+			}()
+
+			uploadCtx, cancel := context.WithTimeout(ctx, tt.callerTimeout)
+			defer cancel()
+			err := driver.UploadTraces(uploadCtx, singleProtoSpan())
+			require.Error(t, err)
+			assert.ErrorIs(t, err, otlptrace.ErrTimeout)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
+
+func TestCallerDeadlineShorterThanTimeout(t *testing.T) {
+	mcCfg := mockCollectorConfig{
+		InjectDelay: 100 * time.Millisecond,
+	}
+	mc := runMockCollector(t, mcCfg)
 	defer mc.MustStop(t)
-	driver := otlptracehttp.NewClient(
+	client := otlptracehttp.NewClient(
 		otlptracehttp.WithEndpoint(mc.Endpoint()),
 		otlptracehttp.WithInsecure(),
-		otlptracehttp.WithRetry(otlptracehttp.RetryConfig{
-			Enabled:         true,
-			InitialInterval: 1 * time.Nanosecond,
-			MaxInterval:     1 * time.Nanosecond,
-			// Never stop retry of retry-able status.
-			MaxElapsedTime: 0,
-		}),
+		otlptracehttp.WithTimeout(time.Minute),
+		otlptracehttp.WithRetry(otlptracehttp.RetryConfig{Enabled: false}),
 	)
 	ctx := context.Background()
-	exporter, err := otlptrace.New(ctx, driver)
+	exporter, err := otlptrace.New(ctx, client)
 	require.NoError(t, err)
 	defer func() {
 		assert.NoError(t, exporter.Shutdown(ctx))
 	}()
-	err = exporter.ExportSpans(ctx, otlptracetest.SingleReadOnlySpan())
-	assert.Error(t, err)
-	assert.Equal(t, fmt.Sprintf("failed to send traces to http://%s/v1/traces: 400 Bad Request", mc.endpoint), err.Error())
-	assert.Empty(t, mc.GetSpans())
+	exportCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	err = exporter.ExportSpans(exportCtx, otlptracetest.SingleReadOnlySpan())
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
 }
 
-func TestEmptyData(t *testing.T) {
-	mcCfg := mockCollectorConfig{}
-	mc := runMockCollector(t, mcCfg)
+func TestRetryBeyondCallerDeadline(t *testing.T) {
+	mc := runMockCollector(t, mockCollectorConfig{
+		InjectHTTPStatus: []int{http.StatusServiceUnavailable},
+	})
 	defer mc.MustStop(t)
-	driver := otlptracehttp.NewClient(
+	client := otlptracehttp.NewClient(
 		otlptracehttp.WithEndpoint(mc.Endpoint()),
 		otlptracehttp.WithInsecure(),
+		otlptracehttp.WithRetry(otlptracehttp.RetryConfig{
+			Enabled:         true,
+			InitialInterval: time.Hour,
+			MaxInterval:     time.Hour,
+			MaxElapsedTime:  0,
+		}),
 	)
 	ctx := context.Background()
-	exporter, err := otlptrace.New(ctx, driver)
+	exporter, err := otlptrace.New(ctx, client)
 	require.NoError(t, err)
 	defer func() {
 		assert.NoError(t, exporter.Shutdown(ctx))
 	}()
-	assert.NoError(t, err)
-	err = exporter.ExportSpans(ctx, nil)
-	assert.NoError(t, err)
-	assert.Empty(t, mc.GetSpans())
+
+	// The retry could not be attempted before the deadline, the export fails
+	// right away instead of waiting for it.
+	exportCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	start := time.Now()
+	err = exporter.ExportSpans(exportCtx, otlptracetest.SingleReadOnlySpan())
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, int64(time.Since(start)), int64(5*time.Second))
+	assert.Len(t, mc.GetSpans(), 0)
 }
 
-func TestCancelledContext(t *testing.T) {
-	mcCfg := mockCollectorConfig{}
-	mc := runMockCollector(t, mcCfg)
+func TestRetryableErrorFunc(t *testing.T) {
+	mc := runMockCollector(t, mockCollectorConfig{
+		InjectHTTPStatus: []int{http.StatusInternalServerError, http.StatusInternalServerError},
+	})
 	defer mc.MustStop(t)
-	driver := otlptracehttp.NewClient(
+	var calls int
+	client := otlptracehttp.NewClient(
 		otlptracehttp.WithEndpoint(mc.Endpoint()),
 		otlptracehttp.WithInsecure(),
+		otlptracehttp.WithRetry(otlptracehttp.RetryConfig{
+			Enabled:         true,
+			InitialInterval: time.Nanosecond,
+			MaxInterval:     time.Nanosecond,
+			MaxElapsedTime:  time.Minute,
+		}),
+		otlptracehttp.WithRetryableErrorFunc(func(err error) bool {
+			calls++
+			return strings.Contains(err.Error(), "500 Internal Server Error")
+		}),
 	)
-	ctx, cancel := context.WithCancel(context.Background())
-	exporter, err := otlptrace.New(ctx, driver)
+	ctx := context.Background()
+	exporter, err := otlptrace.New(ctx, client)
 	require.NoError(t, err)
 	defer func() {
-		assert.NoError(t, exporter.Shutdown(context.Background()))
+		assert.NoError(t, exporter.Shutdown(ctx))
 	}()
-	cancel()
-	err = exporter.ExportSpans(ctx, otlptracetest.SingleReadOnlySpan())
-	assert.Error(t, err)
-	assert.Empty(t, mc.GetSpans())
+
+	// A 500 status is not retried by default.
+	require.NoError(t, exporter.ExportSpans(ctx, otlptracetest.SingleReadOnlySpan()))
+	assert.Equal(t, 2, calls)
+	assert.Len(t, mc.GetSpans(), 1)
 }
 
-func TestDeadlineContext(t *testing.T) {
-	statuses := make([]int, 0, 5)
-	for i := 0; i < cap(statuses); i++ {
-		statuses = append(statuses, http.StatusTooManyRequests)
-	}
-	mcCfg := mockCollectorConfig{
-		InjectHTTPStatus: statuses,
-	}
-	mc := runMockCollector(t, mcCfg)
+func TestBackoffStrategy(t *testing.T) {
+	mc := runMockCollector(t, mockCollectorConfig{
+		InjectHTTPStatus: []int{
+			http.StatusServiceUnavailable,
+			http.StatusServiceUnavailable,
+			http.StatusServiceUnavailable,
+		},
+	})
 	defer mc.MustStop(t)
-	driver := otlptracehttp.NewClient(
+	var attempts []int
+	client := otlptracehttp.NewClient(
 		otlptracehttp.WithEndpoint(mc.Endpoint()),
 		otlptracehttp.WithInsecure(),
 		otlptracehttp.WithRetry(otlptracehttp.RetryConfig{
 			Enabled:         true,
-			InitialInterval: 1 * time.Hour,
-			MaxInterval:     1 * time.Hour,
-			// Never stop retry of retry-able status.
-			MaxElapsedTime: 0,
+			InitialInterval: time.Hour,
+			MaxInterval:     time.Hour,
+		}),
+		otlptracehttp.WithBackoffStrategy(func(attempt int, lastErr error) (time.Duration, bool) {
+			assert.Error(t, lastErr)
+			attempts = append(attempts, attempt)
+			return time.Millisecond, attempt > 1
 		}),
 	)
 	ctx := context.Background()
-	exporter, err := otlptrace.New(ctx, driver)
+	exporter, err := otlptrace.New(ctx, client)
 	require.NoError(t, err)
 	defer func() {
-		assert.NoError(t, exporter.Shutdown(context.Background()))
+		assert.NoError(t, exporter.Shutdown(ctx))
 	}()
-	ctx, cancel := context.WithTimeout(ctx, time.Second)
-	defer cancel()
+
+	// The strategy gives up before the third retry.
 	err = exporter.ExportSpans(ctx, otlptracetest.SingleReadOnlySpan())
-	assert.Error(t, err)
-	assert.Empty(t, mc.GetSpans())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "retry strategy gave up")
+	assert.Equal(t, []int{1, 2}, attempts)
+	assert.Len(t, mc.GetSpans(), 0)
+
+	// The attempts start over for the next export.
+	attempts = nil
+	require.NoError(t, exporter.ExportSpans(ctx, otlptracetest.SingleReadOnlySpan()))
+	assert.Equal(t, []int{1}, attempts)
+	assert.Len(t, mc.GetSpans(), 1)
 }
 
-func TestStopWhileExporting(t *testing.T) {
-	statuses := make([]int, 0, 5)
-	for i := 0; i < cap(statuses); i++ {
-		statuses = append(statuses, http.StatusTooManyRequests)
+func TestOversizeBatchPolicy(t *testing.T) {
+	stubs := make(tracetest.SpanStubs, 25)
+	for i := range stubs {
+		stubs[i].Name = fmt.Sprintf("span %d", i)
 	}
-	mcCfg := mockCollectorConfig{
-		InjectHTTPStatus: statuses,
+
+	for _, tt := range []struct {
+		name    string
+		opts    []otlptracehttp.Option
+		wantErr error
+		spans   int
+	}{
+		{
+			name: "split",
+			opts: []otlptracehttp.Option{
+				otlptracehttp.WithMaxExportBatchBytes(512),
+				otlptracehttp.WithOversizeBatchPolicy(otlptracehttp.SplitOversizeBatch),
+			},
+			spans: 25,
+		},
+		{
+			name: "reject",
+			opts: []otlptracehttp.Option{
+				otlptracehttp.WithMaxExportBatchSpans(10),
+				otlptracehttp.WithOversizeBatchPolicy(otlptracehttp.RejectOversizeBatch),
+			},
+			wantErr: otlptracehttp.ErrOversizeBatch,
+		},
+		{
+			name: "drop",
+			opts: []otlptracehttp.Option{
+				otlptracehttp.WithMaxExportBatchSpans(10),
+				otlptracehttp.WithOversizeBatchPolicy(otlptracehttp.DropOversizeBatch),
+			},
+			spans: 10,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			mc := runMockCollector(t, mockCollectorConfig{})
+			defer mc.MustStop(t)
+			opts := append([]otlptracehttp.Option{
+				otlptracehttp.WithEndpoint(mc.Endpoint()),
+				otlptracehttp.WithInsecure(),
+			}, tt.opts...)
+			ctx := context.Background()
+			exporter, err := otlptrace.New(ctx, otlptracehttp.NewClient(opts...))
+			require.NoError(t, err)
+			defer func() {
+				assert.NoError(t, exporter.Shutdown(ctx))
+			}()
+
+			err = exporter.ExportSpans(ctx, stubs.Snapshots())
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Len(t, mc.GetSpans(), tt.spans)
+		})
 	}
-	mc := runMockCollector(t, mcCfg)
+}
+
+func TestNoRetry(t *testing.T) {
+	mc := runMockCollector(t, mockCollectorConfig{
+		InjectHTTPStatus: []int{http.StatusBadRequest},
+	})
 	defer mc.MustStop(t)
 	driver := otlptracehttp.NewClient(
 		otlptracehttp.WithEndpoint(mc.Endpoint()),
 		otlptracehttp.WithInsecure(),
 		otlptracehttp.WithRetry(otlptracehttp.RetryConfig{
 			Enabled:         true,
-			InitialInterval: 1 * time.Hour,
-			MaxInterval:     1 * time.Hour,
+			InitialInterval: 1 * time.Nanosecond,
+			MaxInterval:     1 * time.Nanosecond,
 			// Never stop retry of retry-able status.
 			MaxElapsedTime: 0,
 		}),
@@ -336,15 +759,1675 @@ func TestStopWhileExporting(t *testing.T) {
 	defer func() {
 		assert.NoError(t, exporter.Shutdown(ctx))
 	}()
-	doneCh := make(chan struct{})
-	go func() {
+	err = exporter.ExportSpans(ctx, otlptracetest.SingleReadOnlySpan())
+	assert.Error(t, err)
+	assert.Equal(t, fmt.Sprintf("failed to send traces to http://%s/v1/traces: 400 Bad Request", mc.endpoint), err.Error())
+	assert.Empty(t, mc.GetSpans())
+}
+
+func TestSelfObservability(t *testing.T) {
+	mc := runMockCollector(t, mockCollectorConfig{
+		InjectHTTPStatus: []int{http.StatusServiceUnavailable, http.StatusBadRequest},
+	})
+	defer mc.MustStop(t)
+	mp := metrictest.NewMeterProvider()
+	driver := otlptracehttp.NewClient(
+		otlptracehttp.WithEndpoint(mc.Endpoint()),
+		otlptracehttp.WithInsecure(),
+		otlptracehttp.WithSelfObservability(mp),
+		otlptracehttp.WithRetry(otlptracehttp.RetryConfig{
+			Enabled:         true,
+			InitialInterval: 1 * time.Nanosecond,
+			MaxInterval:     1 * time.Nanosecond,
+			// Never stop retry of retry-able status.
+			MaxElapsedTime: 0,
+		}),
+	)
+	ctx := context.Background()
+	exporter, err := otlptrace.New(ctx, driver)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, exporter.Shutdown(ctx))
+	}()
+	assert.Error(t, exporter.ExportSpans(ctx, otlptracetest.SingleReadOnlySpan()))
+
+	got := make(map[string]int)
+	for _, m := range metrictest.AsStructs(mp.MeasurementBatches) {
+		got[m.Name] += int(m.Number.AsInt64())
+		assert.Equal(t, "http/protobuf", m.Labels["protocol"].AsString())
+	}
+	assert.Equal(t, 1, got["otlp.exporter.failed_spans"])
+	assert.Equal(t, 2, got["otlp.exporter.export_attempts"])
+	assert.NotContains(t, got, "otlp.exporter.exported_spans")
+	assert.Contains(t, got, "otlp.exporter.export_duration")
+}
+
+func TestSelfObservabilityJSON(t *testing.T) {
+	mc := runMockCollector(t, mockCollectorConfig{})
+	defer mc.MustStop(t)
+	mp := metrictest.NewMeterProvider()
+	driver := otlptracehttp.NewClient(
+		otlptracehttp.WithEndpoint(mc.Endpoint()),
+		otlptracehttp.WithInsecure(),
+		otlptracehttp.WithMarshal(otlptracehttp.MarshalJSON),
+		otlptracehttp.WithSelfObservability(mp),
+	)
+	ctx := context.Background()
+	exporter, err := otlptrace.New(ctx, driver)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, exporter.Shutdown(ctx))
+	}()
+	require.NoError(t, exporter.ExportSpans(ctx, otlptracetest.SingleReadOnlySpan()))
+
+	measurements := metrictest.AsStructs(mp.MeasurementBatches)
+	require.NotEmpty(t, measurements)
+	for _, m := range measurements {
+		assert.Equal(t, "http/json", m.Labels["protocol"].AsString())
+	}
+}
+
+func TestForceFlush(t *testing.T) {
+	mc := runMockCollector(t, mockCollectorConfig{
+		InjectHTTPStatus: []int{http.StatusBadRequest},
+	})
+	defer mc.MustStop(t)
+	driver := otlptracehttp.NewClient(
+		otlptracehttp.WithEndpoint(mc.Endpoint()),
+		otlptracehttp.WithInsecure(),
+	)
+	ctx := context.Background()
+	exporter, err := otlptrace.New(ctx, driver)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, exporter.Shutdown(ctx))
+	}()
+	assert.Error(t, exporter.ForceFlush(ctx))
+	assert.NoError(t, exporter.ForceFlush(ctx))
+	assert.Empty(t, mc.GetSpans())
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	mc := runMockCollector(t, mockCollectorConfig{
+		InjectHTTPStatus: []int{
+			http.StatusBadRequest,
+			http.StatusBadRequest,
+			http.StatusServiceUnavailable,
+			http.StatusServiceUnavailable,
+		},
+	})
+	defer mc.MustStop(t)
+	driver := otlptracehttp.NewClient(
+		otlptracehttp.WithEndpoint(mc.Endpoint()),
+		otlptracehttp.WithInsecure(),
+		otlptracehttp.WithRetry(otlptracehttp.RetryConfig{Enabled: false}),
+		otlptracehttp.WithCircuitBreaker(2, time.Hour),
+	)
+	ctx := context.Background()
+	exporter, err := otlptrace.New(ctx, driver)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, exporter.Shutdown(ctx))
+	}()
+
+	// The rejected exports do not open the circuit breaker, the collector
+	// being healthy.
+	for i := 0; i < 4; i++ {
 		err := exporter.ExportSpans(ctx, otlptracetest.SingleReadOnlySpan())
 		assert.Error(t, err)
-		assert.Empty(t, mc.GetSpans())
-		close(doneCh)
+		assert.NotErrorIs(t, err, otlptracehttp.ErrCircuitOpen)
+	}
+	// The collector would accept this export if it was attempted.
+	err = exporter.ExportSpans(ctx, otlptracetest.SingleReadOnlySpan())
+	assert.ErrorIs(t, err, otlptracehttp.ErrCircuitOpen)
+	assert.Empty(t, mc.GetSpans())
+}
+
+func TestExportErrors(t *testing.T) {
+	mc := runMockCollector(t, mockCollectorConfig{
+		InjectHTTPStatus: []int{http.StatusBadRequest, http.StatusServiceUnavailable},
+	})
+	driver := otlptracehttp.NewClient(
+		otlptracehttp.WithEndpoint(mc.Endpoint()),
+		otlptracehttp.WithInsecure(),
+		otlptracehttp.WithRetry(otlptracehttp.RetryConfig{Enabled: false}),
+	)
+	ctx := context.Background()
+	exporter, err := otlptrace.New(ctx, driver)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, exporter.Shutdown(ctx))
 	}()
-	<-time.After(time.Second)
-	err = exporter.Shutdown(ctx)
-	assert.NoError(t, err)
-	<-doneCh
+
+	var eErr *otlptrace.ExportError
+	err = exporter.ExportSpans(ctx, otlptracetest.SingleReadOnlySpan())
+	assert.ErrorIs(t, err, otlptrace.ErrRejected)
+	require.True(t, errors.As(err, &eErr))
+	assert.False(t, eErr.Retryable())
+
+	err = exporter.ExportSpans(ctx, otlptracetest.SingleReadOnlySpan())
+	assert.ErrorIs(t, err, otlptrace.ErrRejected)
+	require.True(t, errors.As(err, &eErr))
+	assert.True(t, eErr.Retryable())
+
+	require.NoError(t, mc.Stop())
+	err = exporter.ExportSpans(ctx, otlptracetest.SingleReadOnlySpan())
+	assert.ErrorIs(t, err, otlptrace.ErrDisconnected)
+	require.True(t, errors.As(err, &eErr))
+	assert.True(t, eErr.Retryable())
+}
+
+func TestLastSuccess(t *testing.T) {
+	mc := runMockCollector(t, mockCollectorConfig{
+		InjectHTTPStatus: []int{http.StatusBadRequest},
+	})
+	defer mc.MustStop(t)
+	driver := otlptracehttp.NewClient(
+		otlptracehttp.WithEndpoint(mc.Endpoint()),
+		otlptracehttp.WithInsecure(),
+	)
+	ctx := context.Background()
+	exporter, err := otlptrace.New(ctx, driver)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, exporter.Shutdown(ctx))
+	}()
+
+	assert.True(t, exporter.LastSuccess().IsZero())
+	assert.Error(t, exporter.ExportSpans(ctx, otlptracetest.SingleReadOnlySpan()))
+	assert.True(t, exporter.LastSuccess().IsZero())
+
+	before := time.Now()
+	require.NoError(t, exporter.ExportSpans(ctx, otlptracetest.SingleReadOnlySpan()))
+	last := exporter.LastSuccess()
+	assert.False(t, last.Before(before))
+	assert.False(t, last.After(time.Now()))
+}
+
+func TestLastConnectError(t *testing.T) {
+	mc := runMockCollector(t, mockCollectorConfig{
+		InjectHTTPStatus: []int{http.StatusBadRequest},
+	})
+	defer mc.MustStop(t)
+	driver := otlptracehttp.NewClient(
+		otlptracehttp.WithEndpoint(mc.Endpoint()),
+		otlptracehttp.WithInsecure(),
+	)
+	ctx := context.Background()
+	exporter, err := otlptrace.New(ctx, driver)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, exporter.Shutdown(ctx))
+	}()
+
+	assert.NoError(t, exporter.LastConnectError())
+	exportErr := exporter.ExportSpans(ctx, otlptracetest.SingleReadOnlySpan())
+	require.Error(t, exportErr)
+	assert.Equal(t, exportErr, exporter.LastConnectError())
+	assert.Equal(t, otlptrace.ConnectionStatus{LastError: exportErr}, exporter.ConnectionStatus())
+
+	require.NoError(t, exporter.ExportSpans(ctx, otlptracetest.SingleReadOnlySpan()))
+	assert.NoError(t, exporter.LastConnectError())
+	assert.Equal(t, otlptrace.ConnectionStatus{Connected: true}, exporter.ConnectionStatus())
+}
+
+func TestInMemoryCollector(t *testing.T) {
+	collector := otlptracetest.NewCollector()
+	mux := http.NewServeMux()
+	mux.Handle(otlpconfig.DefaultTracesPath, collector)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	driver := otlptracehttp.NewClient(
+		otlptracehttp.WithEndpoint(strings.TrimPrefix(srv.URL, "http://")),
+		otlptracehttp.WithInsecure(),
+		otlptracehttp.WithCompression(otlptracehttp.GzipCompression),
+		otlptracehttp.WithRetry(otlptracehttp.RetryConfig{Enabled: false}),
+	)
+	ctx := context.Background()
+	exporter, err := otlptrace.New(ctx, driver)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, exporter.Shutdown(ctx))
+	}()
+
+	collector.SetResponseError(codes.InvalidArgument)
+	assert.Error(t, exporter.ExportSpans(ctx, otlptracetest.SingleReadOnlySpan()))
+	assert.Empty(t, collector.ResourceSpans())
+
+	collector.SetResponseError(codes.OK)
+	require.NoError(t, exporter.ExportSpans(ctx, otlptracetest.SingleReadOnlySpan()))
+	require.Len(t, collector.ResourceSpans(), 1)
+	assert.Equal(t, "foo", collector.ResourceSpans()[0].InstrumentationLibrarySpans[0].Spans[0].Name)
+}
+
+func TestPayloadSizeObserver(t *testing.T) {
+	for _, tt := range []struct {
+		name        string
+		compression otlptracehttp.Compression
+	}{
+		{name: "uncompressed", compression: otlptracehttp.NoCompression},
+		{name: "gzip", compression: otlptracehttp.GzipCompression},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var sent []int
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, err := ioutil.ReadAll(r.Body)
+				assert.NoError(t, err)
+				sent = append(sent, len(body))
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer srv.Close()
+
+			var observed []otlptrace.PayloadSize
+			ctx := context.Background()
+			exporter, err := otlptrace.New(ctx, otlptracehttp.NewClient(
+				otlptracehttp.WithEndpoint(strings.TrimPrefix(srv.URL, "http://")),
+				otlptracehttp.WithInsecure(),
+				otlptracehttp.WithCompression(tt.compression),
+				otlptracehttp.WithPayloadSizeObserver(func(size otlptrace.PayloadSize) {
+					observed = append(observed, size)
+				}),
+			))
+			require.NoError(t, err)
+			defer func() {
+				assert.NoError(t, exporter.Shutdown(ctx))
+			}()
+
+			spans := tracetest.SpanStubs{{Name: "Span 0"}, {Name: "Span 1"}}.Snapshots()
+			require.NoError(t, exporter.ExportSpans(ctx, spans))
+			require.Len(t, sent, 1)
+			require.Len(t, observed, 1)
+			assert.Equal(t, otlptrace.PayloadSize{
+				Spans:      2,
+				Bytes:      sent[0],
+				Compressed: tt.compression != otlptracehttp.NoCompression,
+			}, observed[0])
+		})
+	}
+}
+
+func TestH2C(t *testing.T) {
+	collector := otlptracetest.NewCollector()
+	var mu sync.Mutex
+	var protos []string
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		protos = append(protos, r.Proto)
+		mu.Unlock()
+		collector.ServeHTTP(w, r)
+	})
+	srv := httptest.NewServer(h2c.NewHandler(handler, &http2.Server{}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	exporter, err := otlptrace.New(ctx, otlptracehttp.NewClient(
+		otlptracehttp.WithEndpoint(strings.TrimPrefix(srv.URL, "http://")),
+		otlptracehttp.WithInsecure(),
+		otlptracehttp.WithH2C(),
+	))
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, exporter.Shutdown(ctx))
+	}()
+
+	require.NoError(t, exporter.ExportSpans(ctx, otlptracetest.SingleReadOnlySpan()))
+	require.NoError(t, exporter.ExportSpans(ctx, otlptracetest.SingleReadOnlySpan()))
+	assert.Equal(t, []string{"HTTP/2.0", "HTTP/2.0"}, protos)
+	assert.Len(t, collector.Requests(), 2)
+}
+
+func TestIdempotencyKeys(t *testing.T) {
+	collector := otlptracetest.NewCollector()
+	var mu sync.Mutex
+	var keys []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+		first := len(keys) == 1
+		mu.Unlock()
+		if first {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		collector.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	exporter, err := otlptrace.New(ctx, otlptracehttp.NewClient(
+		otlptracehttp.WithEndpoint(strings.TrimPrefix(srv.URL, "http://")),
+		otlptracehttp.WithInsecure(),
+		otlptracehttp.WithIdempotencyKeys("Idempotency-Key"),
+		otlptracehttp.WithRetry(otlptracehttp.RetryConfig{
+			Enabled:         true,
+			InitialInterval: time.Millisecond,
+			MaxInterval:     time.Millisecond,
+			MaxElapsedTime:  10 * time.Second,
+		}),
+	))
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, exporter.Shutdown(ctx))
+	}()
+
+	// The retry of the rejected request has the same key.
+	require.NoError(t, exporter.ExportSpans(ctx, otlptracetest.SingleReadOnlySpan()))
+	require.Len(t, keys, 2)
+	assert.NotEmpty(t, keys[0])
+	assert.Equal(t, keys[0], keys[1])
+	assert.Len(t, collector.Requests(), 1)
+
+	// Another request has another key.
+	require.NoError(t, exporter.ExportSpans(ctx, otlptracetest.SingleReadOnlySpan()))
+	require.Len(t, keys, 3)
+	assert.NotEqual(t, keys[0], keys[2])
+}
+
+// connectProxy is an HTTP proxy tunneling CONNECT requests to their target.
+type connectProxy struct {
+	mu      sync.Mutex
+	tunnels int
+}
+
+func (p *connectProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodConnect {
+		http.Error(w, "only CONNECT is supported", http.StatusMethodNotAllowed)
+		return
+	}
+	upstream, err := net.Dial("tcp", r.Host)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	conn, _, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		_ = upstream.Close()
+		return
+	}
+	p.mu.Lock()
+	p.tunnels++
+	p.mu.Unlock()
+	go func() {
+		defer upstream.Close()
+		defer conn.Close()
+		go func() { _, _ = io.Copy(upstream, conn) }()
+		_, _ = io.Copy(conn, upstream)
+	}()
+}
+
+func TestWithProxy(t *testing.T) {
+	mc := runMockCollector(t, mockCollectorConfig{WithTLS: true})
+	defer mc.MustStop(t)
+
+	proxy := &connectProxy{}
+	proxySrv := httptest.NewServer(proxy)
+	defer proxySrv.Close()
+	proxyURL, err := url.Parse(proxySrv.URL)
+	require.NoError(t, err)
+
+	driver := otlptracehttp.NewClient(
+		otlptracehttp.WithEndpoint(mc.Endpoint()),
+		otlptracehttp.WithTLSClientConfig(mc.ClientTLSConfig()),
+		otlptracehttp.WithProxy(http.ProxyURL(proxyURL)),
+	)
+	ctx := context.Background()
+	exporter, err := otlptrace.New(ctx, driver)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, exporter.Shutdown(ctx))
+	}()
+	require.NoError(t, exporter.ExportSpans(ctx, otlptracetest.SingleReadOnlySpan()))
+	assert.Len(t, mc.GetSpans(), 1)
+
+	proxy.mu.Lock()
+	defer proxy.mu.Unlock()
+	assert.Equal(t, 1, proxy.tunnels)
+}
+
+type countingTransport struct {
+	mu       sync.Mutex
+	requests int
+}
+
+func (c *countingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	c.mu.Lock()
+	c.requests++
+	c.mu.Unlock()
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func TestWithHTTPClient(t *testing.T) {
+	mc := runMockCollector(t, mockCollectorConfig{})
+	defer mc.MustStop(t)
+
+	transport := &countingTransport{}
+	driver := otlptracehttp.NewClient(
+		otlptracehttp.WithEndpoint(mc.Endpoint()),
+		otlptracehttp.WithInsecure(),
+		otlptracehttp.WithHTTPClient(&http.Client{Transport: transport}),
+		// Ignored in favor of the client transport.
+		otlptracehttp.WithProxy(func(*http.Request) (*url.URL, error) {
+			return nil, errors.New("proxy must not be used")
+		}),
+	)
+	ctx := context.Background()
+	exporter, err := otlptrace.New(ctx, driver)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, exporter.Shutdown(ctx))
+	}()
+	require.NoError(t, exporter.ExportSpans(ctx, otlptracetest.SingleReadOnlySpan()))
+	assert.Len(t, mc.GetSpans(), 1)
+
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+	assert.Equal(t, 1, transport.requests)
+}
+
+func TestEmptyData(t *testing.T) {
+	mcCfg := mockCollectorConfig{}
+	mc := runMockCollector(t, mcCfg)
+	defer mc.MustStop(t)
+	driver := otlptracehttp.NewClient(
+		otlptracehttp.WithEndpoint(mc.Endpoint()),
+		otlptracehttp.WithInsecure(),
+	)
+	ctx := context.Background()
+	exporter, err := otlptrace.New(ctx, driver)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, exporter.Shutdown(ctx))
+	}()
+	assert.NoError(t, err)
+	err = exporter.ExportSpans(ctx, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, mc.GetSpans())
+}
+
+func TestCancelledContext(t *testing.T) {
+	mcCfg := mockCollectorConfig{}
+	mc := runMockCollector(t, mcCfg)
+	defer mc.MustStop(t)
+	driver := otlptracehttp.NewClient(
+		otlptracehttp.WithEndpoint(mc.Endpoint()),
+		otlptracehttp.WithInsecure(),
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	exporter, err := otlptrace.New(ctx, driver)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, exporter.Shutdown(context.Background()))
+	}()
+	cancel()
+	err = exporter.ExportSpans(ctx, otlptracetest.SingleReadOnlySpan())
+	assert.Error(t, err)
+	assert.Empty(t, mc.GetSpans())
+}
+
+func TestDeadlineContext(t *testing.T) {
+	statuses := make([]int, 0, 5)
+	for i := 0; i < cap(statuses); i++ {
+		statuses = append(statuses, http.StatusTooManyRequests)
+	}
+	mcCfg := mockCollectorConfig{
+		InjectHTTPStatus: statuses,
+	}
+	mc := runMockCollector(t, mcCfg)
+	defer mc.MustStop(t)
+	driver := otlptracehttp.NewClient(
+		otlptracehttp.WithEndpoint(mc.Endpoint()),
+		otlptracehttp.WithInsecure(),
+		otlptracehttp.WithRetry(otlptracehttp.RetryConfig{
+			Enabled:         true,
+			InitialInterval: 1 * time.Hour,
+			MaxInterval:     1 * time.Hour,
+			// Never stop retry of retry-able status.
+			MaxElapsedTime: 0,
+		}),
+	)
+	ctx := context.Background()
+	exporter, err := otlptrace.New(ctx, driver)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, exporter.Shutdown(context.Background()))
+	}()
+	ctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	err = exporter.ExportSpans(ctx, otlptracetest.SingleReadOnlySpan())
+	assert.Error(t, err)
+	assert.Empty(t, mc.GetSpans())
+}
+
+func TestStopWhileExporting(t *testing.T) {
+	statuses := make([]int, 0, 5)
+	for i := 0; i < cap(statuses); i++ {
+		statuses = append(statuses, http.StatusTooManyRequests)
+	}
+	mcCfg := mockCollectorConfig{
+		InjectHTTPStatus: statuses,
+	}
+	mc := runMockCollector(t, mcCfg)
+	defer mc.MustStop(t)
+	driver := otlptracehttp.NewClient(
+		otlptracehttp.WithEndpoint(mc.Endpoint()),
+		otlptracehttp.WithInsecure(),
+		otlptracehttp.WithRetry(otlptracehttp.RetryConfig{
+			Enabled:         true,
+			InitialInterval: 1 * time.Hour,
+			MaxInterval:     1 * time.Hour,
+			// Never stop retry of retry-able status.
+			MaxElapsedTime: 0,
+		}),
+	)
+	ctx := context.Background()
+	exporter, err := otlptrace.New(ctx, driver)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, exporter.Shutdown(ctx))
+	}()
+	doneCh := make(chan struct{})
+	go func() {
+		err := exporter.ExportSpans(ctx, otlptracetest.SingleReadOnlySpan())
+		assert.Error(t, err)
+		assert.Empty(t, mc.GetSpans())
+		close(doneCh)
+	}()
+	<-time.After(time.Second)
+	// The export never completes, it is interrupted once the shutdown
+	// deadline is exceeded.
+	stopCtx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	err = exporter.Shutdown(stopCtx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	<-doneCh
+}
+
+func TestStopWaitsForExport(t *testing.T) {
+	mc := runMockCollector(t, mockCollectorConfig{
+		InjectDelay: 500 * time.Millisecond,
+	})
+	defer mc.MustStop(t)
+	driver := otlptracehttp.NewClient(
+		otlptracehttp.WithEndpoint(mc.Endpoint()),
+		otlptracehttp.WithInsecure(),
+	)
+	ctx := context.Background()
+	exporter, err := otlptrace.New(ctx, driver)
+	require.NoError(t, err)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- exporter.ExportSpans(ctx, otlptracetest.SingleReadOnlySpan())
+	}()
+	<-time.After(100 * time.Millisecond)
+	stopCtx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	assert.NoError(t, exporter.Shutdown(stopCtx))
+	assert.NoError(t, <-errCh)
+	assert.Len(t, mc.GetSpans(), 1)
+
+	assert.Error(t, driver.UploadTraces(ctx, singleProtoSpan()))
+}
+
+func TestStopDrainsExport(t *testing.T) {
+	mc := runMockCollector(t, mockCollectorConfig{
+		InjectDelay: 500 * time.Millisecond,
+	})
+	defer mc.MustStop(t)
+	driver := otlptracehttp.NewClient(
+		otlptracehttp.WithEndpoint(mc.Endpoint()),
+		otlptracehttp.WithInsecure(),
+		otlptracehttp.WithDrainOnStop(10*time.Second),
+	)
+	ctx := context.Background()
+	exporter, err := otlptrace.New(ctx, driver)
+	require.NoError(t, err)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- exporter.ExportSpans(ctx, otlptracetest.SingleReadOnlySpan())
+	}()
+	<-time.After(100 * time.Millisecond)
+	// The export completes after the shutdown deadline is exceeded.
+	stopCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, exporter.Shutdown(stopCtx), context.DeadlineExceeded)
+	assert.NoError(t, <-errCh)
+	assert.Len(t, mc.GetSpans(), 1)
+}
+
+func TestPartialSuccess(t *testing.T) {
+	mc := runMockCollector(t, mockCollectorConfig{
+		RejectedSpans:     2,
+		PartialSuccessMsg: "invalid span",
+	})
+	defer mc.MustStop(t)
+
+	var (
+		gotRejected int64
+		gotMsg      string
+	)
+	driver := otlptracehttp.NewClient(
+		otlptracehttp.WithEndpoint(mc.Endpoint()),
+		otlptracehttp.WithInsecure(),
+		otlptracehttp.WithPartialSuccessHandler(func(rejected int64, msg string) {
+			gotRejected, gotMsg = rejected, msg
+		}),
+	)
+	ctx := context.Background()
+	exporter, err := otlptrace.New(ctx, driver)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, exporter.Shutdown(ctx))
+	}()
+	err = exporter.ExportSpans(ctx, otlptracetest.SingleReadOnlySpan())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), gotRejected)
+	assert.Equal(t, "invalid span", gotMsg)
+	assert.Len(t, mc.GetSpans(), 1)
+}
+
+func TestPartialSuccessJSON(t *testing.T) {
+	mc := runMockCollector(t, mockCollectorConfig{
+		RejectedSpans:     2,
+		PartialSuccessMsg: "invalid span",
+	})
+	defer mc.MustStop(t)
+
+	var (
+		gotRejected int64
+		gotMsg      string
+	)
+	driver := otlptracehttp.NewClient(
+		otlptracehttp.WithEndpoint(mc.Endpoint()),
+		otlptracehttp.WithInsecure(),
+		otlptracehttp.WithMarshal(otlptracehttp.MarshalJSON),
+		otlptracehttp.WithPartialSuccessHandler(func(rejected int64, msg string) {
+			gotRejected, gotMsg = rejected, msg
+		}),
+	)
+	ctx := context.Background()
+	exporter, err := otlptrace.New(ctx, driver)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, exporter.Shutdown(ctx))
+	}()
+	err = exporter.ExportSpans(ctx, otlptracetest.SingleReadOnlySpan())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), gotRejected)
+	assert.Equal(t, "invalid span", gotMsg)
+	assert.Len(t, mc.GetSpans(), 1)
+}
+
+func TestInvalidSuccessResponse(t *testing.T) {
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "application/x-protobuf")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("invalid"))
+	}))
+	defer srv.Close()
+
+	var errs []error
+	ctx := context.Background()
+	exporter, err := otlptrace.New(ctx, otlptracehttp.NewClient(
+		otlptracehttp.WithEndpoint(strings.TrimPrefix(srv.URL, "http://")),
+		otlptracehttp.WithInsecure(),
+		otlptracehttp.WithRetry(otlptracehttp.RetryConfig{
+			Enabled:         true,
+			InitialInterval: time.Millisecond,
+			MaxInterval:     time.Millisecond,
+			MaxElapsedTime:  time.Second,
+		}),
+		otlptracehttp.WithErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+			errs = append(errs, err)
+		})),
+	))
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, exporter.Shutdown(ctx))
+	}()
+
+	// The spans were accepted, they are not sent again.
+	assert.NoError(t, exporter.ExportSpans(ctx, otlptracetest.SingleReadOnlySpan()))
+	assert.Equal(t, 1, requests)
+	require.Len(t, errs, 1)
+	assert.Contains(t, errs[0].Error(), "failed to decode the export response")
+}
+
+func TestExportInterceptor(t *testing.T) {
+	mc := runMockCollector(t, mockCollectorConfig{})
+	defer mc.MustStop(t)
+
+	var calls []string
+	driver := otlptracehttp.NewClient(
+		otlptracehttp.WithEndpoint(mc.Endpoint()),
+		otlptracehttp.WithInsecure(),
+		otlptracehttp.WithExportInterceptor(func(ctx context.Context, req *collectortracepb.ExportTraceServiceRequest, invoker otlptrace.ExportInvoker) error {
+			calls = append(calls, "first")
+			return invoker(ctx, req)
+		}),
+		otlptracehttp.WithExportInterceptor(func(ctx context.Context, req *collectortracepb.ExportTraceServiceRequest, invoker otlptrace.ExportInvoker) error {
+			calls = append(calls, "second")
+			// Send the spans twice in a new request.
+			return invoker(ctx, &collectortracepb.ExportTraceServiceRequest{
+				ResourceSpans: append(req.ResourceSpans, req.ResourceSpans...),
+			})
+		}),
+	)
+	ctx := context.Background()
+	exporter, err := otlptrace.New(ctx, driver)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, exporter.Shutdown(ctx))
+	}()
+
+	require.NoError(t, exporter.ExportSpans(ctx, otlptracetest.SingleReadOnlySpan()))
+	assert.Equal(t, []string{"first", "second"}, calls)
+	assert.Len(t, mc.GetSpans(), 2)
+}
+
+func TestMaxConcurrentExports(t *testing.T) {
+	mc := runMockCollector(t, mockCollectorConfig{})
+	defer mc.MustStop(t)
+
+	var (
+		mu               sync.Mutex
+		running, maxSeen int
+	)
+	release := make(chan struct{})
+	driver := otlptracehttp.NewClient(
+		otlptracehttp.WithEndpoint(mc.Endpoint()),
+		otlptracehttp.WithInsecure(),
+		otlptracehttp.WithMaxConcurrentExports(2),
+		otlptracehttp.WithExportInterceptor(func(ctx context.Context, req *collectortracepb.ExportTraceServiceRequest, invoker otlptrace.ExportInvoker) error {
+			mu.Lock()
+			running++
+			if running > maxSeen {
+				maxSeen = running
+			}
+			mu.Unlock()
+			<-release
+			mu.Lock()
+			running--
+			mu.Unlock()
+			return invoker(ctx, req)
+		}),
+	)
+	ctx := context.Background()
+	exporter, err := otlptrace.New(ctx, driver)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, exporter.Shutdown(ctx))
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, exporter.ExportSpans(ctx, otlptracetest.SingleReadOnlySpan()))
+		}()
+	}
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return running == 2
+	}, time.Second, time.Millisecond)
+
+	// The exports waiting for a slot return once their context is done.
+	tCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, exporter.ExportSpans(tCtx, otlptracetest.SingleReadOnlySpan()), context.DeadlineExceeded)
+
+	close(release)
+	wg.Wait()
+	assert.Equal(t, 2, maxSeen)
+	assert.Len(t, mc.GetSpans(), 6)
+}
+
+func TestResourceAttributes(t *testing.T) {
+	mc := runMockCollector(t, mockCollectorConfig{})
+	defer mc.MustStop(t)
+	driver := otlptracehttp.NewClient(
+		otlptracehttp.WithEndpoint(mc.Endpoint()),
+		otlptracehttp.WithInsecure(),
+		otlptracehttp.WithResourceAttributes([]*commonpb.KeyValue{
+			{Key: "a", Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: "c"}}},
+		}, true),
+	)
+	ctx := context.Background()
+	require.NoError(t, driver.Start(ctx))
+	defer func() {
+		assert.NoError(t, driver.Stop(ctx))
+	}()
+
+	rss := []*tracepb.ResourceSpans{{
+		Resource: &resourcepb.Resource{Attributes: []*commonpb.KeyValue{
+			{Key: "a", Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: "b"}}},
+		}},
+		InstrumentationLibrarySpans: []*tracepb.InstrumentationLibrarySpans{{
+			Spans: []*tracepb.Span{{Name: "span"}},
+		}},
+	}}
+	require.NoError(t, driver.UploadTraces(ctx, rss))
+	// The uploaded resource is not modified.
+	assert.Equal(t, "b", rss[0].Resource.Attributes[0].Value.GetStringValue())
+
+	got := mc.GetResourceSpans()
+	require.Len(t, got, 1)
+	require.Len(t, got[0].Resource.Attributes, 1)
+	assert.Equal(t, "a", got[0].Resource.Attributes[0].Key)
+	assert.Equal(t, "c", got[0].Resource.Attributes[0].Value.GetStringValue())
+}
+
+func TestSpanTransform(t *testing.T) {
+	mc := runMockCollector(t, mockCollectorConfig{})
+	defer mc.MustStop(t)
+	driver := otlptracehttp.NewClient(
+		otlptracehttp.WithEndpoint(mc.Endpoint()),
+		otlptracehttp.WithInsecure(),
+		otlptracehttp.WithSpanTransform(func(s *tracepb.Span) {
+			s.Attributes = nil
+		}),
+		otlptracehttp.WithSpanTransform(func(s *tracepb.Span) {
+			s.Name = "redacted"
+		}),
+	)
+	ctx := context.Background()
+	require.NoError(t, driver.Start(ctx))
+	defer func() {
+		assert.NoError(t, driver.Stop(ctx))
+	}()
+
+	rss := []*tracepb.ResourceSpans{{
+		InstrumentationLibrarySpans: []*tracepb.InstrumentationLibrarySpans{{
+			Spans: []*tracepb.Span{{
+				Name: "span",
+				Attributes: []*commonpb.KeyValue{
+					{Key: "user", Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: "secret"}}},
+				},
+			}},
+		}},
+	}}
+	require.NoError(t, driver.UploadTraces(ctx, rss))
+	// The uploaded span is not modified.
+	assert.Equal(t, "span", rss[0].InstrumentationLibrarySpans[0].Spans[0].Name)
+	assert.Len(t, rss[0].InstrumentationLibrarySpans[0].Spans[0].Attributes, 1)
+
+	got := mc.GetSpans()
+	require.Len(t, got, 1)
+	assert.Equal(t, "redacted", got[0].Name)
+	assert.Empty(t, got[0].Attributes)
+}
+
+func TestStrictValidation(t *testing.T) {
+	mc := runMockCollector(t, mockCollectorConfig{})
+	defer mc.MustStop(t)
+	driver := otlptracehttp.NewClient(
+		otlptracehttp.WithEndpoint(mc.Endpoint()),
+		otlptracehttp.WithInsecure(),
+		otlptracehttp.WithStrictValidation(),
+	)
+	ctx := context.Background()
+	require.NoError(t, driver.Start(ctx))
+	defer func() {
+		assert.NoError(t, driver.Stop(ctx))
+	}()
+
+	traceID := []byte{0x1, 0x2, 0x3, 0x4, 0x5, 0x6, 0x7, 0x8, 0x9, 0xa, 0xb, 0xc, 0xd, 0xe, 0xf, 0x10}
+	spanID := []byte{0x1, 0x2, 0x3, 0x4, 0x5, 0x6, 0x7, 0x8}
+	rss := []*tracepb.ResourceSpans{{
+		InstrumentationLibrarySpans: []*tracepb.InstrumentationLibrarySpans{{
+			Spans: []*tracepb.Span{
+				{Name: "valid", TraceId: traceID, SpanId: spanID},
+				{Name: "short trace ID", TraceId: traceID[:8], SpanId: spanID},
+				{Name: "invalid trace state", TraceId: traceID, SpanId: spanID, TraceState: "=value"},
+			},
+		}},
+	}}
+	require.NoError(t, driver.UploadTraces(ctx, rss))
+	got := mc.GetSpans()
+	require.Len(t, got, 1)
+	assert.Equal(t, "valid", got[0].Name)
+
+	// A batch of malformed spans only is not sent.
+	require.NoError(t, driver.UploadTraces(ctx, []*tracepb.ResourceSpans{{
+		InstrumentationLibrarySpans: []*tracepb.InstrumentationLibrarySpans{{
+			Spans: []*tracepb.Span{{Name: "missing IDs"}},
+		}},
+	}}))
+	assert.Len(t, mc.GetSpans(), 1)
+}
+
+func TestStrictValidationForceFlush(t *testing.T) {
+	mc := runMockCollector(t, mockCollectorConfig{
+		InjectHTTPStatus: []int{http.StatusBadRequest},
+	})
+	defer mc.MustStop(t)
+	driver := otlptracehttp.NewClient(
+		otlptracehttp.WithEndpoint(mc.Endpoint()),
+		otlptracehttp.WithInsecure(),
+		otlptracehttp.WithStrictValidation(),
+	)
+	ctx := context.Background()
+	exporter, err := otlptrace.New(ctx, driver)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, exporter.Shutdown(ctx))
+	}()
+
+	// The empty request of ForceFlush is still sent.
+	assert.Error(t, exporter.ForceFlush(ctx))
+	assert.NoError(t, exporter.ForceFlush(ctx))
+}
+
+func TestExportSampler(t *testing.T) {
+	mc := runMockCollector(t, mockCollectorConfig{})
+	defer mc.MustStop(t)
+	mp := metrictest.NewMeterProvider()
+	driver := otlptracehttp.NewClient(
+		otlptracehttp.WithEndpoint(mc.Endpoint()),
+		otlptracehttp.WithInsecure(),
+		otlptracehttp.WithSelfObservability(mp),
+		otlptracehttp.WithExportSampler(func(s *tracepb.Span) bool {
+			return s.Name != "shed"
+		}),
+	)
+	ctx := context.Background()
+	require.NoError(t, driver.Start(ctx))
+	defer func() {
+		assert.NoError(t, driver.Stop(ctx))
+	}()
+
+	require.NoError(t, driver.UploadTraces(ctx, []*tracepb.ResourceSpans{
+		{
+			InstrumentationLibrarySpans: []*tracepb.InstrumentationLibrarySpans{
+				{Spans: []*tracepb.Span{{Name: "kept"}, {Name: "shed"}}},
+				{Spans: []*tracepb.Span{{Name: "shed"}}},
+			},
+		},
+		{
+			InstrumentationLibrarySpans: []*tracepb.InstrumentationLibrarySpans{
+				{Spans: []*tracepb.Span{{Name: "shed"}}},
+			},
+		},
+	}))
+	// The resources and instrumentation libraries left without spans are
+	// omitted.
+	rss := mc.GetResourceSpans()
+	require.Len(t, rss, 1)
+	require.Len(t, rss[0].InstrumentationLibrarySpans, 1)
+	got := mc.GetSpans()
+	require.Len(t, got, 1)
+	assert.Equal(t, "kept", got[0].Name)
+
+	// A batch of dropped spans only is not sent.
+	require.NoError(t, driver.UploadTraces(ctx, []*tracepb.ResourceSpans{{
+		InstrumentationLibrarySpans: []*tracepb.InstrumentationLibrarySpans{{
+			Spans: []*tracepb.Span{{Name: "shed"}},
+		}},
+	}}))
+	assert.Len(t, mc.GetResourceSpans(), 1)
+
+	var sampledOut int64
+	for _, m := range metrictest.AsStructs(mp.MeasurementBatches) {
+		if m.Name == "otlp.exporter.sampled_out_spans" {
+			sampledOut += m.Number.AsInt64()
+		}
+	}
+	assert.Equal(t, int64(4), sampledOut)
+}
+
+func TestStableOrdering(t *testing.T) {
+	mc := runMockCollector(t, mockCollectorConfig{})
+	defer mc.MustStop(t)
+	var requests [][]byte
+	driver := otlptracehttp.NewClient(
+		otlptracehttp.WithEndpoint(mc.Endpoint()),
+		otlptracehttp.WithInsecure(),
+		otlptracehttp.WithStableOrdering(),
+		otlptracehttp.WithExportInterceptor(func(ctx context.Context, req *collectortracepb.ExportTraceServiceRequest, invoker otlptrace.ExportInvoker) error {
+			b, err := proto.MarshalOptions{Deterministic: true}.Marshal(req)
+			require.NoError(t, err)
+			requests = append(requests, b)
+			return invoker(ctx, req)
+		}),
+	)
+	ctx := context.Background()
+	require.NoError(t, driver.Start(ctx))
+	defer func() {
+		assert.NoError(t, driver.Stop(ctx))
+	}()
+
+	resourceSpans := func(service string, spans ...*tracepb.Span) *tracepb.ResourceSpans {
+		return &tracepb.ResourceSpans{
+			Resource: &resourcepb.Resource{Attributes: []*commonpb.KeyValue{{
+				Key:   "service.name",
+				Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: service}},
+			}}},
+			InstrumentationLibrarySpans: []*tracepb.InstrumentationLibrarySpans{{Spans: spans}},
+		}
+	}
+	first := &tracepb.Span{Name: "first", StartTimeUnixNano: 1}
+	second := &tracepb.Span{Name: "second", StartTimeUnixNano: 2}
+	require.NoError(t, driver.UploadTraces(ctx, []*tracepb.ResourceSpans{
+		resourceSpans("b", second, first),
+		resourceSpans("a", first),
+	}))
+	require.NoError(t, driver.UploadTraces(ctx, []*tracepb.ResourceSpans{
+		resourceSpans("a", first),
+		resourceSpans("b", first, second),
+	}))
+	require.Len(t, requests, 2)
+	assert.Equal(t, requests[0], requests[1])
+}
+
+func TestTLSHandshakeTimeout(t *testing.T) {
+	// The collector accepts the connections but never completes the TLS
+	// handshakes.
+	ln, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	go func() {
+		var conns []net.Conn
+		defer func() {
+			for _, conn := range conns {
+				_ = conn.Close()
+			}
+		}()
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conns = append(conns, conn)
+		}
+	}()
+
+	driver := otlptracehttp.NewClient(
+		otlptracehttp.WithEndpoint(ln.Addr().String()),
+		otlptracehttp.WithTimeout(10*time.Second),
+		otlptracehttp.WithTLSHandshakeTimeout(50*time.Millisecond),
+		otlptracehttp.WithRetry(otlptracehttp.RetryConfig{Enabled: false}),
+	)
+	ctx := context.Background()
+	require.NoError(t, driver.Start(ctx))
+	defer func() {
+		assert.NoError(t, driver.Stop(ctx))
+	}()
+
+	start := time.Now()
+	err = driver.UploadTraces(ctx, singleProtoSpan())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "TLS handshake timeout")
+	assert.Less(t, int64(time.Since(start)), int64(5*time.Second))
+}
+
+func TestResponseHeaderTimeout(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	driver := otlptracehttp.NewClient(
+		otlptracehttp.WithEndpoint(strings.TrimPrefix(srv.URL, "http://")),
+		otlptracehttp.WithInsecure(),
+		otlptracehttp.WithTimeout(10*time.Second),
+		otlptracehttp.WithResponseHeaderTimeout(50*time.Millisecond),
+		otlptracehttp.WithRetry(otlptracehttp.RetryConfig{Enabled: false}),
+	)
+	ctx := context.Background()
+	require.NoError(t, driver.Start(ctx))
+	defer func() {
+		assert.NoError(t, driver.Stop(ctx))
+	}()
+
+	start := time.Now()
+	err := driver.UploadTraces(ctx, singleProtoSpan())
+	require.Error(t, err)
+	assert.ErrorIs(t, err, otlptrace.ErrTimeout)
+	assert.Contains(t, err.Error(), "timeout awaiting response headers")
+	assert.Less(t, int64(time.Since(start)), int64(5*time.Second))
+}
+
+// singleProtoSpan returns a batch of a single span to upload.
+func singleProtoSpan() []*tracepb.ResourceSpans {
+	return []*tracepb.ResourceSpans{{
+		InstrumentationLibrarySpans: []*tracepb.InstrumentationLibrarySpans{{
+			Spans: []*tracepb.Span{{Name: "span"}},
+		}},
+	}}
+}
+
+func TestUploadEmptyBatch(t *testing.T) {
+	mc := runMockCollector(t, mockCollectorConfig{
+		InjectHTTPStatus: []int{http.StatusBadRequest},
+	})
+	driver := otlptracehttp.NewClient(
+		otlptracehttp.WithEndpoint(mc.Endpoint()),
+		otlptracehttp.WithInsecure(),
+		otlptracehttp.WithErrorHistory(1),
+	)
+	ctx := context.Background()
+	exporter, err := otlptrace.New(ctx, driver)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, exporter.Shutdown(ctx))
+	}()
+
+	// No request is sent, the injected error is returned by the next one.
+	assert.NoError(t, driver.UploadTraces(ctx, nil))
+	assert.NoError(t, driver.UploadTraces(ctx, []*tracepb.ResourceSpans{}))
+	assert.Empty(t, exporter.RecentErrors())
+	assert.Error(t, exporter.ForceFlush(ctx))
+
+	// Nothing is sent once the collector is unreachable either.
+	mc.MustStop(t)
+	assert.NoError(t, driver.UploadTraces(ctx, nil))
+	assert.Len(t, exporter.RecentErrors(), 1)
+}
+
+func TestErrorHistory(t *testing.T) {
+	mc := runMockCollector(t, mockCollectorConfig{
+		InjectHTTPStatus: []int{http.StatusBadRequest, http.StatusBadRequest},
+	})
+	defer mc.MustStop(t)
+	exporter, err := otlptrace.New(context.Background(), otlptracehttp.NewClient(
+		otlptracehttp.WithEndpoint(mc.Endpoint()),
+		otlptracehttp.WithInsecure(),
+		otlptracehttp.WithErrorHistory(5),
+	))
+	require.NoError(t, err)
+	ctx := context.Background()
+	defer func() {
+		assert.NoError(t, exporter.Shutdown(ctx))
+	}()
+
+	assert.Error(t, exporter.ExportSpans(ctx, otlptracetest.SingleReadOnlySpan()))
+	assert.Error(t, exporter.ExportSpans(ctx, otlptracetest.SingleReadOnlySpan()))
+	require.NoError(t, exporter.ExportSpans(ctx, otlptracetest.SingleReadOnlySpan()))
+
+	recent := exporter.RecentErrors()
+	require.Len(t, recent, 2)
+	for _, r := range recent {
+		assert.ErrorIs(t, r.Err, otlptrace.ErrRejected)
+		assert.False(t, r.Time.IsZero())
+	}
+}
+
+func TestExportCoalescingFlushesOnShutdown(t *testing.T) {
+	mc := runMockCollector(t, mockCollectorConfig{})
+	defer mc.MustStop(t)
+	exporter, err := otlptrace.New(context.Background(), otlptracehttp.NewClient(
+		otlptracehttp.WithEndpoint(mc.Endpoint()),
+		otlptracehttp.WithInsecure(),
+		otlptracehttp.WithExportCoalescing(time.Hour, 0),
+	))
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	// The exports return once their context is done, their spans are
+	// still held.
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	assert.ErrorIs(t, exporter.ExportSpans(cancelled, otlptracetest.SingleReadOnlySpan()), context.Canceled)
+	assert.ErrorIs(t, exporter.ExportSpans(cancelled, otlptracetest.SingleReadOnlySpan()), context.Canceled)
+	assert.Empty(t, mc.GetSpans())
+
+	require.NoError(t, exporter.Shutdown(ctx))
+	assert.Len(t, mc.GetSpans(), 2)
+}
+
+func TestExportCoalescingForceFlush(t *testing.T) {
+	mc := runMockCollector(t, mockCollectorConfig{})
+	defer mc.MustStop(t)
+	exporter, err := otlptrace.New(context.Background(), otlptracehttp.NewClient(
+		otlptracehttp.WithEndpoint(mc.Endpoint()),
+		otlptracehttp.WithInsecure(),
+		otlptracehttp.WithExportCoalescing(time.Hour, 0),
+	))
+	require.NoError(t, err)
+	ctx := context.Background()
+	defer func() {
+		assert.NoError(t, exporter.Shutdown(ctx))
+	}()
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	assert.ErrorIs(t, exporter.ExportSpans(cancelled, otlptracetest.SingleReadOnlySpan()), context.Canceled)
+	require.NoError(t, exporter.ForceFlush(ctx))
+	assert.Len(t, mc.GetSpans(), 1)
+}
+
+func TestInsecureSkipVerify(t *testing.T) {
+	mc := runMockCollector(t, mockCollectorConfig{WithTLS: true})
+	defer mc.MustStop(t)
+	var errs []error
+	exporter, err := otlptrace.New(context.Background(), otlptracehttp.NewClient(
+		otlptracehttp.WithEndpoint(mc.Endpoint()),
+		otlptracehttp.WithInsecureSkipVerify(),
+		otlptracehttp.WithErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+			errs = append(errs, err)
+		})),
+	))
+	require.NoError(t, err)
+	ctx := context.Background()
+	// The collector only accepts TLS connections, its self-signed
+	// certificate is not verified.
+	require.NoError(t, exporter.ExportSpans(ctx, otlptracetest.SingleReadOnlySpan()))
+	require.NoError(t, exporter.Shutdown(ctx))
+	assert.Len(t, mc.GetSpans(), 1)
+	require.Len(t, errs, 1)
+	assert.Contains(t, errs[0].Error(), "is not verified")
+
+	// The certificate is verified otherwise.
+	exporter, err = otlptrace.New(ctx, otlptracehttp.NewClient(
+		otlptracehttp.WithEndpoint(mc.Endpoint()),
+		otlptracehttp.WithRetry(otlptracehttp.RetryConfig{Enabled: false}),
+	))
+	require.NoError(t, err)
+	assert.Error(t, exporter.ExportSpans(ctx, otlptracetest.SingleReadOnlySpan()))
+	assert.NoError(t, exporter.Shutdown(ctx))
+}
+
+func TestErrorHandler(t *testing.T) {
+	mc := runMockCollector(t, mockCollectorConfig{})
+	defer mc.MustStop(t)
+	var errs []error
+	handler := otel.ErrorHandlerFunc(func(err error) {
+		errs = append(errs, err)
+	})
+	exporter, err := otlptrace.New(context.Background(), otlptracehttp.NewClient(
+		otlptracehttp.WithEndpoint(mc.Endpoint()),
+		otlptracehttp.WithInsecure(),
+		otlptracehttp.WithMaxExportBatchSpans(1),
+		otlptracehttp.WithOversizeBatchPolicy(otlptracehttp.DropOversizeBatch),
+		otlptracehttp.WithErrorHandler(handler),
+	))
+	require.NoError(t, err)
+	ctx := context.Background()
+	defer func() {
+		assert.NoError(t, exporter.Shutdown(ctx))
+	}()
+
+	require.NoError(t, exporter.ExportSpans(ctx, otlptracetest.SingleReadOnlySpan()))
+	assert.Empty(t, errs)
+	spans := append(otlptracetest.SingleReadOnlySpan(), otlptracetest.SingleReadOnlySpan()...)
+	require.NoError(t, exporter.ExportSpans(ctx, spans))
+	require.Len(t, errs, 1)
+	assert.Contains(t, errs[0].Error(), "dropped 1 spans exceeding the export batch limits")
+}
+
+func TestErrorHandlerEnvironment(t *testing.T) {
+	envStore, err := ottest.SetEnvVariables(map[string]string{
+		"OTEL_EXPORTER_OTLP_COMPRESSION": "unknown",
+	})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, envStore.Restore())
+	}()
+
+	// The errors read from the environment are not sent to the global error
+	// handler.
+	var errs []error
+	client := otlptracehttp.NewClient(
+		otlptracehttp.WithInsecure(),
+		otlptracehttp.WithErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+			errs = append(errs, err)
+		})),
+	)
+	require.NotNil(t, client)
+	require.Len(t, errs, 1)
+	assert.Contains(t, errs[0].Error(), "invalid compression type: 'unknown'")
+}
+
+func TestStatsLogInterval(t *testing.T) {
+	mc := runMockCollector(t, mockCollectorConfig{})
+	defer mc.MustStop(t)
+	summaries := make(chan otlptrace.StatsSummary, 100)
+	handler := otel.ErrorHandlerFunc(func(err error) {
+		var s otlptrace.StatsSummary
+		if errors.As(err, &s) {
+			summaries <- s
+		}
+	})
+	exporter, err := otlptrace.New(context.Background(), otlptracehttp.NewClient(
+		otlptracehttp.WithEndpoint(mc.Endpoint()),
+		otlptracehttp.WithInsecure(),
+		otlptracehttp.WithStatsLogInterval(5*time.Millisecond),
+		otlptracehttp.WithErrorHandler(handler),
+	))
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	require.NoError(t, exporter.ExportSpans(ctx, otlptracetest.SingleReadOnlySpan()))
+	var sent int64
+	for sent < 1 {
+		s := <-summaries
+		assert.True(t, s.Status.Connected)
+		assert.Zero(t, s.DroppedSpans)
+		assert.Zero(t, s.Errors)
+		sent += s.SentSpans
+	}
+	assert.Equal(t, int64(1), sent)
+
+	// The summaries stop with the exporter.
+	require.NoError(t, exporter.Shutdown(ctx))
+	for len(summaries) > 0 {
+		<-summaries
+	}
+	time.Sleep(20 * time.Millisecond)
+	assert.Empty(t, summaries)
+}
+
+func TestStatsLogIntervalBatchLimits(t *testing.T) {
+	mc := runMockCollector(t, mockCollectorConfig{
+		InjectHTTPStatus: []int{http.StatusOK, http.StatusBadRequest},
+	})
+	defer mc.MustStop(t)
+	summaries := make(chan otlptrace.StatsSummary, 100)
+	handler := otel.ErrorHandlerFunc(func(err error) {
+		var s otlptrace.StatsSummary
+		if errors.As(err, &s) {
+			summaries <- s
+		}
+	})
+	driver := otlptracehttp.NewClient(
+		otlptracehttp.WithEndpoint(mc.Endpoint()),
+		otlptracehttp.WithInsecure(),
+		otlptracehttp.WithRetry(otlptracehttp.RetryConfig{Enabled: false}),
+		otlptracehttp.WithMaxExportBatchSpans(2),
+		otlptracehttp.WithMaxExportBatchBytes(512),
+		otlptracehttp.WithStatsLogInterval(5*time.Millisecond),
+		otlptracehttp.WithErrorHandler(handler),
+	)
+	ctx := context.Background()
+	require.NoError(t, driver.Start(ctx))
+	defer func() {
+		assert.NoError(t, driver.Stop(ctx))
+	}()
+
+	// The spans are split in two requests, the oversize one is dropped.
+	spans := []*tracepb.Span{
+		{Name: "a"}, {Name: "b"}, {Name: strings.Repeat("x", 1024)}, {Name: "c"}, {Name: "d"},
+	}
+	protoSpans := []*tracepb.ResourceSpans{{
+		InstrumentationLibrarySpans: []*tracepb.InstrumentationLibrarySpans{{Spans: spans}},
+	}}
+	// The first request is accepted, the second one rejected.
+	require.Error(t, driver.UploadTraces(ctx, protoSpans))
+	require.NoError(t, driver.UploadTraces(ctx, protoSpans))
+
+	var total otlptrace.StatsSummary
+	for total.SentSpans < 6 || total.DroppedSpans < 4 {
+		s := <-summaries
+		total.SentSpans += s.SentSpans
+		total.DroppedSpans += s.DroppedSpans
+		total.Errors += s.Errors
+	}
+	time.Sleep(20 * time.Millisecond)
+	for len(summaries) > 0 {
+		s := <-summaries
+		total.SentSpans += s.SentSpans
+		total.DroppedSpans += s.DroppedSpans
+	}
+	assert.Equal(t, int64(6), total.SentSpans)
+	assert.Equal(t, int64(4), total.DroppedSpans)
+	assert.Equal(t, int64(1), total.Errors)
+}
+
+func TestHealthy(t *testing.T) {
+	var mu sync.Mutex
+	status := http.StatusOK
+	var paths, auths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Equal(t, http.MethodGet, r.Method)
+		paths = append(paths, r.URL.Path)
+		auths = append(auths, r.Header.Get("Authorization"))
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+	endpoint := strings.TrimPrefix(srv.URL, "http://")
+
+	ctx := context.Background()
+	exporter, err := otlptrace.New(ctx, otlptracehttp.NewClient(
+		otlptracehttp.WithEndpoint(endpoint),
+		otlptracehttp.WithInsecure(),
+		otlptracehttp.WithHeaders(map[string]string{"Authorization": "Bearer token"}),
+	))
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, exporter.Shutdown(ctx))
+	}()
+	assert.NoError(t, exporter.Healthy(ctx))
+
+	mu.Lock()
+	status = http.StatusServiceUnavailable
+	mu.Unlock()
+	err = exporter.Healthy(ctx)
+	assert.ErrorIs(t, err, otlptrace.ErrUnhealthy)
+	assert.Contains(t, err.Error(), "503 Service Unavailable")
+
+	pathExporter, err := otlptrace.New(ctx, otlptracehttp.NewClient(
+		otlptracehttp.WithEndpoint(endpoint),
+		otlptracehttp.WithInsecure(),
+		otlptracehttp.WithHealthCheckPath("ready"),
+	))
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, pathExporter.Shutdown(ctx))
+	}()
+	assert.ErrorIs(t, pathExporter.Healthy(ctx), otlptrace.ErrUnhealthy)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"/healthz", "/healthz", "/ready"}, paths)
+	assert.Equal(t, []string{"Bearer token", "Bearer token", ""}, auths)
+}
+
+func TestBlockingStart(t *testing.T) {
+	ln, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	endpoint := ln.Addr().String()
+	require.NoError(t, ln.Close())
+
+	driver := otlptracehttp.NewClient(
+		otlptracehttp.WithEndpoint(endpoint),
+		otlptracehttp.WithInsecure(),
+		otlptracehttp.WithBlockingStart(),
+	)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err = driver.Start(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Contains(t, err.Error(), endpoint)
+
+	mc := runMockCollector(t, mockCollectorConfig{})
+	defer mc.MustStop(t)
+	driver = otlptracehttp.NewClient(
+		otlptracehttp.WithEndpoint(mc.Endpoint()),
+		otlptracehttp.WithInsecure(),
+		otlptracehttp.WithBlockingStart(),
+	)
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	require.NoError(t, driver.Start(ctx))
+	assert.NoError(t, driver.Stop(ctx))
+}
+
+func TestResolveOnStart(t *testing.T) {
+	ctx := context.Background()
+	driver := otlptracehttp.NewClient(
+		otlptracehttp.WithEndpoint("collector.invalid:4318"),
+		otlptracehttp.WithInsecure(),
+		otlptracehttp.WithResolveOnStart(),
+	)
+	err := driver.Start(ctx)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "collector.invalid:4318")
+
+	mc := runMockCollector(t, mockCollectorConfig{})
+	defer mc.MustStop(t)
+	driver = otlptracehttp.NewClient(
+		otlptracehttp.WithEndpoint(mc.Endpoint()),
+		otlptracehttp.WithInsecure(),
+		otlptracehttp.WithResolveOnStart(),
+	)
+	require.NoError(t, driver.Start(ctx))
+	assert.NoError(t, driver.Stop(ctx))
+}
+
+func TestPersistentQueue(t *testing.T) {
+	dir, err := ioutil.TempDir("", "otlptracehttp")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// The spans exported while the collector is unreachable are persisted.
+	ln, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	endpoint := ln.Addr().String()
+	require.NoError(t, ln.Close())
+	ctx := context.Background()
+	exporter, err := otlptrace.New(ctx, otlptracehttp.NewClient(
+		otlptracehttp.WithEndpoint(endpoint),
+		otlptracehttp.WithInsecure(),
+		otlptracehttp.WithRetry(otlptracehttp.RetryConfig{Enabled: false}),
+		otlptracehttp.WithPersistentQueue(dir, 1<<20),
+	))
+	require.NoError(t, err)
+	assert.NoError(t, exporter.ExportSpans(ctx, otlptracetest.SingleReadOnlySpan()))
+	require.NoError(t, exporter.Shutdown(ctx))
+
+	// They are sent by a new client once the collector is reachable.
+	mc := runMockCollector(t, mockCollectorConfig{})
+	defer mc.MustStop(t)
+	exporter, err = otlptrace.New(ctx, otlptracehttp.NewClient(
+		otlptracehttp.WithEndpoint(mc.Endpoint()),
+		otlptracehttp.WithInsecure(),
+		otlptracehttp.WithPersistentQueue(dir, 1<<20),
+	))
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, exporter.Shutdown(ctx))
+	}()
+	assert.Eventually(t, func() bool { return len(mc.GetSpans()) == 1 }, 5*time.Second, time.Millisecond)
+}
+
+func TestMemoryQueue(t *testing.T) {
+	mc := runMockCollector(t, mockCollectorConfig{
+		InjectHTTPStatus: []int{http.StatusServiceUnavailable, http.StatusBadRequest},
+	})
+	defer mc.MustStop(t)
+	ctx := context.Background()
+	exporter, err := otlptrace.New(ctx, otlptracehttp.NewClient(
+		otlptracehttp.WithEndpoint(mc.Endpoint()),
+		otlptracehttp.WithInsecure(),
+		otlptracehttp.WithRetry(otlptracehttp.RetryConfig{Enabled: false}),
+		otlptracehttp.WithMemoryQueue(10),
+	))
+	require.NoError(t, err)
+
+	// The retryable failure is queued, the other one is returned.
+	assert.NoError(t, exporter.ExportSpans(ctx, otlptracetest.SingleReadOnlySpan()))
+	assert.Error(t, exporter.ExportSpans(ctx, otlptracetest.SingleReadOnlySpan()))
+	assert.Empty(t, mc.GetSpans())
+
+	// Shutting down sends the queued spans.
+	require.NoError(t, exporter.Shutdown(ctx))
+	assert.Len(t, mc.GetSpans(), 1)
+}
+
+func TestMemoryQueueCollectorDown(t *testing.T) {
+	statuses := make([]int, 10)
+	for i := range statuses {
+		statuses[i] = http.StatusServiceUnavailable
+	}
+	mc := runMockCollector(t, mockCollectorConfig{InjectHTTPStatus: statuses})
+	defer mc.MustStop(t)
+	ctx := context.Background()
+	exporter, err := otlptrace.New(ctx, otlptracehttp.NewClient(
+		otlptracehttp.WithEndpoint(mc.Endpoint()),
+		otlptracehttp.WithInsecure(),
+		otlptracehttp.WithRetry(otlptracehttp.RetryConfig{Enabled: false}),
+		otlptracehttp.WithMemoryQueue(10),
+	))
+	require.NoError(t, err)
+
+	require.NoError(t, exporter.ExportSpans(ctx, otlptracetest.SingleReadOnlySpan()))
+	// The failure of the empty export of ForceFlush is not queued.
+	assert.Error(t, exporter.ForceFlush(ctx))
+
+	// The queued spans are dropped once sending them fails, even without
+	// a deadline.
+	err = exporter.Shutdown(ctx)
+	assert.EqualError(t, err, "dropped 1 queued export requests: retry-able request failure")
+	assert.Empty(t, mc.GetSpans())
+}
+
+func TestHeadersOrder(t *testing.T) {
+	var mu sync.Mutex
+	var got []http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		got = append(got, r.Header.Clone())
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	// The keys differing only by case are set in sorted order, so the last
+	// one is used.
+	ctx := context.Background()
+	exporter, err := otlptrace.New(ctx, otlptracehttp.NewClient(
+		otlptracehttp.WithEndpoint(strings.TrimPrefix(srv.URL, "http://")),
+		otlptracehttp.WithInsecure(),
+		otlptracehttp.WithHeaders(map[string]string{"X-KEY": "a", "X-Key": "b", "x-key": "c"}),
+		otlptracehttp.WithHeadersFunc(func(context.Context) (map[string]string, error) {
+			return map[string]string{"Func-Key": "a", "func-key": "b", "FUNC-KEY": "c"}, nil
+		}),
+	))
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, exporter.Shutdown(ctx))
+	}()
+
+	const n = 20
+	for i := 0; i < n; i++ {
+		require.NoError(t, exporter.ExportSpans(ctx, otlptracetest.SingleReadOnlySpan()))
+	}
+	require.Len(t, got, n)
+	for _, h := range got {
+		assert.Equal(t, []string{"c"}, h.Values("X-Key"))
+		assert.Equal(t, []string{"b"}, h.Values("Func-Key"))
+	}
+}
+
+func TestHeadersFuncError(t *testing.T) {
+	mc := runMockCollector(t, mockCollectorConfig{})
+	defer mc.MustStop(t)
+	driver := otlptracehttp.NewClient(
+		otlptracehttp.WithEndpoint(mc.Endpoint()),
+		otlptracehttp.WithInsecure(),
+		otlptracehttp.WithHeadersFunc(func(context.Context) (map[string]string, error) {
+			return nil, assert.AnError
+		}),
+	)
+	ctx := context.Background()
+	exporter, err := otlptrace.New(ctx, driver)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, exporter.Shutdown(ctx))
+	}()
+	err = exporter.ExportSpans(ctx, otlptracetest.SingleReadOnlySpan())
+	assert.ErrorIs(t, err, assert.AnError)
+	assert.Empty(t, mc.GetSpans())
 }
@@ -18,8 +18,18 @@ import (
 	"context"
 
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/clientfactory"
 )
 
+func init() {
+	clientfactory.Register(otlptrace.ProtocolHTTPProtobuf, func() interface{} {
+		return NewClient()
+	})
+	clientfactory.Register(otlptrace.ProtocolHTTPJSON, func() interface{} {
+		return NewClient(WithMarshal(MarshalJSON))
+	})
+}
+
 // New constructs a new Exporter and starts it.
 func New(ctx context.Context, opts ...Option) (*otlptrace.Exporter, error) {
 	return otlptrace.New(ctx, NewClient(opts...))
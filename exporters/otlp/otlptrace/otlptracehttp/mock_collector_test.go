@@ -28,12 +28,15 @@ import (
 	"testing"
 	"time"
 
+	"github.com/klauspost/compress/zstd"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/proto"
 
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/otlpconfig"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/otlptracetest"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/partialsuccess"
 	collectortracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
 	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
 )
@@ -49,6 +52,8 @@ type mockCollector struct {
 	injectResponseHeader []map[string]string
 	injectContentType    string
 	injectDelay          time.Duration
+	rejectedSpans        int64
+	partialSuccessMsg    string
 
 	clientTLSConfig *tls.Config
 	expectedHeaders map[string]string
@@ -92,14 +97,20 @@ func (c *mockCollector) serveTraces(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	response := collectortracepb.ExportTraceServiceResponse{}
-	rawResponse, err := proto.Marshal(&response)
+	partialsuccess.Set(&response, c.rejectedSpans, c.partialSuccessMsg)
+	contentType := c.injectContentType
+	if contentType == "" {
+		// Reply in the format of the request.
+		contentType = r.Header.Get("content-type")
+	}
+	rawResponse, err := marshalTraceResponse(&response, contentType)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 	h := c.getInjectResponseHeader()
 	if injectedStatus := c.getInjectHTTPStatus(); injectedStatus != 0 {
-		writeReply(w, rawResponse, injectedStatus, c.injectContentType, h)
+		writeReply(w, rawResponse, injectedStatus, contentType, h)
 		return
 	}
 	rawRequest, err := readRequest(r)
@@ -113,7 +124,7 @@ func (c *mockCollector) serveTraces(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
-	writeReply(w, rawResponse, 0, c.injectContentType, h)
+	writeReply(w, rawResponse, 0, contentType, h)
 	c.spanLock.Lock()
 	defer c.spanLock.Unlock()
 	c.spansStorage.AddSpans(request)
@@ -121,13 +132,25 @@ func (c *mockCollector) serveTraces(w http.ResponseWriter, r *http.Request) {
 
 func unmarshalTraceRequest(rawRequest []byte, contentType string) (*collectortracepb.ExportTraceServiceRequest, error) {
 	request := &collectortracepb.ExportTraceServiceRequest{}
-	if contentType != "application/x-protobuf" {
-		return request, fmt.Errorf("invalid content-type: %s, only application/x-protobuf is supported", contentType)
+	var err error
+	switch contentType {
+	case "application/x-protobuf":
+		err = proto.Unmarshal(rawRequest, request)
+	case "application/json":
+		err = protojson.Unmarshal(rawRequest, request)
+	default:
+		err = fmt.Errorf("invalid content-type: %s, only application/x-protobuf and application/json are supported", contentType)
 	}
-	err := proto.Unmarshal(rawRequest, request)
 	return request, err
 }
 
+func marshalTraceResponse(response *collectortracepb.ExportTraceServiceResponse, contentType string) ([]byte, error) {
+	if contentType == "application/json" {
+		return partialsuccess.MarshalJSON(response)
+	}
+	return proto.Marshal(response)
+}
+
 func (c *mockCollector) checkHeaders(r *http.Request) bool {
 	for k, v := range c.expectedHeaders {
 		got := r.Header.Get(k)
@@ -162,12 +185,24 @@ func (c *mockCollector) getInjectResponseHeader() (h map[string]string) {
 }
 
 func readRequest(r *http.Request) ([]byte, error) {
-	if r.Header.Get("Content-Encoding") == "gzip" {
+	switch r.Header.Get("Content-Encoding") {
+	case "gzip":
 		return readGzipBody(r.Body)
+	case "zstd":
+		return readZstdBody(r.Body)
 	}
 	return ioutil.ReadAll(r.Body)
 }
 
+func readZstdBody(body io.Reader) ([]byte, error) {
+	decoder, err := zstd.NewReader(body)
+	if err != nil {
+		return nil, err
+	}
+	defer decoder.Close()
+	return ioutil.ReadAll(decoder)
+}
+
 func readGzipBody(body io.Reader) ([]byte, error) {
 	rawRequest := bytes.Buffer{}
 	gunzipper, err := gzip.NewReader(body)
@@ -206,8 +241,13 @@ type mockCollectorConfig struct {
 	InjectContentType    string
 	InjectResponseHeader []map[string]string
 	InjectDelay          time.Duration
+	RejectedSpans        int64
+	PartialSuccessMsg    string
 	WithTLS              bool
-	ExpectedHeaders      map[string]string
+	// RequireClientCert makes the collector require a client certificate,
+	// included in the ClientTLSConfig, if WithTLS is set.
+	RequireClientCert bool
+	ExpectedHeaders   map[string]string
 }
 
 func (c *mockCollectorConfig) fillInDefaults() {
@@ -229,6 +269,8 @@ func runMockCollector(t *testing.T, cfg mockCollectorConfig) *mockCollector {
 		injectResponseHeader: cfg.InjectResponseHeader,
 		injectContentType:    cfg.InjectContentType,
 		injectDelay:          cfg.InjectDelay,
+		rejectedSpans:        cfg.RejectedSpans,
+		partialSuccessMsg:    cfg.PartialSuccessMsg,
 		expectedHeaders:      cfg.ExpectedHeaders,
 	}
 	mux := http.NewServeMux()
@@ -248,6 +290,10 @@ func runMockCollector(t *testing.T, cfg mockCollectorConfig) *mockCollector {
 		m.clientTLSConfig = &tls.Config{
 			InsecureSkipVerify: true,
 		}
+		if cfg.RequireClientCert {
+			server.TLSConfig.ClientAuth = tls.RequireAnyClientCert
+			m.clientTLSConfig.Certificates = []tls.Certificate{tlsCertificate}
+		}
 	}
 	go func() {
 		if cfg.WithTLS {
@@ -15,11 +15,21 @@
 package otlptracehttp // import "go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
 
 import (
+	"context"
 	"crypto/tls"
+	"net/http"
+	"net/url"
 	"time"
 
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/batchlimit"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/circuitbreaker"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/otlpconfig"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/retry"
+	"go.opentelemetry.io/otel/metric"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
 )
 
 // Compression describes the compression used for payloads sent to the
@@ -33,6 +43,19 @@ const (
 	// GzipCompression tells the driver to send payloads after
 	// compressing them with gzip.
 	GzipCompression = Compression(otlpconfig.GzipCompression)
+	// ZstdCompression tells the driver to send payloads after
+	// compressing them with zstd.
+	ZstdCompression = Compression(otlpconfig.ZstdCompression)
+)
+
+// Marshaler describes the kind of message format sent to the collector.
+type Marshaler otlpconfig.Marshaler
+
+const (
+	// MarshalProto tells the driver to send using the protobuf binary format.
+	MarshalProto = Marshaler(otlpconfig.MarshalProto)
+	// MarshalJSON tells the driver to send using the JSON format.
+	MarshalJSON = Marshaler(otlpconfig.MarshalJSON)
 )
 
 // Option applies an option to the HTTP client.
@@ -55,54 +78,652 @@ func (w wrappedOption) applyHTTPOption(cfg *otlpconfig.Config) {
 // WithEndpoint allows one to set the address of the collector
 // endpoint that the driver will use to send spans. If
 // unset, it will instead try to use
-// the default endpoint (localhost:4317). Note that the endpoint
-// must not contain any URL path.
+// the default endpoint (localhost:4317). If the endpoint contains a
+// URL path, e.g. "collector:4318/otlp/v1/traces", it is used as the
+// path of the requests instead of the default /v1/traces. If the
+// endpoint has an http:// or https:// scheme, it selects the scheme
+// used to connect unless WithInsecure is used. A conflict between
+// the scheme and WithInsecure is reported as an invalid option, see
+// NewClient. User info in the endpoint, e.g. "user:password@collector:4318",
+// is removed and sent as basic authorization credentials. An endpoint whose
+// host has no port, e.g. "collector", gets the default OTLP/HTTP port 4318.
+// A port that is not a number between 1 and 65535 is reported as an invalid
+// option, see NewClient.
 func WithEndpoint(endpoint string) Option {
 	return wrappedOption{otlpconfig.WithEndpoint(endpoint)}
 }
 
+// WithEndpointURL sets the address of the collector endpoint that the driver
+// will use to send spans to the host and port of u, and the scheme used to
+// connect to its http or https scheme, unless WithInsecure is used. The URL
+// path of u is used as the path of the requests, the default /v1/traces being
+// used if it has none. The user info of u, if any, is sent as basic
+// authorization credentials. Its query and fragment are ignored. A nil URL, a
+// URL with another scheme or without a host is reported as an invalid option,
+// see NewClient, and the option has no effect.
+func WithEndpointURL(u *url.URL) Option {
+	return wrappedOption{otlpconfig.WithEndpointURL(u)}
+}
+
 // WithCompression tells the driver to compress the sent data.
 func WithCompression(compression Compression) Option {
 	return wrappedOption{otlpconfig.WithCompression(otlpconfig.Compression(compression))}
 }
 
+// WithMarshal tells the driver which format to send the data in. If unset,
+// MarshalProto is used and export requests are sent as binary protobuf with
+// the "application/x-protobuf" content type. With MarshalJSON they are sent
+// as OTLP/JSON, the JSON mapping of protobuf using integer enum values, with
+// the "application/json" content type, which is easier to inspect through
+// a proxy. Responses are decoded according to their content type.
+func WithMarshal(m Marshaler) Option {
+	return wrappedOption{otlpconfig.WithMarshal(otlpconfig.Marshaler(m))}
+}
+
 // WithURLPath allows one to override the default URL path used
 // for sending traces. If unset, default ("/v1/traces") will be used.
+// The path is used as the request path regardless of the endpoint. A path
+// not beginning with "/" is invalid: it is reported as an invalid option, see
+// NewClient, and made absolute. A path with a query or a fragment is invalid
+// too: it is reported and the default is used instead.
 func WithURLPath(urlPath string) Option {
 	return wrappedOption{otlpconfig.WithURLPath(urlPath)}
 }
 
+// WithHeadersFunc sets a function called before each export to compute
+// headers sent with the HTTP request, for instance to provide a frequently
+// rotated authentication token. The returned headers are merged with the
+// ones set by WithHeaders, taking precedence over them. If the function
+// returns an error the export fails with that error.
+func WithHeadersFunc(fn func(context.Context) (map[string]string, error)) Option {
+	return wrappedOption{otlpconfig.WithHeadersFunc(fn)}
+}
+
 // WithTLSClientConfig can be used to set up a custom TLS
 // configuration for the client used to send payloads to the
-// collector. Use it if you want to use a custom certificate.
+// collector. Use it if you want to use a custom certificate. The client
+// certificates it sets, authenticating the connection for a collector
+// requiring mutual TLS, combine with the Authorization header set with
+// WithBearerToken, WithBasicAuth or WithHeaders, authorizing each request.
 func WithTLSClientConfig(tlsCfg *tls.Config) Option {
 	return wrappedOption{otlpconfig.WithTLSClientConfig(tlsCfg)}
 }
 
+// WithProxy sets the function returning the proxy used for each export
+// request, as the Proxy field of an http.Transport does. It takes precedence
+// over the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables used by
+// default. The TLS configuration set with WithTLSClientConfig still applies to
+// the connections to the collector.
+func WithProxy(pf func(*http.Request) (*url.URL, error)) Option {
+	return wrappedOption{otlpconfig.NewHTTPOption(func(cfg *otlpconfig.Config) {
+		cfg.Traces.Proxy = pf
+	})}
+}
+
+// WithH2C makes the client send the export requests with HTTP/2 over
+// cleartext connections, known as h2c with prior knowledge, to a collector
+// accepting them, for instance to multiplex the requests over fewer
+// connections. It requires an insecure connection, set with WithInsecure or
+// an endpoint with the http scheme: otherwise it is reported as an invalid
+// option, see NewClient, and ignored. The tradeoffs are that the requests
+// are sent unencrypted, that the client does not fall back to HTTP/1.1 if
+// the collector does not support h2c, and that the proxy, the TLS handshake
+// timeout and the response header timeout are not used. It has no effect on
+// the HTTP client set with WithHTTPClient.
+func WithH2C() Option {
+	return wrappedOption{otlpconfig.NewHTTPOption(func(cfg *otlpconfig.Config) {
+		cfg.Traces.H2C = true
+	})}
+}
+
+// WithHTTPClient sets the http.Client used to send payloads to the
+// collector, for instance to tune its connection pool or to instrument its
+// transport. The client is used as is: the options configuring the client
+// built by default, WithTLSClientConfig, WithTLSClientCertificate, WithProxy,
+// WithTimeout, WithTLSHandshakeTimeout and WithResponseHeaderTimeout, are
+// ignored. The client is not modified and can be shared.
+func WithHTTPClient(client *http.Client) Option {
+	return wrappedOption{otlpconfig.NewHTTPOption(func(cfg *otlpconfig.Config) {
+		cfg.Traces.HTTPClient = client
+	})}
+}
+
 // WithInsecure tells the driver to connect to the collector using the
-// HTTP scheme, instead of HTTPS.
+// HTTP scheme, instead of HTTPS. It takes precedence over the scheme
+// of the endpoint.
 func WithInsecure() Option {
 	return wrappedOption{otlpconfig.WithInsecure()}
 }
 
 // WithHeaders allows one to tell the driver to send additional HTTP
 // headers with the payloads. Specifying headers like Content-Length,
-// Content-Encoding and Content-Type may result in a broken driver.
+// Content-Encoding and Content-Type may result in a broken driver. The headers
+// are set in the order of their keys, so among keys differing only by case
+// the last one in that order is used.
 func WithHeaders(headers map[string]string) Option {
 	return wrappedOption{otlpconfig.WithHeaders(headers)}
 }
 
+// WithIdempotencyKeys attaches a random idempotency key, a UUID, to each
+// export request in the HTTP header named header, so that the collectors
+// supporting it can drop the duplicate batches sent when retrying after a
+// timeout. The key of a request is the same for all its retries. A batch
+// exported again later, after being queued, gets a new key. An empty header is
+// invalid: it is reported as an invalid option, see NewClient, and ignored.
+func WithIdempotencyKeys(header string) Option {
+	return wrappedOption{otlpconfig.WithIdempotencyKeys(header)}
+}
+
+// WithUserAgent sets the User-Agent header identifying the exporter to the
+// collector, sent with each HTTP request. If unset, "OTel-OTLP-Exporter-Go/"
+// followed by the version of the exporter is used. An empty user agent sends
+// the default of the net/http package instead.
+func WithUserAgent(userAgent string) Option {
+	return wrappedOption{otlpconfig.WithUserAgent(userAgent)}
+}
+
+// WithBearerToken sets the token sent in the Authorization header, using
+// the bearer scheme, with each HTTP request. It takes precedence over an
+// Authorization header set with WithHeaders.
+func WithBearerToken(token string) Option {
+	return wrappedOption{otlpconfig.WithBearerToken(token)}
+}
+
+// WithBasicAuth sets the user and password sent in the Authorization header,
+// using the basic scheme, with each HTTP request. It takes precedence over an
+// Authorization header set with WithHeaders.
+func WithBasicAuth(user, password string) Option {
+	return wrappedOption{otlpconfig.WithBasicAuth(user, password)}
+}
+
 // WithTimeout tells the driver the max waiting time for the backend to process
-// each spans batch.  If unset, the default will be 10 seconds.
+// each spans batch.  If unset, the default will be 10 seconds. A shorter
+// deadline set on the context passed to the export is honored. The timeout
+// only applies to the trace exports; like OTEL_EXPORTER_OTLP_TRACES_TIMEOUT,
+// over which it takes precedence, and unlike OTEL_EXPORTER_OTLP_TIMEOUT, it
+// does not affect the exporters of other signals.
 func WithTimeout(duration time.Duration) Option {
 	return wrappedOption{otlpconfig.WithTimeout(duration)}
 }
 
+// WithDialTimeout sets the maximum time the client waits for each connection
+// to the collector to be established, so a slow DNS resolution or TCP
+// handshake does not consume the export timeout. If unset, the timeout set
+// with WithTimeout is used. It has no effect on the HTTP client set with
+// WithHTTPClient. A negative timeout is invalid: it is reported as an
+// invalid option, see NewClient, and ignored.
+func WithDialTimeout(d time.Duration) Option {
+	return wrappedOption{otlpconfig.WithDialTimeout(d)}
+}
+
+// WithTLSHandshakeTimeout sets the maximum time the client waits for each TLS
+// handshake with the collector to complete, so a handshake stalled by a TLS or
+// certificate problem is told apart from a slow collector. If unset, the
+// timeout set with WithTimeout is used. It has no effect on the HTTP client
+// set with WithHTTPClient. A negative timeout is invalid: it is reported as an
+// invalid option, see NewClient, and ignored.
+func WithTLSHandshakeTimeout(d time.Duration) Option {
+	return wrappedOption{otlpconfig.WithTLSHandshakeTimeout(d)}
+}
+
+// WithResponseHeaderTimeout sets the maximum time the client waits for the
+// headers of the response of the collector once an export request is written,
+// so a slow collector is told apart from a slow connection. If unset, the
+// timeout set with WithTimeout is used. It has no effect on the HTTP client
+// set with WithHTTPClient. A negative timeout is invalid: it is reported as
+// an invalid option, see NewClient, and ignored.
+func WithResponseHeaderTimeout(d time.Duration) Option {
+	return wrappedOption{otlpconfig.WithResponseHeaderTimeout(d)}
+}
+
 // WithRetry configures the retry policy for transient errors that may occurs
 // when exporting traces. An exponential back-off algorithm is used to ensure
 // endpoints are not overwhelmed with retries. If unset, the default retry
 // policy will retry after 5 seconds and increase exponentially after each
-// error for a total of 1 minute.
+// error for a total of 1 minute. The deadline of the context passed to the
+// export also bounds the retries: the export fails as soon as the next retry
+// could not be attempted before it.
 func WithRetry(rc RetryConfig) Option {
 	return wrappedOption{otlpconfig.WithRetry(retry.Config(rc))}
 }
+
+// WithBackoffStrategy sets the function deciding how long to wait before each
+// retry of a failed export, and when to give up retrying it, instead of the
+// exponential back-off configured by WithRetry. The function is called after
+// each retryable failure with the number of the retry about to be attempted,
+// starting at one, and the error of the last attempt. The export fails with
+// that error once the function returns giveUp. Retries must still be enabled
+// with WithRetry, whose intervals and maximum elapsed time are then unused.
+// A delay requested by the collector is honored when it is the greater, and
+// the deadline of the context passed to the export still bounds the retries.
+// The function is called concurrently by the exports. If unset or nil, the
+// exponential back-off is used.
+func WithBackoffStrategy(strategy func(attempt int, lastErr error) (delay time.Duration, giveUp bool)) Option {
+	return wrappedOption{otlpconfig.WithBackoffStrategy(strategy)}
+}
+
+// ErrCircuitOpen is returned by exports rejected without being attempted
+// because the circuit breaker set with WithCircuitBreaker is open.
+var ErrCircuitOpen = circuitbreaker.ErrOpen
+
+// WithRetryableErrorFunc sets the function deciding whether a failed export
+// is retried, as configured by WithRetry, instead of the default
+// classification. A delay requested by the collector is still honored.
+// The function receives the error of the HTTP request: an
+// *otlptrace.ExportError whose message holds the status of a rejected
+// request, or a *url.Error if the request could not be sent, for instance to
+// retry when the collector is briefly unreachable.
+// Errors caused by the cancellation or the deadline of the export context are
+// never retried, whatever the function returns.
+func WithRetryableErrorFunc(fn func(error) bool) Option {
+	return wrappedOption{otlpconfig.WithRetryableErrorFunc(fn)}
+}
+
+// WithCircuitBreaker stops attempting exports after failureThreshold
+// consecutive exports failed with a retryable error, such as an unreachable or
+// unavailable collector, once retries are exhausted. The exports rejected by
+// the collector and the ones whose context is done are not counted. Exports
+// then fail fast with ErrCircuitOpen for the cooldown duration, after which a
+// single export probes the collector: the exports resume if it succeeds,
+// otherwise they keep failing fast for another cooldown. If unset, all
+// exports are attempted. A threshold lower than one or a non-positive cooldown
+// is invalid: it is reported as an invalid option, see NewClient, and the
+// option has no effect.
+func WithCircuitBreaker(failureThreshold int, cooldown time.Duration) Option {
+	return wrappedOption{otlpconfig.WithCircuitBreaker(failureThreshold, cooldown)}
+}
+
+// OversizeBatchPolicy is the handling of the batches of spans exceeding the
+// limits set with WithMaxExportBatchBytes or WithMaxExportBatchSpans.
+type OversizeBatchPolicy batchlimit.Policy
+
+const (
+	// SplitOversizeBatch exports the batch in several requests within the
+	// limits, each sent once the previous one succeeded. Spans larger than
+	// the maximum size on their own are dropped and reported to the error
+	// handler set with WithErrorHandler.
+	SplitOversizeBatch = OversizeBatchPolicy(batchlimit.Split)
+	// RejectOversizeBatch fails the export of the batch with
+	// ErrOversizeBatch, without sending any of its spans.
+	RejectOversizeBatch = OversizeBatchPolicy(batchlimit.Reject)
+	// DropOversizeBatch exports the first spans of the batch within the
+	// limits in a single request. The other spans are dropped and reported
+	// to the error handler set with WithErrorHandler.
+	DropOversizeBatch = OversizeBatchPolicy(batchlimit.Drop)
+)
+
+// ErrOversizeBatch is returned by the exports of batches of spans exceeding
+// the export limits with the RejectOversizeBatch policy.
+var ErrOversizeBatch = batchlimit.ErrOversize
+
+// WithMaxExportBatchBytes sets the maximum size, in bytes, of the export
+// requests sent to the collector, for instance to stay below the maximum
+// message size the collector receives. The size is the one of the requests
+// before compression. The batches of spans exceeding it are handled according
+// to the policy set with WithOversizeBatchPolicy, split into several requests
+// by default. If unset or zero, the size of the requests is not limited. A
+// negative size is invalid: it is reported as an invalid option, see
+// NewClient, and ignored.
+func WithMaxExportBatchBytes(n int) Option {
+	return wrappedOption{otlpconfig.WithMaxExportBatchBytes(n)}
+}
+
+// WithMaxExportBatchSpans sets the maximum number of spans of the export
+// requests sent to the collector. The batches of spans exceeding it are
+// handled according to the policy set with WithOversizeBatchPolicy, split
+// into several requests by default. If unset or zero, the number of spans of
+// the requests is not limited. A negative number is invalid: it is reported
+// as an invalid option, see NewClient, and ignored.
+func WithMaxExportBatchSpans(n int) Option {
+	return wrappedOption{otlpconfig.WithMaxExportBatchSpans(n)}
+}
+
+// WithOversizeBatchPolicy sets the handling of the batches of spans exceeding
+// the limits set with WithMaxExportBatchBytes or WithMaxExportBatchSpans. If
+// unset, SplitOversizeBatch is used. An unknown policy is invalid: it is
+// reported as an invalid option, see NewClient, and ignored.
+func WithOversizeBatchPolicy(policy OversizeBatchPolicy) Option {
+	return wrappedOption{otlpconfig.WithOversizeBatchPolicy(batchlimit.Policy(policy))}
+}
+
+// WithExportInterceptor adds interceptor around each export request sent to
+// the collector, for instance to log, measure or trace exports. Interceptors
+// are called in the order they are added, the first one being the
+// outermost. The last one calls an invoker sending the request, retrying it
+// as configured by WithRetry. Each part of a batch split by the export batch
+// limits is sent in its own request.
+func WithExportInterceptor(interceptor otlptrace.ExportInterceptor) Option {
+	return wrappedOption{otlpconfig.WithExportInterceptor(interceptor)}
+}
+
+// WithBlockingStart makes starting the client wait for the collector to be
+// reachable, probing it by opening TCP connections to the endpoint until one
+// succeeds. Start returns an error if none succeeds before its context is
+// done, letting short-lived jobs fail fast when the collector is unreachable.
+// The wait is only bounded by the context passed to Start, which should carry
+// a deadline. If unset, Start returns immediately.
+func WithBlockingStart() Option {
+	return wrappedOption{otlpconfig.WithBlockingStart()}
+}
+
+// WithResolveOnStart makes starting the client resolve the host of the
+// endpoint, with the context passed to Start. Start returns an error wrapping
+// the resolution failure if it cannot be resolved, instead of letting every
+// export fail. IP addresses are not resolved.
+func WithResolveOnStart() Option {
+	return wrappedOption{otlpconfig.WithResolveOnStart()}
+}
+
+// WithPersistentQueue sets the client to write the export requests failing
+// with a retryable error, or rejected by the circuit breaker, to files in dir
+// instead of dropping them, once the retries configured by WithRetry are
+// exhausted. Such exports do not return an error. The persisted requests are
+// sent again in the background, oldest first, as soon as an export succeeds
+// and with an exponential backoff after each retryable failure. The ones
+// persisted before the process restarted are sent too. They are kept until
+// accepted or rejected by the collector, including when the exporter is shut
+// down.
+//
+// The files in dir total at most maxBytes, the oldest requests are dropped to
+// make room for new ones and the drops are reported to the error handler set
+// with WithErrorHandler. dir is locked until the exporter is shut down: a
+// client whose dir is used by another client, of this process or another one,
+// fails to open the queue and does not hold the failed exports. An empty dir
+// or a non-positive maxBytes is invalid: it is reported as an invalid option,
+// see NewClient, and the option has no effect. It replaces the queue set by
+// WithMemoryQueue.
+func WithPersistentQueue(dir string, maxBytes int64) Option {
+	return wrappedOption{otlpconfig.WithPersistentQueue(dir, maxBytes)}
+}
+
+// WithMemoryQueue sets the client to hold the export requests failing with a
+// retryable error, or rejected by the circuit breaker, in memory instead of
+// dropping them, once the retries configured by WithRetry are exhausted. Such
+// exports do not return an error, non-retryable errors are still returned. The
+// held requests are sent again in the background, oldest first, with an
+// exponential backoff after each retryable failure and as soon as an export
+// succeeds. Shutting down the exporter sends the held requests once, oldest
+// first: the ones left when a send fails with a retryable error, or when its
+// context is done, are dropped and reported by the error returned. The empty
+// export of ForceFlush is never held, its failure is returned.
+//
+// The held requests total at most maxSpans spans, the oldest requests are
+// dropped to make room for new ones and the drops are reported to the error
+// handler set with WithErrorHandler. A non-positive maxSpans is invalid: it is
+// reported as an invalid option, see NewClient, and the option has no effect.
+// It replaces the queue set by WithPersistentQueue.
+func WithMemoryQueue(maxSpans int) Option {
+	return wrappedOption{otlpconfig.WithMemoryQueue(maxSpans)}
+}
+
+// WithResourceAttributes adds attrs to the resource of each exported span,
+// for instance to identify the deployment exporting them. An attribute of
+// attrs whose key the resource already has replaces the existing attribute if
+// override is true, and is ignored otherwise. The spans passed to the client
+// are not modified, the resources are copied.
+func WithResourceAttributes(attrs []*commonpb.KeyValue, override bool) Option {
+	return wrappedOption{otlpconfig.WithResourceAttributes(attrs, override)}
+}
+
+// WithSpanTransform adds transform, called with each exported span right
+// before it is sent, for instance to redact sensitive attribute values
+// centrally. Unlike the span processors of the SDK, it operates on the OTLP
+// form of the spans at export time. It is passed a copy of the span, that it
+// may modify in place: the spans passed to the client are not modified. The
+// transforms are called in the order they are added, nil ones are ignored.
+func WithSpanTransform(transform func(*tracepb.Span)) Option {
+	return wrappedOption{otlpconfig.WithSpanTransform(transform)}
+}
+
+// WithStrictValidation drops the malformed spans before they are sent, so
+// that a collector strict about their fields does not reject the whole batch
+// containing them. A span is malformed if its trace or span ID does not have
+// the length defined by OTLP or is all zeros, if its parent span ID is
+// neither empty nor of the length of a span ID, or if its trace state is not
+// a valid W3C tracestate. The version of OTLP sent by the client has no span
+// flags to validate. The dropped spans are reported to the error handler set
+// with WithErrorHandler. The validation is done after the transforms added
+// with WithSpanTransform.
+func WithStrictValidation() Option {
+	return wrappedOption{otlpconfig.WithStrictValidation()}
+}
+
+// WithExportSampler sets sampler, called with each span right before it is
+// sent, to shed load as a last resort when the collector is overwhelmed. The
+// spans it returns false for are dropped, whatever the decision of the
+// sampler of the SDK, and the resources and instrumentation libraries left
+// without spans are omitted. An export whose spans are all dropped succeeds
+// without sending a request. The sampler is called after the transforms added
+// with WithSpanTransform and the validation enabled with
+// WithStrictValidation, concurrently by the exports. The dropped spans are
+// counted by the metrics set with WithSelfObservability and the summaries
+// enabled with WithStatsLogInterval. Nil sends all the spans.
+func WithExportSampler(sampler func(*tracepb.Span) bool) Option {
+	return wrappedOption{otlpconfig.WithExportSampler(sampler)}
+}
+
+// WithStableOrdering sorts the spans of each export in a stable order before
+// they are sent, whatever the order they are passed in, so that the requests
+// of the same spans are identical, for instance to compare them byte for byte
+// with golden files in tests. The resources are sorted by their protobuf
+// encoding, the instrumentation libraries of each resource by name and
+// version, and the spans of each library by start time, then by trace and
+// span IDs. The sorting is done after the sampler set with WithExportSampler
+// and before the batch limits split the requests. It has a cost: it is not
+// recommended in production.
+func WithStableOrdering() Option {
+	return wrappedOption{otlpconfig.WithStableOrdering()}
+}
+
+// WithDrainOnStop gives the in-flight exports at least timeout to complete
+// when the exporter is shut down, even if the context passed to Shutdown is
+// done before. By default, Shutdown waits for the in-flight exports until its
+// context is done and then cancels them. With a drain timeout, they are
+// cancelled once both the context is done and the timeout, counted from the
+// call to Shutdown, elapsed. Shutdown still returns the error of its context
+// if it is done. If unset, or zero, no drain timeout is used. A negative
+// timeout is invalid: it is reported as an invalid option, see NewClient, and
+// ignored.
+func WithDrainOnStop(timeout time.Duration) Option {
+	return wrappedOption{otlpconfig.WithDrainOnStop(timeout)}
+}
+
+// WithErrorHistory keeps the last n errors returned by the exports, with the
+// time they were returned, to be inspected with the RecentErrors method of the
+// exporter, for instance to debug failing exports while the span processor
+// discards their errors. The exports whose spans are held by the queue set
+// with WithPersistentQueue or WithMemoryQueue do not fail. If unset, or zero,
+// no error is kept. A negative n is invalid: it is reported as an invalid
+// option, see NewClient, and ignored.
+func WithErrorHistory(n int) Option {
+	return wrappedOption{otlpconfig.WithErrorHistory(n)}
+}
+
+// WithExportCoalescing makes the client merge the exports arriving within
+// maxDelay into a single upload, reducing the overhead of the small batches
+// exported at high rates. The spans of an export are held until maxDelay
+// elapsed since the first spans held were exported, or until the spans held
+// reach maxSpans, and each export returns the result of the upload of its
+// spans. The upload is bounded by the earliest deadline of the contexts of the
+// exports it merges: an export whose context is done before returns the
+// context error, its spans are still uploaded. The spans held are uploaded
+// when the exporter is flushed or shut down. If unset, or if maxDelay is zero,
+// the exports are not merged. A zero maxSpans does not limit the spans held.
+// A negative maxDelay or maxSpans is invalid: an error is sent to the error
+// handler and the option is ignored.
+func WithExportCoalescing(maxDelay time.Duration, maxSpans int) Option {
+	return wrappedOption{otlpconfig.WithExportCoalescing(maxDelay, maxSpans)}
+}
+
+// WithMaxConcurrentExports limits the number of exports uploaded
+// concurrently to n, so that the bursts of exports of the span processors do
+// not overwhelm the collector. Once n exports are in progress, the others
+// block until one of them completes, or return the error of their context if
+// it is done first. The exports merged with WithExportCoalescing are uploaded
+// as one, and the retries of the queue set with WithPersistentQueue or
+// WithMemoryQueue are not limited. If unset, or zero, the exports are not
+// limited. A negative n is invalid: an error is sent to the error handler and
+// it is ignored.
+func WithMaxConcurrentExports(n int) Option {
+	return wrappedOption{otlpconfig.WithMaxConcurrentExports(n)}
+}
+
+// WithStatsLogInterval makes the client send a summary of its exports to
+// the error handler every d, for visibility into the exporter without
+// setting up a meter provider with WithSelfObservability. The summary is an
+// otlptrace.StatsSummary reporting the spans sent and dropped and the export
+// errors since the previous one, and the connection status. The summaries
+// are sent from the start of the exporter until it is shut down. If unset,
+// or zero, no summary is sent. A negative d is invalid: an error is sent to
+// the error handler and it is ignored.
+func WithStatsLogInterval(d time.Duration) Option {
+	return wrappedOption{otlpconfig.WithStatsLogInterval(d)}
+}
+
+// WithHealthCheckPath sets the URL path of the endpoint the Healthy method of
+// the exporter sends its GET request to, made absolute if it is not. If
+// unset, "/healthz" is used. A path with a query or a fragment is invalid: an
+// error is sent to the error handler and it is ignored.
+func WithHealthCheckPath(urlPath string) Option {
+	return wrappedOption{otlpconfig.WithHealthCheckPath(urlPath)}
+}
+
+// WithErrorHandler sets the handler of the errors of the client that are not
+// returned by its methods, such as the invalid options, the dropped spans and
+// batches or the partial successes not handled otherwise, instead of the
+// global error handler, for instance to route the diagnostics of one
+// exporter separately from the others. The invalid values read from the
+// environment variables are reported to it too. If unset, or nil, the global
+// error handler is used.
+func WithErrorHandler(handler otel.ErrorHandler) Option {
+	return wrappedOption{otlpconfig.WithErrorHandler(handler)}
+}
+
+// WithPartialSuccessHandler sets the function called when the collector
+// accepts an export but rejects some of the spans it contains. The handler
+// receives the number of rejected spans and the error message returned by the
+// collector. If unset, partial successes are reported to the global error
+// handler. A partial success that rejects no spans and has no error message
+// is not reported.
+func WithPartialSuccessHandler(handler func(rejected int64, msg string)) Option {
+	return wrappedOption{otlpconfig.WithPartialSuccessHandler(handler)}
+}
+
+// WithPayloadSizeObserver sets the function called with the number of spans
+// and the serialized size of each export request, for instance to tune the
+// batch sizes and the compression. It is called once per request, before it
+// is sent, and not for its retries. The size is the one of the request body:
+// it is after compression, and PayloadSize.Compressed is true, if a
+// compression is configured with WithCompression.
+func WithPayloadSizeObserver(observer func(otlptrace.PayloadSize)) Option {
+	return wrappedOption{otlpconfig.WithPayloadSizeObserver(observer)}
+}
+
+// WithSelfObservability sets the MeterProvider used to record metrics about
+// the exports performed by the client: the number of spans exported, the
+// number of spans dropped because their export failed or by the sampler set
+// with WithExportSampler, the number of export requests sent, retries
+// included, and the duration of each export. The retries of failed requests,
+// the requests abandoned because the maximum retry time elapsed and the time
+// spent waiting before the retries are also recorded, by terminal outcome of
+// the request. The protocol attribute of the measurements is "http/json" if
+// the requests are sent with MarshalJSON, "http/protobuf" otherwise. No
+// metrics are recorded if unset.
+func WithSelfObservability(mp metric.MeterProvider) Option {
+	return wrappedOption{otlpconfig.WithSelfObservability(mp)}
+}
+
+// WithTLSClientCertificate sets the PEM encoded certificate and private key
+// presented to the collector for mutual TLS authentication. It can be combined
+// with WithTLSClientConfig, in which case it must be passed after it. If the
+// certificate cannot be parsed, it is reported as an invalid option, see
+// NewClient, and the option has no effect.
+func WithTLSClientCertificate(certPEM, keyPEM []byte) Option {
+	return wrappedOption{otlpconfig.WithTLSClientCertificate(certPEM, keyPEM)}
+}
+
+// WithTLSMinVersion sets the minimum TLS version, such as tls.VersionTLS13,
+// accepted when connecting to the collector, for instance to comply with a
+// security policy. If unset, TLS 1.2 is the minimum version of the TLS
+// configurations built by the TLS options, while the one passed with
+// WithTLSClientConfig is used as is.
+//
+// It can be combined with WithTLSClientConfig, in which case it must be passed
+// after it. A value that is not a TLS version is invalid: an error is sent to
+// the error handler and the option has no effect.
+func WithTLSMinVersion(version uint16) Option {
+	return wrappedOption{otlpconfig.WithTLSMinVersion(version)}
+}
+
+// WithTLSCipherSuites sets the IDs of the cipher suites, such as
+// tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256, offered when connecting to the
+// collector with TLS 1.2 or earlier. The cipher suites of TLS 1.3 are not
+// configurable. If unset, or empty, the default cipher suites of crypto/tls
+// are offered.
+//
+// It can be combined with WithTLSClientConfig, in which case it must be passed
+// after it. An unknown cipher suite is invalid: an error is sent to the error
+// handler and the option has no effect.
+func WithTLSCipherSuites(suites []uint16) Option {
+	return wrappedOption{otlpconfig.WithTLSCipherSuites(suites)}
+}
+
+// WithInsecureSkipVerify disables the verification of the certificate of the
+// collector, for instance to develop against a collector with a self-signed
+// certificate. Unlike WithInsecure, the connections still use TLS, but
+// anyone able to intercept them can impersonate the collector: it must not
+// be used in production. A warning is sent to the error handler each time
+// the client starts, as it is for a TLS configuration skipping the
+// verification passed with WithTLSClientConfig.
+//
+// It can be combined with WithTLSClientConfig, in which case it must be
+// passed after it.
+func WithInsecureSkipVerify() Option {
+	return wrappedOption{otlpconfig.WithInsecureSkipVerify()}
+}
+
+// WithTLSServerName sets the name the certificate of the collector is verified
+// against, and sent to it with SNI, instead of the host of the endpoint, for
+// instance when the endpoint is an IP address or a proxy while the certificate
+// is issued for the DNS name of the collector. If unset, or empty, the host of
+// the endpoint is used. This can also be set with the
+// OTEL_EXPORTER_OTLP_TLS_SERVER_NAME and
+// OTEL_EXPORTER_OTLP_TRACES_TLS_SERVER_NAME environment variables.
+//
+// It can be combined with WithTLSClientConfig, in which case it must be
+// passed after it.
+func WithTLSServerName(name string) Option {
+	return wrappedOption{otlpconfig.WithTLSServerName(name)}
+}
+
+// WithTLSRootCAs sets the CA certificates verifying the certificate of the
+// collector: the PEM encoded caPEMs, added to a copy of the system cert pool
+// if appendToSystem is true, for instance to reach both collectors signed by
+// an internal CA and public ones. Otherwise only caPEMs are trusted.
+//
+// Appending to the system pool makes the exporter trust every public CA of
+// the host in addition to the internal ones: any of them can then issue a
+// certificate the exporter accepts for the collector host. Prefer a fresh
+// pool when all the collectors are signed by internal CAs.
+//
+// It can be combined with WithTLSClientConfig, in which case it must be
+// passed after it. If the system pool cannot be loaded, a certificate cannot
+// be parsed or no certificate is passed without appendToSystem, it is
+// reported as an invalid option, see NewClient, and the option has no
+// effect.
+func WithTLSRootCAs(appendToSystem bool, caPEMs ...[]byte) Option {
+	return wrappedOption{otlpconfig.WithTLSRootCAs(appendToSystem, caPEMs...)}
+}
+
+// WithTLSConfigFromFiles sets the TLS configuration built from the PEM
+// encoded files at the given paths: the CA certificates verifying the
+// collector, caPath, and the client certificate and key presented to it for
+// mutual TLS authentication, certPath and keyPath. Empty paths are skipped:
+// the system CA certificates are used if caPath is empty, and no client
+// certificate is presented if certPath and keyPath are empty. The
+// configuration replaces any set before with WithTLSClientConfig. If a file
+// cannot be read or parsed, it is reported as an invalid option, see
+// NewClient, and the option has no effect.
+func WithTLSConfigFromFiles(caPath, certPath, keyPath string) Option {
+	return wrappedOption{otlpconfig.WithTLSConfigFromFiles(caPath, certPath, keyPath)}
+}
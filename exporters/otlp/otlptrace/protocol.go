@@ -0,0 +1,70 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otlptrace // import "go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/clientfactory"
+)
+
+// The protocols of the clients, as set with the OTEL_EXPORTER_OTLP_PROTOCOL
+// and OTEL_EXPORTER_OTLP_TRACES_PROTOCOL environment variables.
+const (
+	ProtocolGRPC         = "grpc"
+	ProtocolHTTPProtobuf = "http/protobuf"
+	ProtocolHTTPJSON     = "http/json"
+)
+
+// NewFromEnv constructs a new Exporter using the client of the protocol set
+// with the OTEL_EXPORTER_OTLP_TRACES_PROTOCOL environment variable, or else
+// OTEL_EXPORTER_OTLP_PROTOCOL, and starts it. The client is configured with
+// the other environment variables. ProtocolHTTPProtobuf is used if the
+// protocol is unset, or if it is unknown in which case a warning is sent to
+// the global error handler. The package of the client must be imported, for
+// instance with a blank import, for its protocol to be supported: the
+// otlptracegrpc package supports ProtocolGRPC, and the otlptracehttp package
+// ProtocolHTTPProtobuf and ProtocolHTTPJSON. An error is returned otherwise.
+func NewFromEnv(ctx context.Context) (*Exporter, error) {
+	protocol := protocolFromEnv()
+	newClient, ok := clientfactory.Lookup(protocol)
+	if !ok {
+		return nil, fmt.Errorf("no client registered for the %s protocol, its package must be imported", protocol)
+	}
+	return New(ctx, newClient().(Client))
+}
+
+// protocolFromEnv returns the protocol set in the environment. An empty value
+// is ignored, as if the variable was unset.
+func protocolFromEnv() string {
+	for _, key := range []string{"OTEL_EXPORTER_OTLP_TRACES_PROTOCOL", "OTEL_EXPORTER_OTLP_PROTOCOL"} {
+		v := strings.TrimSpace(os.Getenv(key))
+		if v == "" {
+			continue
+		}
+		switch p := strings.ToLower(v); p {
+		case ProtocolGRPC, ProtocolHTTPProtobuf, ProtocolHTTPJSON:
+			return p
+		default:
+			otel.Handle(fmt.Errorf("invalid %s value %q, using %s: must be one of %s, %s or %s", key, v, ProtocolHTTPProtobuf, ProtocolGRPC, ProtocolHTTPProtobuf, ProtocolHTTPJSON))
+			return ProtocolHTTPProtobuf
+		}
+	}
+	return ProtocolHTTPProtobuf
+}
@@ -0,0 +1,101 @@
+// Copyright The OpenTelemetry Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otlptrace_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/clientfactory"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/internal/otlptracetest"
+	ottest "go.opentelemetry.io/otel/internal/internaltest"
+)
+
+func TestNewFromEnv(t *testing.T) {
+	clients := make(map[string]*otlptracetest.NoopClient)
+	for _, protocol := range []string{otlptrace.ProtocolGRPC, otlptrace.ProtocolHTTPProtobuf, otlptrace.ProtocolHTTPJSON} {
+		client := otlptracetest.NewNoopClient()
+		clients[protocol] = client
+		clientfactory.Register(protocol, func() interface{} { return client })
+	}
+
+	tests := []struct {
+		name string
+		env  map[string]string
+		want string
+	}{
+		{
+			name: "unset",
+			want: otlptrace.ProtocolHTTPProtobuf,
+		},
+		{
+			name: "generic",
+			env:  map[string]string{"OTEL_EXPORTER_OTLP_PROTOCOL": "grpc"},
+			want: otlptrace.ProtocolGRPC,
+		},
+		{
+			name: "traces",
+			env: map[string]string{
+				"OTEL_EXPORTER_OTLP_PROTOCOL":        "grpc",
+				"OTEL_EXPORTER_OTLP_TRACES_PROTOCOL": " HTTP/JSON ",
+			},
+			want: otlptrace.ProtocolHTTPJSON,
+		},
+		{
+			name: "empty traces",
+			env: map[string]string{
+				"OTEL_EXPORTER_OTLP_PROTOCOL":        "grpc",
+				"OTEL_EXPORTER_OTLP_TRACES_PROTOCOL": " ",
+			},
+			want: otlptrace.ProtocolGRPC,
+		},
+		{
+			name: "unknown",
+			env:  map[string]string{"OTEL_EXPORTER_OTLP_TRACES_PROTOCOL": "http/thrift"},
+			want: otlptrace.ProtocolHTTPProtobuf,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			envStore, err := ottest.SetEnvVariables(tt.env)
+			require.NoError(t, err)
+			defer func() {
+				require.NoError(t, envStore.Restore())
+			}()
+
+			ctx := context.Background()
+			exp, err := otlptrace.NewFromEnv(ctx)
+			require.NoError(t, err)
+			require.NoError(t, exp.ExportSpans(ctx, otlptracetest.SingleReadOnlySpan()))
+			assert.NoError(t, exp.Shutdown(ctx))
+			for protocol, client := range clients {
+				if protocol == tt.want {
+					assert.Equal(t, 1, client.UploadedSpanCount(), protocol)
+				} else {
+					assert.Zero(t, client.UploadedSpanCount(), protocol)
+				}
+			}
+			for protocol := range clients {
+				client := otlptracetest.NewNoopClient()
+				clients[protocol] = client
+				clientfactory.Register(protocol, func() interface{} { return client })
+			}
+		})
+	}
+}
@@ -68,10 +68,12 @@ if ! git diff --quiet; then \
 	exit 1
 fi
 
-# Update version.go
-cp ./version.go ./version.go.bak
-sed "s/\(return \"\)[0-9]*\.[0-9]*\.[0-9]*\"/\1${OTEL_VERSION}\"/" ./version.go.bak >./version.go
-rm -f ./version.go.bak
+# Update version.go files
+for version_file in ./version.go ./exporters/otlp/otlptrace/version.go; do
+	cp "${version_file}" "${version_file}.bak"
+	sed "s/\(return \"\)[0-9]*\.[0-9]*\.[0-9]*\"/\1${OTEL_VERSION}\"/" "${version_file}.bak" >"${version_file}"
+	rm -f "${version_file}.bak"
+done
 
 # Update go.mod
 git checkout -b pre_release_${TAG} main